@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
+	"text/tabwriter"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -12,7 +17,14 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/matthieukhl/align-back/config"
+	"github.com/matthieukhl/align-back/db/migrations"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/fec"
 	"github.com/matthieukhl/align-back/internal/handler"
+	"github.com/matthieukhl/align-back/internal/mail"
+	apimiddleware "github.com/matthieukhl/align-back/internal/middleware"
+	"github.com/matthieukhl/align-back/internal/migrate"
+	"github.com/matthieukhl/align-back/internal/payment"
 	"github.com/matthieukhl/align-back/internal/repository"
 	"github.com/matthieukhl/align-back/internal/service"
 	"github.com/matthieukhl/align-back/pkg/logger"
@@ -32,6 +44,9 @@ func main() {
 	}
 
 	cmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./config/config.yaml)")
+	cmd.AddCommand(exportFECCommand())
+	cmd.AddCommand(createStaffCommand())
+	cmd.AddCommand(migrateCommand())
 	if err := cmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -39,30 +54,9 @@ func main() {
 }
 
 func run() {
-	// Initialize config
-	cfg, err := config.LoadConfig(cfgFile)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to load configuration")
-	}
-
-	// Initialize logger
-	logger.InitLogger(cfg.LogLevel)
-
-	// Database connection
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		cfg.DB.User, cfg.DB.Password, cfg.DB.Host, cfg.DB.Port, cfg.DB.Name)
-	db, err := sqlx.Connect("mysql", dsn)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to connect to database")
-	}
+	cfg, location, db := setup()
 	defer db.Close()
 
-	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
-	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
-	db.SetConnMaxLifetime(time.Duration(cfg.DB.ConnMaxLifetime) * time.Second)
-
-	log.Info().Msg("Connected to database")
-
 	// Initialize repositories
 	clientRepo := repository.NewClientRepository(db)
 	packageRepo := repository.NewPackageRepository(db)
@@ -70,22 +64,145 @@ func run() {
 	scheduleRepo := repository.NewScheduleRepository(db)
 	appointmentRepo := repository.NewAppointmentRepository(db)
 	billingRepo := repository.NewBillingRepository(db)
+	cancellationRepo := repository.NewCancellationRepository(db)
+	waitlistRepo := repository.NewWaitlistRepository(db)
+	seriesRepo := repository.NewScheduleSeriesRepository(db)
+	creditLedgerRepo := repository.NewCreditLedgerRepository(db)
+	creditLotRepo := repository.NewCreditLotRepository(db)
+	invoiceRepo := repository.NewInvoiceRepository(db)
+	paymentRepo := repository.NewPaymentRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	subscriptionRepo := repository.NewSubscriptionRepository(db)
+	promotionRepo := repository.NewPromotionRepository(db)
+	voucherRepo := repository.NewVoucherRepository(db)
+	checkoutRepo := repository.NewCheckoutRepository(db)
+	staffRepo := repository.NewStaffRepository(db)
+	clientLoginRepo := repository.NewClientLoginRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	transactor := repository.NewTransactor(db)
+
+	// Initialize payment provider
+	var paymentProvider domain.PaymentProvider
+	var fakePayments *payment.Fake
+	switch cfg.Payments.Provider {
+	case "fake":
+		fakePayments = payment.NewFake(cfg.Payments.PublicURL, cfg.Payments.WebhookSecret)
+		paymentProvider = fakePayments
+		log.Warn().Msg("taking payments with the fake provider, nobody is charged")
+		if cfg.Payments.DevRoutes {
+			log.Warn().Msg("serving the fake provider checkout pages under /api/dev/payments")
+		}
+	default:
+		log.Fatal().Str("provider", cfg.Payments.Provider).Msg("unknown payment provider")
+	}
+
+	if cfg.Payments.WebhookSecret == "" {
+		log.Warn().Msg("no payment webhook secret, every payment event will be rejected")
+	}
+
+	// Check the database schema
+	statuses, err := newMigrator(db).Status(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to check database migrations")
+	}
+	for _, status := range statuses {
+		if status.State != migrate.Applied {
+			log.Warn().Int64("version", status.Version).Str("name", status.Name).Str("state", string(status.State)).
+				Msg("database schema is not up to date, run align-back migrate up")
+		}
+	}
+
+	// Initialize mailer
+	var mailer domain.Mailer
+	if cfg.Mail.Host != "" {
+		smtpMailer, err := mail.NewSMTP(cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to set up mailer")
+		}
+		mailer = smtpMailer
+	} else {
+		mailer = mail.NewLog()
+		log.Warn().Msg("no SMTP server, emails are only logged")
+	}
 
 	// Initialize services
-	clientService := service.NewClientService(clientRepo)
-	packageService := service.NewPackageService(packageRepo)
-	classService := service.NewClassService(classRepo)
-	scheduleService := service.NewScheduleService(scheduleRepo, classRepo)
-	appointmentService := service.NewAppointmentService(appointmentRepo, scheduleRepo, clientRepo)
-	billingService := service.NewBillingService(billingRepo, clientRepo, packageRepo)
+	clientService := service.NewClientService(clientRepo, transactor)
+	packageService := service.NewPackageService(packageRepo, transactor)
+	classService := service.NewClassService(classRepo, transactor)
+	scheduleService := service.NewScheduleService(scheduleRepo, classRepo, transactor)
+	appointmentService := service.NewAppointmentService(appointmentRepo, scheduleRepo, clientRepo, cancellationRepo, cfg.Cancellation, transactor)
+	billingService := service.NewBillingService(billingRepo, clientRepo, packageRepo, transactor, cfg.Studio.Issuer(), location)
+	waitlistService := service.NewWaitlistService(waitlistRepo, transactor)
+	seriesService := service.NewScheduleSeriesService(seriesRepo, scheduleRepo, classRepo, transactor, location, cfg.Schedule.SeriesHorizonDays)
+	invoiceService := service.NewInvoiceService(invoiceRepo, transactor, cfg.Studio.Issuer(), location)
+	creditService := service.NewCreditService(creditLedgerRepo, creditLotRepo, clientRepo, transactor)
+	paymentService := service.NewPaymentService(paymentRepo, transactor)
+	reportService := service.NewReportService(reportRepo)
+	dashboardService := service.NewDashboardService(clientService, scheduleRepo, paymentRepo, reportRepo, cfg.Dashboard.Settings(), location)
+	accountingService := service.NewAccountingService(billingRepo, cfg.Accounting.Settings(cfg.Studio.SIRET), location)
+	subscriptionService := service.NewSubscriptionService(subscriptionRepo, transactor, cfg.Studio.Issuer(), location)
+	promotionService := service.NewPromotionService(promotionRepo, transactor)
+	voucherService := service.NewVoucherService(voucherRepo, transactor, cfg.Studio.Issuer(), location)
+	staffService := service.NewStaffService(staffRepo, transactor, cfg.Auth.SessionTTL())
+	checkoutService := service.NewCheckoutService(checkoutRepo, billingService, paymentProvider, transactor, cfg.Studio.Issuer(), location,
+		cfg.Payments.CheckoutTTL())
+	portalService := service.NewPortalService(clientLoginRepo, clientService, creditService, appointmentService, billingService, scheduleService, mailer, transactor,
+		cfg.Portal.URL, cfg.Portal.SessionTTL())
+	auditService := service.NewAuditService(auditRepo)
 
 	// Initialize handlers
 	clientHandler := handler.NewClientHandler(clientService)
 	packageHandler := handler.NewPackageHandler(packageService)
 	classHandler := handler.NewClassHandler(classService)
-	scheduleHandler := handler.NewScheduleHandler(scheduleService)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService, location)
 	appointmentHandler := handler.NewAppointmentHandler(appointmentService)
 	billingHandler := handler.NewBillingHandler(billingService)
+	waitlistHandler := handler.NewWaitlistHandler(waitlistService)
+	seriesHandler := handler.NewSeriesHandler(seriesService)
+	creditHandler := handler.NewCreditHandler(creditService)
+	invoiceHandler := handler.NewInvoiceHandler(invoiceService, location)
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+	reportHandler := handler.NewReportHandler(reportService, location)
+	dashboardHandler := handler.NewDashboardHandler(dashboardService)
+	accountingHandler := handler.NewAccountingHandler(accountingService)
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService)
+	promotionHandler := handler.NewPromotionHandler(promotionService)
+	voucherHandler := handler.NewVoucherHandler(voucherService)
+	checkoutHandler := handler.NewCheckoutHandler(checkoutService)
+	staffHandler := handler.NewStaffHandler(staffService)
+	portalHandler := handler.NewPortalHandler(portalService, location)
+	auditHandler := handler.NewAuditHandler(auditService)
+
+	// Background jobs
+	startJob("series generation", 24*time.Hour, func() error {
+		created, err := seriesService.GenerateAll(context.Background())
+		log.Info().Int("created", created).Msg("generated series occurrences")
+		return err
+	})
+
+	startJob("credit expiry", time.Hour, func() error {
+		expired, err := creditService.ExpireAll(context.Background())
+		log.Info().Int("entries", len(expired)).Msg("expired unused credits")
+		return err
+	})
+
+	startJob("subscription renewal", time.Hour, func() error {
+		billed, err := subscriptionService.RenewAll(context.Background())
+		log.Info().Int("billed", billed).Msg("renewed subscriptions")
+		return err
+	})
+
+	startJob("staff session purge", 24*time.Hour, func() error {
+		purged, err := staffService.PurgeSessions()
+		log.Info().Int64("sessions", purged).Msg("purged expired staff sessions")
+		return err
+	})
+
+	startJob("portal session purge", 24*time.Hour, func() error {
+		purged, err := portalService.PurgeExpired()
+		log.Info().Int64("rows", purged).Msg("purged expired portal logins and sessions")
+		return err
+	})
 
 	// Initialize router
 	r := chi.NewRouter()
@@ -102,7 +219,7 @@ func run() {
 		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:5173"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Content-Disposition"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -115,73 +232,225 @@ func run() {
 			w.Write([]byte("API is running"))
 		})
 
-		// Clients endpoints
-		r.Route("/clients", func(r chi.Router) {
-			r.Get("/", clientHandler.GetAll)
-			r.Post("/", clientHandler.Create)
-			r.Get("/{id}", clientHandler.GetByID)
-			r.Put("/{id}", clientHandler.Update)
-			r.Delete("/{id}", clientHandler.Delete)
-			r.Put("/low-group-credit", clientHandler.GetLowGroupCredits)
-			r.Put("/low-private-credits", clientHandler.GetLowPrivateCredits)
-		})
+		// Staff login
+		r.Post("/auth/login", staffHandler.Login)
 
-		// Packages endpoints
-		r.Route("/packages", func(r chi.Router) {
-			r.Get("/", packageHandler.GetAll)
-			r.Post("/", packageHandler.Create)
-			r.Get("/{id}", packageHandler.GetByID)
-			r.Put("/{id}", packageHandler.Update)
-			r.Delete("/{id}", packageHandler.Delete)
+		// Webhooks endpoints, authenticated by their signature
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/payments", checkoutHandler.Webhook)
 		})
 
-		// Classes endpoints
-		r.Route("/classes", func(r chi.Router) {
-			r.Get("/", classHandler.GetAll)
-			r.Post("/", classHandler.Create)
-			r.Get("/{id}", classHandler.GetByID)
-			r.Put("/{id}", classHandler.Update)
-			r.Delete("/{id}", classHandler.Delete)
-		})
+		// Client portal endpoints, scoped to the logged in client
+		r.Route("/me", func(r chi.Router) {
+			r.Post("/login", portalHandler.RequestLogin)
+			r.Post("/verify", portalHandler.Verify)
 
-		// Schedule endpoints
-		r.Route("/schedule", func(r chi.Router) {
-			r.Get("/", scheduleHandler.GetAll)
-			r.Post("/", scheduleHandler.Create)
-			r.Get("/{id}", scheduleHandler.GetByID)
-			r.Put("/{id}", scheduleHandler.Update)
-			r.Delete("/{id}", scheduleHandler.Delete)
-			r.Get("/date/{date}", scheduleHandler.GetByDate)
-			r.Get("/week/{date}", scheduleHandler.GetByWeek)
-		})
+			r.Group(func(r chi.Router) {
+				r.Use(apimiddleware.AuthenticateClient(portalService))
 
-		// Appointments endpoints
-		r.Route("/appointments", func(r chi.Router) {
-			r.Get("/", appointmentHandler.GetAll)
-			r.Post("/", appointmentHandler.Create)
-			r.Get("/{id}", appointmentHandler.GetByID)
-			r.Put("/{id}", appointmentHandler.Update)
-			r.Delete("/{id}", appointmentHandler.Delete)
-			r.Get("/client/{clientId}", appointmentHandler.GetByClientID)
-			r.Get("/schedule/{scheduleId}", appointmentHandler.GetByScheduleID)
+				r.Get("/", portalHandler.Get)
+				r.Post("/logout", portalHandler.Logout)
+				r.Get("/credits", portalHandler.GetCredits)
+				r.Get("/billings", portalHandler.GetBillings)
+				r.Get("/schedule/week/{date}", portalHandler.GetSchedule)
+				r.Get("/appointments", portalHandler.GetAppointments)
+				r.Post("/appointments", portalHandler.Book)
+				r.Post("/appointments/{id}/cancel", portalHandler.Cancel)
+			})
 		})
 
-		// Billing endpoints
-		r.Route("/billings", func(r chi.Router) {
-			r.Get("/", billingHandler.GetAll)
-			r.Post("/", billingHandler.Create)
-			r.Get("/{id}", billingHandler.GetByID)
-			r.Put("/{id}", billingHandler.Update)
-			r.Delete("/{id}", billingHandler.Delete)
-			r.Get("/client/{clientId}", billingHandler.GetByClientID)
-			r.Get("/recent", billingHandler.GetRecent)
-		})
+		// Staff endpoints
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.Authenticate(staffService))
 
-		// Dashboard data endpoint
-		r.Get("/dashboard", func(w http.ResponseWriter, r *http.Request) {
-			// Implement dashboard data aggregation here
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"message": "Dashboard data endpoint"}`))
+			r.Post("/auth/logout", staffHandler.Logout)
+			r.Get("/auth/me", staffHandler.Me)
+
+			// Instructors only read the schedule and attendance
+			r.Group(func(r chi.Router) {
+				r.Use(apimiddleware.ReadOnlyFor(domain.InstructorRole))
+
+				// Classes endpoints
+				r.Route("/classes", func(r chi.Router) {
+					r.Get("/", classHandler.GetAll)
+					r.Post("/", classHandler.Create)
+					r.Get("/{id}", classHandler.GetByID)
+					r.Put("/{id}", classHandler.Update)
+					r.Delete("/{id}", classHandler.Delete)
+				})
+
+				// Schedule endpoints
+				r.Route("/schedule", func(r chi.Router) {
+					r.Get("/", scheduleHandler.GetAll)
+					r.Post("/", scheduleHandler.Create)
+					r.Get("/{id}", scheduleHandler.GetByID)
+					r.Put("/{id}", scheduleHandler.Update)
+					r.Delete("/{id}", scheduleHandler.Delete)
+					r.Get("/date/{date}", scheduleHandler.GetByDate)
+					r.Get("/week/{date}", scheduleHandler.GetByWeek)
+					r.Get("/{id}/waitlist", waitlistHandler.GetBySchedule)
+					r.Post("/{id}/waitlist", waitlistHandler.Join)
+					r.Delete("/{id}/waitlist/{clientId}", waitlistHandler.Leave)
+				})
+
+				// Schedule series endpoints
+				r.Route("/series", func(r chi.Router) {
+					r.Get("/", seriesHandler.GetAll)
+					r.Post("/", seriesHandler.Create)
+					r.Get("/{id}", seriesHandler.GetByID)
+					r.Put("/{id}", seriesHandler.Update)
+					r.Delete("/{id}", seriesHandler.Delete)
+					r.Get("/{id}/schedule", seriesHandler.GetOccurrences)
+				})
+
+				// Appointments endpoints
+				r.Route("/appointments", func(r chi.Router) {
+					r.Get("/", appointmentHandler.GetAll)
+					r.Post("/", appointmentHandler.Create)
+					r.Get("/{id}", appointmentHandler.GetByID)
+					r.Put("/{id}", appointmentHandler.Update)
+					r.Delete("/{id}", appointmentHandler.Delete)
+					r.Post("/{id}/cancel", appointmentHandler.Cancel)
+					r.Get("/cancellations/client/{clientId}", appointmentHandler.GetCancellationsByClientID)
+					r.Get("/client/{clientId}", appointmentHandler.GetByClientID)
+					r.Get("/schedule/{scheduleId}", appointmentHandler.GetByScheduleID)
+				})
+			})
+
+			// Front desk manages clients and sales, reading prices only
+			r.Group(func(r chi.Router) {
+				r.Use(apimiddleware.RequireRole(domain.OwnerRole, domain.FrontDeskRole))
+
+				// Clients endpoints
+				r.Route("/clients", func(r chi.Router) {
+					r.Get("/", clientHandler.GetAll)
+					r.Post("/", clientHandler.Create)
+					r.Get("/{id}", clientHandler.GetByID)
+					r.Put("/{id}", clientHandler.Update)
+					r.Delete("/{id}", clientHandler.Delete)
+					r.Put("/low-group-credit", clientHandler.GetLowGroupCredits)
+					r.Put("/low-private-credits", clientHandler.GetLowPrivateCredits)
+					r.Post("/credits/reconcile", creditHandler.Reconcile)
+					r.Post("/credits/expire", creditHandler.Expire)
+					r.Get("/{id}/credits", creditHandler.GetHistory)
+					r.Post("/{id}/credits", creditHandler.Adjust)
+					r.Get("/{id}/credits/balance", creditHandler.GetBalance)
+					r.Get("/{id}/credits/lots", creditHandler.GetLots)
+					r.Get("/{id}/payments", paymentHandler.GetByClientID)
+					r.Get("/{id}/balance", paymentHandler.GetClientBalance)
+					r.Get("/{id}/subscriptions", subscriptionHandler.GetByClientID)
+				})
+
+				// Packages endpoints
+				r.With(apimiddleware.ReadOnlyFor(domain.FrontDeskRole)).Route("/packages", func(r chi.Router) {
+					r.Get("/", packageHandler.GetAll)
+					r.Post("/", packageHandler.Create)
+					r.Get("/{id}", packageHandler.GetByID)
+					r.Put("/{id}", packageHandler.Update)
+					r.Delete("/{id}", packageHandler.Delete)
+				})
+
+				// Promotions endpoints
+				r.With(apimiddleware.ReadOnlyFor(domain.FrontDeskRole)).Route("/promotions", func(r chi.Router) {
+					r.Get("/", promotionHandler.GetAll)
+					r.Post("/", promotionHandler.Create)
+					r.Get("/{id}", promotionHandler.GetByID)
+					r.Put("/{id}", promotionHandler.Update)
+				})
+
+				// Gift vouchers endpoints
+				r.Route("/vouchers", func(r chi.Router) {
+					r.Get("/", voucherHandler.GetAll)
+					r.Post("/", voucherHandler.Create)
+					r.Get("/{id}", voucherHandler.GetByID)
+					r.Post("/{id}/cancel", voucherHandler.Cancel)
+					r.Get("/code/{code}", voucherHandler.GetByCode)
+					r.Post("/code/{code}/redeem", voucherHandler.Redeem)
+				})
+
+				// Online payments endpoints
+				r.Route("/checkouts", func(r chi.Router) {
+					r.Get("/", checkoutHandler.GetAll)
+					r.Post("/", checkoutHandler.Create)
+					r.Get("/{id}", checkoutHandler.GetByID)
+					r.Post("/{id}/sync", checkoutHandler.Sync)
+					r.With(apimiddleware.RequireRole(domain.OwnerRole)).Post("/{id}/refunds", checkoutHandler.Refund)
+				})
+
+				// Subscriptions endpoints
+				r.Route("/subscriptions", func(r chi.Router) {
+					r.Get("/", subscriptionHandler.GetAll)
+					r.Post("/", subscriptionHandler.Create)
+					r.Get("/renewals", subscriptionHandler.GetRenewals)
+					r.Get("/{id}", subscriptionHandler.GetByID)
+					r.Post("/{id}/pause", subscriptionHandler.Pause)
+					r.Post("/{id}/resume", subscriptionHandler.Resume)
+					r.Post("/{id}/cancel", subscriptionHandler.Cancel)
+				})
+
+				// Dashboard data endpoint
+				r.Get("/dashboard", dashboardHandler.Get)
+
+				// Fake payment provider pages, in development only
+				if fakePayments != nil && cfg.Payments.DevRoutes {
+					fakePaymentHandler := handler.NewFakePaymentHandler(fakePayments, cfg.Payments.WebhookURL())
+					r.Route("/dev/payments", func(r chi.Router) {
+						r.Get("/{id}", fakePaymentHandler.GetByID)
+						r.Post("/{id}/pay", fakePaymentHandler.Pay)
+						r.Post("/{id}/fail", fakePaymentHandler.Fail)
+					})
+				}
+			})
+
+			// Owners only
+			r.Group(func(r chi.Router) {
+				r.Use(apimiddleware.RequireRole(domain.OwnerRole))
+
+				// Billing endpoints
+				r.Route("/billings", func(r chi.Router) {
+					r.Get("/", billingHandler.GetAll)
+					r.Post("/", billingHandler.Create)
+					r.Get("/{id}", billingHandler.GetByID)
+					r.Put("/{id}", billingHandler.Update)
+					r.Post("/{id}/refunds", billingHandler.Refund)
+					r.Get("/{id}/refunds", billingHandler.GetRefunds)
+					r.Get("/client/{clientId}", billingHandler.GetByClientID)
+					r.Get("/{id}/invoice", invoiceHandler.GetByBillingID)
+					r.Get("/{id}/invoice.pdf", invoiceHandler.GetPDF)
+					r.Get("/{id}/payments", paymentHandler.GetByBillingID)
+					r.Post("/{id}/payments", paymentHandler.Record)
+					r.Get("/{id}/balance", paymentHandler.GetBalance)
+					r.Get("/recent", billingHandler.GetRecent)
+					r.Get("/outstanding", paymentHandler.GetOutstanding)
+				})
+
+				// Reports endpoints
+				r.Route("/reports", func(r chi.Router) {
+					r.Get("/revenue/monthly", reportHandler.GetRevenueByMonth)
+					r.Get("/revenue/locations", reportHandler.GetRevenueByLocation)
+					r.Get("/revenue/package-types", reportHandler.GetRevenueByPackageType)
+					r.Get("/revenue/payment-methods", reportHandler.GetRevenueByPaymentMethod)
+					r.Get("/sessions", reportHandler.GetSessionCounts)
+					r.Get("/fill-rate/classes", reportHandler.GetFillRateByClass)
+					r.Get("/fill-rate/slots", reportHandler.GetFillRateBySlot)
+				})
+
+				// Exports endpoints
+				r.Route("/exports", func(r chi.Router) {
+					r.Get("/fec/{year}", accountingHandler.GetFEC)
+				})
+
+				// Staff accounts endpoints
+				r.Route("/staff", func(r chi.Router) {
+					r.Get("/", staffHandler.GetAll)
+					r.Post("/", staffHandler.Create)
+					r.Get("/{id}", staffHandler.GetByID)
+					r.Put("/{id}", staffHandler.Update)
+				})
+
+				// Audit log endpoint, read only
+				r.Get("/audit", auditHandler.Find)
+			})
 		})
 	})
 
@@ -192,3 +461,245 @@ func run() {
 		log.Fatal().Err(err).Msg("server failed")
 	}
 }
+
+// exportFECCommand returns the command writing the FEC of a fiscal year
+func exportFECCommand() *cobra.Command {
+	var year int
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export-fec",
+		Short: "Export the sales journal of a fiscal year as a FEC file",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, location, db := setup()
+			defer db.Close()
+
+			billingRepo := repository.NewBillingRepository(db)
+			accountingService := service.NewAccountingService(billingRepo, cfg.Accounting.Settings(cfg.Studio.SIRET), location)
+
+			journal, err := accountingService.GetSalesJournal(year)
+			if err != nil {
+				log.Fatal().Err(err).Int("year", year).Msg("failed to get sales journal")
+			}
+
+			if output == "" {
+				output = fec.FileName(journal)
+			}
+
+			file, err := os.Create(output)
+			if err != nil {
+				log.Fatal().Err(err).Str("output", output).Msg("failed to create FEC file")
+			}
+			defer file.Close()
+
+			if err := fec.Write(file, journal); err != nil {
+				log.Fatal().Err(err).Str("output", output).Msg("failed to write FEC file")
+			}
+
+			log.Info().Int("year", year).Int("lines", len(journal.Lines)).Str("output", output).Msg("exported FEC")
+		},
+	}
+
+	cmd.Flags().IntVar(&year, "year", time.Now().Year()-1, "fiscal year, named after the year it closes in")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output file (default is the regulatory SIRENFECYYYYMMDD.txt name)")
+	return cmd
+}
+
+// createStaffCommand returns the command creating a staff account, e.g. the
+// first owner, with the password read from the standard input
+func createStaffCommand() *cobra.Command {
+	var input domain.StaffInput
+
+	cmd := &cobra.Command{
+		Use:   "create-staff",
+		Short: "Create a staff account, reading its password from the standard input",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, _, db := setup()
+			defer db.Close()
+
+			fmt.Fprint(os.Stderr, "Password: ")
+			password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && password == "" {
+				log.Fatal().Err(err).Msg("failed to read password")
+			}
+			input.Password = strings.TrimRight(password, "\r\n")
+
+			staffService := service.NewStaffService(repository.NewStaffRepository(db), repository.NewTransactor(db), 0)
+			user, err := staffService.Create(context.Background(), input)
+			if err != nil {
+				log.Fatal().Err(err).Str("email", input.Email).Msg("failed to create staff account")
+			}
+
+			log.Info().Str("id", user.ID).Str("email", user.Email).Str("role", string(user.Role)).Msg("created staff account")
+		},
+	}
+
+	cmd.Flags().StringVar(&input.Email, "email", "", "email used to log in")
+	cmd.Flags().StringVar(&input.Name, "name", "", "full name")
+	cmd.Flags().StringVar((*string)(&input.Role), "role", string(domain.OwnerRole), "OWNER, FRONT_DESK or INSTRUCTOR")
+	cmd.MarkFlagRequired("email")
+	cmd.MarkFlagRequired("name")
+	return cmd
+}
+
+// migrateCommand returns the command managing the versioned migrations of
+// the database schema
+func migrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the versioned migrations of the database schema",
+	}
+
+	var baseline bool
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply the pending migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, _, db := setup()
+			defer db.Close()
+
+			migrator := newMigrator(db)
+			if baseline {
+				migration, err := migrator.Baseline(cmd.Context())
+				if err != nil {
+					log.Fatal().Err(err).Msg("failed to baseline database")
+				}
+				log.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("recorded migration as applied")
+			}
+
+			migrated, err := migrator.Up(cmd.Context())
+			for _, migration := range migrated {
+				log.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("applied migration")
+			}
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to migrate database")
+			}
+
+			log.Info().Int("migrations", len(migrated)).Msg("database is up to date")
+		},
+	}
+	up.Flags().BoolVar(&baseline, "baseline", false, "first record the baseline migration as applied without running it, to adopt a database created by the former db/init.sql")
+	cmd.AddCommand(up)
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Revert the latest applied migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, _, db := setup()
+			defer db.Close()
+
+			reverted, err := newMigrator(db).Down(cmd.Context(), steps)
+			for _, migration := range reverted {
+				log.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("reverted migration")
+			}
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to revert migrations")
+			}
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "number of migrations to revert")
+	cmd.AddCommand(down)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show the state of every migration",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, _, db := setup()
+			defer db.Close()
+
+			statuses, err := newMigrator(db).Status(cmd.Context())
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to get migrations status")
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+			for _, status := range statuses {
+				appliedAt := "-"
+				if status.AppliedAt != nil {
+					appliedAt = status.AppliedAt.Format(time.DateTime)
+				}
+				fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, status.State, appliedAt)
+			}
+			w.Flush()
+		},
+	})
+
+	var dir string
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create the empty up and down scripts of a new migration",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			up, down, err := migrate.Create(dir, args[0])
+			if err != nil {
+				log.Fatal().Err(err).Str("name", args[0]).Msg("failed to create migration")
+			}
+
+			log.Info().Str("up", up).Str("down", down).Msg("created migration, rebuild to embed it")
+		},
+	}
+	create.Flags().StringVar(&dir, "dir", "db/migrations", "migrations directory")
+	cmd.AddCommand(create)
+
+	return cmd
+}
+
+// newMigrator returns a migrator applying the embedded migrations to db
+func newMigrator(db *sqlx.DB) *migrate.Migrator {
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load migrations")
+	}
+	return migrator
+}
+
+// setup loads the configuration, initializes the logger and connects to the
+// database
+func setup() (*config.Config, *time.Location, *sqlx.DB) {
+	// Initialize config
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load configuration")
+	}
+
+	// Initialize logger
+	logger.InitLogger(cfg.LogLevel)
+
+	location, err := time.LoadLocation(cfg.Schedule.Timezone)
+	if err != nil {
+		log.Fatal().Err(err).Str("timezone", cfg.Schedule.Timezone).Msg("failed to load studio time zone")
+	}
+
+	// Database connection
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+		cfg.DB.User, cfg.DB.Password, cfg.DB.Host, cfg.DB.Port, cfg.DB.Name)
+	db, err := sqlx.Connect("mysql", dsn)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect to database")
+	}
+
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.DB.ConnMaxLifetime) * time.Second)
+
+	log.Info().Msg("Connected to database")
+
+	return cfg, location, db
+}
+
+// startJob runs fn now and then every interval in the background
+func startJob(name string, interval time.Duration, fn func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := fn(); err != nil {
+				log.Error().Err(err).Str("job", name).Msg("background job failed")
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -3,15 +3,26 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/matthieukhl/align-back/internal/domain"
 	"github.com/spf13/viper"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	DB       DBConfig
-	LogLevel string `mapstructure:"log_level"`
+	Server       ServerConfig
+	DB           DBConfig
+	Schedule     ScheduleConfig
+	Cancellation CancellationConfig
+	Studio       StudioConfig
+	Dashboard    DashboardConfig
+	Accounting   AccountingConfig
+	Payments     PaymentsConfig
+	Auth         AuthConfig
+	Mail         MailConfig
+	Portal       PortalConfig
+	LogLevel     string `mapstructure:"log_level"`
 }
 
 // ServerConfig holds server related configuration
@@ -31,6 +42,180 @@ type DBConfig struct {
 	ConnMaxLifetime int `mapstructure:"conn_max_lifetime"`
 }
 
+// ScheduleConfig holds schedule related configuration
+type ScheduleConfig struct {
+	// Timezone is the studio time zone, used to generate recurring classes
+	Timezone          string
+	SeriesHorizonDays int `mapstructure:"series_horizon_days"`
+}
+
+// CancellationConfig holds the late cancellation policy. A cancellation made
+// less than the window before the class starts forfeits the credit.
+type CancellationConfig struct {
+	DefaultWindowHours int `mapstructure:"default_window_hours"`
+	// Windows overrides the default window per location, then per class
+	// type, e.g. windows.clairvivre.group: 12
+	Windows map[string]map[string]int
+}
+
+// Window returns the late cancellation window for a class
+func (c CancellationConfig) Window(location domain.Location, classType domain.ClassType) time.Duration {
+	hours := c.DefaultWindowHours
+
+	// Viper lowercases map keys
+	if byType, ok := c.Windows[strings.ToLower(string(location))]; ok {
+		if h, ok := byType[strings.ToLower(string(classType))]; ok {
+			hours = h
+		}
+	}
+
+	return time.Duration(hours) * time.Hour
+}
+
+// DashboardConfig holds what the dashboard shows and how long it is cached
+type DashboardConfig struct {
+	CacheSeconds       int    `mapstructure:"cache_seconds"`
+	LowCreditThreshold int    `mapstructure:"low_credit_threshold"`
+	RecentPayments     int    `mapstructure:"recent_payments"`
+	EmptyClassDays     int    `mapstructure:"empty_class_days"`
+	Currency           string `mapstructure:"currency"`
+}
+
+// Settings returns the dashboard settings
+func (c DashboardConfig) Settings() domain.DashboardSettings {
+	return domain.DashboardSettings{
+		CacheTTL:           time.Duration(c.CacheSeconds) * time.Second,
+		LowCreditThreshold: c.LowCreditThreshold,
+		RecentPayments:     c.RecentPayments,
+		EmptyClassDays:     c.EmptyClassDays,
+		Currency:           c.Currency,
+	}
+}
+
+// AccountingConfig holds the journal and accounts billings are posted to in
+// the FEC export
+type AccountingConfig struct {
+	FiscalYearStartMonth   int    `mapstructure:"fiscal_year_start_month"`
+	SalesJournalCode       string `mapstructure:"sales_journal_code"`
+	SalesJournalLabel      string `mapstructure:"sales_journal_label"`
+	SalesAccount           string `mapstructure:"sales_account"`
+	SalesAccountLabel      string `mapstructure:"sales_account_label"`
+	VATAccount             string `mapstructure:"vat_account"`
+	VATAccountLabel        string `mapstructure:"vat_account_label"`
+	ReceivableAccount      string `mapstructure:"receivable_account"`
+	ReceivableAccountLabel string `mapstructure:"receivable_account_label"`
+	ClientAccountPrefix    string `mapstructure:"client_account_prefix"`
+}
+
+// Settings returns the accounting settings of the studio with a SIRET, whose
+// first nine digits are its SIREN
+func (c AccountingConfig) Settings(siret string) domain.AccountingSettings {
+	siren := strings.ReplaceAll(siret, " ", "")
+	if len(siren) > 9 {
+		siren = siren[:9]
+	}
+
+	return domain.AccountingSettings{
+		FiscalYearStartMonth:   time.Month(c.FiscalYearStartMonth),
+		SIREN:                  siren,
+		JournalCode:            c.SalesJournalCode,
+		JournalLabel:           c.SalesJournalLabel,
+		SalesAccount:           c.SalesAccount,
+		SalesAccountLabel:      c.SalesAccountLabel,
+		VATAccount:             c.VATAccount,
+		VATAccountLabel:        c.VATAccountLabel,
+		ReceivableAccount:      c.ReceivableAccount,
+		ReceivableAccountLabel: c.ReceivableAccountLabel,
+		ClientAccountPrefix:    c.ClientAccountPrefix,
+	}
+}
+
+// PaymentsConfig holds the provider taking payments online. Webhook events
+// are signed with WebhookSecret, shared with the provider; PublicURL is where
+// the provider reaches the API. DevRoutes serves the checkout pages of the
+// fake provider to staff, in development only.
+type PaymentsConfig struct {
+	Provider        string
+	WebhookSecret   string `mapstructure:"webhook_secret"`
+	PublicURL       string `mapstructure:"public_url"`
+	DevRoutes       bool   `mapstructure:"dev_routes"`
+	CheckoutMinutes int    `mapstructure:"checkout_minutes"`
+}
+
+// CheckoutTTL returns how long clients have to pay a checkout once opened
+func (c PaymentsConfig) CheckoutTTL() time.Duration {
+	return time.Duration(c.CheckoutMinutes) * time.Minute
+}
+
+// WebhookURL returns the URL the provider sends its events to
+func (c PaymentsConfig) WebhookURL() string {
+	return strings.TrimSuffix(c.PublicURL, "/") + "/api/webhooks/payments"
+}
+
+// AuthConfig holds how staff are authenticated
+type AuthConfig struct {
+	SessionHours int `mapstructure:"session_hours"`
+}
+
+// SessionTTL returns how long staff sessions last from login
+func (c AuthConfig) SessionTTL() time.Duration {
+	return time.Duration(c.SessionHours) * time.Hour
+}
+
+// MailConfig holds the SMTP server emails are sent through. Without a host,
+// emails are only logged.
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// PortalConfig holds the client portal. URL is where login links point to.
+type PortalConfig struct {
+	URL         string `mapstructure:"url"`
+	SessionDays int    `mapstructure:"session_days"`
+}
+
+// SessionTTL returns how long client sessions last from login
+func (c PortalConfig) SessionTTL() time.Duration {
+	return time.Duration(c.SessionDays) * 24 * time.Hour
+}
+
+// StudioConfig holds the studio legal details printed on invoices
+type StudioConfig struct {
+	Name          string
+	Address       string
+	ZipCode       string `mapstructure:"zip_code"`
+	City          string
+	Country       string
+	SIRET         string `mapstructure:"siret"`
+	VATNumber     string `mapstructure:"vat_number"`
+	Email         string
+	Phone         string
+	LegalMentions []string `mapstructure:"legal_mentions"`
+}
+
+// Issuer returns the studio details as printed on invoices
+func (c StudioConfig) Issuer() domain.InvoiceIssuer {
+	contact := []string{}
+	for _, value := range []string{c.Email, c.Phone} {
+		if value != "" {
+			contact = append(contact, value)
+		}
+	}
+
+	return domain.InvoiceIssuer{
+		Name:          c.Name,
+		Address:       strings.Join([]string{c.Address, strings.TrimSpace(c.ZipCode + " " + c.City), c.Country}, "\n"),
+		SIRET:         c.SIRET,
+		VATNumber:     c.VATNumber,
+		Contact:       strings.Join(contact, " - "),
+		LegalMentions: strings.Join(c.LegalMentions, "\n"),
+	}
+}
+
 // LoadConfig loads configuration from config file
 func LoadConfig(cfgFile string) (*Config, error) {
 	var config Config
@@ -45,6 +230,34 @@ func LoadConfig(cfgFile string) (*Config, error) {
 		viper.AddConfigPath(".")
 	}
 
+	// Defaults
+	viper.SetDefault("schedule.timezone", "Europe/Paris")
+	viper.SetDefault("schedule.series_horizon_days", 56)
+	viper.SetDefault("cancellation.default_window_hours", 24)
+	viper.SetDefault("dashboard.cache_seconds", 30)
+	viper.SetDefault("dashboard.low_credit_threshold", 2)
+	viper.SetDefault("dashboard.recent_payments", 10)
+	viper.SetDefault("dashboard.empty_class_days", 7)
+	viper.SetDefault("dashboard.currency", "EUR")
+	viper.SetDefault("accounting.fiscal_year_start_month", 1)
+	viper.SetDefault("accounting.sales_journal_code", "VE")
+	viper.SetDefault("accounting.sales_journal_label", "Ventes")
+	viper.SetDefault("accounting.sales_account", "706000")
+	viper.SetDefault("accounting.sales_account_label", "Prestations de services")
+	viper.SetDefault("accounting.vat_account", "445710")
+	viper.SetDefault("accounting.vat_account_label", "TVA collectée")
+	viper.SetDefault("accounting.receivable_account", "411000")
+	viper.SetDefault("accounting.receivable_account_label", "Clients")
+	viper.SetDefault("accounting.client_account_prefix", "C")
+	viper.SetDefault("payments.provider", "fake")
+	viper.SetDefault("payments.public_url", "http://localhost:8080")
+	viper.SetDefault("payments.dev_routes", false)
+	viper.SetDefault("payments.checkout_minutes", 30)
+	viper.SetDefault("auth.session_hours", 12)
+	viper.SetDefault("mail.port", 587)
+	viper.SetDefault("portal.url", "http://localhost:3000")
+	viper.SetDefault("portal.session_days", 30)
+
 	// Environment variables
 	viper.SetEnvPrefix("ALIGN")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -0,0 +1,12 @@
+-- Drop the whole baseline schema, with its data
+
+SET FOREIGN_KEY_CHECKS = 0;
+
+DROP TABLE IF EXISTS billings;
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS schedule;
+DROP TABLE IF EXISTS classes;
+DROP TABLE IF EXISTS packages;
+DROP TABLE IF EXISTS clients;
+
+SET FOREIGN_KEY_CHECKS = 1;
@@ -1,6 +1,5 @@
--- Create the Pilates Management Database
-CREATE DATABASE IF NOT EXISTS align;
-USE align;
+-- Baseline schema of the Pilates Management Database, as created by the
+-- former db/init.sql
 
 -- Enable foreign key constraints
 SET FOREIGN_KEY_CHECKS = 1;
@@ -90,16 +89,3 @@ CREATE INDEX idx_schedule_datetime ON schedule(class_datetime);
 CREATE INDEX idx_appointments_client ON appointments(client_id);
 CREATE INDEX idx_appointments_schedule ON appointments(schedule_id);
 CREATE INDEX idx_billings_client ON billings(client_id);
-
--- Insert some sample data
-INSERT INTO clients (full_name, firstname, lastname, phone, email) VALUES
-('Jane Smith', 'Jane', 'Smith', '+33123456789', 'jane.smith@example.com'),
-('John Doe', 'John', 'Doe', '+33987654321', 'john.doe@example.com');
-
-INSERT INTO packages (name, number_of_sessions, type, price) VALUES
-('10 Group Sessions', 10, 'GROUP', 150.00),
-('5 Private Sessions', 5, 'PRIVATE', 250.00);
-
-INSERT INTO classes (name, location, type, equipment) VALUES
-('Morning Pilates', 'CLAIRVIVRE', 'GROUP', 'Mat, resistance bands'),
-('Reformer Session', 'CUBJAC', 'PRIVATE', 'Reformer machine');
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- Cancellations Table (history of cancelled appointments)
+CREATE TABLE IF NOT EXISTS cancellations (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    appointment_id VARCHAR(36) NOT NULL,
+    schedule_id VARCHAR(36) NOT NULL,
+    client_id VARCHAR(36) NOT NULL,
+    class_type ENUM('GROUP', 'PRIVATE') NOT NULL,
+    late BOOLEAN NOT NULL DEFAULT FALSE,
+    refunded BOOLEAN NOT NULL DEFAULT FALSE,
+    override_reason VARCHAR(255),
+    cancelled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (schedule_id) REFERENCES schedule(id) ON DELETE CASCADE,
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_cancellations_client ON cancellations(client_id);
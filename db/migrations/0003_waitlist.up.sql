@@ -0,0 +1,14 @@
+-- Waitlist Table (clients waiting for a slot on a full schedule)
+CREATE TABLE IF NOT EXISTS waitlist (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    schedule_id VARCHAR(36) NOT NULL,
+    client_id VARCHAR(36) NOT NULL,
+    status ENUM('WAITING', 'PROMOTED') NOT NULL DEFAULT 'WAITING',
+    appointment_id VARCHAR(36),
+    promoted_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (schedule_id) REFERENCES schedule(id) ON DELETE CASCADE,
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_waitlist_schedule ON waitlist(schedule_id, status, created_at);
@@ -0,0 +1,8 @@
+ALTER TABLE schedule
+    DROP FOREIGN KEY fk_schedule_series,
+    DROP INDEX unique_series_occurrence,
+    DROP COLUMN detached,
+    DROP COLUMN occurrence_date,
+    DROP COLUMN series_id;
+
+DROP TABLE IF EXISTS schedule_series;
@@ -0,0 +1,21 @@
+-- Schedule Series Table (recurring classes)
+CREATE TABLE IF NOT EXISTS schedule_series (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    class_id VARCHAR(36) NOT NULL,
+    capacity INT NOT NULL DEFAULT 10,
+    dtstart DATETIME NOT NULL,
+    rrule VARCHAR(255) NOT NULL,
+    exdates TEXT,
+    ends_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE CASCADE
+);
+
+-- Occurrences of a series, one schedule per series and day
+ALTER TABLE schedule
+    ADD COLUMN series_id VARCHAR(36) NULL AFTER class_datetime,
+    ADD COLUMN occurrence_date DATE NULL AFTER series_id,
+    ADD COLUMN detached BOOLEAN NOT NULL DEFAULT FALSE AFTER occurrence_date,
+    ADD CONSTRAINT fk_schedule_series FOREIGN KEY (series_id) REFERENCES schedule_series(id) ON DELETE SET NULL,
+    ADD UNIQUE KEY unique_series_occurrence (series_id, occurrence_date);
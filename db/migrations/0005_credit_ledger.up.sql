@@ -0,0 +1,16 @@
+-- Credit Ledger Table (append-only history of every credit change)
+CREATE TABLE IF NOT EXISTS credit_ledger (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    client_id VARCHAR(36) NOT NULL,
+    type ENUM('GROUP', 'PRIVATE') NOT NULL,
+    kind ENUM('GRANT', 'DEBIT', 'REFUND', 'ADJUSTMENT') NOT NULL,
+    delta INT NOT NULL,
+    balance_after INT NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    reference_id VARCHAR(36),
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_credit_ledger_client ON credit_ledger(client_id, created_at);
@@ -0,0 +1,13 @@
+-- Expiry entries cannot be told apart from adjustments once reverted
+UPDATE credit_ledger SET kind = 'ADJUSTMENT' WHERE kind = 'EXPIRY';
+
+ALTER TABLE credit_ledger
+    DROP FOREIGN KEY fk_credit_ledger_lot,
+    DROP INDEX idx_credit_ledger_reference,
+    DROP COLUMN lot_id,
+    MODIFY COLUMN kind ENUM('GRANT', 'DEBIT', 'REFUND', 'ADJUSTMENT') NOT NULL;
+
+DROP TABLE IF EXISTS credit_lots;
+
+ALTER TABLE packages
+    DROP COLUMN validity_days;
@@ -0,0 +1,25 @@
+ALTER TABLE packages
+    ADD COLUMN validity_days INT NOT NULL DEFAULT 0 AFTER price;
+
+-- Credit Lots Table (credits granted together, consumed soonest expiry first)
+CREATE TABLE IF NOT EXISTS credit_lots (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    client_id VARCHAR(36) NOT NULL,
+    billing_id VARCHAR(36),
+    type ENUM('GROUP', 'PRIVATE') NOT NULL,
+    credits INT NOT NULL,
+    remaining INT NOT NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE,
+    FOREIGN KEY (billing_id) REFERENCES billings(id) ON DELETE SET NULL
+);
+
+ALTER TABLE credit_ledger
+    MODIFY COLUMN kind ENUM('GRANT', 'DEBIT', 'REFUND', 'ADJUSTMENT', 'EXPIRY') NOT NULL,
+    ADD COLUMN lot_id VARCHAR(36) AFTER reference_id,
+    ADD CONSTRAINT fk_credit_ledger_lot FOREIGN KEY (lot_id) REFERENCES credit_lots(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_credit_ledger_reference ON credit_ledger(reference_id);
+CREATE INDEX idx_credit_lots_client ON credit_lots(client_id, type, expires_at);
+CREATE INDEX idx_credit_lots_expiry ON credit_lots(expires_at, remaining);
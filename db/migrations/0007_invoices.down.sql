@@ -0,0 +1,5 @@
+-- Issued invoices are legal records, this is only meant for databases
+-- that never issued any. Dropping the tables drops their triggers.
+DROP TABLE IF EXISTS invoice_lines;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS invoice_sequences;
@@ -0,0 +1,53 @@
+-- Invoice Sequences Table (last invoice number issued per year)
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    year INT PRIMARY KEY,
+    last_number INT NOT NULL
+);
+
+-- Invoices Table (immutable once issued)
+CREATE TABLE IF NOT EXISTS invoices (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    number VARCHAR(20) NOT NULL UNIQUE,
+    year INT NOT NULL,
+    sequence INT NOT NULL,
+    billing_id VARCHAR(36) NOT NULL UNIQUE,
+    client_id VARCHAR(36) NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    paid_at TIMESTAMP NOT NULL,
+    seller_name VARCHAR(255) NOT NULL,
+    seller_address VARCHAR(500) NOT NULL,
+    seller_siret VARCHAR(20) NOT NULL,
+    seller_vat_number VARCHAR(20) NOT NULL,
+    seller_contact VARCHAR(255) NOT NULL,
+    seller_legal_mentions TEXT NOT NULL,
+    buyer_name VARCHAR(255) NOT NULL,
+    buyer_address VARCHAR(500) NOT NULL,
+    total DECIMAL(10, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_invoices_sequence UNIQUE (year, sequence),
+    FOREIGN KEY (billing_id) REFERENCES billings(id)
+);
+
+-- Invoice Lines Table
+CREATE TABLE IF NOT EXISTS invoice_lines (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    invoice_id VARCHAR(36) NOT NULL,
+    position INT NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price DECIMAL(10, 2) NOT NULL,
+    total DECIMAL(10, 2) NOT NULL,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id)
+);
+
+CREATE TRIGGER invoices_immutable_update BEFORE UPDATE ON invoices
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be changed';
+
+CREATE TRIGGER invoices_immutable_delete BEFORE DELETE ON invoices
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be deleted';
+
+CREATE TRIGGER invoice_lines_immutable_update BEFORE UPDATE ON invoice_lines
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be changed';
+
+CREATE TRIGGER invoice_lines_immutable_delete BEFORE DELETE ON invoice_lines
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be deleted';
@@ -0,0 +1,20 @@
+-- Only possible before any refund or credit note was recorded
+ALTER TABLE invoices
+    DROP FOREIGN KEY fk_invoices_corrected,
+    DROP INDEX uq_invoices_sequence,
+    ADD CONSTRAINT uq_invoices_sequence UNIQUE (year, sequence),
+    DROP COLUMN reason,
+    DROP COLUMN corrected_invoice_number,
+    DROP COLUMN corrected_invoice_id,
+    DROP COLUMN kind;
+
+ALTER TABLE invoice_sequences
+    DROP PRIMARY KEY,
+    DROP COLUMN series,
+    ADD PRIMARY KEY (year);
+
+ALTER TABLE billings
+    DROP FOREIGN KEY fk_billings_refunded,
+    DROP COLUMN reason,
+    DROP COLUMN refunded_billing_id,
+    DROP COLUMN kind;
@@ -0,0 +1,27 @@
+-- Refunds are negative billings pointing to the billing they refund
+ALTER TABLE billings
+    ADD COLUMN kind ENUM('PAYMENT', 'REFUND') NOT NULL DEFAULT 'PAYMENT' AFTER id,
+    ADD COLUMN refunded_billing_id VARCHAR(36) AFTER payment_date,
+    ADD COLUMN reason VARCHAR(255) AFTER refunded_billing_id,
+    ADD CONSTRAINT fk_billings_refunded FOREIGN KEY (refunded_billing_id) REFERENCES billings(id);
+
+-- Invoices and credit notes are numbered in their own series, the
+-- invoices already issued being in the unnamed one
+ALTER TABLE invoice_sequences
+    ADD COLUMN series VARCHAR(10) NOT NULL DEFAULT '' FIRST,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (series, year);
+
+ALTER TABLE invoice_sequences
+    ALTER COLUMN series DROP DEFAULT;
+
+ALTER TABLE invoices
+    ADD COLUMN kind ENUM('INVOICE', 'CREDIT_NOTE') NOT NULL DEFAULT 'INVOICE' AFTER id,
+    ADD COLUMN corrected_invoice_id VARCHAR(36) AFTER buyer_address,
+    ADD COLUMN corrected_invoice_number VARCHAR(20) AFTER corrected_invoice_id,
+    ADD COLUMN reason VARCHAR(255) AFTER corrected_invoice_number,
+    DROP INDEX uq_invoices_sequence,
+    ADD CONSTRAINT uq_invoices_sequence UNIQUE (kind, year, sequence),
+    ADD CONSTRAINT fk_invoices_corrected FOREIGN KEY (corrected_invoice_id) REFERENCES invoices(id);
+
+CREATE INDEX idx_billings_refunded ON billings(refunded_billing_id);
@@ -0,0 +1,40 @@
+-- Amounts in cents are converted back to euros once their type changed
+DROP TRIGGER IF EXISTS invoices_immutable_update;
+DROP TRIGGER IF EXISTS invoice_lines_immutable_update;
+
+ALTER TABLE invoice_lines
+    DROP COLUMN vat_rate,
+    MODIFY COLUMN unit_price DECIMAL(10, 2) NOT NULL,
+    MODIFY COLUMN total DECIMAL(10, 2) NOT NULL;
+
+UPDATE invoice_lines SET unit_price = unit_price / 100, total = total / 100;
+
+ALTER TABLE invoices
+    DROP COLUMN vat_total,
+    DROP COLUMN net_total,
+    DROP COLUMN currency,
+    MODIFY COLUMN total DECIMAL(10, 2) NOT NULL;
+
+UPDATE invoices SET total = total / 100;
+
+CREATE TRIGGER invoices_immutable_update BEFORE UPDATE ON invoices
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be changed';
+
+CREATE TRIGGER invoice_lines_immutable_update BEFORE UPDATE ON invoice_lines
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be changed';
+
+ALTER TABLE billings
+    DROP COLUMN currency,
+    DROP COLUMN vat_rate,
+    DROP COLUMN vat,
+    DROP COLUMN net_price,
+    MODIFY COLUMN price DECIMAL(10, 2) NOT NULL;
+
+UPDATE billings SET price = price / 100;
+
+ALTER TABLE packages
+    DROP COLUMN vat_rate,
+    DROP COLUMN currency,
+    MODIFY COLUMN price DECIMAL(10, 2) NOT NULL;
+
+UPDATE packages SET price = price / 100;
@@ -0,0 +1,53 @@
+-- Prices are stored in cents, VAT included, with their currency and VAT
+-- rate in basis points. Amounts in euros are converted to cents before
+-- their type changes, and amounts recorded before have no VAT.
+UPDATE packages SET price = ROUND(price * 100);
+
+ALTER TABLE packages
+    MODIFY COLUMN price BIGINT NOT NULL,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'EUR' AFTER price,
+    ADD COLUMN vat_rate INT NOT NULL DEFAULT 0 AFTER currency;
+
+UPDATE billings SET price = ROUND(price * 100);
+
+ALTER TABLE billings
+    MODIFY COLUMN price BIGINT NOT NULL,
+    ADD COLUMN net_price BIGINT NOT NULL AFTER price,
+    ADD COLUMN vat BIGINT NOT NULL DEFAULT 0 AFTER net_price,
+    ADD COLUMN vat_rate INT NOT NULL DEFAULT 0 AFTER vat,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'EUR' AFTER vat_rate;
+
+UPDATE billings SET net_price = price;
+
+ALTER TABLE billings
+    ALTER COLUMN vat DROP DEFAULT;
+
+-- Issued invoices cannot be changed, except for this one conversion
+DROP TRIGGER IF EXISTS invoices_immutable_update;
+DROP TRIGGER IF EXISTS invoice_lines_immutable_update;
+
+UPDATE invoices SET total = ROUND(total * 100);
+
+ALTER TABLE invoices
+    MODIFY COLUMN total BIGINT NOT NULL,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'EUR' AFTER reason,
+    ADD COLUMN net_total BIGINT NOT NULL AFTER currency,
+    ADD COLUMN vat_total BIGINT NOT NULL DEFAULT 0 AFTER net_total;
+
+UPDATE invoices SET net_total = total;
+
+ALTER TABLE invoices
+    ALTER COLUMN vat_total DROP DEFAULT;
+
+UPDATE invoice_lines SET unit_price = ROUND(unit_price * 100), total = ROUND(total * 100);
+
+ALTER TABLE invoice_lines
+    MODIFY COLUMN unit_price BIGINT NOT NULL,
+    ADD COLUMN vat_rate INT NOT NULL DEFAULT 0 AFTER unit_price,
+    MODIFY COLUMN total BIGINT NOT NULL;
+
+CREATE TRIGGER invoices_immutable_update BEFORE UPDATE ON invoices
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be changed';
+
+CREATE TRIGGER invoice_lines_immutable_update BEFORE UPDATE ON invoice_lines
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'issued invoices cannot be changed';
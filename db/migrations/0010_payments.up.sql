@@ -0,0 +1,17 @@
+-- Payments Table (installments received for billings, negative when paid back for refunds)
+CREATE TABLE IF NOT EXISTS payments (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    billing_id VARCHAR(36) NOT NULL,
+    client_id VARCHAR(36) NOT NULL,
+    method ENUM('CASH', 'CHEQUE', 'CARD', 'TRANSFER') NOT NULL,
+    amount BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'EUR',
+    paid_at TIMESTAMP NOT NULL,
+    reference VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (billing_id) REFERENCES billings(id),
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_payments_billing ON payments(billing_id);
+CREATE INDEX idx_payments_client ON payments(client_id, paid_at);
@@ -0,0 +1,2 @@
+DROP INDEX idx_payments_paid_at ON payments;
+DROP INDEX idx_billings_payment_date ON billings;
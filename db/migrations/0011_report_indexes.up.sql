@@ -0,0 +1,3 @@
+-- Reports read billings and payments by date
+CREATE INDEX idx_billings_payment_date ON billings(payment_date);
+CREATE INDEX idx_payments_paid_at ON payments(paid_at);
@@ -0,0 +1,13 @@
+-- Only possible before any membership was sold
+ALTER TABLE billings
+    DROP FOREIGN KEY fk_billings_subscription,
+    DROP COLUMN subscription_id;
+
+ALTER TABLE appointments
+    DROP FOREIGN KEY fk_appointments_subscription,
+    DROP COLUMN subscription_id;
+
+DROP TABLE IF EXISTS subscriptions;
+
+ALTER TABLE packages
+    MODIFY COLUMN type ENUM('GROUP', 'PRIVATE') NOT NULL;
@@ -0,0 +1,32 @@
+ALTER TABLE packages
+    MODIFY COLUMN type ENUM('GROUP', 'PRIVATE', 'MEMBERSHIP') NOT NULL;
+
+-- Subscriptions Table (memberships to plans, billed each period)
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    client_id VARCHAR(36) NOT NULL,
+    package_id VARCHAR(36) NOT NULL,
+    status ENUM('ACTIVE', 'PAUSED', 'CANCELLED') NOT NULL DEFAULT 'ACTIVE',
+    period ENUM('MONTHLY', 'QUARTERLY', 'YEARLY') NOT NULL DEFAULT 'MONTHLY',
+    start_date TIMESTAMP NOT NULL,
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE,
+    paused_at TIMESTAMP NULL,
+    cancelled_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE,
+    FOREIGN KEY (package_id) REFERENCES packages(id)
+);
+
+ALTER TABLE appointments
+    ADD COLUMN subscription_id VARCHAR(36) AFTER client_id,
+    ADD CONSTRAINT fk_appointments_subscription FOREIGN KEY (subscription_id) REFERENCES subscriptions(id);
+
+ALTER TABLE billings
+    ADD COLUMN subscription_id VARCHAR(36) AFTER refunded_billing_id,
+    ADD CONSTRAINT fk_billings_subscription FOREIGN KEY (subscription_id) REFERENCES subscriptions(id);
+
+CREATE INDEX idx_subscriptions_client ON subscriptions(client_id, status);
+CREATE INDEX idx_subscriptions_renewal ON subscriptions(status, current_period_end);
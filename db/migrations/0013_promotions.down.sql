@@ -0,0 +1,9 @@
+ALTER TABLE billings
+    DROP FOREIGN KEY fk_billings_promotion,
+    DROP INDEX idx_billings_promotion,
+    DROP COLUMN promotion_id,
+    DROP COLUMN discount,
+    DROP COLUMN original_price;
+
+DROP TABLE IF EXISTS promotion_packages;
+DROP TABLE IF EXISTS promotions;
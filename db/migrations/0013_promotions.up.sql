@@ -0,0 +1,37 @@
+-- Promotions Table (discount codes, rates in basis points, values in cents)
+CREATE TABLE IF NOT EXISTS promotions (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,
+    description VARCHAR(255),
+    discount_type ENUM('PERCENTAGE', 'FIXED') NOT NULL,
+    rate INT NOT NULL DEFAULT 0,
+    value BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL DEFAULT 'EUR',
+    valid_from TIMESTAMP NULL,
+    valid_until TIMESTAMP NULL,
+    max_uses INT NOT NULL DEFAULT 0,
+    max_uses_per_client INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- Promotion Packages Table (packages a promotion is limited to)
+CREATE TABLE IF NOT EXISTS promotion_packages (
+    promotion_id VARCHAR(36) NOT NULL,
+    package_id VARCHAR(36) NOT NULL,
+    PRIMARY KEY (promotion_id, package_id),
+    FOREIGN KEY (promotion_id) REFERENCES promotions(id) ON DELETE CASCADE,
+    FOREIGN KEY (package_id) REFERENCES packages(id) ON DELETE CASCADE
+);
+
+-- Billings recorded before were not discounted
+ALTER TABLE billings
+    ADD COLUMN original_price BIGINT NOT NULL AFTER amount,
+    ADD COLUMN discount BIGINT NOT NULL DEFAULT 0 AFTER original_price,
+    ADD COLUMN promotion_id VARCHAR(36) AFTER subscription_id,
+    ADD CONSTRAINT fk_billings_promotion FOREIGN KEY (promotion_id) REFERENCES promotions(id);
+
+UPDATE billings SET original_price = price;
+
+CREATE INDEX idx_billings_promotion ON billings(promotion_id, client_id);
@@ -0,0 +1,6 @@
+-- Only possible before any voucher was redeemed
+ALTER TABLE payments
+    MODIFY COLUMN method ENUM('CASH', 'CHEQUE', 'CARD', 'TRANSFER') NOT NULL;
+
+DROP TABLE IF EXISTS voucher_redemptions;
+DROP TABLE IF EXISTS vouchers;
@@ -0,0 +1,40 @@
+-- Vouchers Table (gift vouchers, money in cents)
+CREATE TABLE IF NOT EXISTS vouchers (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,
+    kind ENUM('MONEY', 'SESSIONS') NOT NULL,
+    value BIGINT NOT NULL DEFAULT 0,
+    balance BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL DEFAULT 'EUR',
+    sessions INT NOT NULL DEFAULT 0,
+    session_type ENUM('GROUP', 'PRIVATE'),
+    purchaser_name VARCHAR(200) NOT NULL,
+    purchaser_email VARCHAR(100),
+    purchaser_client_id VARCHAR(36),
+    status ENUM('ACTIVE', 'PARTIALLY_REDEEMED', 'REDEEMED', 'CANCELLED') NOT NULL DEFAULT 'ACTIVE',
+    purchased_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (purchaser_client_id) REFERENCES clients(id) ON DELETE SET NULL
+);
+
+-- Voucher Redemptions Table (each use of a voucher by a client)
+CREATE TABLE IF NOT EXISTS voucher_redemptions (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    voucher_id VARCHAR(36) NOT NULL,
+    client_id VARCHAR(36) NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL DEFAULT 'EUR',
+    sessions INT NOT NULL DEFAULT 0,
+    billing_id VARCHAR(36),
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+    FOREIGN KEY (voucher_id) REFERENCES vouchers(id),
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE,
+    FOREIGN KEY (billing_id) REFERENCES billings(id) ON DELETE SET NULL
+);
+
+ALTER TABLE payments
+    MODIFY COLUMN method ENUM('CASH', 'CHEQUE', 'CARD', 'TRANSFER', 'VOUCHER') NOT NULL;
+
+CREATE INDEX idx_voucher_redemptions_voucher ON voucher_redemptions(voucher_id, created_at);
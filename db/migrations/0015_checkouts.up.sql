@@ -0,0 +1,38 @@
+-- Checkouts Table (packages bought online, amounts in cents)
+CREATE TABLE IF NOT EXISTS checkouts (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    client_id VARCHAR(36) NOT NULL,
+    package_id VARCHAR(36) NOT NULL,
+    amount INT NOT NULL DEFAULT 1,
+    original_price BIGINT NOT NULL,
+    discount BIGINT NOT NULL DEFAULT 0,
+    price BIGINT NOT NULL,
+    refunded BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL DEFAULT 'EUR',
+    promotion_id VARCHAR(36),
+    status ENUM('PENDING', 'PAID', 'FAILED', 'REFUNDED') NOT NULL DEFAULT 'PENDING',
+    provider VARCHAR(50) NOT NULL,
+    provider_checkout_id VARCHAR(255) NOT NULL,
+    provider_payment_id VARCHAR(255),
+    url VARCHAR(2048) NOT NULL,
+    billing_id VARCHAR(36),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_checkouts_provider (provider, provider_checkout_id),
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE,
+    FOREIGN KEY (package_id) REFERENCES packages(id) ON DELETE CASCADE,
+    FOREIGN KEY (promotion_id) REFERENCES promotions(id),
+    FOREIGN KEY (billing_id) REFERENCES billings(id)
+);
+
+-- Payment Events Table (webhook events already handled, per provider)
+CREATE TABLE IF NOT EXISTS payment_events (
+    provider VARCHAR(50) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    type VARCHAR(100) NOT NULL,
+    checkout_id VARCHAR(255),
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, event_id)
+);
+
+CREATE INDEX idx_checkouts_client ON checkouts(client_id, created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS staff_sessions;
+DROP TABLE IF EXISTS staff_users;
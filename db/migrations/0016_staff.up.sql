@@ -0,0 +1,23 @@
+-- Staff Users Table (studio team accounts, passwords hashed with bcrypt)
+CREATE TABLE IF NOT EXISTS staff_users (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    email VARCHAR(100) NOT NULL UNIQUE,
+    name VARCHAR(200) NOT NULL,
+    role ENUM('OWNER', 'FRONT_DESK', 'INSTRUCTOR') NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_login_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- Staff Sessions Table (logged in staff, tokens stored as SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS staff_sessions (
+    token_hash CHAR(64) PRIMARY KEY,
+    staff_id VARCHAR(36) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (staff_id) REFERENCES staff_users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_staff_sessions_expiry ON staff_sessions(expires_at);
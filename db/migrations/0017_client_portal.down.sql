@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS client_sessions;
+DROP TABLE IF EXISTS client_logins;
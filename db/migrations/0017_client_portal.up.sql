@@ -0,0 +1,25 @@
+-- Client Logins Table (passwordless logins emailed to clients, codes and tokens stored as SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS client_logins (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    client_id VARCHAR(36) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE
+);
+
+-- Client Sessions Table (clients logged in to the portal, tokens stored as SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS client_sessions (
+    token_hash CHAR(64) PRIMARY KEY,
+    client_id VARCHAR(36) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_client_logins_client ON client_logins(client_id, created_at);
+CREATE INDEX idx_client_logins_expiry ON client_logins(expires_at);
+CREATE INDEX idx_client_sessions_expiry ON client_sessions(expires_at);
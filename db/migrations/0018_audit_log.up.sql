@@ -0,0 +1,20 @@
+-- Audit Log Table (append only, see the triggers below)
+CREATE TABLE IF NOT EXISTS audit_log (
+    id VARCHAR(36) DEFAULT (UUID()) PRIMARY KEY,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id VARCHAR(36) NOT NULL,
+    action ENUM('CREATE', 'UPDATE', 'DELETE') NOT NULL,
+    actor_type ENUM('STAFF', 'CLIENT', 'SYSTEM') NOT NULL,
+    actor_id VARCHAR(36) NULL,
+    request_id VARCHAR(64) NULL,
+    changes JSON NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log entries cannot be modified';
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log entries cannot be deleted';
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE cancellations
+    DROP INDEX uq_cancellations_appointment;
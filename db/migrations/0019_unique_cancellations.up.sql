@@ -0,0 +1,3 @@
+-- An appointment is cancelled once
+ALTER TABLE cancellations
+    ADD CONSTRAINT uq_cancellations_appointment UNIQUE (appointment_id);
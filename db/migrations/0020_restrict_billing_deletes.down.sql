@@ -0,0 +1,7 @@
+ALTER TABLE billings
+    DROP FOREIGN KEY fk_billings_client,
+    DROP FOREIGN KEY fk_billings_package;
+
+ALTER TABLE billings
+    ADD CONSTRAINT billings_ibfk_1 FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE CASCADE,
+    ADD CONSTRAINT billings_ibfk_2 FOREIGN KEY (package_id) REFERENCES packages(id) ON DELETE CASCADE;
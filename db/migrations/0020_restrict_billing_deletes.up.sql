@@ -0,0 +1,8 @@
+-- Billings are never deleted, so neither are their client and package
+ALTER TABLE billings
+    DROP FOREIGN KEY billings_ibfk_1,
+    DROP FOREIGN KEY billings_ibfk_2;
+
+ALTER TABLE billings
+    ADD CONSTRAINT fk_billings_client FOREIGN KEY (client_id) REFERENCES clients(id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_billings_package FOREIGN KEY (package_id) REFERENCES packages(id) ON DELETE RESTRICT;
@@ -0,0 +1,2 @@
+ALTER TABLE checkouts
+    DROP COLUMN expires_at;
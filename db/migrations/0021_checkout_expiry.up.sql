@@ -0,0 +1,10 @@
+-- Pending checkouts hold a use of their promotion until they expire, the
+-- checkouts already opened expiring 30 minutes after they were
+ALTER TABLE checkouts
+    ADD COLUMN expires_at TIMESTAMP NULL AFTER billing_id;
+
+UPDATE checkouts
+SET expires_at = created_at + INTERVAL 30 MINUTE;
+
+ALTER TABLE checkouts
+    MODIFY COLUMN expires_at TIMESTAMP NOT NULL;
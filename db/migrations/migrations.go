@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned SQL migrations of the database,
+// named NNNN_name.up.sql and NNNN_name.down.sql
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,16 @@
+-- Sample data for development databases, never applied by the migrations.
+-- Load it once the schema is up to date:
+--   align-back migrate up && mysql <database> < db/seed.sql
+
+INSERT INTO clients (firstname, lastname, phone, email) VALUES
+('Jane', 'Smith', '+33123456789', 'jane.smith@example.com'),
+('John', 'Doe', '+33987654321', 'john.doe@example.com');
+
+INSERT INTO packages (name, number_of_sessions, type, price, vat_rate, validity_days) VALUES
+('10 Group Sessions', 10, 'GROUP', 15000, 2000, 180),
+('5 Private Sessions', 5, 'PRIVATE', 25000, 2000, 90),
+('Unlimited Monthly', 0, 'MEMBERSHIP', 9000, 2000, 0);
+
+INSERT INTO classes (name, location, type, equipment) VALUES
+('Morning Pilates', 'CLAIRVIVRE', 'GROUP', 'Mat, resistance bands'),
+('Reformer Session', 'CUBJAC', 'PRIVATE', 'Reformer machine');
\ No newline at end of file
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// AccountingSettings holds the chart of accounts used to post billings to
+// the sales journal
+type AccountingSettings struct {
+	// FiscalYearStartMonth is the first month of a fiscal year. A fiscal
+	// year is named after the calendar year it closes in.
+	FiscalYearStartMonth time.Month
+	// SIREN identifies the studio in the name of the exported file
+	SIREN string
+
+	JournalCode            string
+	JournalLabel           string
+	SalesAccount           string
+	SalesAccountLabel      string
+	VATAccount             string
+	VATAccountLabel        string
+	ReceivableAccount      string
+	ReceivableAccountLabel string
+	// ClientAccountPrefix prefixes the client ID to form its auxiliary
+	// receivable account
+	ClientAccountPrefix string
+}
+
+// JournalLine is a line of an accounting entry. The lines of an entry share
+// its journal, number and dates, and their debits and credits balance.
+type JournalLine struct {
+	JournalCode     string
+	JournalLabel    string
+	EntryNumber     string
+	EntryDate       time.Time
+	AccountNumber   string
+	AccountLabel    string
+	AuxiliaryNumber string
+	AuxiliaryLabel  string
+	PieceRef        string
+	PieceDate       time.Time
+	Label           string
+	Debit           money.Money
+	Credit          money.Money
+	ValidatedAt     time.Time
+}
+
+// Journal is the journal of a fiscal year, from Start (inclusive) to End
+// (exclusive)
+type Journal struct {
+	SIREN string
+	Year  int
+	Start time.Time
+	End   time.Time
+	Lines []JournalLine
+}
+
+// Closing returns the last day of the fiscal year
+func (j *Journal) Closing() time.Time {
+	return j.End.AddDate(0, 0, -1)
+}
+
+// AccountingService defines methods for accounting exports
+type AccountingService interface {
+	GetSalesJournal(year int) (*Journal, error)
+}
@@ -1,16 +1,37 @@
 package domain
 
 import (
+	"context"
 	"time"
 )
 
+var (
+	// ErrScheduleFull is returned when a schedule has no slot left
+	ErrScheduleFull = NewError(ErrCapacityReached, "schedule_full", "schedule is full")
+	// ErrScheduleStarted is returned when booking a class that has already started
+	ErrScheduleStarted = NewError(ErrConflict, "schedule_started", "schedule has already started")
+	// ErrAlreadyBooked is returned when a client is already booked on a schedule
+	ErrAlreadyBooked = NewError(ErrConflict, "already_booked", "client is already booked on this schedule")
+	// ErrScheduleBooked is returned when deleting a schedule with appointments
+	ErrScheduleBooked = NewError(ErrConflict, "schedule_booked", "schedule has booked appointments, cancel them first")
+	// ErrOverrideReasonRequired is returned when staff override a policy without a reason
+	ErrOverrideReasonRequired = &Error{
+		Kind:    ErrValidation,
+		Code:    "override_reason_required",
+		Message: "a reason is required to override the cancellation policy",
+		Fields:  []FieldError{{Field: "reason", Message: "is required to override the cancellation policy"}},
+	}
+)
+
 // Appointment represents a client booking for a scheduled class
 type Appointment struct {
-	ID         string    `json:"id" db:"id"`
-	ScheduleID string    `json:"schedule_id" db:"schedule_id"`
-	ClientID   string    `json:"client_id" db:"client_id"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	ID         string `json:"id" db:"id"`
+	ScheduleID string `json:"schedule_id" db:"schedule_id"`
+	ClientID   string `json:"client_id" db:"client_id"`
+	// Set when the booking is covered by a subscription instead of a credit
+	SubscriptionID string    `json:"subscription_id,omitempty" db:"subscription_id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 
 	// Populated from joins
 	Client   *Client   `json:"client,omitempty" db:"-"`
@@ -34,11 +55,13 @@ type AppointmentInput struct {
 type AppointmentRepository interface {
 	GetAll() ([]Appointment, error)
 	GetByID(id string) (*Appointment, error)
+	GetByIDForUpdate(id string) (*Appointment, error)
 	GetByClient(clientID string) ([]Appointment, error)
 	GetBySchedule(scheduleID string) ([]Appointment, error)
 	GetByClientAndSchedule(clientID, scheduleID string) (*Appointment, error)
 	GetUpcomingByClient(clientID string) ([]Appointment, error)
 	GetWithDetails(id string) (*AppointmentWithDetails, error)
+	EachWithDetails(fn func(*AppointmentWithDetails) error) error
 	Create(appointment *Appointment) error
 	Update(appointment *Appointment) error
 	Delete(id string) error
@@ -53,7 +76,10 @@ type AppointmentService interface {
 	GetByScheduleID(scheduleID string) ([]Appointment, error)
 	GetUpcomingByClient(clientID string) ([]Appointment, error)
 	GetWithDetails(id string) (*AppointmentWithDetails, error)
-	Create(appointment *Appointment) error
-	Update(appointment *Appointment) error
-	Delete(id string) error
+	EachWithDetails(fn func(*AppointmentWithDetails) error) error
+	Create(ctx context.Context, appointment *Appointment) error
+	Update(ctx context.Context, appointment *Appointment) error
+	Delete(ctx context.Context, id string) error
+	Cancel(ctx context.Context, id string, input CancellationInput) (*Cancellation, error)
+	GetCancellationsByClient(clientID string) ([]Cancellation, error)
 }
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditAction represents the kind of change recorded in the audit log
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "CREATE"
+	AuditUpdate AuditAction = "UPDATE"
+	AuditDelete AuditAction = "DELETE"
+)
+
+// Entity types recorded in the audit log
+const (
+	AuditClient       = "client"
+	AuditPackage      = "package"
+	AuditClass        = "class"
+	AuditSchedule     = "schedule"
+	AuditSeries       = "series"
+	AuditAppointment  = "appointment"
+	AuditCancellation = "cancellation"
+	AuditWaitlist     = "waitlist"
+	AuditBilling      = "billing"
+	AuditPayment      = "payment"
+	AuditInvoice      = "invoice"
+	AuditSubscription = "subscription"
+	AuditPromotion    = "promotion"
+	AuditVoucher      = "voucher"
+	AuditCheckout     = "checkout"
+	AuditStaff        = "staff"
+)
+
+// ActorType represents who makes a change
+type ActorType string
+
+const (
+	// ActorStaff is a staff member using the API
+	ActorStaff ActorType = "STAFF"
+	// ActorClient is a client using the portal
+	ActorClient ActorType = "CLIENT"
+	// ActorSystem is the API itself, in background jobs and webhooks
+	ActorSystem ActorType = "SYSTEM"
+)
+
+// Actor is who makes a change recorded in the audit log
+type Actor struct {
+	Type ActorType `json:"type"`
+	ID   string    `json:"id,omitempty"`
+}
+
+// actorKey is the context key of the actor
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor of the changes made
+// with it
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by ctx, the system when there is none
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Type: ActorSystem}
+}
+
+// AuditEntry records a change of an entity. Changes maps the JSON fields
+// that changed to their values before and after. Entries are never updated
+// nor deleted.
+type AuditEntry struct {
+	ID         string          `json:"id" db:"id"`
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   string          `json:"entity_id" db:"entity_id"`
+	Action     AuditAction     `json:"action" db:"action"`
+	ActorType  ActorType       `json:"actor_type" db:"actor_type"`
+	ActorID    string          `json:"actor_id,omitempty" db:"actor_id"`
+	RequestID  string          `json:"request_id,omitempty" db:"request_id"`
+	Changes    json.RawMessage `json:"changes" db:"changes"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditChange is the value of a field before and after a change. Before is
+// missing on creation and After on deletion.
+type AuditChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries. The entity ID is optional; entries are
+// returned newest first, up to Limit.
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	Limit      int
+}
+
+// AuditRepository defines methods for audit log persistence. There is
+// deliberately no way to update or delete entries.
+type AuditRepository interface {
+	Create(entry *AuditEntry) error
+	Find(filter AuditFilter) ([]AuditEntry, error)
+}
+
+// AuditService defines business logic for the audit log
+type AuditService interface {
+	Find(filter AuditFilter) ([]AuditEntry, error)
+}
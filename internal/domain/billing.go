@@ -1,52 +1,117 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
 
-// Billing represents a payment for a package
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// BillingKind represents whether a billing is a payment or a refund
+type BillingKind string
+
+const (
+	PaymentBilling BillingKind = "PAYMENT"
+	RefundBilling  BillingKind = "REFUND"
+)
+
+var (
+	// ErrRefundExceedsBilling is returned when refunding more money or
+	// credits than a billing has left
+	ErrRefundExceedsBilling = NewError(ErrConflict, "refund_exceeds_billing", "refund exceeds what is left on the billing")
+	// ErrClientBilled is returned when deleting a client with billings
+	ErrClientBilled = NewError(ErrConflict, "client_billed", "client has billings, which are never deleted")
+	// ErrPackageBilled is returned when deleting a package with billings
+	ErrPackageBilled = NewError(ErrConflict, "package_billed", "package has billings, which are never deleted")
+)
+
+// Billing represents a payment for a package. Price is the gross amount
+// paid, split into NetPrice and VAT at the VATRate of the package when it was
+// billed. Refunds are billings of kind REFUND with negative amounts and
+// credits, referencing the refunded payment. Billings of a subscription period
+// reference the subscription and grant no credits. OriginalPrice is the
+// gross price before the Discount of a promotion, if any, and Price what is
+// left to pay after it.
 type Billing struct {
-	ID          string    `json:"id" db:"id"`
-	ClientID    string    `json:"client_id" db:"client_id"`
-	PackageID   string    `json:"package_id" db:"package_id"`
-	Amount      int       `json:"amount" db:"amount"`
-	Price       float64   `json:"price" db:"price"`
-	Credits     int       `json:"credits" db:"credits"`
-	PaymentDate time.Time `json:"payment_date" db:"payment_date"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID                string      `json:"id" db:"id"`
+	Kind              BillingKind `json:"kind" db:"kind"`
+	ClientID          string      `json:"client_id" db:"client_id"`
+	PackageID         string      `json:"package_id" db:"package_id"`
+	Amount            int         `json:"amount" db:"amount"`
+	OriginalPrice     money.Money `json:"original_price" db:"-"`
+	Discount          money.Money `json:"discount" db:"-"`
+	Price             money.Money `json:"price" db:"-"`
+	NetPrice          money.Money `json:"net_price" db:"-"`
+	VAT               money.Money `json:"vat" db:"-"`
+	VATRate           int         `json:"vat_rate" db:"vat_rate"`
+	Credits           int         `json:"credits" db:"credits"`
+	PaymentDate       time.Time   `json:"payment_date" db:"payment_date"`
+	RefundedBillingID string      `json:"refunded_billing_id,omitempty" db:"refunded_billing_id"`
+	SubscriptionID    string      `json:"subscription_id,omitempty" db:"subscription_id"`
+	PromotionID       string      `json:"promotion_id,omitempty" db:"promotion_id"`
+	Reason            string      `json:"reason,omitempty" db:"reason"`
+	CreatedAt         time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at" db:"updated_at"`
 
 	// Populated from joins
 	Client  *Client  `json:"client,omitempty" db:"-"`
 	Package *Package `json:"package,omitempty" db:"-"`
 }
 
-// BillingWithDetails includes the client and package details
+// BillingWithDetails includes the client and package details, and the
+// invoice or credit note once issued
 type BillingWithDetails struct {
 	Billing *Billing `json:"billing"`
 	Client  *Client  `json:"client"`
 	Package *Package `json:"package"`
+	Invoice *Invoice `json:"invoice,omitempty"`
 }
 
-// BillingInput is used for creating/updating billings
+// BillingInput is used for creating/updating billings. Credits and VAT are
+// derived from the package and the amount. Price is the gross amount due, in
+// the currency of the package when none is given, before the discount of the
+// promotion with PromotionCode, if any, valid at the payment date. Payments
+// already received, such as a first installment, are recorded on creation
+// only.
 type BillingInput struct {
-	ClientID    string    `json:"client_id" validate:"required,uuid"`
-	PackageID   string    `json:"package_id" validate:"required,uuid"`
-	Amount      int       `json:"amount" validate:"required,min=1"`
-	Price       float64   `json:"price" validate:"required,min=0"`
-	Credits     int       `json:"credits" validate:"required,min=1"`
-	PaymentDate time.Time `json:"payment_date"`
+	ClientID      string         `json:"client_id" validate:"required,uuid"`
+	PackageID     string         `json:"package_id" validate:"required,uuid"`
+	Amount        int            `json:"amount" validate:"required,min=1"`
+	Price         money.Money    `json:"price" validate:"required"`
+	PaymentDate   time.Time      `json:"payment_date"`
+	PromotionCode string         `json:"promotion_code"`
+	Payments      []PaymentInput `json:"payments"`
+}
+
+// RefundInput is used for refunding a billing. Without a price the whole
+// remaining gross amount is refunded, while a zero price refunds credits
+// only. Without credits, credits are taken back in
+// proportion to the refunded price. With a method, the refunded price is paid
+// back to the client at once.
+type RefundInput struct {
+	Price     *money.Money  `json:"price"`
+	Credits   *int          `json:"credits" validate:"omitempty,min=0"`
+	Reason    string        `json:"reason" validate:"required"`
+	Method    PaymentMethod `json:"method" validate:"omitempty,oneof=CASH CHEQUE CARD TRANSFER"`
+	Reference string        `json:"reference"`
 }
 
 // BillingRepository defines methods for billing persistence
 type BillingRepository interface {
 	GetAll() ([]Billing, error)
 	GetByID(id string) (*Billing, error)
+	GetByIDForUpdate(id string) (*Billing, error)
 	GetByClient(clientID string) ([]Billing, error)
+	GetRefunds(billingID string) ([]Billing, error)
+	CountByClient(clientID string) (int, error)
+	CountByPackage(packageID string) (int, error)
 	GetRecent(limit int) ([]Billing, error)
 	GetWithDetails(id string) (*BillingWithDetails, error)
 	GetAllWithDetails() ([]BillingWithDetails, error)
+	GetDetailsByPeriod(start, end time.Time) ([]BillingWithDetails, error)
+	EachWithDetails(fn func(*BillingWithDetails) error) error
 	Create(billing *Billing) error
 	Update(billing *Billing) error
-	Delete(id string) error
 }
 
 // BillingService defines methods for billing business logic
@@ -57,7 +122,9 @@ type BillingService interface {
 	GetRecent(limit int) ([]Billing, error)
 	GetWithDetails(id string) ([]BillingWithDetails, error)
 	GetAllWithDetails() ([]BillingWithDetails, error)
-	Create(input BillingInput) error
-	Update(id string, billing BillingInput) (*Billing, error)
-	Delete(id string) error
+	EachWithDetails(fn func(*BillingWithDetails) error) error
+	Create(ctx context.Context, input BillingInput) error
+	Update(ctx context.Context, id string, billing BillingInput) (*Billing, error)
+	Refund(ctx context.Context, id string, input RefundInput) (*Billing, error)
+	GetRefunds(id string) ([]Billing, error)
 }
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// Cancellation records a cancelled appointment and whether its credit was refunded
+type Cancellation struct {
+	ID             string    `json:"id" db:"id"`
+	AppointmentID  string    `json:"appointment_id" db:"appointment_id"`
+	ScheduleID     string    `json:"schedule_id" db:"schedule_id"`
+	ClientID       string    `json:"client_id" db:"client_id"`
+	ClassType      ClassType `json:"class_type" db:"class_type"`
+	Late           bool      `json:"late" db:"late"`
+	Refunded       bool      `json:"refunded" db:"refunded"`
+	OverrideReason string    `json:"override_reason,omitempty" db:"override_reason"`
+	CancelledAt    time.Time `json:"cancelled_at" db:"cancelled_at"`
+}
+
+// CancellationInput is used for cancelling appointments. Staff can override
+// the policy by setting Refund, in which case Reason is required.
+type CancellationInput struct {
+	Refund *bool  `json:"refund"`
+	Reason string `json:"reason"`
+}
+
+// CancellationPolicy gives the late cancellation window of a class
+type CancellationPolicy interface {
+	Window(location Location, classType ClassType) time.Duration
+}
+
+// CancellationRepository defines methods for cancellation persistence
+type CancellationRepository interface {
+	GetByClient(clientID string) ([]Cancellation, error)
+	Create(cancellation *Cancellation) error
+}
@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// CheckoutStatus represents where an online payment stands
+type CheckoutStatus string
+
+const (
+	// PendingCheckout is waiting for the client to pay on the provider page
+	PendingCheckout CheckoutStatus = "PENDING"
+	// PaidCheckout is confirmed by the provider and billed
+	PaidCheckout CheckoutStatus = "PAID"
+	// FailedCheckout was declined, abandoned or expired
+	FailedCheckout CheckoutStatus = "FAILED"
+	// RefundedCheckout has been paid back in full
+	RefundedCheckout CheckoutStatus = "REFUNDED"
+)
+
+// PaymentEventType represents what a payment provider notifies
+type PaymentEventType string
+
+const (
+	PaymentSucceededEvent PaymentEventType = "payment.succeeded"
+	PaymentFailedEvent    PaymentEventType = "payment.failed"
+)
+
+var (
+	// ErrInvalidSignature is returned when a webhook payload is not signed
+	// with the secret shared with the payment provider
+	ErrInvalidSignature = NewError(ErrUnauthorized, "invalid_signature", "invalid webhook signature")
+	// ErrCheckoutStatus is returned when a checkout is not in a status
+	// allowing the change, e.g. refunding a checkout that was never paid
+	ErrCheckoutStatus = NewError(ErrConflict, "checkout_status", "checkout status does not allow this change")
+)
+
+// Checkout is a package bought online by a client. The price, discounted by
+// a promotion if any, is set when the checkout is opened; the billing is
+// created and the credits granted only once the provider confirms the
+// payment. The provider no longer takes the payment after ExpiresAt.
+type Checkout struct {
+	ID                 string         `json:"id" db:"id"`
+	ClientID           string         `json:"client_id" db:"client_id"`
+	PackageID          string         `json:"package_id" db:"package_id"`
+	Amount             int            `json:"amount" db:"amount"`
+	OriginalPrice      money.Money    `json:"original_price" db:"-"`
+	Discount           money.Money    `json:"discount" db:"-"`
+	Price              money.Money    `json:"price" db:"-"`
+	Refunded           money.Money    `json:"refunded" db:"-"`
+	PromotionID        string         `json:"promotion_id,omitempty" db:"promotion_id"`
+	Status             CheckoutStatus `json:"status" db:"status"`
+	Provider           string         `json:"provider" db:"provider"`
+	ProviderCheckoutID string         `json:"provider_checkout_id" db:"provider_checkout_id"`
+	ProviderPaymentID  string         `json:"provider_payment_id,omitempty" db:"provider_payment_id"`
+	URL                string         `json:"url" db:"url"`
+	BillingID          string         `json:"billing_id,omitempty" db:"billing_id"`
+	ExpiresAt          time.Time      `json:"expires_at" db:"expires_at"`
+	CreatedAt          time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// CheckoutInput is used for opening a checkout. The client is sent back to
+// SuccessURL or CancelURL when leaving the provider page.
+type CheckoutInput struct {
+	ClientID      string `json:"client_id" validate:"required,uuid"`
+	PackageID     string `json:"package_id" validate:"required,uuid"`
+	Amount        int    `json:"amount" validate:"min=0"`
+	PromotionCode string `json:"promotion_code"`
+	SuccessURL    string `json:"success_url" validate:"required,url"`
+	CancelURL     string `json:"cancel_url" validate:"required,url"`
+}
+
+// CheckoutRequest is what a payment provider needs to open a checkout.
+// Reference is the ID of the checkout on our side.
+type CheckoutRequest struct {
+	Reference   string
+	Description string
+	Price       money.Money
+	Email       string
+	SuccessURL  string
+	CancelURL   string
+	ExpiresAt   time.Time
+}
+
+// ProviderCheckout is a checkout as known by the payment provider. PaymentID
+// identifies the payment once made, for refunds.
+type ProviderCheckout struct {
+	ID        string
+	URL       string
+	Status    CheckoutStatus
+	PaymentID string
+}
+
+// PaymentEvent is a notification of a payment provider about a checkout. ID
+// is unique per event, so that deliveries retried by the provider are only
+// handled once.
+type PaymentEvent struct {
+	ID         string           `json:"id"`
+	Type       PaymentEventType `json:"type"`
+	CheckoutID string           `json:"checkout_id"`
+	PaymentID  string           `json:"payment_id,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// PaymentProvider takes payments online on behalf of the studio
+type PaymentProvider interface {
+	// Name identifies the provider in stored checkouts and events
+	Name() string
+	// CreateCheckout opens a checkout the client pays on at its URL
+	CreateCheckout(request CheckoutRequest) (*ProviderCheckout, error)
+	// GetCheckout fetches the current status of a checkout
+	GetCheckout(id string) (*ProviderCheckout, error)
+	// Refund pays back part or all of a payment
+	Refund(paymentID string, amount money.Money) error
+	// ParseEvent checks the signature of a webhook payload and returns the
+	// event it holds, failing with ErrInvalidSignature
+	ParseEvent(payload []byte, signature string) (*PaymentEvent, error)
+}
+
+// CheckoutRepository defines methods for checkout persistence
+type CheckoutRepository interface {
+	GetAll() ([]Checkout, error)
+	GetByID(id string) (*Checkout, error)
+	GetByIDForUpdate(id string) (*Checkout, error)
+	GetByProviderIDForUpdate(provider string, providerCheckoutID string) (*Checkout, error)
+	Create(checkout *Checkout) error
+	Update(checkout *Checkout) error
+	// RecordEvent records an event as handled, returning false when it
+	// already was
+	RecordEvent(provider string, event *PaymentEvent) (bool, error)
+}
+
+// CheckoutService defines methods for online payment business logic
+type CheckoutService interface {
+	GetAll() ([]Checkout, error)
+	GetByID(id string) (*Checkout, error)
+	Create(ctx context.Context, input CheckoutInput) (*Checkout, error)
+	HandleEvent(ctx context.Context, payload []byte, signature string) error
+	Sync(ctx context.Context, id string) (*Checkout, error)
+	Refund(ctx context.Context, id string, input RefundInput) (*Billing, error)
+}
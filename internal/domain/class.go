@@ -1,9 +1,14 @@
 package domain
 
 import (
+	"context"
 	"time"
 )
 
+// ErrClassNameExists is returned when creating or renaming a class with a
+// name already in use
+var ErrClassNameExists = NewError(ErrConflict, "class_name_exists", "class name already in use")
+
 // Location represents the location where a class is held
 type Location string
 
@@ -57,7 +62,7 @@ type ClassService interface {
 	GetByID(id string) (*Class, error)
 	GetByType(classType ClassType) ([]Class, error)
 	GetByLocation(location Location) ([]Class, error)
-	Create(input ClassInput) error
-	Update(id string, input ClassInput) (*Class, error)
-	Delete(id string) error
+	Create(ctx context.Context, input ClassInput) error
+	Update(ctx context.Context, id string, input ClassInput) (*Class, error)
+	Delete(ctx context.Context, id string) error
 }
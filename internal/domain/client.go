@@ -1,6 +1,13 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// ErrClientEmailExists is returned when creating or updating a client with
+// an email already in use by another client
+var ErrClientEmailExists = NewError(ErrConflict, "client_email_exists", "client email already in use")
 
 // Client represents a pilates client
 type Client struct {
@@ -38,25 +45,29 @@ type ClientInput struct {
 // ClientRepository defines methods for client persistence
 type ClientRepository interface {
 	GetAll() ([]Client, error)
+	Each(fn func(*Client) error) error
 	GetByID(id string) (*Client, error)
+	GetByIDForUpdate(id string) (*Client, error)
 	GetByEmail(email string) (*Client, error)
 	GetLowGroupCredits(threshold int) ([]Client, error)
 	GetLowPrivateCredits(threshold int) ([]Client, error)
 	Create(client *Client) error
 	Update(client *Client) error
+	UpdateCredits(client *Client) error
 	Delete(id string) error
 }
 
 // ClientService defines business logic for clients
 type ClientService interface {
 	GetAll() ([]Client, error)
+	Each(fn func(*Client) error) error
 	GetByID(id string) (*Client, error)
-	Create(input ClientInput) error
-	Update(id string, input ClientInput) (*Client, error)
-	Delete(id string) error
+	Create(ctx context.Context, input ClientInput) error
+	Update(ctx context.Context, id string, input ClientInput) (*Client, error)
+	Delete(ctx context.Context, id string) error
 	GetByEmail(email string) (*Client, error)
 	GetLowGroupCredits(threshold int) ([]Client, error)
 	GetLowPrivateCredits(threshold int) ([]Client, error)
-	UpdateGroupCredits(id string, groupCredits int) error
-	UpdatePrivateCredits(id string, privateCredits int) error
+	UpdateGroupCredits(ctx context.Context, id string, groupCredits int) error
+	UpdatePrivateCredits(ctx context.Context, id string, privateCredits int) error
 }
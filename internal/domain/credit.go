@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// CreditType represents the kind of sessions a credit can be spent on
+type CreditType string
+
+const (
+	GroupCredit   CreditType = "GROUP"
+	PrivateCredit CreditType = "PRIVATE"
+)
+
+// CreditEntryKind represents the reason of a credit ledger entry
+type CreditEntryKind string
+
+const (
+	CreditGrant      CreditEntryKind = "GRANT"
+	CreditDebit      CreditEntryKind = "DEBIT"
+	CreditRefund     CreditEntryKind = "REFUND"
+	CreditAdjustment CreditEntryKind = "ADJUSTMENT"
+	CreditExpiry     CreditEntryKind = "EXPIRY"
+)
+
+// SystemActor is the actor of ledger entries made automatically
+const SystemActor = "system"
+
+// CreditEntry is an append-only credit ledger entry. The client credit
+// counters are the running sum of these entries.
+type CreditEntry struct {
+	ID           string          `json:"id" db:"id"`
+	ClientID     string          `json:"client_id" db:"client_id"`
+	Type         CreditType      `json:"type" db:"type"`
+	Kind         CreditEntryKind `json:"kind" db:"kind"`
+	Delta        int             `json:"delta" db:"delta"`
+	BalanceAfter int             `json:"balance_after" db:"balance_after"`
+	Reason       string          `json:"reason" db:"reason"`
+	Actor        string          `json:"actor" db:"actor"`
+	ReferenceID  string          `json:"reference_id,omitempty" db:"reference_id"`
+	LotID        string          `json:"lot_id,omitempty" db:"lot_id"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+}
+
+// CreditLot is a batch of credits granted together, usually by a billing.
+// Bookings consume the lot expiring soonest first; the remaining credits of a
+// lot are retired once it expires. Lots without ExpiresAt never expire.
+type CreditLot struct {
+	ID        string     `json:"id" db:"id"`
+	ClientID  string     `json:"client_id" db:"client_id"`
+	BillingID string     `json:"billing_id,omitempty" db:"billing_id"`
+	Type      CreditType `json:"type" db:"type"`
+	Credits   int        `json:"credits" db:"credits"`
+	Remaining int        `json:"remaining" db:"remaining"`
+	ExpiresAt *time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreditBalance is a client's balance as derived from the ledger
+type CreditBalance struct {
+	ClientID       string `json:"client_id" db:"client_id"`
+	GroupCredits   int    `json:"group_credits" db:"group_credits"`
+	PrivateCredits int    `json:"private_credits" db:"private_credits"`
+}
+
+// CreditAdjustmentInput is used for manual credit adjustments
+type CreditAdjustmentInput struct {
+	Type   CreditType `json:"type" validate:"required,oneof=GROUP PRIVATE"`
+	Delta  int        `json:"delta" validate:"required"`
+	Reason string     `json:"reason" validate:"required"`
+}
+
+// CreditLedgerRepository defines methods for credit ledger persistence
+type CreditLedgerRepository interface {
+	GetByClient(clientID string) ([]CreditEntry, error)
+	GetBalance(clientID string) (*CreditBalance, error)
+	GetAllBalances() ([]CreditBalance, error)
+	GetByReference(referenceID string) ([]CreditEntry, error)
+	CountByClient(clientID string) (int, error)
+	Create(entry *CreditEntry) error
+}
+
+// CreditLotRepository defines methods for credit lot persistence
+type CreditLotRepository interface {
+	GetByClient(clientID string) ([]CreditLot, error)
+	GetByIDForUpdate(id string) (*CreditLot, error)
+	GetByBillingForUpdate(billingID string) (*CreditLot, error)
+	GetAvailableForUpdate(clientID string, creditType CreditType, at time.Time) ([]CreditLot, error)
+	GetExpiredForUpdate(clientID string, at time.Time) ([]CreditLot, error)
+	GetClientsWithExpired(at time.Time) ([]string, error)
+	Create(lot *CreditLot) error
+	Update(lot *CreditLot) error
+}
+
+// CreditService defines methods for credit business logic
+type CreditService interface {
+	GetHistory(clientID string) ([]CreditEntry, error)
+	GetBalance(clientID string) (*CreditBalance, error)
+	Adjust(ctx context.Context, clientID string, input CreditAdjustmentInput) (*CreditEntry, error)
+	GetLots(clientID string) ([]CreditLot, error)
+	Reconcile(ctx context.Context) ([]CreditBalance, error)
+	ExpireAll(ctx context.Context) ([]CreditEntry, error)
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// Dashboard gathers what the front page shows at a glance. MonthToDate only
+// sums the billings in the currency of the dashboard.
+type Dashboard struct {
+	GeneratedAt       time.Time             `json:"generated_at"`
+	Today             []ScheduleWithDetails `json:"today"`
+	ThisWeek          []ScheduleWithDetails `json:"this_week"`
+	LowGroupCredits   []Client              `json:"low_group_credits"`
+	LowPrivateCredits []Client              `json:"low_private_credits"`
+	RecentPayments    []Payment             `json:"recent_payments"`
+	MonthToDate       MonthlyRevenue        `json:"month_to_date"`
+	EmptyClasses      []ScheduleWithDetails `json:"empty_classes"`
+}
+
+// DashboardSettings holds what the dashboard shows and how long it is cached
+type DashboardSettings struct {
+	CacheTTL           time.Duration
+	LowCreditThreshold int
+	RecentPayments     int
+	EmptyClassDays     int
+	Currency           string
+}
+
+// DashboardService defines methods for the dashboard
+type DashboardService interface {
+	Get() (*Dashboard, error)
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is a category of domain errors, deciding e.g. the HTTP status of a
+// response. Errors of a kind match it with errors.Is, and a kind is itself
+// an error for failures needing no more specific code.
+type Kind string
+
+const (
+	// ErrNotFound is the kind of errors about missing entities
+	ErrNotFound Kind = "not_found"
+	// ErrConflict is the kind of errors about changes the current state of
+	// an entity does not allow
+	ErrConflict Kind = "conflict"
+	// ErrValidation is the kind of errors about invalid input
+	ErrValidation Kind = "validation_failed"
+	// ErrInsufficientCredits is returned when a client has too few credits
+	ErrInsufficientCredits Kind = "insufficient_credits"
+	// ErrCapacityReached is the kind of errors about full schedules
+	ErrCapacityReached Kind = "capacity_reached"
+	// ErrUnauthorized is the kind of errors about invalid credentials
+	ErrUnauthorized Kind = "unauthorized"
+)
+
+// Error returns the kind in plain words, e.g. "not found"
+func (k Kind) Error() string {
+	return strings.ReplaceAll(string(k), "_", " ")
+}
+
+// FieldError is the reason why an input field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error of a kind with a stable code, e.g. for API
+// clients. Validation errors list the invalid fields.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+// NewError creates a domain error of a kind
+func NewError(kind Kind, code string, message string) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// NewValidationError creates the error of an invalid input field
+func NewValidationError(field string, format string, args ...interface{}) *Error {
+	message := fmt.Sprintf(format, args...)
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    string(ErrValidation),
+		Message: field + " " + message,
+		Fields:  []FieldError{{Field: field, Message: message}},
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether the error is of the target kind
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
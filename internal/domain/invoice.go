@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// ErrInvoiceIssued is returned when changing a billing whose invoice has
+// already been issued
+var ErrInvoiceIssued = NewError(ErrConflict, "invoice_issued", "billing has an issued invoice")
+
+// InvoiceKind represents whether an invoice is an invoice or a credit note
+type InvoiceKind string
+
+const (
+	InvoiceDocument    InvoiceKind = "INVOICE"
+	CreditNoteDocument InvoiceKind = "CREDIT_NOTE"
+)
+
+// Numbering series of invoices and credit notes
+const (
+	InvoiceSeries    = ""
+	CreditNoteSeries = "AV"
+)
+
+// Invoice is the invoice issued for a billing, or the credit note (avoir)
+// issued for a refund. Each kind is numbered without gaps per year and never
+// changes once issued, so the seller and buyer details are copied at issue
+// time.
+type Invoice struct {
+	ID        string      `json:"id" db:"id"`
+	Kind      InvoiceKind `json:"kind" db:"kind"`
+	Number    string      `json:"number" db:"number"`
+	Year      int         `json:"year" db:"year"`
+	Sequence  int         `json:"sequence" db:"sequence"`
+	BillingID string      `json:"billing_id" db:"billing_id"`
+	ClientID  string      `json:"client_id" db:"client_id"`
+	IssuedAt  time.Time   `json:"issued_at" db:"issued_at"`
+	PaidAt    time.Time   `json:"paid_at" db:"paid_at"`
+
+	SellerName          string `json:"seller_name" db:"seller_name"`
+	SellerAddress       string `json:"seller_address" db:"seller_address"`
+	SellerSIRET         string `json:"seller_siret" db:"seller_siret"`
+	SellerVATNumber     string `json:"seller_vat_number" db:"seller_vat_number"`
+	SellerContact       string `json:"seller_contact" db:"seller_contact"`
+	SellerLegalMentions string `json:"seller_legal_mentions" db:"seller_legal_mentions"`
+
+	BuyerName    string `json:"buyer_name" db:"buyer_name"`
+	BuyerAddress string `json:"buyer_address" db:"buyer_address"`
+
+	// Credit notes reference the invoice they correct
+	CorrectedInvoiceID     string `json:"corrected_invoice_id,omitempty" db:"corrected_invoice_id"`
+	CorrectedInvoiceNumber string `json:"corrected_invoice_number,omitempty" db:"corrected_invoice_number"`
+	Reason                 string `json:"reason,omitempty" db:"reason"`
+
+	// Totals excluding VAT, of VAT and including VAT
+	NetTotal  money.Money   `json:"net_total" db:"-"`
+	VATTotal  money.Money   `json:"vat_total" db:"-"`
+	Total     money.Money   `json:"total" db:"-"`
+	Lines     []InvoiceLine `json:"lines" db:"-"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// InvoiceLine is a line of an invoice. Prices exclude VAT.
+type InvoiceLine struct {
+	ID          string      `json:"id" db:"id"`
+	InvoiceID   string      `json:"invoice_id" db:"invoice_id"`
+	Position    int         `json:"position" db:"position"`
+	Description string      `json:"description" db:"description"`
+	Quantity    int         `json:"quantity" db:"quantity"`
+	UnitPrice   money.Money `json:"unit_price" db:"-"`
+	VATRate     int         `json:"vat_rate" db:"vat_rate"`
+	Total       money.Money `json:"total" db:"-"`
+}
+
+// InvoiceIssuer holds the studio legal details printed on invoices
+type InvoiceIssuer struct {
+	Name          string
+	Address       string
+	SIRET         string
+	VATNumber     string
+	Contact       string
+	LegalMentions string
+}
+
+// InvoiceNumber formats the number of an invoice in a series, e.g.
+// 2026-000123 or AV-2026-000004
+func InvoiceNumber(series string, year, sequence int) string {
+	if series == "" {
+		return fmt.Sprintf("%d-%06d", year, sequence)
+	}
+	return fmt.Sprintf("%s-%d-%06d", series, year, sequence)
+}
+
+// InvoiceRepository defines methods for invoice persistence. Invoices are
+// only ever created.
+type InvoiceRepository interface {
+	GetByID(id string) (*Invoice, error)
+	GetByBilling(billingID string) (*Invoice, error)
+	NextSequence(series string, year int) (int, error)
+	Create(invoice *Invoice) error
+}
+
+// InvoiceService defines methods for invoice business logic
+type InvoiceService interface {
+	GetByBilling(billingID string) (*Invoice, error)
+	Issue(ctx context.Context, billingID string) (*Invoice, error)
+}
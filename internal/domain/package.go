@@ -1,6 +1,15 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// ErrPackageNameExists is returned when creating or renaming a package with
+// a name already in use
+var ErrPackageNameExists = NewError(ErrConflict, "package_name_exists", "package name already in use")
 
 // PackageType represents the type of package
 type PackageType string
@@ -8,15 +17,23 @@ type PackageType string
 const (
 	GroupPackage   PackageType = "GROUP"
 	PrivatePackage PackageType = "PRIVATE"
+	// MembershipPackage is a plan subscribed to for unlimited group
+	// classes, priced per month and granting no credits
+	MembershipPackage PackageType = "MEMBERSHIP"
 )
 
-// Package represents a Pilates session Package
+// Package represents a Pilates session Package. Its credits expire
+// ValidityDays after the payment, or never when ValidityDays is 0. Price
+// includes VAT at VATRate, in basis points (2000 is 20%). Membership packages
+// are plans priced per month that are subscribed to rather than billed.
 type Package struct {
 	ID               string      `json:"id" db:"id"`
 	Name             string      `json:"name" db:"name"`
 	NumberOfSessions int         `json:"number_of_sessions" db:"number_of_sessions"`
 	Type             PackageType `json:"type" db:"type"`
-	Price            float64     `json:"price" db:"price"`
+	Price            money.Money `json:"price" db:"-"`
+	VATRate          int         `json:"vat_rate" db:"vat_rate"`
+	ValidityDays     int         `json:"validity_days" db:"validity_days"`
 	CreatedAt        time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time   `json:"updated_at" db:"updated_at"`
 }
@@ -25,8 +42,10 @@ type Package struct {
 type PackageInput struct {
 	Name             string      `json:"name" validate:"required"`
 	NumberOfSessions int         `json:"number_of_sessions" validate:"required,min=1"`
-	Type             PackageType `json:"type" validate:"required,oneof=GROUP PRIVATE"`
-	Price            float64     `json:"price" validate:"required,min=0"`
+	Type             PackageType `json:"type" validate:"required,oneof=GROUP PRIVATE MEMBERSHIP"`
+	Price            money.Money `json:"price" validate:"required"`
+	VATRate          int         `json:"vat_rate" validate:"min=0,max=10000"`
+	ValidityDays     int         `json:"validity_days" validate:"min=0"`
 }
 
 // PackageRepository defines methods for package persistence
@@ -46,7 +65,7 @@ type PackageService interface {
 	GetByID(id string) (*Package, error)
 	GetByName(name string) (*Package, error)
 	GetByType(pkgType PackageType) ([]Package, error)
-	Create(input PackageInput) error
-	Update(id string, input PackageInput) (*Package, error)
-	Delete(id string) error
+	Create(ctx context.Context, input PackageInput) error
+	Update(ctx context.Context, id string, input PackageInput) (*Package, error)
+	Delete(ctx context.Context, id string) error
 }
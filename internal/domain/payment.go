@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// PaymentMethod represents how a payment was made
+type PaymentMethod string
+
+const (
+	CashPayment     PaymentMethod = "CASH"
+	ChequePayment   PaymentMethod = "CHEQUE"
+	CardPayment     PaymentMethod = "CARD"
+	TransferPayment PaymentMethod = "TRANSFER"
+	// VoucherPayment is the part of a billing paid with a gift voucher,
+	// recorded when redeeming the voucher
+	VoucherPayment PaymentMethod = "VOUCHER"
+)
+
+// PaymentStatus represents how much of a billing has been paid
+type PaymentStatus string
+
+const (
+	UnpaidStatus   PaymentStatus = "UNPAID"
+	PartialStatus  PaymentStatus = "PARTIAL"
+	PaidStatus     PaymentStatus = "PAID"
+	OverpaidStatus PaymentStatus = "OVERPAID"
+)
+
+// ErrPaymentExceedsBalance is returned when a payment is more than what is
+// left to pay, or to pay back, on a billing
+var ErrPaymentExceedsBalance = NewError(ErrConflict, "payment_exceeds_balance", "payment exceeds the outstanding balance")
+
+// Payment is money received for a billing, one per installment. Money paid
+// back for a refund is a payment of the refund with a negative amount.
+type Payment struct {
+	ID        string        `json:"id" db:"id"`
+	BillingID string        `json:"billing_id" db:"billing_id"`
+	ClientID  string        `json:"client_id" db:"client_id"`
+	Method    PaymentMethod `json:"method" db:"method"`
+	Amount    money.Money   `json:"amount" db:"-"`
+	PaidAt    time.Time     `json:"paid_at" db:"paid_at"`
+	Reference string        `json:"reference,omitempty" db:"reference"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// PaymentInput is used for recording a payment. Reference holds e.g. the
+// cheque number or the transfer label.
+type PaymentInput struct {
+	Method    PaymentMethod `json:"method" validate:"required,oneof=CASH CHEQUE CARD TRANSFER"`
+	Amount    money.Money   `json:"amount" validate:"required"`
+	PaidAt    time.Time     `json:"paid_at"`
+	Reference string        `json:"reference"`
+}
+
+// BillingBalance is what is left to pay on a billing. Refunds lower what is
+// due and the money paid back for them lowers what was paid, so a negative
+// outstanding amount is owed to the client.
+type BillingBalance struct {
+	BillingID   string        `json:"billing_id" db:"billing_id"`
+	ClientID    string        `json:"client_id" db:"client_id"`
+	PaymentDate time.Time     `json:"payment_date" db:"payment_date"`
+	Due         money.Money   `json:"due" db:"-"`
+	Paid        money.Money   `json:"paid" db:"-"`
+	Outstanding money.Money   `json:"outstanding" db:"-"`
+	Status      PaymentStatus `json:"status" db:"-"`
+}
+
+// ClientBalance sums the balances of the billings of a client
+type ClientBalance struct {
+	ClientID    string           `json:"client_id"`
+	Due         money.Money      `json:"due"`
+	Paid        money.Money      `json:"paid"`
+	Outstanding money.Money      `json:"outstanding"`
+	Billings    []BillingBalance `json:"billings"`
+}
+
+// PaymentStatusOf returns the status of a billing given what is due and
+// what was paid
+func PaymentStatusOf(due, paid money.Money) PaymentStatus {
+	switch {
+	case paid.Amount > due.Amount:
+		return OverpaidStatus
+	case paid.Amount == due.Amount:
+		return PaidStatus
+	case paid.Amount > 0:
+		return PartialStatus
+	default:
+		return UnpaidStatus
+	}
+}
+
+// PaymentRepository defines methods for payment persistence. Balances of a
+// billing include its refunds and their payments.
+type PaymentRepository interface {
+	GetByBilling(billingID string) ([]Payment, error)
+	GetByClient(clientID string) ([]Payment, error)
+	GetRecent(limit int) ([]Payment, error)
+	GetBalance(billingID string) (*BillingBalance, error)
+	GetBalancesByClient(clientID string) ([]BillingBalance, error)
+	GetOutstanding() ([]BillingBalance, error)
+	Create(payment *Payment) error
+}
+
+// PaymentService defines methods for payment business logic
+type PaymentService interface {
+	GetByBilling(billingID string) ([]Payment, error)
+	GetByClient(clientID string) ([]Payment, error)
+	Record(ctx context.Context, billingID string, input PaymentInput) (*Payment, error)
+	GetBalance(billingID string) (*BillingBalance, error)
+	GetClientBalance(clientID string) (*ClientBalance, error)
+	GetOutstanding(status PaymentStatus) ([]BillingBalance, error)
+}
@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ErrInvalidLoginCode is returned when verifying a client login with a code
+// or link that is wrong, expired, already used or tried too many times
+var ErrInvalidLoginCode = NewError(ErrUnauthorized, "invalid_login_code", "invalid or expired login code")
+
+// ClientLogin is a passwordless login sent to the email of a client: a short
+// code to type or a link carrying a token, either one usable once before it
+// expires. Only their hashes are stored.
+type ClientLogin struct {
+	ID        string     `json:"id" db:"id"`
+	ClientID  string     `json:"client_id" db:"client_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	Attempts  int        `json:"attempts" db:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ClientSession is a client logged in to the portal. Token is only known
+// when the session is opened: the hash is stored instead.
+type ClientSession struct {
+	Token     string    `json:"token" db:"-"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	ClientID  string    `json:"client_id" db:"client_id"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	Client    *Client   `json:"client,omitempty" db:"-"`
+}
+
+// PortalLoginInput is used for requesting a login email
+type PortalLoginInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PortalVerifyInput is used for logging in with the code of a login email,
+// along with the email, or with the token of its link
+type PortalVerifyInput struct {
+	Email string `json:"email" validate:"omitempty,email"`
+	Code  string `json:"code"`
+	Token string `json:"token"`
+}
+
+// PortalBookingInput is used for a client booking a class
+type PortalBookingInput struct {
+	ScheduleID string `json:"schedule_id" validate:"required,uuid"`
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// ClientLoginRepository defines methods for client login and session
+// persistence
+type ClientLoginRepository interface {
+	CreateLogin(login *ClientLogin) error
+	GetPendingLoginForUpdate(clientID string, at time.Time) (*ClientLogin, error)
+	GetLoginByTokenForUpdate(tokenHash string) (*ClientLogin, error)
+	CountLoginsSince(clientID string, since time.Time) (int, error)
+	UpdateLogin(login *ClientLogin) error
+	CreateSession(session *ClientSession) error
+	GetSession(tokenHash string) (*ClientSession, error)
+	DeleteSession(tokenHash string) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+// PortalService defines methods for clients managing their own account.
+// Every operation is scoped to the client ID of the session.
+type PortalService interface {
+	RequestLogin(input PortalLoginInput) error
+	Verify(ctx context.Context, input PortalVerifyInput) (*ClientSession, error)
+	Logout(token string) error
+	Authenticate(token string) (*Client, error)
+	GetCredits(clientID string) (*CreditBalance, error)
+	GetUpcomingAppointments(clientID string) ([]Appointment, error)
+	GetBillings(clientID string) ([]Billing, error)
+	GetSchedule(date time.Time) ([]Schedule, error)
+	Book(ctx context.Context, clientID string, input PortalBookingInput) (*Appointment, error)
+	Cancel(ctx context.Context, clientID string, appointmentID string) (*Cancellation, error)
+	PurgeExpired() (int64, error)
+}
@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// DiscountType represents how a promotion discounts a price
+type DiscountType string
+
+const (
+	PercentageDiscount DiscountType = "PERCENTAGE"
+	FixedDiscount      DiscountType = "FIXED"
+)
+
+var (
+	// ErrUnknownPromotion is returned when billing with a code that matches
+	// no promotion
+	ErrUnknownPromotion = &Error{
+		Kind:    ErrValidation,
+		Code:    "unknown_promotion",
+		Message: "unknown promotion code",
+		Fields:  []FieldError{{Field: "promotion_code", Message: "matches no promotion"}},
+	}
+	// ErrPromotionNotApplicable is returned when a promotion is inactive, out
+	// of its validity dates, used up or not valid for the package
+	ErrPromotionNotApplicable = NewError(ErrConflict, "promotion_not_applicable", "promotion code cannot be applied")
+	// ErrPromotionCodeExists is returned when creating a promotion with a
+	// code already in use
+	ErrPromotionCodeExists = NewError(ErrConflict, "promotion_code_exists", "promotion code already exists")
+)
+
+// Promotion is a discount applied to a billing with its code. Percentage
+// discounts take Rate, in basis points (2000 is 20%), off the price; fixed
+// discounts take Value off, up to the whole price.
+//
+// A promotion is valid from ValidFrom until ValidUntil, both optional, on
+// the packages listed in PackageIDs, or on every package when none are
+// listed. MaxUses and MaxUsesPerClient limit the number of billings using
+// it, with 0 for no limit. Uses counts the billings that used it so far,
+// refunded or not.
+type Promotion struct {
+	ID               string       `json:"id" db:"id"`
+	Code             string       `json:"code" db:"code"`
+	Description      string       `json:"description,omitempty" db:"description"`
+	DiscountType     DiscountType `json:"discount_type" db:"discount_type"`
+	Rate             int          `json:"rate,omitempty" db:"rate"`
+	Value            money.Money  `json:"value" db:"-"`
+	ValidFrom        *time.Time   `json:"valid_from,omitempty" db:"valid_from"`
+	ValidUntil       *time.Time   `json:"valid_until,omitempty" db:"valid_until"`
+	MaxUses          int          `json:"max_uses" db:"max_uses"`
+	MaxUsesPerClient int          `json:"max_uses_per_client" db:"max_uses_per_client"`
+	PackageIDs       []string     `json:"package_ids" db:"-"`
+	Active           bool         `json:"active" db:"active"`
+	Uses             int          `json:"uses" db:"uses"`
+	CreatedAt        time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// ValidAt reports whether a time is within the validity dates of a
+// promotion
+func (p *Promotion) ValidAt(t time.Time) bool {
+	if p.ValidFrom != nil && t.Before(*p.ValidFrom) {
+		return false
+	}
+	return p.ValidUntil == nil || t.Before(*p.ValidUntil)
+}
+
+// AppliesTo reports whether a promotion can be used on a package
+func (p *Promotion) AppliesTo(packageID string) bool {
+	if len(p.PackageIDs) == 0 {
+		return true
+	}
+
+	for _, id := range p.PackageIDs {
+		if id == packageID {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscountOn returns the discount of a promotion on a gross price, never
+// more than the price itself
+func (p *Promotion) DiscountOn(price money.Money) money.Money {
+	discount := money.New(p.Value.Amount, price.Currency)
+	if p.DiscountType == PercentageDiscount {
+		discount = price.Mul(int64(p.Rate)).Div(10000)
+	}
+
+	if discount.Amount > price.Amount {
+		return price
+	}
+	return discount
+}
+
+// PromotionInput is used for creating/updating promotions. Codes are
+// matched regardless of case. Promotions are active when created unless
+// stated otherwise, and fixed values are in euros when no currency is given.
+type PromotionInput struct {
+	Code             string       `json:"code" validate:"required"`
+	Description      string       `json:"description"`
+	DiscountType     DiscountType `json:"discount_type" validate:"required,oneof=PERCENTAGE FIXED"`
+	Rate             int          `json:"rate" validate:"min=0,max=10000"`
+	Value            money.Money  `json:"value"`
+	ValidFrom        *time.Time   `json:"valid_from"`
+	ValidUntil       *time.Time   `json:"valid_until"`
+	MaxUses          int          `json:"max_uses" validate:"min=0"`
+	MaxUsesPerClient int          `json:"max_uses_per_client" validate:"min=0"`
+	PackageIDs       []string     `json:"package_ids" validate:"dive,uuid"`
+	Active           *bool        `json:"active"`
+}
+
+// PromotionRepository defines methods for promotion persistence
+type PromotionRepository interface {
+	GetAll() ([]Promotion, error)
+	GetByID(id string) (*Promotion, error)
+	GetByCode(code string) (*Promotion, error)
+	GetByCodeForUpdate(code string) (*Promotion, error)
+	CountUses(promotionID, clientID, excludedBillingID string) (total int, byClient int, err error)
+	Create(promotion *Promotion) error
+	Update(promotion *Promotion) error
+}
+
+// PromotionService defines methods for promotion business logic
+type PromotionService interface {
+	GetAll() ([]Promotion, error)
+	GetByID(id string) (*Promotion, error)
+	Create(ctx context.Context, input PromotionInput) (*Promotion, error)
+	Update(ctx context.Context, id string, input PromotionInput) (*Promotion, error)
+}
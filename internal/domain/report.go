@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// ReportFilter restricts a report to dates from From (inclusive) to To
+// (exclusive). A zero bound leaves the range open on that side. Months,
+// weekdays and times of day are reported in Location, UTC when nil.
+type ReportFilter struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+}
+
+// MonthlyRevenue is the revenue billed in a month, e.g. 2026-01, net of
+// refunds, and the discounts promotions took off it
+type MonthlyRevenue struct {
+	Month     string      `json:"month" db:"month"`
+	Billings  int         `json:"billings" db:"billings"`
+	Refunds   int         `json:"refunds" db:"refunds"`
+	Discounts money.Money `json:"discounts" db:"-"`
+	Net       money.Money `json:"net" db:"-"`
+	VAT       money.Money `json:"vat" db:"-"`
+	Gross     money.Money `json:"gross" db:"-"`
+}
+
+// LocationRevenue is the value of the sessions booked at a location, each
+// worth the price of a credit of the package it was paid with
+type LocationRevenue struct {
+	Location Location    `json:"location" db:"location"`
+	Sessions int         `json:"sessions" db:"sessions"`
+	Net      money.Money `json:"net" db:"-"`
+	Gross    money.Money `json:"gross" db:"-"`
+}
+
+// PackageTypeRevenue is the revenue billed for a package type, net of
+// refunds
+type PackageTypeRevenue struct {
+	Type     PackageType `json:"type" db:"type"`
+	Billings int         `json:"billings" db:"billings"`
+	Net      money.Money `json:"net" db:"-"`
+	VAT      money.Money `json:"vat" db:"-"`
+	Gross    money.Money `json:"gross" db:"-"`
+}
+
+// PaymentMethodRevenue is the money collected with a payment method, net of
+// the money paid back
+type PaymentMethodRevenue struct {
+	Method   PaymentMethod `json:"method" db:"method"`
+	Payments int           `json:"payments" db:"payments"`
+	Amount   money.Money   `json:"amount" db:"-"`
+}
+
+// SessionCount is the number of sessions held in a month and their bookings
+type SessionCount struct {
+	Month    string `json:"month" db:"month"`
+	Sessions int    `json:"sessions" db:"sessions"`
+	Capacity int    `json:"capacity" db:"capacity"`
+	Booked   int    `json:"booked" db:"booked"`
+}
+
+// ClassFillRate is the share of the capacity of a class that was booked
+type ClassFillRate struct {
+	ClassID   string   `json:"class_id" db:"class_id"`
+	ClassName string   `json:"class_name" db:"class_name"`
+	Location  Location `json:"location" db:"location"`
+	Sessions  int      `json:"sessions" db:"sessions"`
+	Capacity  int      `json:"capacity" db:"capacity"`
+	Booked    int      `json:"booked" db:"booked"`
+	FillRate  float64  `json:"fill_rate" db:"fill_rate"`
+}
+
+// SlotFillRate is the share of the capacity booked for the sessions starting
+// on a weekday (1 is Monday) at a time of day, e.g. 18:30
+type SlotFillRate struct {
+	Weekday  int     `json:"weekday" db:"weekday"`
+	Time     string  `json:"time" db:"time"`
+	Sessions int     `json:"sessions" db:"sessions"`
+	Capacity int     `json:"capacity" db:"capacity"`
+	Booked   int     `json:"booked" db:"booked"`
+	FillRate float64 `json:"fill_rate" db:"fill_rate"`
+}
+
+// ReportRepository defines aggregate queries over billings, payments and
+// schedules
+type ReportRepository interface {
+	GetRevenueByMonth(filter ReportFilter) ([]MonthlyRevenue, error)
+	GetRevenueByLocation(filter ReportFilter) ([]LocationRevenue, error)
+	GetRevenueByPackageType(filter ReportFilter) ([]PackageTypeRevenue, error)
+	GetRevenueByPaymentMethod(filter ReportFilter) ([]PaymentMethodRevenue, error)
+	GetSessionCounts(filter ReportFilter) ([]SessionCount, error)
+	GetFillRateByClass(filter ReportFilter) ([]ClassFillRate, error)
+	GetFillRateBySlot(filter ReportFilter) ([]SlotFillRate, error)
+}
+
+// ReportService defines methods for reporting
+type ReportService interface {
+	GetRevenueByMonth(filter ReportFilter) ([]MonthlyRevenue, error)
+	GetRevenueByLocation(filter ReportFilter) ([]LocationRevenue, error)
+	GetRevenueByPackageType(filter ReportFilter) ([]PackageTypeRevenue, error)
+	GetRevenueByPaymentMethod(filter ReportFilter) ([]PaymentMethodRevenue, error)
+	GetSessionCounts(filter ReportFilter) ([]SessionCount, error)
+	GetFillRateByClass(filter ReportFilter) ([]ClassFillRate, error)
+	GetFillRateBySlot(filter ReportFilter) ([]SlotFillRate, error)
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Schedule represents a scheduled class
 type Schedule struct {
@@ -11,6 +14,12 @@ type Schedule struct {
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 
+	// Set on occurrences generated from a series. Detached occurrences were
+	// edited individually and are no longer changed by series updates.
+	SeriesID       string `json:"series_id,omitempty" db:"series_id"`
+	OccurrenceDate string `json:"occurrence_date,omitempty" db:"occurrence_date"`
+	Detached       bool   `json:"detached,omitempty" db:"detached"`
+
 	// Populated from joins
 	Class       *Class `json:"class,omitempty" db:"-"`
 	BookedCount int    `json:"booked_count,omitempty" db:"-"`
@@ -35,15 +44,20 @@ type ScheduleInput struct {
 type ScheduleRepository interface {
 	GetAll() ([]Schedule, error)
 	GetByID(id string) (*Schedule, error)
+	GetByIDForUpdate(id string) (*Schedule, error)
 	GetByDate(date time.Time) ([]Schedule, error)
 	GetByDateRange(startDate, endDate time.Time) ([]Schedule, error)
 	GetByClass(classID string) ([]Schedule, error)
+	GetBySeries(seriesID string) ([]Schedule, error)
 	GetUpcoming(limit int) ([]Schedule, error)
 	GetWithDetails(id string) (*ScheduleWithDetails, error)
 	GetAllWithDetails() ([]ScheduleWithDetails, error)
+	EachWithDetails(fn func(*ScheduleWithDetails) error) error
+	GetDetailsByDateRange(startDate, endDate time.Time) ([]ScheduleWithDetails, error)
 	Create(schedule *Schedule) error
 	Update(schedule *Schedule) error
 	Delete(id string) error
+	DeleteUnbookedBySeries(seriesID string, from time.Time) error
 }
 
 // ScheduleService defines methods for schedule business logic
@@ -56,7 +70,8 @@ type ScheduleService interface {
 	GetUpcoming(limit int) ([]Schedule, error)
 	GetWithDetails(id string) (*ScheduleWithDetails, error)
 	GetAllWithDetails() ([]ScheduleWithDetails, error)
-	Create(input ScheduleInput) (*Schedule, error)
-	Update(id string, input ScheduleInput) (*Schedule, error)
-	Delete(id string) error
+	EachWithDetails(fn func(*ScheduleWithDetails) error) error
+	Create(ctx context.Context, input ScheduleInput) (*Schedule, error)
+	Update(ctx context.Context, id string, input ScheduleInput) (*Schedule, error)
+	Delete(ctx context.Context, id string) error
 }
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduleSeries represents a recurring class from which Schedule rows are
+// generated over a rolling horizon
+type ScheduleSeries struct {
+	ID       string    `json:"id" db:"id"`
+	ClassID  string    `json:"class_id" db:"class_id"`
+	Capacity int       `json:"capacity" db:"capacity"`
+	DTStart  time.Time `json:"dtstart" db:"dtstart"`
+	RRule    string    `json:"rrule" db:"rrule"`
+	// ExDates are the excluded occurrence dates (YYYY-MM-DD)
+	ExDates []string `json:"exdates" db:"-"`
+	// EndsAt caps the series when it was split by a "this and following" edit
+	EndsAt    *time.Time `json:"ends_at,omitempty" db:"ends_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ScheduleSeriesInput is used for creating/updating series. The recurrence is
+// either given as an RRULE, or built from Weekdays with an End date or a Count.
+type ScheduleSeriesInput struct {
+	ClassID  string     `json:"class_id" validate:"required,uuid"`
+	Capacity int        `json:"capacity" validate:"required,min=1"`
+	Start    time.Time  `json:"start" validate:"required"`
+	RRule    string     `json:"rrule"`
+	Weekdays []string   `json:"weekdays"`
+	End      *time.Time `json:"end"`
+	Count    int        `json:"count"`
+	ExDates  []string   `json:"exdates"`
+}
+
+// ScheduleSeriesUpdateInput is used for updating series. When From is set,
+// only the occurrences from that date on are changed ("this and following"),
+// otherwise all the upcoming occurrences are.
+type ScheduleSeriesUpdateInput struct {
+	ScheduleSeriesInput
+	From *time.Time `json:"from"`
+}
+
+// ScheduleSeriesRepository defines methods for series persistence
+type ScheduleSeriesRepository interface {
+	GetAll() ([]ScheduleSeries, error)
+	GetByID(id string) (*ScheduleSeries, error)
+	Create(series *ScheduleSeries) error
+	Update(series *ScheduleSeries) error
+	Delete(id string) error
+}
+
+// ScheduleSeriesService defines methods for series business logic
+type ScheduleSeriesService interface {
+	GetAll() ([]ScheduleSeries, error)
+	GetByID(id string) (*ScheduleSeries, error)
+	GetOccurrences(id string) ([]Schedule, error)
+	Create(ctx context.Context, input ScheduleSeriesInput) (*ScheduleSeries, error)
+	Update(ctx context.Context, id string, input ScheduleSeriesUpdateInput) (*ScheduleSeries, error)
+	Delete(ctx context.Context, id string) error
+	GenerateAll(ctx context.Context) (int, error)
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Role represents what a staff member is allowed to do
+type Role string
+
+const (
+	// OwnerRole manages everything, including billing and staff accounts
+	OwnerRole Role = "OWNER"
+	// FrontDeskRole manages clients, bookings and sales but not billing
+	FrontDeskRole Role = "FRONT_DESK"
+	// InstructorRole only reads the schedule and attendance
+	InstructorRole Role = "INSTRUCTOR"
+)
+
+var (
+	// ErrInvalidCredentials is returned when logging in with an unknown
+	// email, a wrong password or an inactive account, without telling which
+	ErrInvalidCredentials = NewError(ErrUnauthorized, "invalid_credentials", "invalid email or password")
+	// ErrInvalidSession is returned when authenticating with a token that is
+	// unknown, expired or belongs to an inactive or deleted account
+	ErrInvalidSession = NewError(ErrUnauthorized, "invalid_session", "invalid or expired session")
+	// ErrStaffEmailExists is returned when creating a staff account with an
+	// email already in use
+	ErrStaffEmailExists = NewError(ErrConflict, "staff_email_exists", "staff email already in use")
+	// ErrLastOwner is returned when demoting or deactivating the last active
+	// owner, which would leave nobody able to manage staff
+	ErrLastOwner = NewError(ErrConflict, "last_owner", "the last active owner cannot be demoted or deactivated")
+)
+
+// StaffUser is an account of a member of the studio team. Accounts are
+// deactivated rather than deleted.
+type StaffUser struct {
+	ID           string     `json:"id" db:"id"`
+	Email        string     `json:"email" db:"email"`
+	Name         string     `json:"name" db:"name"`
+	Role         Role       `json:"role" db:"role"`
+	PasswordHash string     `json:"-" db:"password_hash"`
+	Active       bool       `json:"active" db:"active"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// StaffInput is used for creating/updating staff accounts. The password is
+// required on creation only, and left unchanged on update when empty.
+// Accounts are active unless stated otherwise.
+type StaffInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Name     string `json:"name" validate:"required"`
+	Role     Role   `json:"role" validate:"required,oneof=OWNER FRONT_DESK INSTRUCTOR"`
+	Password string `json:"password"`
+	Active   *bool  `json:"active"`
+}
+
+// LoginInput is used for logging in a staff member
+type LoginInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// StaffSession is a logged in staff member. Token is only known when the
+// session is opened: the hash is stored instead.
+type StaffSession struct {
+	Token     string     `json:"token" db:"-"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	StaffID   string     `json:"staff_id" db:"staff_id"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	Staff     *StaffUser `json:"staff,omitempty" db:"-"`
+}
+
+// StaffRepository defines methods for staff account and session persistence
+type StaffRepository interface {
+	GetAll() ([]StaffUser, error)
+	GetByID(id string) (*StaffUser, error)
+	GetByEmail(email string) (*StaffUser, error)
+	Create(user *StaffUser) error
+	Update(user *StaffUser) error
+	UpdateLastLogin(id string, at time.Time) error
+	CreateSession(session *StaffSession) error
+	GetSession(tokenHash string) (*StaffSession, error)
+	DeleteSession(tokenHash string) error
+	DeleteSessions(staffID string) error
+	DeleteExpiredSessions(before time.Time) (int64, error)
+}
+
+// StaffService defines methods for staff accounts and authentication
+type StaffService interface {
+	GetAll() ([]StaffUser, error)
+	GetByID(id string) (*StaffUser, error)
+	Create(ctx context.Context, input StaffInput) (*StaffUser, error)
+	Update(ctx context.Context, id string, input StaffInput) (*StaffUser, error)
+	Login(input LoginInput) (*StaffSession, error)
+	Logout(token string) error
+	Authenticate(token string) (*StaffUser, error)
+	PurgeSessions() (int64, error)
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// SubscriptionStatus represents the state of a subscription
+type SubscriptionStatus string
+
+const (
+	ActiveSubscription    SubscriptionStatus = "ACTIVE"
+	PausedSubscription    SubscriptionStatus = "PAUSED"
+	CancelledSubscription SubscriptionStatus = "CANCELLED"
+)
+
+// BillingPeriod represents how often a subscription is billed
+type BillingPeriod string
+
+const (
+	MonthlyPeriod   BillingPeriod = "MONTHLY"
+	QuarterlyPeriod BillingPeriod = "QUARTERLY"
+	YearlyPeriod    BillingPeriod = "YEARLY"
+)
+
+// Months returns the number of months in a billing period, or 0 for an
+// unknown period
+func (p BillingPeriod) Months() int {
+	switch p {
+	case MonthlyPeriod:
+		return 1
+	case QuarterlyPeriod:
+		return 3
+	case YearlyPeriod:
+		return 12
+	}
+	return 0
+}
+
+var (
+	// ErrAlreadySubscribed is returned when subscribing a client who
+	// already has a subscription running
+	ErrAlreadySubscribed = NewError(ErrConflict, "already_subscribed", "client already has a subscription")
+	// ErrSubscriptionStatus is returned when a subscription cannot change
+	// from its current status
+	ErrSubscriptionStatus = NewError(ErrConflict, "subscription_status", "subscription cannot change from its current status")
+)
+
+// Subscription is a membership to a plan, a package of type MEMBERSHIP whose
+// price is per month. Each billing period is billed when it starts, for the
+// number of months of the period. While active, the member books group
+// classes without using credits.
+//
+// A cancelled subscription keeps running until the end of the current
+// period. A paused one cannot be used to book and is not renewed; resuming
+// it extends the current period by the time it was paused.
+type Subscription struct {
+	ID                 string             `json:"id" db:"id"`
+	ClientID           string             `json:"client_id" db:"client_id"`
+	PackageID          string             `json:"package_id" db:"package_id"`
+	Status             SubscriptionStatus `json:"status" db:"status"`
+	Period             BillingPeriod      `json:"period" db:"period"`
+	StartDate          time.Time          `json:"start_date" db:"start_date"`
+	CurrentPeriodStart time.Time          `json:"current_period_start" db:"current_period_start"`
+	CurrentPeriodEnd   time.Time          `json:"current_period_end" db:"current_period_end"`
+	CancelAtPeriodEnd  bool               `json:"cancel_at_period_end" db:"cancel_at_period_end"`
+	PausedAt           *time.Time         `json:"paused_at,omitempty" db:"paused_at"`
+	CancelledAt        *time.Time         `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CreatedAt          time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// Covers reports whether a subscription lets its member book a class
+// starting at a time without using credits. Only the period already paid is
+// covered: classes after it need the subscription to be renewed first.
+func (s *Subscription) Covers(classDatetime time.Time) bool {
+	if s.Status != ActiveSubscription || classDatetime.Before(s.StartDate) {
+		return false
+	}
+
+	return classDatetime.Before(s.CurrentPeriodEnd)
+}
+
+// SubscriptionRenewal is a subscription due to be billed again, with the
+// price of its next period
+type SubscriptionRenewal struct {
+	Subscription *Subscription `json:"subscription"`
+	Client       *Client       `json:"client"`
+	Package      *Package      `json:"package"`
+	RenewsAt     time.Time     `json:"renews_at"`
+	Price        money.Money   `json:"price"`
+}
+
+// SubscriptionInput is used for subscribing a client to a plan. The
+// subscription starts now when no start date is given and is billed monthly
+// when no period is given.
+type SubscriptionInput struct {
+	ClientID  string        `json:"client_id" validate:"required,uuid"`
+	PackageID string        `json:"package_id" validate:"required,uuid"`
+	Period    BillingPeriod `json:"period" validate:"omitempty,oneof=MONTHLY QUARTERLY YEARLY"`
+	StartDate time.Time     `json:"start_date"`
+}
+
+// SubscriptionRepository defines methods for subscription persistence
+type SubscriptionRepository interface {
+	GetAll() ([]Subscription, error)
+	GetByID(id string) (*Subscription, error)
+	GetByIDForUpdate(id string) (*Subscription, error)
+	GetByClient(clientID string) ([]Subscription, error)
+	GetCurrentByClient(clientID string) (*Subscription, error)
+	GetDue(at time.Time) ([]Subscription, error)
+	GetRenewals(until time.Time) ([]SubscriptionRenewal, error)
+	Create(subscription *Subscription) error
+	Update(subscription *Subscription) error
+}
+
+// SubscriptionService defines methods for subscription business logic
+type SubscriptionService interface {
+	GetAll() ([]Subscription, error)
+	GetByID(id string) (*Subscription, error)
+	GetByClient(clientID string) ([]Subscription, error)
+	GetRenewals(days int) ([]SubscriptionRenewal, error)
+	Create(ctx context.Context, input SubscriptionInput) (*Subscription, error)
+	Pause(ctx context.Context, id string) (*Subscription, error)
+	Resume(ctx context.Context, id string) (*Subscription, error)
+	Cancel(ctx context.Context, id string) (*Subscription, error)
+	RenewAll(ctx context.Context) (int, error)
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscriptionCovers(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name              string
+		status            SubscriptionStatus
+		cancelAtPeriodEnd bool
+		classDatetime     time.Time
+		want              bool
+	}{
+		{"within the period", ActiveSubscription, false, start.AddDate(0, 0, 10), true},
+		{"at the period start", ActiveSubscription, false, start, true},
+		{"before the start", ActiveSubscription, false, start.Add(-time.Hour), false},
+		{"at the period end", ActiveSubscription, false, end, false},
+		{"past the period end", ActiveSubscription, false, end.AddDate(0, 2, 0), false},
+		{"cancelled at period end, within", ActiveSubscription, true, end.Add(-time.Hour), true},
+		{"cancelled at period end, past", ActiveSubscription, true, end.Add(time.Hour), false},
+		{"paused", PausedSubscription, false, start.AddDate(0, 0, 10), false},
+		{"cancelled", CancelledSubscription, false, start.AddDate(0, 0, 10), false},
+	}
+
+	for _, tt := range tests {
+		subscription := &Subscription{
+			Status:             tt.status,
+			StartDate:          start,
+			CurrentPeriodStart: start,
+			CurrentPeriodEnd:   end,
+			CancelAtPeriodEnd:  tt.cancelAtPeriodEnd,
+		}
+
+		if got := subscription.Covers(tt.classDatetime); got != tt.want {
+			t.Errorf("%s: Covers(%s) = %v, want %v", tt.name, tt.classDatetime, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "context"
+
+// UnitOfWork gives access to repositories bound to a single database
+// transaction. Context carries who makes the changes, for the audit log.
+type UnitOfWork interface {
+	Context() context.Context
+	Clients() ClientRepository
+	Credits() CreditLedgerRepository
+	CreditLots() CreditLotRepository
+	Packages() PackageRepository
+	Billings() BillingRepository
+	Invoices() InvoiceRepository
+	Payments() PaymentRepository
+	Classes() ClassRepository
+	Schedules() ScheduleRepository
+	Series() ScheduleSeriesRepository
+	Appointments() AppointmentRepository
+	Cancellations() CancellationRepository
+	Waitlist() WaitlistRepository
+	Subscriptions() SubscriptionRepository
+	Promotions() PromotionRepository
+	Vouchers() VoucherRepository
+	Checkouts() CheckoutRepository
+	ClientLogins() ClientLoginRepository
+	Staff() StaffRepository
+	Audit() AuditRepository
+}
+
+// Transactor runs a unit of work inside a database transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(uow UnitOfWork) error) error
+}
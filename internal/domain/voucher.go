@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// VoucherKind represents what a gift voucher is worth
+type VoucherKind string
+
+const (
+	// MoneyVoucher is worth an amount of money, spent on packages in one or
+	// more redemptions
+	MoneyVoucher VoucherKind = "MONEY"
+	// SessionsVoucher is worth a number of sessions of a package type,
+	// billed and granted as credits in a single redemption
+	SessionsVoucher VoucherKind = "SESSIONS"
+)
+
+// VoucherStatus represents how much of a gift voucher has been redeemed
+type VoucherStatus string
+
+const (
+	ActiveVoucher            VoucherStatus = "ACTIVE"
+	PartiallyRedeemedVoucher VoucherStatus = "PARTIALLY_REDEEMED"
+	RedeemedVoucher          VoucherStatus = "REDEEMED"
+	CancelledVoucher         VoucherStatus = "CANCELLED"
+)
+
+var (
+	// ErrUnknownVoucher is returned when redeeming a code that matches no
+	// voucher
+	ErrUnknownVoucher = NewError(ErrNotFound, "unknown_voucher", "unknown voucher code")
+	// ErrVoucherNotRedeemable is returned when redeeming a voucher that is
+	// expired, cancelled or already fully redeemed
+	ErrVoucherNotRedeemable = NewError(ErrConflict, "voucher_not_redeemable", "voucher cannot be redeemed")
+	// ErrVoucherCodeExists is returned when creating a voucher with a code
+	// already in use
+	ErrVoucherCodeExists = NewError(ErrConflict, "voucher_code_exists", "voucher code already exists")
+)
+
+// Voucher is a gift voucher bought by a purchaser, who may not be a client,
+// for someone else to redeem before it expires. Money vouchers are worth
+// Value and pay for packages until their Balance is spent; sessions vouchers
+// are sold for Value and worth a number of Sessions of a SessionType.
+type Voucher struct {
+	ID                string        `json:"id" db:"id"`
+	Code              string        `json:"code" db:"code"`
+	Kind              VoucherKind   `json:"kind" db:"kind"`
+	Value             money.Money   `json:"value" db:"-"`
+	Balance           money.Money   `json:"balance" db:"-"`
+	Sessions          int           `json:"sessions,omitempty" db:"sessions"`
+	SessionType       PackageType   `json:"session_type,omitempty" db:"session_type"`
+	PurchaserName     string        `json:"purchaser_name" db:"purchaser_name"`
+	PurchaserEmail    string        `json:"purchaser_email,omitempty" db:"purchaser_email"`
+	PurchaserClientID string        `json:"purchaser_client_id,omitempty" db:"purchaser_client_id"`
+	Status            VoucherStatus `json:"status" db:"status"`
+	PurchasedAt       time.Time     `json:"purchased_at" db:"purchased_at"`
+	ExpiresAt         time.Time     `json:"expires_at" db:"expires_at"`
+	CreatedAt         time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at" db:"updated_at"`
+
+	// Populated when getting a single voucher
+	Redemptions []VoucherRedemption `json:"redemptions,omitempty" db:"-"`
+}
+
+// Redeemable reports whether a voucher can still be redeemed at a time
+func (v *Voucher) Redeemable(at time.Time) bool {
+	if v.Status != ActiveVoucher && v.Status != PartiallyRedeemedVoucher {
+		return false
+	}
+	return at.Before(v.ExpiresAt)
+}
+
+// VoucherRedemption is a use of a voucher by a client. Money redemptions
+// pay part or all of the billing of a package with Amount; sessions
+// redemptions bill the Sessions of the voucher as credits, paid with its
+// Amount.
+type VoucherRedemption struct {
+	ID        string      `json:"id" db:"id"`
+	VoucherID string      `json:"voucher_id" db:"voucher_id"`
+	ClientID  string      `json:"client_id" db:"client_id"`
+	Amount    money.Money `json:"amount" db:"-"`
+	Sessions  int         `json:"sessions,omitempty" db:"sessions"`
+	BillingID string      `json:"billing_id,omitempty" db:"billing_id"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+}
+
+// VoucherInput is used for selling a voucher. Value is what a money voucher
+// is worth, or the price of a sessions voucher. A code is generated when
+// none is given, and vouchers expire a year after purchase unless stated
+// otherwise. Values are in euros when no currency is given.
+type VoucherInput struct {
+	Code              string      `json:"code"`
+	Kind              VoucherKind `json:"kind" validate:"required,oneof=MONEY SESSIONS"`
+	Value             money.Money `json:"value"`
+	Sessions          int         `json:"sessions" validate:"min=0"`
+	SessionType       PackageType `json:"session_type" validate:"omitempty,oneof=GROUP PRIVATE"`
+	PurchaserName     string      `json:"purchaser_name" validate:"required"`
+	PurchaserEmail    string      `json:"purchaser_email" validate:"omitempty,email"`
+	PurchaserClientID string      `json:"purchaser_client_id" validate:"omitempty,uuid"`
+	PurchasedAt       time.Time   `json:"purchased_at"`
+	ExpiresAt         *time.Time  `json:"expires_at"`
+}
+
+// RedemptionInput is used for redeeming a voucher for a client. Money
+// vouchers pay for Amount packages of PackageID, one when no amount is
+// given; what the balance does not cover is left to pay on the billing.
+// Sessions vouchers are billed as a package of PackageID, which must be of
+// their session type, for its VAT rate and the validity of the credits.
+type RedemptionInput struct {
+	ClientID  string `json:"client_id" validate:"required,uuid"`
+	PackageID string `json:"package_id" validate:"omitempty,uuid"`
+	Amount    int    `json:"amount" validate:"min=0"`
+}
+
+// VoucherRepository defines methods for voucher persistence
+type VoucherRepository interface {
+	GetAll() ([]Voucher, error)
+	GetByID(id string) (*Voucher, error)
+	GetByIDForUpdate(id string) (*Voucher, error)
+	GetByCode(code string) (*Voucher, error)
+	GetByCodeForUpdate(code string) (*Voucher, error)
+	GetRedemptions(voucherID string) ([]VoucherRedemption, error)
+	Create(voucher *Voucher) error
+	Update(voucher *Voucher) error
+	CreateRedemption(redemption *VoucherRedemption) error
+}
+
+// VoucherService defines methods for voucher business logic
+type VoucherService interface {
+	GetAll() ([]Voucher, error)
+	GetByID(id string) (*Voucher, error)
+	GetByCode(code string) (*Voucher, error)
+	Create(ctx context.Context, input VoucherInput) (*Voucher, error)
+	Redeem(ctx context.Context, code string, input RedemptionInput) (*VoucherRedemption, error)
+	Cancel(ctx context.Context, id string) (*Voucher, error)
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	// ErrAlreadyWaitlisted is returned when a client is already on a schedule waitlist
+	ErrAlreadyWaitlisted = NewError(ErrConflict, "already_waitlisted", "client is already on the waitlist")
+	// ErrScheduleNotFull is returned when joining the waitlist of a schedule that still has slots
+	ErrScheduleNotFull = NewError(ErrConflict, "schedule_not_full", "schedule still has available slots")
+)
+
+// WaitlistStatus represents the state of a waitlist entry
+type WaitlistStatus string
+
+const (
+	Waiting  WaitlistStatus = "WAITING"
+	Promoted WaitlistStatus = "PROMOTED"
+)
+
+// WaitlistEntry represents a client waiting for a slot on a full schedule
+type WaitlistEntry struct {
+	ID            string         `json:"id" db:"id"`
+	ScheduleID    string         `json:"schedule_id" db:"schedule_id"`
+	ClientID      string         `json:"client_id" db:"client_id"`
+	Status        WaitlistStatus `json:"status" db:"status"`
+	AppointmentID string         `json:"appointment_id,omitempty" db:"appointment_id"`
+	PromotedAt    *time.Time     `json:"promoted_at,omitempty" db:"promoted_at"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+}
+
+// WaitlistInput is used for joining a waitlist
+type WaitlistInput struct {
+	ClientID string `json:"client_id" validate:"required,uuid"`
+}
+
+// WaitlistRepository defines methods for waitlist persistence
+type WaitlistRepository interface {
+	GetBySchedule(scheduleID string) ([]WaitlistEntry, error)
+	GetWaitingByClientAndSchedule(clientID, scheduleID string) (*WaitlistEntry, error)
+	Create(entry *WaitlistEntry) error
+	Update(entry *WaitlistEntry) error
+	Delete(id string) error
+}
+
+// WaitlistService defines methods for waitlist business logic
+type WaitlistService interface {
+	GetBySchedule(scheduleID string) ([]WaitlistEntry, error)
+	Join(ctx context.Context, scheduleID string, input WaitlistInput) (*WaitlistEntry, error)
+	Leave(ctx context.Context, scheduleID, clientID string) error
+}
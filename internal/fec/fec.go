@@ -0,0 +1,113 @@
+// Package fec writes journals as a Fichier des Écritures Comptables (FEC),
+// the file French businesses hand over to the tax administration as defined
+// by article A47 A-1 of the Livre des procédures fiscales
+package fec
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// header lists the 18 mandatory columns in order
+var header = []string{
+	"JournalCode",
+	"JournalLib",
+	"EcritureNum",
+	"EcritureDate",
+	"CompteNum",
+	"CompteLib",
+	"CompAuxNum",
+	"CompAuxLib",
+	"PieceRef",
+	"PieceDate",
+	"EcritureLib",
+	"Debit",
+	"Credit",
+	"EcritureLet",
+	"DateLet",
+	"ValidDate",
+	"Montantdevise",
+	"Idevise",
+}
+
+// sanitize replaces the characters that would break the file layout
+var sanitize = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// Write writes the lines of a journal to w as tab-separated values, one
+// line per journal line after the header line
+func Write(w io.Writer, journal *domain.Journal) error {
+	buf := bufio.NewWriter(w)
+
+	if err := writeFields(buf, header); err != nil {
+		return err
+	}
+
+	for _, line := range journal.Lines {
+		fields := []string{
+			line.JournalCode,
+			line.JournalLabel,
+			line.EntryNumber,
+			formatDate(line.EntryDate),
+			line.AccountNumber,
+			line.AccountLabel,
+			line.AuxiliaryNumber,
+			line.AuxiliaryLabel,
+			line.PieceRef,
+			formatDate(line.PieceDate),
+			line.Label,
+			formatAmount(line.Debit),
+			formatAmount(line.Credit),
+			"",
+			"",
+			formatDate(line.ValidatedAt),
+			"",
+			"",
+		}
+
+		if err := writeFields(buf, fields); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+// FileName returns the name required for the FEC of a journal, made of the
+// SIREN and the closing date of the fiscal year, e.g. 123456789FEC20261231.txt
+func FileName(journal *domain.Journal) string {
+	return journal.SIREN + "FEC" + formatDate(journal.Closing()) + ".txt"
+}
+
+// writeFields writes fields as a tab-separated line
+func writeFields(w *bufio.Writer, fields []string) error {
+	for i, field := range fields {
+		if i > 0 {
+			if err := w.WriteByte('\t'); err != nil {
+				return err
+			}
+		}
+		if _, err := w.WriteString(sanitize.Replace(field)); err != nil {
+			return err
+		}
+	}
+	_, err := w.WriteString("\r\n")
+	return err
+}
+
+// formatDate formats a date as YYYYMMDD, or empty for the zero time
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("20060102")
+}
+
+// formatAmount formats an amount with a decimal comma, e.g. 1250,00
+func formatAmount(amount money.Money) string {
+	return strings.Replace(amount.Decimal(), ".", ",", 1)
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/fec"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type AccountingHandler struct {
+	service domain.AccountingService
+}
+
+// NewAccountingHandler creates a new accounting handler
+func NewAccountingHandler(service domain.AccountingService) *AccountingHandler {
+	return &AccountingHandler{
+		service: service,
+	}
+}
+
+// GetFEC handles GET /api/exports/fec/{year}
+func (h *AccountingHandler) GetFEC(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil || year < 1 {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid fiscal year")
+		return
+	}
+
+	journal, err := h.service.GetSalesJournal(year)
+	if err != nil {
+		log.Error().Err(err).Int("year", year).Msg("failed to get sales journal")
+		problem.WriteError(w, err, "Failed to get sales journal")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := fec.Write(&buf, journal); err != nil {
+		log.Error().Err(err).Int("year", year).Msg("failed to write FEC")
+		problem.WriteError(w, err, "Failed to write FEC")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fec.FileName(journal)))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type AppointmentHandler struct {
+	service domain.AppointmentService
+}
+
+// NewAppointmentHandler creates a new appointment handler
+func NewAppointmentHandler(service domain.AppointmentService) *AppointmentHandler {
+	return &AppointmentHandler{
+		service: service,
+	}
+}
+
+// appointmentColumns are the columns of the appointments table export
+var appointmentColumns = []column[domain.AppointmentWithDetails]{
+	{"id", func(a *domain.AppointmentWithDetails) interface{} { return a.Appointment.ID }},
+	{"class_datetime", func(a *domain.AppointmentWithDetails) interface{} { return a.Schedule.Schedule.ClassDatetime }},
+	{"schedule_id", func(a *domain.AppointmentWithDetails) interface{} { return a.Appointment.ScheduleID }},
+	{"class_name", func(a *domain.AppointmentWithDetails) interface{} { return a.Schedule.Class.Name }},
+	{"class_location", func(a *domain.AppointmentWithDetails) interface{} { return string(a.Schedule.Class.Location) }},
+	{"class_type", func(a *domain.AppointmentWithDetails) interface{} { return string(a.Schedule.Class.Type) }},
+	{"client_id", func(a *domain.AppointmentWithDetails) interface{} { return a.Appointment.ClientID }},
+	{"client_firstname", func(a *domain.AppointmentWithDetails) interface{} { return a.Client.FirstName }},
+	{"client_lastname", func(a *domain.AppointmentWithDetails) interface{} { return a.Client.LastName }},
+	{"client_email", func(a *domain.AppointmentWithDetails) interface{} { return a.Client.Email }},
+	{"created_at", func(a *domain.AppointmentWithDetails) interface{} { return a.Appointment.CreatedAt }},
+}
+
+// GetAll handles GET /api/appointments, as CSV or XLSX with their details
+// when accepted
+func (h *AppointmentHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	if format := tableFormat(r); format != "" {
+		respondWithTable(w, format, "appointments", appointmentColumns, h.service.EachWithDetails)
+		return
+	}
+
+	appointments, err := h.service.GetAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all appointments")
+		problem.WriteError(w, err, "Failed to get appointments")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, appointments)
+}
+
+// GetByID handles GET /api/appointments/{id}
+func (h *AppointmentHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing appointment ID")
+		return
+	}
+
+	appointment, err := h.service.GetWithDetails(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get appointment by ID")
+		problem.WriteError(w, err, "Failed to get appointment")
+		return
+	}
+
+	if appointment == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Appointment not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, appointment)
+}
+
+// Create handles POST /api/appointments
+func (h *AppointmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.AppointmentInput
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ScheduleID == "" || input.ClientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	appointment := &domain.Appointment{
+		ScheduleID: input.ScheduleID,
+		ClientID:   input.ClientID,
+	}
+
+	err := h.service.Create(r.Context(), appointment)
+	if err != nil {
+		log.Error().Err(err).Interface("input", input).Msg("failed to create appointment")
+		problem.WriteError(w, err, "Failed to create appointment")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, appointment)
+}
+
+// Update handles PUT /api/appointments/{id}
+func (h *AppointmentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing appointment ID")
+		return
+	}
+
+	var input domain.AppointmentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ScheduleID == "" || input.ClientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	err := h.service.Update(r.Context(), &domain.Appointment{
+		ID:         id,
+		ScheduleID: input.ScheduleID,
+		ClientID:   input.ClientID,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update appointment")
+		problem.WriteError(w, err, "Failed to update appointment")
+		return
+	}
+
+	appointment, err := h.service.GetByID(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get updated appointment")
+		problem.WriteError(w, err, "Failed to get appointment")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, appointment)
+}
+
+// Delete handles DELETE /api/appointments/{id}
+func (h *AppointmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing appointment ID")
+		return
+	}
+
+	err := h.service.Delete(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to delete appointment")
+		problem.WriteError(w, err, "Failed to delete appointment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Cancel handles POST /api/appointments/{id}/cancel
+func (h *AppointmentHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing appointment ID")
+		return
+	}
+
+	// The body is optional, an empty one applies the policy
+	var input domain.CancellationInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+			return
+		}
+	}
+
+	cancellation, err := h.service.Cancel(r.Context(), id, input)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to cancel appointment")
+		problem.WriteError(w, err, "Failed to cancel appointment")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, cancellation)
+}
+
+// GetCancellationsByClientID handles GET /api/appointments/cancellations/client/{clientId}
+func (h *AppointmentHandler) GetCancellationsByClientID(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	cancellations, err := h.service.GetCancellationsByClient(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get cancellations by client ID")
+		problem.WriteError(w, err, "Failed to get cancellations")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, cancellations)
+}
+
+// GetByClientID handles GET /api/appointments/client/{clientId}
+func (h *AppointmentHandler) GetByClientID(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	appointments, err := h.service.GetByClientID(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get appointments by client ID")
+		problem.WriteError(w, err, "Failed to get appointments")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, appointments)
+}
+
+// GetByScheduleID handles GET /api/appointments/schedule/{scheduleId}
+func (h *AppointmentHandler) GetByScheduleID(w http.ResponseWriter, r *http.Request) {
+	scheduleID := chi.URLParam(r, "scheduleId")
+	if scheduleID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing schedule ID")
+		return
+	}
+
+	appointments, err := h.service.GetByScheduleID(scheduleID)
+	if err != nil {
+		log.Error().Err(err).Str("scheduleID", scheduleID).Msg("failed to get appointments by schedule ID")
+		problem.WriteError(w, err, "Failed to get appointments")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, appointments)
+}
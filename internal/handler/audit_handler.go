@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+// AuditHandler serves the audit log, which is read only
+type AuditHandler struct {
+	service domain.AuditService
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(service domain.AuditService) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+	}
+}
+
+// Find handles GET /api/audit?entity=client&id=...&limit=100
+func (h *AuditHandler) Find(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		EntityType: query.Get("entity"),
+		EntityID:   query.Get("id"),
+	}
+
+	// Validate input
+	if filter.EntityType == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	if param := query.Get("limit"); param != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(param); err != nil || filter.Limit < 0 {
+			problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid limit")
+			return
+		}
+	}
+
+	entries, err := h.service.Find(filter)
+	if err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to find audit entries")
+		problem.WriteError(w, err, "Failed to get audit entries")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, entries)
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/matthieukhl/align-back/pkg/money"
 	"github.com/rs/zerolog/log"
 )
 
@@ -20,12 +22,49 @@ func NewBillingHandler(service domain.BillingService) *BillingHandler {
 	}
 }
 
-// GetAll handles GET /api/billings
+// billingColumns are the columns of the billings table export
+var billingColumns = []column[domain.BillingWithDetails]{
+	{"id", func(b *domain.BillingWithDetails) interface{} { return b.Billing.ID }},
+	{"kind", func(b *domain.BillingWithDetails) interface{} { return string(b.Billing.Kind) }},
+	{"payment_date", func(b *domain.BillingWithDetails) interface{} { return b.Billing.PaymentDate }},
+	{"invoice_number", func(b *domain.BillingWithDetails) interface{} {
+		if b.Invoice == nil {
+			return ""
+		}
+		return b.Invoice.Number
+	}},
+	{"client_id", func(b *domain.BillingWithDetails) interface{} { return b.Billing.ClientID }},
+	{"client_firstname", func(b *domain.BillingWithDetails) interface{} { return b.Client.FirstName }},
+	{"client_lastname", func(b *domain.BillingWithDetails) interface{} { return b.Client.LastName }},
+	{"package_id", func(b *domain.BillingWithDetails) interface{} { return b.Billing.PackageID }},
+	{"package_name", func(b *domain.BillingWithDetails) interface{} { return b.Package.Name }},
+	{"package_type", func(b *domain.BillingWithDetails) interface{} { return string(b.Package.Type) }},
+	{"amount", func(b *domain.BillingWithDetails) interface{} { return b.Billing.Amount }},
+	{"credits", func(b *domain.BillingWithDetails) interface{} { return b.Billing.Credits }},
+	{"original_price", func(b *domain.BillingWithDetails) interface{} { return b.Billing.OriginalPrice }},
+	{"discount", func(b *domain.BillingWithDetails) interface{} { return b.Billing.Discount }},
+	{"promotion_id", func(b *domain.BillingWithDetails) interface{} { return b.Billing.PromotionID }},
+	{"net_price", func(b *domain.BillingWithDetails) interface{} { return b.Billing.NetPrice }},
+	{"vat_rate", func(b *domain.BillingWithDetails) interface{} { return money.FormatRate(b.Billing.VATRate) }},
+	{"vat", func(b *domain.BillingWithDetails) interface{} { return b.Billing.VAT }},
+	{"price", func(b *domain.BillingWithDetails) interface{} { return b.Billing.Price }},
+	{"currency", func(b *domain.BillingWithDetails) interface{} { return b.Billing.Price.Currency }},
+	{"refunded_billing_id", func(b *domain.BillingWithDetails) interface{} { return b.Billing.RefundedBillingID }},
+	{"reason", func(b *domain.BillingWithDetails) interface{} { return b.Billing.Reason }},
+}
+
+// GetAll handles GET /api/billings, as CSV or XLSX with their details when
+// accepted
 func (h *BillingHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	if format := tableFormat(r); format != "" {
+		respondWithTable(w, format, "billings", billingColumns, h.service.EachWithDetails)
+		return
+	}
+
 	billings, err := h.service.GetAll()
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get billings")
-		http.Error(w, "Failed to get billings", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get billings")
 		return
 	}
 
@@ -37,19 +76,19 @@ func (h *BillingHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	if id == "" {
-		http.Error(w, "Missing billing ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
 		return
 	}
 
 	billing, err := h.service.GetByID(id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to get billing by ID")
-		http.Error(w, "Failed to get billing by ID", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get billing by ID")
 		return
 	}
 
 	if billing == nil {
-		http.Error(w, "Billing not found", http.StatusNotFound)
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Billing not found")
 		return
 	}
 
@@ -60,20 +99,20 @@ func (h *BillingHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *BillingHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input domain.BillingInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if input.ClientID == "" || input.PackageID == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
 		return
 	}
 
-	err := h.service.Create(input)
+	err := h.service.Create(r.Context(), input)
 	if err != nil {
 		log.Error().Err(err).Interface("input", input).Msg("failed to create billing")
-		http.Error(w, "Failed to create billing", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to create billing")
 		return
 	}
 
@@ -87,47 +126,106 @@ func (h *BillingHandler) GetRecent(w http.ResponseWriter, r *http.Request) {
 	billings, err := h.service.GetRecent(limit)
 	if err != nil {
 		log.Error().Err(err).Int("limit", limit).Msg("failed to get recent billings")
-		http.Error(w, "Failed to get recent billings", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get recent billings")
 		return
 	}
 
 	respondwithJSON(w, http.StatusOK, billings)
 }
 
-// r.Put("/{id}", billingHandler.Update)
-// r.Delete("/{id}", billingHandler.Delete)
-// r.Get("/client/{clientId}", billingHandler.GetByClientID)
-
 // Update handles PUT /api/billings/{id}
 func (h *BillingHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing billing ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
 		return
 	}
 
 	var input domain.BillingInput
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
 	}
 
 	// Validate input
 	if input.ClientID == "" || input.PackageID == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
 		return
 	}
 
-	billing, err := h.service.Update(id, input)
+	billing, err := h.service.Update(r.Context(), id, input)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update billing")
-		if err.Error() == "billing not found" {
-			http.Error(w, "Billing not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to update billing", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to update billing")
 		return
 	}
 
 	respondwithJSON(w, http.StatusOK, billing)
 }
+
+// Refund handles POST /api/billings/{id}/refunds
+func (h *BillingHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
+		return
+	}
+
+	var input domain.RefundInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Reason == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	refund, err := h.service.Refund(r.Context(), id, input)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to refund billing")
+		problem.WriteError(w, err, "Failed to refund billing")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, refund)
+}
+
+// GetRefunds handles GET /api/billings/{id}/refunds
+func (h *BillingHandler) GetRefunds(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
+		return
+	}
+
+	refunds, err := h.service.GetRefunds(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get billing refunds")
+		problem.WriteError(w, err, "Failed to get billing refunds")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, refunds)
+}
+
+// GetByClientID handles GET /api/billings/client/{clientId}
+func (h *BillingHandler) GetByClientID(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientId")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	billings, err := h.service.GetByClientID(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get billings by client ID")
+		problem.WriteError(w, err, "Failed to get billings")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, billings)
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/payment"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+// maxWebhookBytes caps the size of webhook payloads read
+const maxWebhookBytes = 1 << 20
+
+type CheckoutHandler struct {
+	service domain.CheckoutService
+}
+
+// NewCheckoutHandler creates a new checkout handler
+func NewCheckoutHandler(service domain.CheckoutService) *CheckoutHandler {
+	return &CheckoutHandler{
+		service: service,
+	}
+}
+
+// GetAll handles GET /api/checkouts
+func (h *CheckoutHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	checkouts, err := h.service.GetAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get checkouts")
+		problem.WriteError(w, err, "Failed to get checkouts")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, checkouts)
+}
+
+// GetByID handles GET /api/checkouts/{id}
+func (h *CheckoutHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing checkout ID")
+		return
+	}
+
+	checkout, err := h.service.GetByID(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get checkout")
+		problem.WriteError(w, err, "Failed to get checkout")
+		return
+	}
+
+	if checkout == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Checkout not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, checkout)
+}
+
+// Create handles POST /api/checkouts
+func (h *CheckoutHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.CheckoutInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClientID == "" || input.PackageID == "" || input.SuccessURL == "" || input.CancelURL == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	checkout, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		log.Error().Err(err).Interface("input", input).Msg("failed to create checkout")
+		problem.WriteError(w, err, "Failed to create checkout")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, checkout)
+}
+
+// Sync handles POST /api/checkouts/{id}/sync
+func (h *CheckoutHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing checkout ID")
+		return
+	}
+
+	checkout, err := h.service.Sync(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to sync checkout")
+		problem.WriteError(w, err, "Failed to sync checkout")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, checkout)
+}
+
+// Refund handles POST /api/checkouts/{id}/refunds
+func (h *CheckoutHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing checkout ID")
+		return
+	}
+
+	var input domain.RefundInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Reason == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	refund, err := h.service.Refund(r.Context(), id, input)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to refund checkout")
+		problem.WriteError(w, err, "Failed to refund checkout")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, refund)
+}
+
+// Webhook handles POST /api/webhooks/payments. Anything but a success makes
+// the provider deliver the event again later.
+func (h *CheckoutHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := h.service.HandleEvent(r.Context(), payload, r.Header.Get(payment.SignatureHeader)); err != nil {
+		if errors.Is(err, domain.ErrInvalidSignature) {
+			log.Warn().Str("remoteAddr", r.RemoteAddr).Msg("rejected payment webhook with an invalid signature")
+			problem.WriteError(w, err, "")
+			return
+		}
+		log.Error().Err(err).Msg("failed to handle payment webhook")
+		problem.WriteError(w, err, "Failed to handle payment event")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
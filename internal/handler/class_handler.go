@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
 	"github.com/rs/zerolog/log"
 )
 
@@ -25,7 +26,7 @@ func (h *ClassHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	classes, err := h.service.GetAll()
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get all classes")
-		http.Error(w, "Failed to get classes", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get classes")
 		return
 	}
 
@@ -37,19 +38,19 @@ func (h *ClassHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	if id == "" {
-		http.Error(w, "Missing class ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing class ID")
 		return
 	}
 
 	class, err := h.service.GetByID(id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to get class by ID")
-		http.Error(w, "Failed to get class", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get class")
 		return
 	}
 
 	if class == nil {
-		http.Error(w, "Class not found", http.StatusNotFound)
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Class not found")
 		return
 	}
 
@@ -61,23 +62,20 @@ func (h *ClassHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input domain.ClassInput
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if input.Name == "" || input.Location == "" || input.Type == "" || input.Equipment == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
 	}
 
-	err := h.service.Create(input)
+	err := h.service.Create(r.Context(), input)
 	if err != nil {
 		log.Error().Err(err).Interface("input", input).Msg("failed to create class")
-		if err.Error() == "class name is already in use" {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to create class", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to create class")
 		return
 	}
 
@@ -89,34 +87,27 @@ func (h *ClassHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *ClassHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing class ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing class ID")
 		return
 	}
 
 	var input domain.ClassInput
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if input.Name == "" || input.Location == "" || input.Type == "" || input.Equipment == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
 	}
 
-	class, err := h.service.Update(id, input)
+	class, err := h.service.Update(r.Context(), id, input)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update class")
-		if err.Error() == "class not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		if err.Error() == "class name is already in use" {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to update class", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to update class")
 		return
 	}
 
@@ -127,18 +118,14 @@ func (h *ClassHandler) Update(w http.ResponseWriter, r *http.Request) {
 func (h *ClassHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing class ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing class ID")
 		return
 	}
 
-	err := h.service.Delete(id)
+	err := h.service.Delete(r.Context(), id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to delete class")
-		if err.Error() == "class not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to delete class", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to delete class")
 		return
 	}
 
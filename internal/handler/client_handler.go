@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
 	"github.com/rs/zerolog/log"
 )
 
@@ -20,12 +21,34 @@ func NewClientHandler(service domain.ClientService) *ClientHandler {
 	}
 }
 
-// GetAll handles GET /api/clients
+// clientColumns are the columns of the clients table export
+var clientColumns = []column[domain.Client]{
+	{"id", func(c *domain.Client) interface{} { return c.ID }},
+	{"firstname", func(c *domain.Client) interface{} { return c.FirstName }},
+	{"lastname", func(c *domain.Client) interface{} { return c.LastName }},
+	{"email", func(c *domain.Client) interface{} { return c.Email }},
+	{"phone", func(c *domain.Client) interface{} { return c.Phone }},
+	{"street_number", func(c *domain.Client) interface{} { return c.StreetNumber }},
+	{"street_name", func(c *domain.Client) interface{} { return c.StreetName }},
+	{"zip_code", func(c *domain.Client) interface{} { return c.ZipCode }},
+	{"city", func(c *domain.Client) interface{} { return c.City }},
+	{"country", func(c *domain.Client) interface{} { return c.Country }},
+	{"group_credits", func(c *domain.Client) interface{} { return c.GroupCredits }},
+	{"private_credits", func(c *domain.Client) interface{} { return c.PrivateCredits }},
+	{"created_at", func(c *domain.Client) interface{} { return c.CreatedAt }},
+}
+
+// GetAll handles GET /api/clients, as CSV or XLSX when accepted
 func (h *ClientHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	if format := tableFormat(r); format != "" {
+		respondWithTable(w, format, "clients", clientColumns, h.service.Each)
+		return
+	}
+
 	clients, err := h.service.GetAll()
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get all clients")
-		http.Error(w, "Failed to get clients", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get clients")
 		return
 	}
 
@@ -36,19 +59,19 @@ func (h *ClientHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 func (h *ClientHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing client ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
 		return
 	}
 
 	client, err := h.service.GetByID(id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to get client by ID")
-		http.Error(w, "Failed to get client", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get client")
 		return
 	}
 
 	if client == nil {
-		http.Error(w, "Client not found", http.StatusNotFound)
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Client not found")
 		return
 	}
 
@@ -60,24 +83,20 @@ func (h *ClientHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input domain.ClientInput
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if input.FirstName == "" || input.LastName == "" || input.Email == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
 		return
 	}
 
-	err := h.service.Create(input)
+	err := h.service.Create(r.Context(), input)
 	if err != nil {
 		log.Error().Err(err).Interface("input", input).Msg("failed to create client")
-		if err.Error() == "email is already in use" {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to create client", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to create client")
 		return
 	}
 
@@ -88,34 +107,26 @@ func (h *ClientHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *ClientHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing Client ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing Client ID")
 		return
 	}
 
 	var input domain.ClientInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if input.FirstName == "" || input.LastName == "" || input.Email == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
 		return
 	}
 
-	client, err := h.service.Update(id, input)
+	client, err := h.service.Update(r.Context(), id, input)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update client")
-		if err.Error() == "client not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		if err.Error() == "email is already in use" {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to update client", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to update client")
 		return
 	}
 
@@ -126,18 +137,14 @@ func (h *ClientHandler) Update(w http.ResponseWriter, r *http.Request) {
 func (h *ClientHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing client ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
 		return
 	}
 
-	err := h.service.Delete(id)
+	err := h.service.Delete(r.Context(), id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to delete client")
-		if err.Error() == "client not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to delete client", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to delete client")
 		return
 	}
 
@@ -150,7 +157,7 @@ func (h *ClientHandler) GetLowGroupCredits(w http.ResponseWriter, r *http.Reques
 	clients, err := h.service.GetLowGroupCredits(threshold)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get clients with low group credits")
-		http.Error(w, "Failed to get clients with low group credits", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get clients with low group credits")
 		return
 	}
 
@@ -163,7 +170,7 @@ func (h *ClientHandler) GetLowPrivateCredits(w http.ResponseWriter, r *http.Requ
 	clients, err := h.service.GetLowPrivateCredits(threshold)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get clients with low private credits")
-		http.Error(w, "Failed to get clients with low private credits", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get clients with low private credits")
 		return
 	}
 
@@ -175,7 +182,7 @@ func respondwithJSON(w http.ResponseWriter, status int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to marshal response")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Internal Server Error")
 		return
 	}
 
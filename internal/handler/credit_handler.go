@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type CreditHandler struct {
+	service domain.CreditService
+}
+
+// NewCreditHandler creates a new credit handler
+func NewCreditHandler(service domain.CreditService) *CreditHandler {
+	return &CreditHandler{
+		service: service,
+	}
+}
+
+// GetHistory handles GET /api/clients/{id}/credits
+func (h *CreditHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	entries, err := h.service.GetHistory(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get credit history")
+		problem.WriteError(w, err, "Failed to get credit history")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, entries)
+}
+
+// GetBalance handles GET /api/clients/{id}/credits/balance
+func (h *CreditHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	balance, err := h.service.GetBalance(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get credit balance")
+		problem.WriteError(w, err, "Failed to get credit balance")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, balance)
+}
+
+// GetLots handles GET /api/clients/{id}/credits/lots
+func (h *CreditHandler) GetLots(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	lots, err := h.service.GetLots(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get credit lots")
+		problem.WriteError(w, err, "Failed to get credit lots")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, lots)
+}
+
+// Adjust handles POST /api/clients/{id}/credits
+func (h *CreditHandler) Adjust(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	var input domain.CreditAdjustmentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Type == "" || input.Delta == 0 || input.Reason == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	entry, err := h.service.Adjust(r.Context(), clientID, input)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Interface("input", input).Msg("failed to adjust credits")
+		problem.WriteError(w, err, "Failed to adjust credits")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, entry)
+}
+
+// Reconcile handles POST /api/clients/credits/reconcile
+func (h *CreditHandler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	balances, err := h.service.Reconcile(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to reconcile credits")
+		problem.WriteError(w, err, "Failed to reconcile credits")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, balances)
+}
+
+// Expire handles POST /api/clients/credits/expire
+func (h *CreditHandler) Expire(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.service.ExpireAll(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to expire credits")
+		problem.WriteError(w, err, "Failed to expire credits")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type DashboardHandler struct {
+	service domain.DashboardService
+}
+
+// NewDashboardHandler creates a new dashboard handler
+func NewDashboardHandler(service domain.DashboardService) *DashboardHandler {
+	return &DashboardHandler{
+		service: service,
+	}
+}
+
+// Get handles GET /api/dashboard
+func (h *DashboardHandler) Get(w http.ResponseWriter, r *http.Request) {
+	dashboard, err := h.service.Get()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get dashboard")
+		problem.WriteError(w, err, "Failed to get dashboard")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, dashboard)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/payment"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+// FakePaymentHandler stands for the checkout pages of the fake payment
+// provider in development: settling a checkout delivers the signed event to
+// the webhook as a real provider would.
+type FakePaymentHandler struct {
+	provider   *payment.Fake
+	webhookURL string
+	client     *http.Client
+}
+
+// NewFakePaymentHandler creates a new fake payment handler delivering events
+// to webhookURL
+func NewFakePaymentHandler(provider *payment.Fake, webhookURL string) *FakePaymentHandler {
+	return &FakePaymentHandler{
+		provider:   provider,
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetByID handles GET /api/dev/payments/{id}
+func (h *FakePaymentHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing checkout ID")
+		return
+	}
+
+	checkout, err := h.provider.GetCheckout(id)
+	if err != nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Checkout not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, checkout)
+}
+
+// Pay handles POST /api/dev/payments/{id}/pay
+func (h *FakePaymentHandler) Pay(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, true)
+}
+
+// Fail handles POST /api/dev/payments/{id}/fail
+func (h *FakePaymentHandler) Fail(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, false)
+}
+
+// settle settles a checkout and delivers the event to the webhook
+func (h *FakePaymentHandler) settle(w http.ResponseWriter, r *http.Request, paid bool) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing checkout ID")
+		return
+	}
+
+	payload, signature, err := h.provider.Settle(id, paid)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to settle fake checkout")
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Checkout not found")
+		return
+	}
+
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodPost, h.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Error().Err(err).Str("url", h.webhookURL).Msg("failed to build payment webhook request")
+		problem.WriteError(w, err, "Failed to deliver payment event")
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(payment.SignatureHeader, signature)
+
+	response, err := h.client.Do(request)
+	if err != nil {
+		log.Error().Err(err).Str("url", h.webhookURL).Msg("failed to deliver payment event")
+		problem.Write(w, http.StatusBadGateway, problem.CodeBadGateway, "Failed to deliver payment event")
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		log.Error().Int("status", response.StatusCode).Str("url", h.webhookURL).Msg("payment event rejected by the webhook")
+		problem.Write(w, http.StatusBadGateway, problem.CodeBadGateway, "Payment event rejected by the webhook")
+		return
+	}
+
+	checkout, err := h.provider.GetCheckout(id)
+	if err != nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Checkout not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, checkout)
+}
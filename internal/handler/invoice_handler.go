@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/pdf"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type InvoiceHandler struct {
+	service  domain.InvoiceService
+	location *time.Location
+}
+
+// NewInvoiceHandler creates a new invoice handler. Dates on invoices are
+// printed in location.
+func NewInvoiceHandler(service domain.InvoiceService, location *time.Location) *InvoiceHandler {
+	return &InvoiceHandler{
+		service:  service,
+		location: location,
+	}
+}
+
+// GetByBillingID handles GET /api/billings/{id}/invoice
+func (h *InvoiceHandler) GetByBillingID(w http.ResponseWriter, r *http.Request) {
+	billingID := chi.URLParam(r, "id")
+	if billingID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
+		return
+	}
+
+	invoice, err := h.service.Issue(r.Context(), billingID)
+	if err != nil {
+		log.Error().Err(err).Str("billingID", billingID).Msg("failed to get invoice")
+		problem.WriteError(w, err, "Failed to get invoice")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, invoice)
+}
+
+// GetPDF handles GET /api/billings/{id}/invoice.pdf
+func (h *InvoiceHandler) GetPDF(w http.ResponseWriter, r *http.Request) {
+	billingID := chi.URLParam(r, "id")
+	if billingID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
+		return
+	}
+
+	invoice, err := h.service.Issue(r.Context(), billingID)
+	if err != nil {
+		log.Error().Err(err).Str("billingID", billingID).Msg("failed to get invoice")
+		problem.WriteError(w, err, "Failed to get invoice")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.RenderInvoice(&buf, invoice, h.location); err != nil {
+		log.Error().Err(err).Str("number", invoice.Number).Msg("failed to render invoice")
+		problem.WriteError(w, err, "Failed to render invoice")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="facture-%s.pdf"`, invoice.Number))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
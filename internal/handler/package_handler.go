@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
 	"github.com/rs/zerolog/log"
 )
 
@@ -33,7 +34,7 @@ func (h *PackageHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	packages, err := h.service.GetAll()
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get all clients")
-		http.Error(w, "failed to get clients", http.StatusInternalServerError)
+		problem.WriteError(w, err, "failed to get clients")
 		return
 	}
 
@@ -44,19 +45,19 @@ func (h *PackageHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 func (h *PackageHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing package ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing package ID")
 		return
 	}
 
 	pkg, err := h.service.GetByID(id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to get package by ID")
-		http.Error(w, "Failed to get package", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to get package")
 		return
 	}
 
 	if pkg == nil {
-		http.Error(w, "Client not found", http.StatusNotFound)
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Client not found")
 		return
 	}
 
@@ -68,24 +69,20 @@ func (h *PackageHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input domain.PackageInput
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate input
-	if input.Name == "" || input.NumberOfSessions == 0 {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+	if input.Name == "" || (input.NumberOfSessions == 0 && input.Type != domain.MembershipPackage) {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
 		return
 	}
 
-	err := h.service.Create(input)
+	err := h.service.Create(r.Context(), input)
 	if err != nil {
 		log.Error().Err(err).Interface("input", input).Msg("failed to create package")
-		if err.Error() == "package already exists" {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to create package", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to create package")
 		return
 	}
 
@@ -96,34 +93,26 @@ func (h *PackageHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *PackageHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing package ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing package ID")
 		return
 	}
 
 	var input domain.PackageInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate input
-	if input.Name == "" || input.NumberOfSessions == 0 {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+	if input.Name == "" || (input.NumberOfSessions == 0 && input.Type != domain.MembershipPackage) {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
 		return
 	}
 
-	pkg, err := h.service.Update(id, input)
+	pkg, err := h.service.Update(r.Context(), id, input)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update package")
-		if err.Error() == "package not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		if err.Error() == "package name already exists" {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to update package", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to update package")
 		return
 	}
 
@@ -135,18 +124,14 @@ func (h *PackageHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	if id == "" {
-		http.Error(w, "Missing package ID", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing package ID")
 		return
 	}
 
-	err := h.service.Delete(id)
+	err := h.service.Delete(r.Context(), id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to delete client")
-		if err.Error() == "package not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to delete package", http.StatusInternalServerError)
+		problem.WriteError(w, err, "Failed to delete package")
 		return
 	}
 
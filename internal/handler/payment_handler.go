@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type PaymentHandler struct {
+	service domain.PaymentService
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(service domain.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		service: service,
+	}
+}
+
+// GetByBillingID handles GET /api/billings/{id}/payments
+func (h *PaymentHandler) GetByBillingID(w http.ResponseWriter, r *http.Request) {
+	billingID := chi.URLParam(r, "id")
+	if billingID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
+		return
+	}
+
+	payments, err := h.service.GetByBilling(billingID)
+	if err != nil {
+		log.Error().Err(err).Str("billingID", billingID).Msg("failed to get billing payments")
+		problem.WriteError(w, err, "Failed to get payments")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, payments)
+}
+
+// GetByClientID handles GET /api/clients/{id}/payments
+func (h *PaymentHandler) GetByClientID(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	payments, err := h.service.GetByClient(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get client payments")
+		problem.WriteError(w, err, "Failed to get payments")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, payments)
+}
+
+// Record handles POST /api/billings/{id}/payments
+func (h *PaymentHandler) Record(w http.ResponseWriter, r *http.Request) {
+	billingID := chi.URLParam(r, "id")
+	if billingID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
+		return
+	}
+
+	var input domain.PaymentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Method == "" || input.Amount.Amount == 0 {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	payment, err := h.service.Record(r.Context(), billingID, input)
+	if err != nil {
+		log.Error().Err(err).Str("billingID", billingID).Interface("input", input).Msg("failed to record payment")
+		problem.WriteError(w, err, "Failed to record payment")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, payment)
+}
+
+// GetBalance handles GET /api/billings/{id}/balance
+func (h *PaymentHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	billingID := chi.URLParam(r, "id")
+	if billingID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing billing ID")
+		return
+	}
+
+	balance, err := h.service.GetBalance(billingID)
+	if err != nil {
+		log.Error().Err(err).Str("billingID", billingID).Msg("failed to get billing balance")
+		problem.WriteError(w, err, "Failed to get billing balance")
+		return
+	}
+
+	if balance == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Billing not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, balance)
+}
+
+// GetClientBalance handles GET /api/clients/{id}/balance
+func (h *PaymentHandler) GetClientBalance(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	balance, err := h.service.GetClientBalance(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get client balance")
+		problem.WriteError(w, err, "Failed to get client balance")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, balance)
+}
+
+// GetOutstanding handles GET /api/billings/outstanding?status=UNPAID|PARTIAL
+func (h *PaymentHandler) GetOutstanding(w http.ResponseWriter, r *http.Request) {
+	status := domain.PaymentStatus(r.URL.Query().Get("status"))
+	if status != "" && status != domain.UnpaidStatus && status != domain.PartialStatus {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid status, expected UNPAID or PARTIAL")
+		return
+	}
+
+	balances, err := h.service.GetOutstanding(status)
+	if err != nil {
+		log.Error().Err(err).Str("status", string(status)).Msg("failed to get outstanding billings")
+		problem.WriteError(w, err, "Failed to get outstanding billings")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, balances)
+}
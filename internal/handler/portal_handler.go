@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/middleware"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+// PortalHandler serves the client portal. Every handler but RequestLogin and
+// Verify acts on the client of the session, never on IDs from the request.
+type PortalHandler struct {
+	service  domain.PortalService
+	location *time.Location
+}
+
+// NewPortalHandler creates a new portal handler. Dates are days of the
+// calendar in location.
+func NewPortalHandler(service domain.PortalService, location *time.Location) *PortalHandler {
+	return &PortalHandler{
+		service:  service,
+		location: location,
+	}
+}
+
+// RequestLogin handles POST /api/me/login
+func (h *PortalHandler) RequestLogin(w http.ResponseWriter, r *http.Request) {
+	var input domain.PortalLoginInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Email == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	if err := h.service.RequestLogin(input); err != nil {
+		log.Error().Err(err).Str("email", input.Email).Msg("failed to send portal login")
+		problem.WriteError(w, err, "Failed to send login email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Verify handles POST /api/me/verify
+func (h *PortalHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var input domain.PortalVerifyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Token == "" && (input.Email == "" || input.Code == "") {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	session, err := h.service.Verify(r.Context(), input)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidLoginCode) {
+			log.Warn().Str("email", input.Email).Str("remoteAddr", r.RemoteAddr).Msg("failed portal login")
+			problem.WriteError(w, err, "")
+			return
+		}
+		log.Error().Err(err).Str("email", input.Email).Msg("failed to verify portal login")
+		problem.WriteError(w, err, "Failed to log in")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, session)
+}
+
+// Logout handles POST /api/me/logout
+func (h *PortalHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Logout(middleware.BearerToken(r)); err != nil {
+		log.Error().Err(err).Msg("failed to log out client")
+		problem.WriteError(w, err, "Failed to log out")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Get handles GET /api/me
+func (h *PortalHandler) Get(w http.ResponseWriter, r *http.Request) {
+	respondwithJSON(w, http.StatusOK, middleware.ClientFrom(r.Context()))
+}
+
+// GetCredits handles GET /api/me/credits
+func (h *PortalHandler) GetCredits(w http.ResponseWriter, r *http.Request) {
+	client := middleware.ClientFrom(r.Context())
+
+	balance, err := h.service.GetCredits(client.ID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", client.ID).Msg("failed to get portal credits")
+		problem.WriteError(w, err, "Failed to get credits")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, balance)
+}
+
+// GetAppointments handles GET /api/me/appointments
+func (h *PortalHandler) GetAppointments(w http.ResponseWriter, r *http.Request) {
+	client := middleware.ClientFrom(r.Context())
+
+	appointments, err := h.service.GetUpcomingAppointments(client.ID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", client.ID).Msg("failed to get portal appointments")
+		problem.WriteError(w, err, "Failed to get appointments")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, appointments)
+}
+
+// Book handles POST /api/me/appointments
+func (h *PortalHandler) Book(w http.ResponseWriter, r *http.Request) {
+	client := middleware.ClientFrom(r.Context())
+
+	var input domain.PortalBookingInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ScheduleID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	appointment, err := h.service.Book(r.Context(), client.ID, input)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", client.ID).Interface("input", input).Msg("failed to book from portal")
+		problem.WriteError(w, err, "Failed to book class")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, appointment)
+}
+
+// Cancel handles POST /api/me/appointments/{id}/cancel
+func (h *PortalHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	client := middleware.ClientFrom(r.Context())
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing appointment ID")
+		return
+	}
+
+	cancellation, err := h.service.Cancel(r.Context(), client.ID, id)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", client.ID).Str("id", id).Msg("failed to cancel from portal")
+		problem.WriteError(w, err, "Failed to cancel appointment")
+		return
+	}
+
+	if cancellation == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Appointment not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, cancellation)
+}
+
+// GetBillings handles GET /api/me/billings
+func (h *PortalHandler) GetBillings(w http.ResponseWriter, r *http.Request) {
+	client := middleware.ClientFrom(r.Context())
+
+	billings, err := h.service.GetBillings(client.ID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", client.ID).Msg("failed to get portal billings")
+		problem.WriteError(w, err, "Failed to get billings")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, billings)
+}
+
+// GetSchedule handles GET /api/me/schedule/week/{date}
+func (h *PortalHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	date, err := time.ParseInLocation(dateLayout, chi.URLParam(r, "date"), h.location)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid date, expected YYYY-MM-DD")
+		return
+	}
+
+	schedules, err := h.service.GetSchedule(date)
+	if err != nil {
+		log.Error().Err(err).Time("date", date).Msg("failed to get portal schedule")
+		problem.WriteError(w, err, "Failed to get schedules")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, schedules)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type PromotionHandler struct {
+	service domain.PromotionService
+}
+
+// NewPromotionHandler creates a new promotion handler
+func NewPromotionHandler(service domain.PromotionService) *PromotionHandler {
+	return &PromotionHandler{
+		service: service,
+	}
+}
+
+// GetAll handles GET /api/promotions
+func (h *PromotionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.service.GetAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get promotions")
+		problem.WriteError(w, err, "Failed to get promotions")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, promotions)
+}
+
+// GetByID handles GET /api/promotions/{id}
+func (h *PromotionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing promotion ID")
+		return
+	}
+
+	promotion, err := h.service.GetByID(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get promotion")
+		problem.WriteError(w, err, "Failed to get promotion")
+		return
+	}
+
+	if promotion == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Promotion not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, promotion)
+}
+
+// Create handles POST /api/promotions
+func (h *PromotionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.PromotionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Code == "" || input.DiscountType == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	promotion, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		log.Error().Err(err).Interface("input", input).Msg("failed to create promotion")
+		problem.WriteError(w, err, "Failed to create promotion")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, promotion)
+}
+
+// Update handles PUT /api/promotions/{id}
+func (h *PromotionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing promotion ID")
+		return
+	}
+
+	var input domain.PromotionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Code == "" || input.DiscountType == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	promotion, err := h.service.Update(r.Context(), id, input)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update promotion")
+		problem.WriteError(w, err, "Failed to update promotion")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, promotion)
+}
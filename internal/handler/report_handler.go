@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type ReportHandler struct {
+	service  domain.ReportService
+	location *time.Location
+}
+
+// NewReportHandler creates a new report handler. Dates are days of the
+// calendar in location, and reports are grouped in it.
+func NewReportHandler(service domain.ReportService, location *time.Location) *ReportHandler {
+	return &ReportHandler{
+		service:  service,
+		location: location,
+	}
+}
+
+// GetRevenueByMonth handles GET /api/reports/revenue/monthly
+func (h *ReportHandler) GetRevenueByMonth(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, r, h.location, "revenue by month", h.service.GetRevenueByMonth)
+}
+
+// GetRevenueByLocation handles GET /api/reports/revenue/locations
+func (h *ReportHandler) GetRevenueByLocation(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, r, h.location, "revenue by location", h.service.GetRevenueByLocation)
+}
+
+// GetRevenueByPackageType handles GET /api/reports/revenue/package-types
+func (h *ReportHandler) GetRevenueByPackageType(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, r, h.location, "revenue by package type", h.service.GetRevenueByPackageType)
+}
+
+// GetRevenueByPaymentMethod handles GET /api/reports/revenue/payment-methods
+func (h *ReportHandler) GetRevenueByPaymentMethod(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, r, h.location, "revenue by payment method", h.service.GetRevenueByPaymentMethod)
+}
+
+// GetSessionCounts handles GET /api/reports/sessions
+func (h *ReportHandler) GetSessionCounts(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, r, h.location, "session counts", h.service.GetSessionCounts)
+}
+
+// GetFillRateByClass handles GET /api/reports/fill-rate/classes
+func (h *ReportHandler) GetFillRateByClass(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, r, h.location, "fill rate by class", h.service.GetFillRateByClass)
+}
+
+// GetFillRateBySlot handles GET /api/reports/fill-rate/slots
+func (h *ReportHandler) GetFillRateBySlot(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, r, h.location, "fill rate by slot", h.service.GetFillRateBySlot)
+}
+
+// respondWithReport responds with a report for the ?from= and ?to= dates of
+// a request, both inclusive and in YYYY-MM-DD format
+func respondWithReport[T any](w http.ResponseWriter, r *http.Request, location *time.Location, name string, report func(domain.ReportFilter) ([]T, error)) {
+	filter, err := reportFilter(r, location)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid date range, expected YYYY-MM-DD")
+		return
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid date range, from is after to")
+		return
+	}
+
+	rows, err := report(filter)
+	if err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get " + name)
+		problem.WriteError(w, err, "Failed to get report")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, rows)
+}
+
+// reportFilter parses the date range of a report request in location. The
+// to date is included, so the filter ends the day after.
+func reportFilter(r *http.Request, location *time.Location) (domain.ReportFilter, error) {
+	filter := domain.ReportFilter{Location: location}
+
+	if from := r.URL.Query().Get("from"); from != "" {
+		date, err := time.ParseInLocation(dateLayout, from, location)
+		if err != nil {
+			return filter, err
+		}
+		filter.From = date
+	}
+
+	if to := r.URL.Query().Get("to"); to != "" {
+		date, err := time.ParseInLocation(dateLayout, to, location)
+		if err != nil {
+			return filter, err
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
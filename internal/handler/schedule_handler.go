@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+// dateLayout is the format of the {date} URL parameters
+const dateLayout = "2006-01-02"
+
+type ScheduleHandler struct {
+	service  domain.ScheduleService
+	location *time.Location
+}
+
+// NewScheduleHandler creates a new schedule handler. Dates are days of the
+// calendar in location.
+func NewScheduleHandler(service domain.ScheduleService, location *time.Location) *ScheduleHandler {
+	return &ScheduleHandler{
+		service:  service,
+		location: location,
+	}
+}
+
+// scheduleColumns are the columns of the schedule table export
+var scheduleColumns = []column[domain.ScheduleWithDetails]{
+	{"id", func(s *domain.ScheduleWithDetails) interface{} { return s.Schedule.ID }},
+	{"class_datetime", func(s *domain.ScheduleWithDetails) interface{} { return s.Schedule.ClassDatetime }},
+	{"class_id", func(s *domain.ScheduleWithDetails) interface{} { return s.Schedule.ClassID }},
+	{"class_name", func(s *domain.ScheduleWithDetails) interface{} { return s.Class.Name }},
+	{"class_location", func(s *domain.ScheduleWithDetails) interface{} { return string(s.Class.Location) }},
+	{"class_type", func(s *domain.ScheduleWithDetails) interface{} { return string(s.Class.Type) }},
+	{"capacity", func(s *domain.ScheduleWithDetails) interface{} { return s.Schedule.Capacity }},
+	{"booked_count", func(s *domain.ScheduleWithDetails) interface{} { return s.BookedCount }},
+	{"available_slots", func(s *domain.ScheduleWithDetails) interface{} { return s.AvailableSlots }},
+	{"series_id", func(s *domain.ScheduleWithDetails) interface{} { return s.Schedule.SeriesID }},
+}
+
+// GetAll handles GET /api/schedule, as CSV or XLSX when accepted
+func (h *ScheduleHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	if format := tableFormat(r); format != "" {
+		respondWithTable(w, format, "schedule", scheduleColumns, h.service.EachWithDetails)
+		return
+	}
+
+	schedules, err := h.service.GetAllWithDetails()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all schedules")
+		problem.WriteError(w, err, "Failed to get schedules")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, schedules)
+}
+
+// GetByID handles GET /api/schedule/{id}
+func (h *ScheduleHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing schedule ID")
+		return
+	}
+
+	schedule, err := h.service.GetWithDetails(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get schedule by ID")
+		problem.WriteError(w, err, "Failed to get schedule")
+		return
+	}
+
+	if schedule == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Schedule not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, schedule)
+}
+
+// Create handles POST /api/schedule
+func (h *ScheduleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.ScheduleInput
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClassID == "" || input.Capacity == 0 || input.ClassDatetime.IsZero() {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	schedule, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		log.Error().Err(err).Interface("input", input).Msg("failed to create schedule")
+		problem.WriteError(w, err, "Failed to create schedule")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, schedule)
+}
+
+// Update handles PUT /api/schedule/{id}
+func (h *ScheduleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing schedule ID")
+		return
+	}
+
+	var input domain.ScheduleInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClassID == "" || input.Capacity == 0 || input.ClassDatetime.IsZero() {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	schedule, err := h.service.Update(r.Context(), id, input)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update schedule")
+		problem.WriteError(w, err, "Failed to update schedule")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, schedule)
+}
+
+// Delete handles DELETE /api/schedule/{id}
+func (h *ScheduleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing schedule ID")
+		return
+	}
+
+	err := h.service.Delete(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to delete schedule")
+		problem.WriteError(w, err, "Failed to delete schedule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetByDate handles GET /api/schedule/date/{date}
+func (h *ScheduleHandler) GetByDate(w http.ResponseWriter, r *http.Request) {
+	date, err := time.ParseInLocation(dateLayout, chi.URLParam(r, "date"), h.location)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid date, expected YYYY-MM-DD")
+		return
+	}
+
+	schedules, err := h.service.GetByDate(date)
+	if err != nil {
+		log.Error().Err(err).Time("date", date).Msg("failed to get schedules by date")
+		problem.WriteError(w, err, "Failed to get schedules")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, schedules)
+}
+
+// GetByWeek handles GET /api/schedule/week/{date}
+func (h *ScheduleHandler) GetByWeek(w http.ResponseWriter, r *http.Request) {
+	date, err := time.ParseInLocation(dateLayout, chi.URLParam(r, "date"), h.location)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid date, expected YYYY-MM-DD")
+		return
+	}
+
+	schedules, err := h.service.GetByWeek(date)
+	if err != nil {
+		log.Error().Err(err).Time("date", date).Msg("failed to get schedules by week")
+		problem.WriteError(w, err, "Failed to get schedules")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, schedules)
+}
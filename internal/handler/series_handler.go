@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type SeriesHandler struct {
+	service domain.ScheduleSeriesService
+}
+
+// NewSeriesHandler creates a new schedule series handler
+func NewSeriesHandler(service domain.ScheduleSeriesService) *SeriesHandler {
+	return &SeriesHandler{
+		service: service,
+	}
+}
+
+// GetAll handles GET /api/series
+func (h *SeriesHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	series, err := h.service.GetAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all schedule series")
+		problem.WriteError(w, err, "Failed to get series")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, series)
+}
+
+// GetByID handles GET /api/series/{id}
+func (h *SeriesHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing series ID")
+		return
+	}
+
+	series, err := h.service.GetByID(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get schedule series by ID")
+		problem.WriteError(w, err, "Failed to get series")
+		return
+	}
+
+	if series == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Series not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, series)
+}
+
+// GetOccurrences handles GET /api/series/{id}/schedule
+func (h *SeriesHandler) GetOccurrences(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing series ID")
+		return
+	}
+
+	schedules, err := h.service.GetOccurrences(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get series occurrences")
+		problem.WriteError(w, err, "Failed to get series occurrences")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, schedules)
+}
+
+// Create handles POST /api/series
+func (h *SeriesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.ScheduleSeriesInput
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClassID == "" || input.Capacity == 0 || input.Start.IsZero() {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	series, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		log.Error().Err(err).Interface("input", input).Msg("failed to create schedule series")
+		problem.WriteError(w, err, "Failed to create series")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, series)
+}
+
+// Update handles PUT /api/series/{id}
+func (h *SeriesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing series ID")
+		return
+	}
+
+	var input domain.ScheduleSeriesUpdateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClassID == "" || input.Capacity == 0 || input.Start.IsZero() {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	series, err := h.service.Update(r.Context(), id, input)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Interface("input", input).Msg("failed to update schedule series")
+		problem.WriteError(w, err, "Failed to update series")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, series)
+}
+
+// Delete handles DELETE /api/series/{id}
+func (h *SeriesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing series ID")
+		return
+	}
+
+	err := h.service.Delete(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to delete schedule series")
+		problem.WriteError(w, err, "Failed to delete series")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
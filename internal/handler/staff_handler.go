@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/middleware"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type StaffHandler struct {
+	service domain.StaffService
+}
+
+// NewStaffHandler creates a new staff handler
+func NewStaffHandler(service domain.StaffService) *StaffHandler {
+	return &StaffHandler{
+		service: service,
+	}
+}
+
+// Login handles POST /api/auth/login
+func (h *StaffHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var input domain.LoginInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Email == "" || input.Password == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	session, err := h.service.Login(input)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			log.Warn().Str("email", input.Email).Str("remoteAddr", r.RemoteAddr).Msg("failed staff login")
+			problem.WriteError(w, err, "")
+			return
+		}
+		log.Error().Err(err).Str("email", input.Email).Msg("failed to log in staff")
+		problem.WriteError(w, err, "Failed to log in")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, session)
+}
+
+// Logout handles POST /api/auth/logout
+func (h *StaffHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Logout(middleware.BearerToken(r)); err != nil {
+		log.Error().Err(err).Msg("failed to log out staff")
+		problem.WriteError(w, err, "Failed to log out")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me handles GET /api/auth/me
+func (h *StaffHandler) Me(w http.ResponseWriter, r *http.Request) {
+	respondwithJSON(w, http.StatusOK, middleware.StaffFrom(r.Context()))
+}
+
+// GetAll handles GET /api/staff
+func (h *StaffHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	users, err := h.service.GetAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get staff")
+		problem.WriteError(w, err, "Failed to get staff")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, users)
+}
+
+// GetByID handles GET /api/staff/{id}
+func (h *StaffHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing staff ID")
+		return
+	}
+
+	user, err := h.service.GetByID(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get staff")
+		problem.WriteError(w, err, "Failed to get staff")
+		return
+	}
+
+	if user == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Staff not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, user)
+}
+
+// Create handles POST /api/staff
+func (h *StaffHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.StaffInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Email == "" || input.Name == "" || input.Role == "" || input.Password == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	user, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		log.Error().Err(err).Str("email", input.Email).Msg("failed to create staff")
+		problem.WriteError(w, err, "Failed to create staff")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, user)
+}
+
+// Update handles PUT /api/staff/{id}
+func (h *StaffHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing staff ID")
+		return
+	}
+
+	var input domain.StaffInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Email == "" || input.Name == "" || input.Role == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	user, err := h.service.Update(r.Context(), id, input)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to update staff")
+		problem.WriteError(w, err, "Failed to update staff")
+		return
+	}
+
+	if user == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Staff not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, user)
+}
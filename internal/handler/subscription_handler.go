@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultRenewalDays is how far ahead renewals are listed when no number of
+// days is given
+const defaultRenewalDays = 30
+
+type SubscriptionHandler struct {
+	service domain.SubscriptionService
+}
+
+// NewSubscriptionHandler creates a new subscription handler
+func NewSubscriptionHandler(service domain.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		service: service,
+	}
+}
+
+// GetAll handles GET /api/subscriptions
+func (h *SubscriptionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.service.GetAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get subscriptions")
+		problem.WriteError(w, err, "Failed to get subscriptions")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, subscriptions)
+}
+
+// GetByID handles GET /api/subscriptions/{id}
+func (h *SubscriptionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing subscription ID")
+		return
+	}
+
+	subscription, err := h.service.GetByID(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get subscription")
+		problem.WriteError(w, err, "Failed to get subscription")
+		return
+	}
+
+	if subscription == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Subscription not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, subscription)
+}
+
+// GetByClientID handles GET /api/clients/{id}/subscriptions
+func (h *SubscriptionHandler) GetByClientID(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	if clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing client ID")
+		return
+	}
+
+	subscriptions, err := h.service.GetByClient(clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get client subscriptions")
+		problem.WriteError(w, err, "Failed to get subscriptions")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, subscriptions)
+}
+
+// GetRenewals handles GET /api/subscriptions/renewals?days=30
+func (h *SubscriptionHandler) GetRenewals(w http.ResponseWriter, r *http.Request) {
+	days := defaultRenewalDays
+	if param := r.URL.Query().Get("days"); param != "" {
+		var err error
+		if days, err = strconv.Atoi(param); err != nil || days < 0 {
+			problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid number of days")
+			return
+		}
+	}
+
+	renewals, err := h.service.GetRenewals(days)
+	if err != nil {
+		log.Error().Err(err).Int("days", days).Msg("failed to get subscription renewals")
+		problem.WriteError(w, err, "Failed to get subscription renewals")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, renewals)
+}
+
+// Create handles POST /api/subscriptions
+func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.SubscriptionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClientID == "" || input.PackageID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	if input.Period != "" && input.Period.Months() == 0 {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid period, expected MONTHLY, QUARTERLY or YEARLY")
+		return
+	}
+
+	subscription, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		log.Error().Err(err).Interface("input", input).Msg("failed to create subscription")
+		problem.WriteError(w, err, "Failed to create subscription")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, subscription)
+}
+
+// Pause handles POST /api/subscriptions/{id}/pause
+func (h *SubscriptionHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, "pause", h.service.Pause)
+}
+
+// Resume handles POST /api/subscriptions/{id}/resume
+func (h *SubscriptionHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, "resume", h.service.Resume)
+}
+
+// Cancel handles POST /api/subscriptions/{id}/cancel
+func (h *SubscriptionHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, "cancel", h.service.Cancel)
+}
+
+// change applies a status change to the subscription of the request
+func (h *SubscriptionHandler) change(w http.ResponseWriter, r *http.Request, action string, fn func(ctx context.Context, id string) (*domain.Subscription, error)) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing subscription ID")
+		return
+	}
+
+	subscription, err := fn(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to " + action + " subscription")
+		problem.WriteError(w, err, "Failed to "+action+" subscription")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, subscription)
+}
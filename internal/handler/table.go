@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/matthieukhl/align-back/internal/spreadsheet"
+	"github.com/rs/zerolog/log"
+)
+
+// Media types of the spreadsheet formats list endpoints can respond with
+const (
+	csvMediaType  = "text/csv"
+	xlsxMediaType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// column is a column of a table of T, with its header and cell value
+type column[T any] struct {
+	header string
+	value  func(*T) interface{}
+}
+
+// tableFormat returns the spreadsheet media type a request accepts before
+// JSON, or empty to respond with JSON. Quality values are ignored; the first
+// listed type wins.
+func tableFormat(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case csvMediaType, xlsxMediaType:
+			return mediaType
+		case "application/json", "application/*", "*/*":
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// respondWithTable streams the items each yields as a CSV or XLSX attachment
+// named after the table. Rows are written as they are read, so an error after
+// the first row can only be logged and truncates the response.
+func respondWithTable[T any](w http.ResponseWriter, format string, name string, columns []column[T], each func(func(*T) error) error) {
+	var out spreadsheet.Writer
+
+	start := func() error {
+		var err error
+		extension := "csv"
+		if format == xlsxMediaType {
+			extension = "xlsx"
+			if out, err = spreadsheet.NewXLSXWriter(w, name); err != nil {
+				return err
+			}
+		} else {
+			out = spreadsheet.NewCSVWriter(w)
+		}
+
+		w.Header().Set("Content-Type", format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, extension))
+		w.WriteHeader(http.StatusOK)
+
+		headers := make([]interface{}, len(columns))
+		for i, c := range columns {
+			headers[i] = c.header
+		}
+		return out.WriteRow(headers)
+	}
+
+	err := each(func(item *T) error {
+		if out == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		values := make([]interface{}, len(columns))
+		for i, c := range columns {
+			values[i] = c.value(item)
+		}
+		return out.WriteRow(values)
+	})
+	if err != nil {
+		log.Error().Err(err).Str("table", name).Msg("failed to export table")
+		if out == nil {
+			problem.WriteError(w, err, "Failed to export "+name)
+		}
+		return
+	}
+
+	// Empty tables still have their header row
+	if out == nil {
+		if err := start(); err != nil {
+			log.Error().Err(err).Str("table", name).Msg("failed to export table")
+			problem.WriteError(w, err, "Failed to export "+name)
+			return
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		log.Error().Err(err).Str("table", name).Msg("failed to export table")
+	}
+}
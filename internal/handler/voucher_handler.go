@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type VoucherHandler struct {
+	service domain.VoucherService
+}
+
+// NewVoucherHandler creates a new voucher handler
+func NewVoucherHandler(service domain.VoucherService) *VoucherHandler {
+	return &VoucherHandler{
+		service: service,
+	}
+}
+
+// GetAll handles GET /api/vouchers
+func (h *VoucherHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	vouchers, err := h.service.GetAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get vouchers")
+		problem.WriteError(w, err, "Failed to get vouchers")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, vouchers)
+}
+
+// GetByID handles GET /api/vouchers/{id}
+func (h *VoucherHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing voucher ID")
+		return
+	}
+
+	voucher, err := h.service.GetByID(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get voucher")
+		problem.WriteError(w, err, "Failed to get voucher")
+		return
+	}
+
+	if voucher == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Voucher not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, voucher)
+}
+
+// GetByCode handles GET /api/vouchers/code/{code}
+func (h *VoucherHandler) GetByCode(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing voucher code")
+		return
+	}
+
+	voucher, err := h.service.GetByCode(code)
+	if err != nil {
+		log.Error().Err(err).Str("code", code).Msg("failed to get voucher by code")
+		problem.WriteError(w, err, "Failed to get voucher")
+		return
+	}
+
+	if voucher == nil {
+		problem.Write(w, http.StatusNotFound, problem.CodeNotFound, "Voucher not found")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, voucher)
+}
+
+// Create handles POST /api/vouchers
+func (h *VoucherHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input domain.VoucherInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.Kind == "" || input.PurchaserName == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	voucher, err := h.service.Create(r.Context(), input)
+	if err != nil {
+		log.Error().Err(err).Interface("input", input).Msg("failed to create voucher")
+		problem.WriteError(w, err, "Failed to create voucher")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, voucher)
+}
+
+// Redeem handles POST /api/vouchers/code/{code}/redeem
+func (h *VoucherHandler) Redeem(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing voucher code")
+		return
+	}
+
+	var input domain.RedemptionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	redemption, err := h.service.Redeem(r.Context(), code, input)
+	if err != nil {
+		log.Error().Err(err).Str("code", code).Interface("input", input).Msg("failed to redeem voucher")
+		problem.WriteError(w, err, "Failed to redeem voucher")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, redemption)
+}
+
+// Cancel handles POST /api/vouchers/{id}/cancel
+func (h *VoucherHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing voucher ID")
+		return
+	}
+
+	voucher, err := h.service.Cancel(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to cancel voucher")
+		problem.WriteError(w, err, "Failed to cancel voucher")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, voucher)
+}
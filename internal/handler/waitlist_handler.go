@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+type WaitlistHandler struct {
+	service domain.WaitlistService
+}
+
+// NewWaitlistHandler creates a new waitlist handler
+func NewWaitlistHandler(service domain.WaitlistService) *WaitlistHandler {
+	return &WaitlistHandler{
+		service: service,
+	}
+}
+
+// GetBySchedule handles GET /api/schedule/{id}/waitlist
+func (h *WaitlistHandler) GetBySchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := chi.URLParam(r, "id")
+	if scheduleID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing schedule ID")
+		return
+	}
+
+	entries, err := h.service.GetBySchedule(scheduleID)
+	if err != nil {
+		log.Error().Err(err).Str("scheduleID", scheduleID).Msg("failed to get waitlist")
+		problem.WriteError(w, err, "Failed to get waitlist")
+		return
+	}
+
+	respondwithJSON(w, http.StatusOK, entries)
+}
+
+// Join handles POST /api/schedule/{id}/waitlist
+func (h *WaitlistHandler) Join(w http.ResponseWriter, r *http.Request) {
+	scheduleID := chi.URLParam(r, "id")
+	if scheduleID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing schedule ID")
+		return
+	}
+
+	var input domain.WaitlistInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if input.ClientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeValidationFailed, "Missing required fields")
+		return
+	}
+
+	entry, err := h.service.Join(r.Context(), scheduleID, input)
+	if err != nil {
+		log.Error().Err(err).Str("scheduleID", scheduleID).Interface("input", input).Msg("failed to join waitlist")
+		problem.WriteError(w, err, "Failed to join waitlist")
+		return
+	}
+
+	respondwithJSON(w, http.StatusCreated, entry)
+}
+
+// Leave handles DELETE /api/schedule/{id}/waitlist/{clientId}
+func (h *WaitlistHandler) Leave(w http.ResponseWriter, r *http.Request) {
+	scheduleID := chi.URLParam(r, "id")
+	clientID := chi.URLParam(r, "clientId")
+	if scheduleID == "" || clientID == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidParameter, "Missing schedule or client ID")
+		return
+	}
+
+	err := h.service.Leave(r.Context(), scheduleID, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("scheduleID", scheduleID).Str("clientID", clientID).Msg("failed to leave waitlist")
+		problem.WriteError(w, err, "Failed to leave waitlist")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+// Package mail sends the emails of the API, through SMTP or, in
+// development, to the log
+package mail
+
+import (
+	"fmt"
+	"mime"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SMTP sends plain text emails through an SMTP server
+type SMTP struct {
+	host     string
+	addr     string
+	username string
+	password string
+	from     *netmail.Address
+}
+
+// NewSMTP creates a mailer sending emails from an address, e.g.
+// "Studio <contact@example.com>", through an SMTP server. It authenticates
+// when a username is given.
+func NewSMTP(host string, port int, username string, password string, from string) (*SMTP, error) {
+	address, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", from, err)
+	}
+
+	return &SMTP{
+		host:     host,
+		addr:     host + ":" + strconv.Itoa(port),
+		username: username,
+		password: password,
+		from:     address,
+	}, nil
+}
+
+// Send implements domain.Mailer
+func (m *SMTP) Send(to string, subject string, body string) error {
+	recipient, err := netmail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address %q: %w", to, err)
+	}
+
+	// Headers cannot be split to inject others
+	if strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid subject %q", subject)
+	}
+
+	var message strings.Builder
+	message.WriteString("From: " + m.from.String() + "\r\n")
+	message.WriteString("To: " + recipient.String() + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.addr, auth, m.from.Address, []string{recipient.Address}, []byte(message.String())); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", recipient.Address, err)
+	}
+
+	return nil
+}
+
+// Log writes emails to the log instead of sending them, for development
+type Log struct{}
+
+// NewLog creates a mailer writing emails to the log
+func NewLog() *Log {
+	return &Log{}
+}
+
+// Send implements domain.Mailer
+func (m *Log) Send(to string, subject string, body string) error {
+	log.Info().Str("to", to).Str("subject", subject).Str("body", body).Msg("email not sent, no SMTP server configured")
+	return nil
+}
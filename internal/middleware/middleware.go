@@ -0,0 +1,138 @@
+// Package middleware holds the HTTP middlewares specific to the API
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/internal/problem"
+	"github.com/rs/zerolog/log"
+)
+
+// staffKey is the context key of the authenticated staff member
+type staffKey struct{}
+
+// clientKey is the context key of the client authenticated on the portal
+type clientKey struct{}
+
+// StaffFrom returns the staff member authenticated by Authenticate, or nil
+func StaffFrom(ctx context.Context) *domain.StaffUser {
+	user, _ := ctx.Value(staffKey{}).(*domain.StaffUser)
+	return user
+}
+
+// WithStaff returns a copy of ctx carrying an authenticated staff member,
+// who is also the actor of the changes made with it
+func WithStaff(ctx context.Context, user *domain.StaffUser) context.Context {
+	ctx = domain.WithActor(ctx, domain.Actor{Type: domain.ActorStaff, ID: user.ID})
+	return context.WithValue(ctx, staffKey{}, user)
+}
+
+// ClientFrom returns the client authenticated by AuthenticateClient, or nil
+func ClientFrom(ctx context.Context) *domain.Client {
+	client, _ := ctx.Value(clientKey{}).(*domain.Client)
+	return client
+}
+
+// WithClient returns a copy of ctx carrying an authenticated client, who is
+// also the actor of the changes made with it
+func WithClient(ctx context.Context, client *domain.Client) context.Context {
+	ctx = domain.WithActor(ctx, domain.Actor{Type: domain.ActorClient, ID: client.ID})
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// BearerToken returns the token of the Authorization header of a request,
+// or an empty string
+func BearerToken(r *http.Request) string {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Authenticate rejects requests without a valid staff session token and
+// makes the staff member available to the next handlers through StaffFrom
+func Authenticate(staff domain.StaffService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := staff.Authenticate(BearerToken(r))
+			if err != nil {
+				if errors.Is(err, domain.ErrInvalidSession) {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="align-back"`)
+					problem.WriteError(w, err, "Unauthorized")
+					return
+				}
+				log.Error().Err(err).Msg("failed to authenticate staff")
+				problem.WriteError(w, err, "Failed to authenticate")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithStaff(r.Context(), user)))
+		})
+	}
+}
+
+// AuthenticateClient rejects requests without a valid portal session token
+// and makes the client available to the next handlers through ClientFrom
+func AuthenticateClient(portal domain.PortalService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client, err := portal.Authenticate(BearerToken(r))
+			if err != nil {
+				if errors.Is(err, domain.ErrInvalidSession) {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="align-back-portal"`)
+					problem.WriteError(w, err, "Unauthorized")
+					return
+				}
+				log.Error().Err(err).Msg("failed to authenticate client")
+				problem.WriteError(w, err, "Failed to authenticate")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithClient(r.Context(), client)))
+		})
+	}
+}
+
+// RequireRole only lets staff members with one of roles through. It must be
+// used after Authenticate.
+func RequireRole(roles ...domain.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := StaffFrom(r.Context())
+			if user == nil || !slices.Contains(roles, user.Role) {
+				problem.Write(w, http.StatusForbidden, problem.CodeForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReadOnlyFor only lets staff members with one of roles read, with GET or
+// HEAD requests. Other roles are let through. It must be used after
+// Authenticate.
+func ReadOnlyFor(roles ...domain.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := StaffFrom(r.Context())
+			if user == nil {
+				problem.Write(w, http.StatusForbidden, problem.CodeForbidden, "Forbidden")
+				return
+			}
+
+			if slices.Contains(roles, user.Role) && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				problem.Write(w, http.StatusForbidden, problem.CodeForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
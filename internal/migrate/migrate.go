@@ -0,0 +1,468 @@
+// Package migrate applies versioned SQL migrations to the database. Applied
+// migrations are recorded in the schema_migrations table with the checksum
+// of their up script, and a MySQL named lock keeps two instances from
+// migrating at the same time.
+//
+// MySQL commits schema changes implicitly, so a migration failing midway is
+// not rolled back and must be repaired by hand before migrating again.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lockTimeout is how long to wait for another instance to finish migrating
+const lockTimeout = 60 * time.Second
+
+// fileName matches migration files, e.g. 0002_add_rooms.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema. Down is empty, or has no
+// statements, for migrations that cannot be reverted.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// State is the state of a migration in the database
+type State string
+
+const (
+	// Pending migrations are not applied yet
+	Pending State = "pending"
+	// Applied migrations match their recorded checksum
+	Applied State = "applied"
+	// Modified migrations were changed after being applied
+	Modified State = "modified"
+	// Missing migrations are applied but unknown to this build
+	Missing State = "missing"
+)
+
+// Status is the state of a migration, with when it was applied
+type Status struct {
+	Version   int64
+	Name      string
+	State     State
+	AppliedAt *time.Time
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// New creates a migrator applying the migrations of fsys to db
+func New(db *sqlx.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Load reads the migrations of fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+			migration.Checksum = checksum(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// Up applies the pending migrations in order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var migrated []Migration
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn, applied map[int64]appliedMigration) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if err := execScript(ctx, conn, migration.Up); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			if err := record(ctx, conn, migration); err != nil {
+				return err
+			}
+
+			migrated = append(migrated, migration)
+		}
+		return nil
+	})
+
+	return migrated, err
+}
+
+// Baseline records the first migration as applied without running it, to
+// adopt a database whose schema was created before migrations, and returns
+// it. It fails once any migration is applied.
+func (m *Migrator) Baseline(ctx context.Context) (*Migration, error) {
+	if len(m.migrations) == 0 {
+		return nil, fmt.Errorf("no migration to baseline")
+	}
+	migration := m.migrations[0]
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn, applied map[int64]appliedMigration) error {
+		if len(applied) > 0 {
+			return fmt.Errorf("cannot baseline a database with applied migrations")
+		}
+		return record(ctx, conn, migration)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &migration, nil
+}
+
+// Down reverts the last steps applied migrations, latest first, and returns
+// them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn, applied map[int64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			// A down script left as created would revert nothing
+			if len(splitStatements(migration.Down)) == 0 {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+
+			if err := execScript(ctx, conn, migration.Down); err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version); err != nil {
+				return fmt.Errorf("failed to unrecord migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status returns the state of every known or applied migration, ordered by
+// version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name, State: Pending}
+		if row, ok := applied[migration.Version]; ok {
+			status.State = Applied
+			if row.Checksum != migration.Checksum {
+				status.State = Modified
+			}
+			status.AppliedAt = &row.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, row := range applied {
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, State: Missing, AppliedAt: &row.AppliedAt})
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection holding the migration lock, after
+// checking that the applied migrations match the known ones
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn, applied map[int64]appliedMigration) error) error {
+	// Named locks belong to a session, so everything runs on one connection
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.GetContext(ctx, &locked, `SELECT GET_LOCK(CONCAT(DATABASE(), '.schema_migrations'), ?)`, lockTimeout.Seconds()); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("failed to lock migrations: another instance is migrating")
+	}
+	defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.schema_migrations'))`)
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	for version, row := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("applied migration %d_%s is missing", row.Version, row.Name)
+		}
+		if row.Checksum != migration.Checksum {
+			return fmt.Errorf("applied migration %d_%s was modified, checksum mismatch", row.Version, row.Name)
+		}
+	}
+
+	return fn(conn, applied)
+}
+
+// appliedMigrations returns the applied migrations by version, creating
+// the schema_migrations table on first use
+func appliedMigrations(ctx context.Context, conn *sqlx.Conn) (map[int64]appliedMigration, error) {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)
+	`
+
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var rows []appliedMigration
+	if err := conn.SelectContext(ctx, &rows, `SELECT version, name, checksum, applied_at FROM schema_migrations`); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+// record records a migration as applied
+func record(ctx context.Context, conn *sqlx.Conn, migration Migration) error {
+	query := `
+	INSERT INTO
+		schema_migrations (
+			version
+			, name
+			, checksum
+		)
+	VALUES (?, ?, ?)
+	`
+
+	if _, err := conn.ExecContext(ctx, query, migration.Version, migration.Name, migration.Checksum); err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// execScript runs the statements of a script one by one
+func execScript(ctx context.Context, conn *sqlx.Conn, script string) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%w in %q", err, firstLine(statement))
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script on the semicolons ending its statements,
+// dropping comments. Statements cannot contain semicolons outside quotes,
+// so triggers and procedures must be single statements.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	var quote byte
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		if quote != 0 {
+			current.WriteByte(c)
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				i++
+				current.WriteByte(script[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || strings.HasPrefix(script[i:], "-- ") || strings.HasPrefix(script[i:], "--\n"):
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end
+				c = '\n'
+			} else {
+				i = len(script)
+				continue
+			}
+		case strings.HasPrefix(script[i:], "/*"):
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(script)
+			}
+			current.WriteByte(' ')
+			continue
+		case c == ';':
+			flush()
+			continue
+		}
+
+		current.WriteByte(c)
+	}
+	flush()
+
+	return statements
+}
+
+// firstLine returns the first line of a statement, to identify it in errors
+func firstLine(statement string) string {
+	line, _, _ := strings.Cut(statement, "\n")
+	return line
+}
+
+// checksum returns the hex SHA-256 of a migration script
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Create writes the empty up and down scripts of a new migration to dir,
+// numbered after the latest one, and returns their paths. The migration
+// cannot be reverted until statements are added to its down script.
+func Create(dir string, name string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`\W+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("invalid migration name")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to create migration: %w", err)
+	}
+	if err := os.WriteFile(down, []byte("-- Revert "+name+"\n"), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to create migration: %w", err)
+	}
+
+	return up, down, nil
+}
@@ -0,0 +1,180 @@
+package migrate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	dbmigrations "github.com/matthieukhl/align-back/db/migrations"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			script: "  \n\t",
+			want:   nil,
+		},
+		{
+			name:   "single statement without semicolon",
+			script: "SELECT 1",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "several statements",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "empty statements",
+			script: ";;SELECT 1;;",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "semicolons in quotes",
+			script: `INSERT INTO a VALUES ('a;b', "c;d", ` + "`e;f`" + `);SELECT 1`,
+			want:   []string{`INSERT INTO a VALUES ('a;b', "c;d", ` + "`e;f`" + `)`, "SELECT 1"},
+		},
+		{
+			name:   "escaped quotes",
+			script: `INSERT INTO a VALUES ('it\'s;', 'it''s;');SELECT 1`,
+			want:   []string{`INSERT INTO a VALUES ('it\'s;', 'it''s;')`, "SELECT 1"},
+		},
+		{
+			name:   "line comments",
+			script: "-- first; table\nCREATE TABLE a (id INT); # second; table\n--\nSELECT 1",
+			want:   []string{"CREATE TABLE a (id INT)", "SELECT 1"},
+		},
+		{
+			name:   "double dash without space is not a comment",
+			script: "SELECT 1--1;SELECT 2",
+			want:   []string{"SELECT 1--1", "SELECT 2"},
+		},
+		{
+			name:   "block comments",
+			script: "CREATE /* a; b */ TABLE a (id INT);/* trailing; */",
+			want:   []string{"CREATE   TABLE a (id INT)"},
+		},
+		{
+			name:   "comment markers in quotes",
+			script: "INSERT INTO a VALUES ('-- a', '# b', '/* c */');",
+			want:   []string{"INSERT INTO a VALUES ('-- a', '# b', '/* c */')"},
+		},
+		{
+			name:   "unterminated comment",
+			script: "SELECT 1; -- the end",
+			want:   []string{"SELECT 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !slices.Equal(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0010_add_rooms.up.sql":    {Data: []byte("CREATE TABLE rooms (id INT);")},
+		"0010_add_rooms.down.sql":  {Data: []byte("DROP TABLE rooms;")},
+		"0002_seed_only_up.up.sql": {Data: []byte("SELECT 1;")},
+		"0001_baseline.up.sql":     {Data: []byte("CREATE TABLE a (id INT);")},
+		"0001_baseline.down.sql":   {Data: []byte("DROP TABLE a;")},
+		"migrations.go":            {Data: []byte("package migrations")},
+		"README.md":                {Data: []byte("not a migration")},
+		"0003_nested.up.sql/x":     {Data: []byte("in a directory")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []struct {
+		version int64
+		name    string
+		down    bool
+	}{
+		{1, "baseline", true},
+		{2, "seed_only_up", false},
+		{10, "add_rooms", true},
+	}
+
+	if len(migrations) != len(want) {
+		t.Fatalf("Load() returned %d migrations, want %d", len(migrations), len(want))
+	}
+
+	for i, w := range want {
+		migration := migrations[i]
+		if migration.Version != w.version || migration.Name != w.name {
+			t.Errorf("migration %d = %d_%s, want %d_%s", i, migration.Version, migration.Name, w.version, w.name)
+		}
+		if (migration.Down != "") != w.down {
+			t.Errorf("migration %d_%s has down script = %t, want %t", migration.Version, migration.Name, migration.Down != "", w.down)
+		}
+		if migration.Checksum != checksum([]byte(migration.Up)) {
+			t.Errorf("migration %d_%s checksum = %s, want the checksum of its up script", migration.Version, migration.Name, migration.Checksum)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{
+			name: "missing up script",
+			fsys: fstest.MapFS{
+				"0001_baseline.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+			want: "has no up script",
+		},
+		{
+			name: "two names",
+			fsys: fstest.MapFS{
+				"0001_baseline.up.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+				"0001_initial.up.sql":  {Data: []byte("CREATE TABLE a (id INT);")},
+			},
+			want: "has two names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(dbmigrations.FS)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for _, migration := range migrations {
+		if len(splitStatements(migration.Down)) == 0 {
+			t.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+
+		// Sample data belongs to db/seed.sql, not to every database
+		for _, statement := range splitStatements(migration.Up) {
+			if strings.HasPrefix(strings.ToUpper(statement), "INSERT") {
+				t.Errorf("migration %d_%s inserts data: %q", migration.Version, migration.Name, firstLine(statement))
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+)
+
+// fakeCheckout is a checkout held in memory by the fake provider
+type fakeCheckout struct {
+	checkout  domain.ProviderCheckout
+	price     money.Money
+	refunded  money.Money
+	expiresAt time.Time
+}
+
+// expire fails a pending checkout past its expiry, as the provider page
+// no longer takes the payment
+func (c *fakeCheckout) expire(now time.Time) {
+	if c.checkout.Status == domain.PendingCheckout && now.After(c.expiresAt) {
+		c.checkout.Status = domain.FailedCheckout
+	}
+}
+
+// Fake is a payment provider keeping checkouts in memory, for development
+// and tests. Nobody is charged: checkouts are settled by calling Settle,
+// which returns the signed event a real provider would send to the webhook.
+type Fake struct {
+	mu        sync.Mutex
+	baseURL   string
+	secret    string
+	checkouts map[string]*fakeCheckout
+	payments  map[string]string
+}
+
+// NewFake creates a fake provider whose checkout pages are served under
+// baseURL and whose events are signed with secret
+func NewFake(baseURL string, secret string) *Fake {
+	return &Fake{
+		baseURL:   baseURL,
+		secret:    secret,
+		checkouts: make(map[string]*fakeCheckout),
+		payments:  make(map[string]string),
+	}
+}
+
+// Name implements domain.PaymentProvider
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// CreateCheckout implements domain.PaymentProvider
+func (f *Fake) CreateCheckout(request domain.CheckoutRequest) (*domain.ProviderCheckout, error) {
+	if request.Price.IsNegative() || request.Price.IsZero() {
+		return nil, fmt.Errorf("checkout price must be positive: %s", request.Price)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := "cs_fake_" + utils.NewUUID()
+	f.checkouts[id] = &fakeCheckout{
+		checkout: domain.ProviderCheckout{
+			ID:     id,
+			URL:    f.baseURL + "/api/dev/payments/" + id,
+			Status: domain.PendingCheckout,
+		},
+		price:     request.Price,
+		refunded:  money.New(0, request.Price.Currency),
+		expiresAt: request.ExpiresAt,
+	}
+
+	checkout := f.checkouts[id].checkout
+	return &checkout, nil
+}
+
+// GetCheckout implements domain.PaymentProvider
+func (f *Fake) GetCheckout(id string) (*domain.ProviderCheckout, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fake, ok := f.checkouts[id]
+	if !ok {
+		return nil, fmt.Errorf("checkout %s not found", id)
+	}
+
+	fake.expire(time.Now())
+	checkout := fake.checkout
+	return &checkout, nil
+}
+
+// Refund implements domain.PaymentProvider
+func (f *Fake) Refund(paymentID string, amount money.Money) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fake, ok := f.checkouts[f.payments[paymentID]]
+	if !ok {
+		return fmt.Errorf("payment %s not found", paymentID)
+	}
+
+	if amount.Currency != fake.price.Currency {
+		return fmt.Errorf("refund in %s of a payment in %s", amount.Currency, fake.price.Currency)
+	}
+
+	left := fake.price.Sub(fake.refunded)
+	if amount.IsNegative() || amount.IsZero() || amount.Amount > left.Amount {
+		return fmt.Errorf("refund of %s with %s left on payment %s", amount, left, paymentID)
+	}
+
+	fake.refunded = fake.refunded.Add(amount)
+	if fake.refunded == fake.price {
+		fake.checkout.Status = domain.RefundedCheckout
+	}
+
+	return nil
+}
+
+// ParseEvent implements domain.PaymentProvider. Events are JSON encoded
+// domain.PaymentEvent signed with Sign.
+func (f *Fake) ParseEvent(payload []byte, signature string) (*domain.PaymentEvent, error) {
+	if !Verify(f.secret, payload, signature) {
+		return nil, domain.ErrInvalidSignature
+	}
+
+	var event domain.PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode payment event: %w", err)
+	}
+
+	return &event, nil
+}
+
+// Settle pays or fails a pending checkout as the client would on the
+// provider page. It returns the event payload and its signature, to be
+// posted to the webhook.
+func (f *Fake) Settle(id string, paid bool) ([]byte, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fake, ok := f.checkouts[id]
+	if !ok {
+		return nil, "", fmt.Errorf("checkout %s not found", id)
+	}
+
+	fake.expire(time.Now())
+	if fake.checkout.Status != domain.PendingCheckout {
+		return nil, "", fmt.Errorf("checkout %s is %s: %w", id, fake.checkout.Status, domain.ErrCheckoutStatus)
+	}
+
+	event := domain.PaymentEvent{
+		ID:         "evt_fake_" + utils.NewUUID(),
+		Type:       domain.PaymentFailedEvent,
+		CheckoutID: id,
+		CreatedAt:  time.Now(),
+	}
+
+	fake.checkout.Status = domain.FailedCheckout
+	if paid {
+		fake.checkout.Status = domain.PaidCheckout
+		fake.checkout.PaymentID = "pi_fake_" + utils.NewUUID()
+		f.payments[fake.checkout.PaymentID] = id
+
+		event.Type = domain.PaymentSucceededEvent
+		event.PaymentID = fake.checkout.PaymentID
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode payment event: %w", err)
+	}
+
+	return payload, Sign(f.secret, payload), nil
+}
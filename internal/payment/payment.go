@@ -0,0 +1,36 @@
+// Package payment holds the online payment providers and the signing of
+// the webhook events they send
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignatureHeader is the header holding the signature of a webhook payload
+const SignatureHeader = "X-Signature"
+
+// Sign returns the hex encoded HMAC-SHA256 of a payload with a secret
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether a signature is the HMAC-SHA256 of a payload with a
+// secret, in constant time. Nothing verifies without a secret.
+func Verify(secret string, payload []byte, signature string) bool {
+	if secret == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,167 @@
+// Package pdf renders billing documents as PDF
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+const (
+	pageMargin  = 15.0
+	lineHeight  = 5.0
+	tableHeight = 8.0
+)
+
+// RenderInvoice writes an invoice or a credit note as an A4 PDF to w. Dates
+// are printed in location.
+func RenderInvoice(w io.Writer, invoice *domain.Invoice, location *time.Location) error {
+	doc := gofpdf.New("P", "mm", "A4", "")
+	doc.SetMargins(pageMargin, pageMargin, pageMargin)
+	doc.SetAutoPageBreak(true, pageMargin)
+	title, paid := "Facture", "Facture acquittée le "
+	if invoice.Kind == domain.CreditNoteDocument {
+		title, paid = "Avoir", "Remboursement effectué le "
+	}
+
+	doc.SetTitle(title+" "+invoice.Number, true)
+	doc.AddPage()
+
+	// Core fonts are encoded in cp1252
+	tr := doc.UnicodeTranslatorFromDescriptor("")
+	width, _ := doc.GetPageSize()
+	content := width - 2*pageMargin
+
+	// Seller
+	doc.SetFont("Helvetica", "B", 14)
+	doc.CellFormat(content/2, 7, tr(invoice.SellerName), "", 1, "L", false, 0, "")
+	doc.SetFont("Helvetica", "", 9)
+	seller := []string{invoice.SellerAddress, invoice.SellerContact}
+	if invoice.SellerSIRET != "" {
+		seller = append(seller, "SIRET : "+invoice.SellerSIRET)
+	}
+	if invoice.SellerVATNumber != "" {
+		seller = append(seller, "N° TVA intracommunautaire : "+invoice.SellerVATNumber)
+	}
+	doc.MultiCell(content/2, lineHeight-1, tr(joinLines(seller...)), "", "L", false)
+
+	// Title and dates
+	doc.SetXY(pageMargin+content/2, pageMargin)
+	doc.SetFont("Helvetica", "B", 16)
+	doc.CellFormat(content/2, 8, tr(strings.ToUpper(title)), "", 2, "R", false, 0, "")
+	doc.SetFont("Helvetica", "", 10)
+	doc.CellFormat(content/2, lineHeight, tr("N° "+invoice.Number), "", 2, "R", false, 0, "")
+	doc.CellFormat(content/2, lineHeight, tr("Date d'émission : "+formatDate(invoice.IssuedAt, location)), "", 2, "R", false, 0, "")
+	if invoice.CorrectedInvoiceNumber != "" {
+		doc.CellFormat(content/2, lineHeight, tr("Sur la facture N° "+invoice.CorrectedInvoiceNumber), "", 2, "R", false, 0, "")
+	}
+
+	// Buyer
+	doc.SetXY(pageMargin+content/2, 55)
+	doc.SetFont("Helvetica", "B", 10)
+	doc.CellFormat(content/2, lineHeight, tr("Facturé à"), "", 2, "L", false, 0, "")
+	doc.SetFont("Helvetica", "", 10)
+	doc.MultiCell(content/2, lineHeight, tr(joinLines(invoice.BuyerName, invoice.BuyerAddress)), "", "L", false)
+
+	// Lines
+	columns := []struct {
+		title string
+		width float64
+		align string
+	}{
+		{"Désignation", content - 103, "L"},
+		{"Quantité", 20, "R"},
+		{"Prix unitaire HT", 32, "R"},
+		{"TVA", 18, "R"},
+		{"Total HT", 33, "R"},
+	}
+
+	doc.SetY(90)
+	doc.SetFont("Helvetica", "B", 10)
+	doc.SetFillColor(235, 235, 235)
+	for _, column := range columns {
+		doc.CellFormat(column.width, tableHeight, tr(column.title), "1", 0, column.align, true, 0, "")
+	}
+	doc.Ln(-1)
+
+	doc.SetFont("Helvetica", "", 10)
+	for _, line := range invoice.Lines {
+		values := []string{line.Description, fmt.Sprintf("%d", line.Quantity), formatMoney(line.UnitPrice), formatRate(line.VATRate), formatMoney(line.Total)}
+		for i, column := range columns {
+			doc.CellFormat(column.width, tableHeight, tr(values[i]), "1", 0, column.align, false, 0, "")
+		}
+		doc.Ln(-1)
+	}
+
+	// Totals
+	doc.SetFont("Helvetica", "", 10)
+	doc.CellFormat(content-33, tableHeight, tr("Total HT"), "1", 0, "R", false, 0, "")
+	doc.CellFormat(33, tableHeight, tr(formatMoney(invoice.NetTotal)), "1", 1, "R", false, 0, "")
+	doc.CellFormat(content-33, tableHeight, tr("TVA"), "1", 0, "R", false, 0, "")
+	doc.CellFormat(33, tableHeight, tr(formatMoney(invoice.VATTotal)), "1", 1, "R", false, 0, "")
+	doc.SetFont("Helvetica", "B", 10)
+	doc.CellFormat(content-33, tableHeight, tr("Total TTC"), "1", 0, "R", false, 0, "")
+	doc.CellFormat(33, tableHeight, tr(formatMoney(invoice.Total)), "1", 1, "R", false, 0, "")
+	doc.Ln(4)
+	doc.SetFont("Helvetica", "", 10)
+	doc.CellFormat(content, lineHeight, tr(paid+formatDate(invoice.PaidAt, location)), "", 1, "L", false, 0, "")
+
+	// Legal mentions
+	if invoice.SellerLegalMentions != "" {
+		doc.Ln(10)
+		doc.SetFont("Helvetica", "I", 8)
+		doc.MultiCell(content, lineHeight-1, tr(invoice.SellerLegalMentions), "", "L", false)
+	}
+
+	return doc.Output(w)
+}
+
+// formatDate formats a date the French way, e.g. 31/01/2026
+func formatDate(t time.Time, location *time.Location) string {
+	return t.In(location).Format("02/01/2006")
+}
+
+// formatMoney formats an amount the French way, e.g. 1 250,00 €
+func formatMoney(amount money.Money) string {
+	units, cents, _ := strings.Cut(amount.Decimal(), ".")
+	sign := ""
+	if strings.HasPrefix(units, "-") {
+		sign, units = "-", units[1:]
+	}
+
+	var grouped strings.Builder
+	for i, digit := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			grouped.WriteByte(' ')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	symbol := amount.Currency
+	if symbol == money.EUR {
+		symbol = "€"
+	}
+
+	return sign + grouped.String() + "," + cents + " " + symbol
+}
+
+// formatRate formats a VAT rate in basis points the French way, e.g. 5,5 %
+func formatRate(rate int) string {
+	return strings.Replace(money.FormatRate(rate), ".", ",", 1) + " %"
+}
+
+// joinLines joins the non-empty parts with line breaks
+func joinLines(parts ...string) string {
+	lines := []string{}
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			lines = append(lines, part)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,113 @@
+// Package problem writes error responses as RFC 7807 problem details, with
+// a stable code API clients can rely on rather than the message
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// Codes of the problems not coming from domain errors
+const (
+	// CodeInvalidBody is the code of request bodies that cannot be decoded
+	CodeInvalidBody = "invalid_body"
+	// CodeInvalidParameter is the code of missing or malformed URL and
+	// query parameters
+	CodeInvalidParameter = "invalid_parameter"
+	// CodeValidationFailed is the code of invalid input
+	CodeValidationFailed = string(domain.ErrValidation)
+	// CodeNotFound is the code of missing resources
+	CodeNotFound = string(domain.ErrNotFound)
+	// CodeUnauthorized is the code of requests without valid credentials
+	CodeUnauthorized = string(domain.ErrUnauthorized)
+	// CodeForbidden is the code of requests the user is not allowed to make
+	CodeForbidden = "forbidden"
+	// CodeInternal is the code of unexpected failures
+	CodeInternal = "internal_error"
+	// CodeBadGateway is the code of failures of an upstream service
+	CodeBadGateway = "bad_gateway"
+)
+
+// statuses maps the kinds of domain errors to HTTP statuses
+var statuses = map[domain.Kind]int{
+	domain.ErrNotFound:            http.StatusNotFound,
+	domain.ErrConflict:            http.StatusConflict,
+	domain.ErrValidation:          http.StatusBadRequest,
+	domain.ErrInsufficientCredits: http.StatusConflict,
+	domain.ErrCapacityReached:     http.StatusConflict,
+	domain.ErrUnauthorized:        http.StatusUnauthorized,
+}
+
+// Problem is an RFC 7807 problem details object. Code is an extension
+// member identifying the problem, and Errors lists the invalid fields of
+// validation problems.
+type Problem struct {
+	Type   string              `json:"type"`
+	Title  string              `json:"title"`
+	Status int                 `json:"status"`
+	Code   string              `json:"code"`
+	Detail string              `json:"detail,omitempty"`
+	Errors []domain.FieldError `json:"errors,omitempty"`
+}
+
+// New creates a problem of an HTTP status
+func New(status int, code string, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// FromError creates the problem of an error. Domain errors keep their
+// message as detail; other errors are internal and detailed by fallback
+// only, not to leak their message.
+func FromError(err error, fallback string) Problem {
+	for kind, status := range statuses {
+		if !errors.Is(err, kind) {
+			continue
+		}
+
+		problem := New(status, string(kind), err.Error())
+
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			problem.Code = domainErr.Code
+			problem.Errors = domainErr.Fields
+		}
+
+		return problem
+	}
+
+	return New(http.StatusInternalServerError, CodeInternal, fallback)
+}
+
+// Write writes a problem of an HTTP status
+func Write(w http.ResponseWriter, status int, code string, detail string) {
+	write(w, New(status, code, detail))
+}
+
+// WriteError writes the problem of an error, see FromError
+func WriteError(w http.ResponseWriter, err error, fallback string) {
+	write(w, FromError(err, fallback))
+}
+
+// write writes a problem as the response
+func write(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		log.Error().Err(err).Int("status", problem.Status).Msg("failed to write problem")
+	}
+}
@@ -3,14 +3,16 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
 type appointmentRepository struct {
-	db *sqlx.DB
+	db queryer
 }
 
 // CountBySchedule return the count of appoitments for a given schedule ID.
@@ -35,19 +37,25 @@ func (r *appointmentRepository) CountBySchedule(scheduleID string) (int, error)
 
 // Create creates a new appointment.
 func (r *appointmentRepository) Create(appointment *domain.Appointment) error {
+	if appointment.ID == "" {
+		appointment.ID = utils.NewUUID()
+	}
+
 	query := `
 	INSERT INTO
 		appointments (
-			schedule_id
+			id
+			, schedule_id
 			, client_id
+			, subscription_id
 		)
-	VALUES (?, ?)
+	VALUES (?, ?, ?, NULLIF(?, ''))
 	`
 
-	_, err := r.db.Exec(query, appointment.ScheduleID, appointment.ClientID)
+	_, err := r.db.Exec(query, appointment.ID, appointment.ScheduleID, appointment.ClientID, appointment.SubscriptionID)
 	if err != nil {
 		log.Error().Err(err).Interface("appointment", appointment).Msg("failed to create appointment")
-		return fmt.Errorf("failed to create appointment: %w", err)
+		return fmt.Errorf("failed to create appointment: %w", constraintError(err))
 	}
 
 	return nil
@@ -62,12 +70,21 @@ func (r *appointmentRepository) Delete(id string) error {
 		id = ?
 	`
 
-	_, err := r.db.Exec(query, id)
+	result, err := r.db.Exec(query, id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to delete appointment")
+		return fmt.Errorf("failed to delete appointment: %w", constraintError(err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
 		return fmt.Errorf("failed to delete appointment: %w", err)
 	}
 
+	if deleted == 0 {
+		return fmt.Errorf("appointment with ID %s: %w", id, domain.ErrNotFound)
+	}
+
 	return nil
 }
 
@@ -80,6 +97,9 @@ func (r *appointmentRepository) GetAll() ([]domain.Appointment, error) {
 		id
 		, schedule_id
 		, client_id
+		, COALESCE(subscription_id, '') AS subscription_id
+		, created_at
+		, updated_at
 	FROM
 		appointments
 	`
@@ -102,6 +122,9 @@ func (r *appointmentRepository) GetByClient(clientID string) ([]domain.Appointme
 		id
 		, schedule_id
 		, client_id
+		, COALESCE(subscription_id, '') AS subscription_id
+		, created_at
+		, updated_at
 	FROM
 		appointments
 	WHERE 
@@ -122,13 +145,16 @@ func (r *appointmentRepository) GetByClient(clientID string) ([]domain.Appointme
 
 // GetByClientAndSchedule returns an appointment given a client ID and a schedule ID.
 func (r *appointmentRepository) GetByClientAndSchedule(clientID string, scheduleID string) (*domain.Appointment, error) {
-	var appointment *domain.Appointment
+	var appointment domain.Appointment
 
 	query := `
 		SELECT 
 			id
 			, schedule_id
 			, client_id
+			, COALESCE(subscription_id, '') AS subscription_id
+			, created_at
+			, updated_at
 		FROM 
 			appointments
 		WHERE
@@ -145,25 +171,28 @@ func (r *appointmentRepository) GetByClientAndSchedule(clientID string, schedule
 		return nil, fmt.Errorf("failed to get appointment by client ID and schedule ID: %w", err)
 	}
 
-	return appointment, nil
+	return &appointment, nil
 }
 
 // GetByID returns an appointment by its ID.
 func (r *appointmentRepository) GetByID(id string) (*domain.Appointment, error) {
-	var appointment *domain.Appointment
+	var appointment domain.Appointment
 
 	query := `
 	SELECT
 		id
 		, schedule_id
 		, client_id
+		, COALESCE(subscription_id, '') AS subscription_id
+		, created_at
+		, updated_at
 	FROM
 		appointments
 	WHERE 
 		id = ?
 	`
 
-	err := r.db.Get(&appointment, id)
+	err := r.db.Get(&appointment, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -172,27 +201,235 @@ func (r *appointmentRepository) GetByID(id string) (*domain.Appointment, error)
 		return nil, fmt.Errorf("failed to get appointment by ID: %w", err)
 	}
 
-	return appointment, nil
+	return &appointment, nil
+}
+
+// GetByIDForUpdate returns an appointment by ID and locks its row until the
+// surrounding transaction ends.
+func (r *appointmentRepository) GetByIDForUpdate(id string) (*domain.Appointment, error) {
+	var appointment domain.Appointment
+
+	query := `
+	SELECT
+		id
+		, schedule_id
+		, client_id
+		, COALESCE(subscription_id, '') AS subscription_id
+		, created_at
+		, updated_at
+	FROM
+		appointments
+	WHERE
+		id = ?
+	FOR UPDATE
+	`
+
+	err := r.db.Get(&appointment, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to lock appointment by ID")
+		return nil, fmt.Errorf("failed to lock appointment by ID: %w", err)
+	}
+
+	return &appointment, nil
 }
 
-// GetBySchedule implements domain.AppointmentRepository.
+// GetBySchedule returns a list of appointments for a given schedule ID.
 func (r *appointmentRepository) GetBySchedule(scheduleID string) ([]domain.Appointment, error) {
-	panic("unimplemented")
+	var appointments []domain.Appointment
+
+	query := `
+	SELECT
+		id
+		, schedule_id
+		, client_id
+		, COALESCE(subscription_id, '') AS subscription_id
+		, created_at
+		, updated_at
+	FROM
+		appointments
+	WHERE
+		schedule_id = ?
+	ORDER BY
+		created_at
+	`
+
+	err := r.db.Select(&appointments, query, scheduleID)
+	if err != nil {
+		log.Error().Err(err).Str("scheduleID", scheduleID).Msg("failed to retrieve appointments by schedule ID")
+		return nil, fmt.Errorf("failed to retrieve appointments by schedule ID: %w", err)
+	}
+
+	return appointments, nil
 }
 
-// GetUpcomingByClient implements domain.AppointmentRepository.
+// GetUpcomingByClient returns the appointments of a client for classes that have not started yet.
 func (r *appointmentRepository) GetUpcomingByClient(clientID string) ([]domain.Appointment, error) {
-	panic("unimplemented")
+	var appointments []domain.Appointment
+
+	query := `
+	SELECT
+		a.id
+		, a.schedule_id
+		, a.client_id
+		, COALESCE(a.subscription_id, '') AS subscription_id
+		, a.created_at
+		, a.updated_at
+	FROM
+		appointments a
+		INNER JOIN schedule s ON s.id = a.schedule_id
+	WHERE
+		a.client_id = ?
+		AND s.class_datetime >= NOW()
+	ORDER BY
+		s.class_datetime
+	`
+
+	err := r.db.Select(&appointments, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to retrieve upcoming appointments by client ID")
+		return nil, fmt.Errorf("failed to retrieve upcoming appointments by client ID: %w", err)
+	}
+
+	return appointments, nil
 }
 
-// GetWithDetails implements domain.AppointmentRepository.
+// GetWithDetails returns an appointment by ID with its client and schedule details.
 func (r *appointmentRepository) GetWithDetails(id string) (*domain.AppointmentWithDetails, error) {
-	panic("unimplemented")
+	appointment, err := r.GetByID(id)
+	if err != nil || appointment == nil {
+		return nil, err
+	}
+
+	client, err := (&clientRepository{db: r.db}).GetByID(appointment.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := (&scheduleRepository{db: r.db}).GetWithDetails(appointment.ScheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AppointmentWithDetails{
+		Appointment: appointment,
+		Client:      client,
+		Schedule:    schedule,
+	}, nil
 }
 
-// Update implements domain.AppointmentRepository.
+// appointmentDetailsRow is an appointment flattened with its client,
+// schedule and class
+type appointmentDetailsRow struct {
+	domain.Appointment
+	ClientFirstName string           `db:"client_firstname"`
+	ClientLastName  string           `db:"client_lastname"`
+	ClientEmail     string           `db:"client_email"`
+	ClassDatetime   time.Time        `db:"class_datetime"`
+	Capacity        int              `db:"capacity"`
+	ClassID         string           `db:"class_id"`
+	ClassName       string           `db:"class_name"`
+	ClassLocation   domain.Location  `db:"class_location"`
+	ClassType       domain.ClassType `db:"class_type"`
+}
+
+// toDetails converts a flattened row to a domain.AppointmentWithDetails.
+// Booking counts are left out.
+func (row *appointmentDetailsRow) toDetails() domain.AppointmentWithDetails {
+	appointment := row.Appointment
+
+	class := &domain.Class{
+		ID:       row.ClassID,
+		Name:     row.ClassName,
+		Location: row.ClassLocation,
+		Type:     row.ClassType,
+	}
+
+	return domain.AppointmentWithDetails{
+		Appointment: &appointment,
+		Client: &domain.Client{
+			ID:        row.ClientID,
+			FirstName: row.ClientFirstName,
+			LastName:  row.ClientLastName,
+			Email:     row.ClientEmail,
+		},
+		Schedule: &domain.ScheduleWithDetails{
+			Schedule: &domain.Schedule{
+				ID:            row.ScheduleID,
+				ClassID:       row.ClassID,
+				Capacity:      row.Capacity,
+				ClassDatetime: row.ClassDatetime,
+				Class:         class,
+			},
+			Class: class,
+		},
+	}
+}
+
+// EachWithDetails calls fn with every appointment and its client and
+// schedule, by class date, one row at a time.
+func (r *appointmentRepository) EachWithDetails(fn func(*domain.AppointmentWithDetails) error) error {
+	query := `
+	SELECT
+		ap.id
+		, ap.schedule_id
+		, ap.client_id
+		, COALESCE(ap.subscription_id, '') AS subscription_id
+		, ap.created_at
+		, ap.updated_at
+		, c.firstname AS client_firstname
+		, c.lastname AS client_lastname
+		, c.email AS client_email
+		, s.class_datetime
+		, s.capacity
+		, cl.id AS class_id
+		, cl.name AS class_name
+		, cl.location AS class_location
+		, cl.type AS class_type
+	FROM
+		appointments ap
+		INNER JOIN clients c ON c.id = ap.client_id
+		INNER JOIN schedule s ON s.id = ap.schedule_id
+		INNER JOIN classes cl ON cl.id = s.class_id
+	ORDER BY
+		s.class_datetime
+		, c.lastname
+		, c.firstname
+	`
+
+	err := each(r.db, func(row *appointmentDetailsRow) error {
+		details := row.toDetails()
+		return fn(&details)
+	}, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to iterate appointments with details")
+		return fmt.Errorf("failed to iterate appointments with details: %w", err)
+	}
+
+	return nil
+}
+
+// Update updates an existing appointment.
 func (r *appointmentRepository) Update(appointment *domain.Appointment) error {
-	panic("unimplemented")
+	query := `
+	UPDATE
+		appointments
+	SET
+		schedule_id = ?
+		, client_id = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, appointment.ScheduleID, appointment.ClientID, appointment.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("appointment", appointment).Msg("failed to update appointment")
+		return fmt.Errorf("failed to update appointment: %w", constraintError(err))
+	}
+
+	return nil
 }
 
 // NewAppointmentRepository creates a new appointment repository
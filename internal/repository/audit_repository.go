@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type auditRepository struct {
+	db queryer
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *sqlx.DB) domain.AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// Create appends an entry to the audit log.
+func (r *auditRepository) Create(entry *domain.AuditEntry) error {
+	if entry.ID == "" {
+		entry.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		audit_log (
+			id
+			, entity_type
+			, entity_id
+			, action
+			, actor_type
+			, actor_id
+			, request_id
+			, changes
+		)
+	VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?)
+	`
+
+	_, err := r.db.Exec(query, entry.ID, entry.EntityType, entry.EntityID, entry.Action, entry.ActorType, entry.ActorID,
+		entry.RequestID, string(entry.Changes))
+	if err != nil {
+		log.Error().Err(err).Str("entityType", entry.EntityType).Str("entityID", entry.EntityID).Msg("failed to create audit entry")
+		return fmt.Errorf("failed to create audit entry: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// Find returns the audit entries of an entity type, and of an entity when
+// an ID is given, newest first.
+func (r *auditRepository) Find(filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	var entries []domain.AuditEntry
+
+	condition, args := "entity_type = ?", []interface{}{filter.EntityType}
+	if filter.EntityID != "" {
+		condition += " AND entity_id = ?"
+		args = append(args, filter.EntityID)
+	}
+	args = append(args, filter.Limit)
+
+	query := `
+	SELECT
+		id
+		, entity_type
+		, entity_id
+		, action
+		, actor_type
+		, COALESCE(actor_id, '') AS actor_id
+		, COALESCE(request_id, '') AS request_id
+		, changes
+		, created_at
+	FROM
+		audit_log
+	WHERE
+		` + condition + `
+	ORDER BY
+		created_at DESC
+	LIMIT ?
+	`
+
+	if err := r.db.Select(&entries, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to find audit entries")
+		return nil, fmt.Errorf("failed to find audit entries: %w", err)
+	}
+
+	return entries, nil
+}
@@ -3,14 +3,17 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
 type billingRepository struct {
-	db *sqlx.DB
+	db queryer
 }
 
 // NewBillingRepository creates a new billing repository
@@ -20,43 +23,179 @@ func NewBillingRepository(db *sqlx.DB) domain.BillingRepository {
 	}
 }
 
+// billingColumns are the columns selected for a billing
+const billingColumns = `
+		id
+		, kind
+		, client_id
+		, package_id
+		, amount
+		, original_price
+		, discount
+		, price
+		, net_price
+		, vat
+		, vat_rate
+		, currency
+		, credits
+		, payment_date
+		, COALESCE(refunded_billing_id, '') AS refunded_billing_id
+		, COALESCE(subscription_id, '') AS subscription_id
+		, COALESCE(promotion_id, '') AS promotion_id
+		, COALESCE(reason, '') AS reason`
+
+// billingRow is a stored billing, with its amounts in minor units
+type billingRow struct {
+	domain.Billing
+	OriginalPrice int64  `db:"original_price"`
+	Discount      int64  `db:"discount"`
+	Price         int64  `db:"price"`
+	NetPrice      int64  `db:"net_price"`
+	VAT           int64  `db:"vat"`
+	Currency      string `db:"currency"`
+}
+
+// toBilling converts a stored row to a domain.Billing
+func (row *billingRow) toBilling() *domain.Billing {
+	billing := row.Billing
+	billing.OriginalPrice = money.New(row.OriginalPrice, row.Currency)
+	billing.Discount = money.New(row.Discount, row.Currency)
+	billing.Price = money.New(row.Price, row.Currency)
+	billing.NetPrice = money.New(row.NetPrice, row.Currency)
+	billing.VAT = money.New(row.VAT, row.Currency)
+	return &billing
+}
+
+// toBillings converts stored rows to domain.Billings
+func toBillings(rows []billingRow) []domain.Billing {
+	billings := make([]domain.Billing, 0, len(rows))
+	for i := range rows {
+		billings = append(billings, *rows[i].toBilling())
+	}
+	return billings
+}
+
+// billingDetailsRow is a billing flattened with its client, package and
+// invoice
+type billingDetailsRow struct {
+	billingRow
+	ClientFirstName string    `db:"client_firstname"`
+	ClientLastName  string    `db:"client_lastname"`
+	ClientEmail     string    `db:"client_email"`
+	PackageName     string    `db:"package_name"`
+	PackageType     string    `db:"package_type"`
+	InvoiceID       string    `db:"invoice_id"`
+	InvoiceKind     string    `db:"invoice_kind"`
+	InvoiceNumber   string    `db:"invoice_number"`
+	InvoiceIssuedAt time.Time `db:"invoice_issued_at"`
+}
+
+// billingDetailsQuery selects the billings matching a condition with their
+// client, package and invoice, in order of payment
+func billingDetailsQuery(condition string) string {
+	return `
+	SELECT
+		b.*
+		, c.firstname AS client_firstname
+		, c.lastname AS client_lastname
+		, c.email AS client_email
+		, p.name AS package_name
+		, p.type AS package_type
+		, COALESCE(i.id, '') AS invoice_id
+		, COALESCE(i.kind, '') AS invoice_kind
+		, COALESCE(i.number, '') AS invoice_number
+		, COALESCE(i.issued_at, b.payment_date) AS invoice_issued_at
+	FROM
+		(
+			SELECT
+			` + billingColumns + `
+			FROM
+				billings
+			WHERE` + condition + `
+		) b
+		INNER JOIN clients c ON c.id = b.client_id
+		INNER JOIN packages p ON p.id = b.package_id
+		LEFT JOIN invoices i ON i.billing_id = b.id
+	ORDER BY
+		b.payment_date
+		, b.id
+	`
+}
+
+// toDetails converts a flattened row to a domain.BillingWithDetails
+func (row *billingDetailsRow) toDetails() domain.BillingWithDetails {
+	billing := row.toBilling()
+
+	details := domain.BillingWithDetails{
+		Billing: billing,
+		Client: &domain.Client{
+			ID:        billing.ClientID,
+			FirstName: row.ClientFirstName,
+			LastName:  row.ClientLastName,
+			Email:     row.ClientEmail,
+		},
+		Package: &domain.Package{
+			ID:   billing.PackageID,
+			Name: row.PackageName,
+			Type: domain.PackageType(row.PackageType),
+		},
+	}
+
+	if row.InvoiceID != "" {
+		details.Invoice = &domain.Invoice{
+			ID:        row.InvoiceID,
+			Kind:      domain.InvoiceKind(row.InvoiceKind),
+			Number:    row.InvoiceNumber,
+			BillingID: billing.ID,
+			ClientID:  billing.ClientID,
+			IssuedAt:  row.InvoiceIssuedAt,
+		}
+	}
+
+	return details
+}
+
 // Create creates a new billing.
 func (r *billingRepository) Create(billing *domain.Billing) error {
+	if billing.ID == "" {
+		billing.ID = utils.NewUUID()
+	}
+
 	query := `
 	INSERT INTO 
 		billings (
-			client_id
+			id
+			, kind
+			, client_id
 			, package_id
 			, amount
+			, original_price
+			, discount
 			, price
+			, net_price
+			, vat
+			, vat_rate
+			, currency
 			, credits
 			, payment_date
+			, refunded_billing_id
+			, subscription_id
+			, promotion_id
+			, reason
 		)
-	VALUES (?, ?, ?, ?, ?, ?)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))
 	`
 
-	_, err := r.db.Exec(query, billing.ClientID, billing.PackageID, billing.Amount, billing.Price, billing.Credits, billing.PaymentDate)
-	if err != nil {
-		log.Error().Err(err).Interface("billing", billing).Msg("failed to create billing")
-		return fmt.Errorf("failed to create billing: %w", err)
+	if billing.Kind == "" {
+		billing.Kind = domain.PaymentBilling
 	}
 
-	return nil
-}
-
-// Delete implements domain.BillingRepository.
-func (r *billingRepository) Delete(id string) error {
-	query := `
-	DELETE FROM
-		billings
-	WHERE 
-		id = ?
-	`
-
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.Exec(query, billing.ID, billing.Kind, billing.ClientID, billing.PackageID, billing.Amount,
+		billing.OriginalPrice.Amount, billing.Discount.Amount, billing.Price.Amount, billing.NetPrice.Amount, billing.VAT.Amount, billing.VATRate, billing.Price.Currency,
+		billing.Credits, billing.PaymentDate, billing.RefundedBillingID, billing.SubscriptionID, billing.PromotionID, billing.Reason)
 	if err != nil {
-		log.Error().Err(err).Str("id", id).Msg("failed to delete billing")
-		return fmt.Errorf("failed to delete billing: %w", err)
+		log.Error().Err(err).Interface("billing", billing).Msg("failed to create billing")
+		return fmt.Errorf("failed to create billing: %w", constraintError(err))
 	}
 
 	return nil
@@ -64,22 +203,16 @@ func (r *billingRepository) Delete(id string) error {
 
 // GetAll returns all billings
 func (r *billingRepository) GetAll() ([]domain.Billing, error) {
-	var billings []domain.Billing
+	var rows []billingRow
 
 	query := `
 	SELECT 
-		id
-		, client_id
-		, package_id
-		, amount
-		, price
-		, credits
-		, payment_date
+	` + billingColumns + `
 	FROM 
 		billings
 	`
 
-	err := r.db.Select(&billings, query)
+	err := r.db.Select(&rows, query)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -87,7 +220,7 @@ func (r *billingRepository) GetAll() ([]domain.Billing, error) {
 		log.Error().Err(err).Msg("failed to get billings")
 		return nil, fmt.Errorf("failed to get billings: %w", err)
 	}
-	return billings, nil
+	return toBillings(rows), nil
 }
 
 // GetAllWithDetails returns all billings with their details
@@ -95,26 +228,61 @@ func (r *billingRepository) GetAllWithDetails() ([]domain.BillingWithDetails, er
 	panic("unimplemented")
 }
 
+// GetDetailsByPeriod returns the billings paid between start (inclusive) and
+// end (exclusive) with their details, in order of payment.
+func (r *billingRepository) GetDetailsByPeriod(start, end time.Time) ([]domain.BillingWithDetails, error) {
+	var rows []billingDetailsRow
+
+	query := billingDetailsQuery(`
+				payment_date >= ?
+				AND payment_date < ?`)
+
+	err := r.db.Select(&rows, query, start, end)
+	if err != nil {
+		log.Error().Err(err).Time("start", start).Time("end", end).Msg("failed to get billings with details by period")
+		return nil, fmt.Errorf("failed to get billings with details by period: %w", err)
+	}
+
+	billings := make([]domain.BillingWithDetails, 0, len(rows))
+	for i := range rows {
+		billings = append(billings, rows[i].toDetails())
+	}
+
+	return billings, nil
+}
+
+// EachWithDetails calls fn with every billing and its details in order of
+// payment, one row at a time.
+func (r *billingRepository) EachWithDetails(fn func(*domain.BillingWithDetails) error) error {
+	query := billingDetailsQuery(`
+				TRUE`)
+
+	err := each(r.db, func(row *billingDetailsRow) error {
+		details := row.toDetails()
+		return fn(&details)
+	}, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to iterate billings with details")
+		return fmt.Errorf("failed to iterate billings with details: %w", err)
+	}
+
+	return nil
+}
+
 // GetByClient implements domain.BillingRepository.
 func (r *billingRepository) GetByClient(clientID string) ([]domain.Billing, error) {
-	var billings []domain.Billing
+	var rows []billingRow
 
 	query := `
 	SELECT 
-		id
-		, client_id
-		, package_id
-		, amount
-		, price
-		, credits
-		, payment_date
+	` + billingColumns + `
 	FROM 
 		billings
 	WHERE
 		client_id = ?
 	`
 
-	err := r.db.Select(&billings, query, clientID)
+	err := r.db.Select(&rows, query, clientID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -123,29 +291,23 @@ func (r *billingRepository) GetByClient(clientID string) ([]domain.Billing, erro
 		return nil, fmt.Errorf("failed to get billings by client ID")
 	}
 
-	return billings, nil
+	return toBillings(rows), nil
 }
 
 // GetByID implements domain.BillingRepository.
 func (r *billingRepository) GetByID(id string) (*domain.Billing, error) {
-	var billing domain.Billing
+	var row billingRow
 
 	query := `
 	SELECT 
-		id
-		, client_id
-		, package_id
-		, amount
-		, price
-		, credits
-		, payment_date
+	` + billingColumns + `
 	FROM
 		billings
 	WHERE 
 		id = ?
 	`
 
-	err := r.db.Get(&billing, query, id)
+	err := r.db.Get(&row, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -154,22 +316,101 @@ func (r *billingRepository) GetByID(id string) (*domain.Billing, error) {
 		return nil, fmt.Errorf("failed to get billing by ID: %w", err)
 	}
 
-	return &billing, nil
+	return row.toBilling(), nil
+}
+
+// GetByIDForUpdate returns a billing by ID and locks its row until the end
+// of the transaction.
+func (r *billingRepository) GetByIDForUpdate(id string) (*domain.Billing, error) {
+	var row billingRow
+
+	query := `
+	SELECT
+	` + billingColumns + `
+	FROM
+		billings
+	WHERE
+		id = ?
+	FOR UPDATE
+	`
+
+	err := r.db.Get(&row, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to lock billing by ID")
+		return nil, fmt.Errorf("failed to lock billing by ID: %w", err)
+	}
+
+	return row.toBilling(), nil
+}
+
+// GetRefunds returns the refunds of a billing, oldest first.
+func (r *billingRepository) GetRefunds(billingID string) ([]domain.Billing, error) {
+	var rows []billingRow
+
+	query := `
+	SELECT
+	` + billingColumns + `
+	FROM
+		billings
+	WHERE
+		refunded_billing_id = ?
+	ORDER BY
+		payment_date
+	`
+
+	err := r.db.Select(&rows, query, billingID)
+	if err != nil {
+		log.Error().Err(err).Str("billingID", billingID).Msg("failed to get billing refunds")
+		return nil, fmt.Errorf("failed to get billing refunds: %w", err)
+	}
+
+	return toBillings(rows), nil
+}
+
+// CountByClient returns the number of billings of a client, refunds included
+func (r *billingRepository) CountByClient(clientID string) (int, error) {
+	var count int
+
+	query := `
+	SELECT COUNT(1) FROM billings WHERE client_id = ?
+	`
+
+	err := r.db.Get(&count, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to retrieve billing count by client")
+		return 0, fmt.Errorf("failed to retrieve billing count by client: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountByPackage returns the number of billings of a package, refunds included
+func (r *billingRepository) CountByPackage(packageID string) (int, error) {
+	var count int
+
+	query := `
+	SELECT COUNT(1) FROM billings WHERE package_id = ?
+	`
+
+	err := r.db.Get(&count, query, packageID)
+	if err != nil {
+		log.Error().Err(err).Str("packageID", packageID).Msg("failed to retrieve billing count by package")
+		return 0, fmt.Errorf("failed to retrieve billing count by package: %w", err)
+	}
+
+	return count, nil
 }
 
 // GetRecent returns recent billings based on a limit.
 func (r *billingRepository) GetRecent(limit int) ([]domain.Billing, error) {
-	var billings []domain.Billing
+	var rows []billingRow
 
 	query := `
 	SELECT 
-		id
-		, client_id
-		, package_id
-		, amount
-		, price
-		, credits
-		, payment_date
+	` + billingColumns + `
 	FROM
 		billings
 	ORDER BY 
@@ -178,7 +419,7 @@ func (r *billingRepository) GetRecent(limit int) ([]domain.Billing, error) {
 		?
 	`
 
-	err := r.db.Select(&billings, query, limit)
+	err := r.db.Select(&rows, query, limit)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -187,7 +428,7 @@ func (r *billingRepository) GetRecent(limit int) ([]domain.Billing, error) {
 		return nil, fmt.Errorf("failed to get recent billings: %w", err)
 	}
 
-	return billings, nil
+	return toBillings(rows), nil
 }
 
 // GetWithDetails returns a billing by ID with its details.
@@ -204,17 +445,25 @@ func (r *billingRepository) Update(billing *domain.Billing) error {
 		client_id = ?
 		, package_id = ?
 		, amount = ?
+		, original_price = ?
+		, discount = ?
 		, price = ?
+		, net_price = ?
+		, vat = ?
+		, vat_rate = ?
+		, currency = ?
 		, credits = ?
 		, payment_date = ?
+		, promotion_id = NULLIF(?, '')
 	WHERE
 		id = ?
 	`
 
-	_, err := r.db.Exec(query, billing.ClientID, billing.PackageID, billing.Amount, billing.Price, billing.Credits, billing.PaymentDate)
+	_, err := r.db.Exec(query, billing.ClientID, billing.PackageID, billing.Amount, billing.OriginalPrice.Amount, billing.Discount.Amount,
+		billing.Price.Amount, billing.NetPrice.Amount, billing.VAT.Amount, billing.VATRate, billing.Price.Currency, billing.Credits, billing.PaymentDate, billing.PromotionID, billing.ID)
 	if err != nil {
 		log.Error().Err(err).Interface("billing", billing).Msg("failed to update billing")
-		return fmt.Errorf("failed to update billing")
+		return fmt.Errorf("failed to update billing: %w", constraintError(err))
 	}
 
 	return nil
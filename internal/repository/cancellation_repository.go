@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type cancellationRepository struct {
+	db queryer
+}
+
+// NewCancellationRepository creates a new cancellation repository
+func NewCancellationRepository(db *sqlx.DB) domain.CancellationRepository {
+	return &cancellationRepository{
+		db: db,
+	}
+}
+
+// Create records a cancellation.
+func (r *cancellationRepository) Create(cancellation *domain.Cancellation) error {
+	if cancellation.ID == "" {
+		cancellation.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		cancellations (
+			id
+			, appointment_id
+			, schedule_id
+			, client_id
+			, class_type
+			, late
+			, refunded
+			, override_reason
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, cancellation.ID, cancellation.AppointmentID, cancellation.ScheduleID, cancellation.ClientID, cancellation.ClassType, cancellation.Late, cancellation.Refunded, cancellation.OverrideReason)
+	if err != nil {
+		log.Error().Err(err).Interface("cancellation", cancellation).Msg("failed to create cancellation")
+		return fmt.Errorf("failed to create cancellation: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetByClient returns the cancellations of a client, most recent first.
+func (r *cancellationRepository) GetByClient(clientID string) ([]domain.Cancellation, error) {
+	var cancellations []domain.Cancellation
+
+	query := `
+	SELECT
+		id
+		, appointment_id
+		, schedule_id
+		, client_id
+		, class_type
+		, late
+		, refunded
+		, COALESCE(override_reason, '') AS override_reason
+		, cancelled_at
+	FROM
+		cancellations
+	WHERE
+		client_id = ?
+	ORDER BY
+		cancelled_at DESC
+	`
+
+	err := r.db.Select(&cancellations, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get cancellations by client ID")
+		return nil, fmt.Errorf("failed to get cancellations by client ID: %w", err)
+	}
+
+	return cancellations, nil
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type checkoutRepository struct {
+	db queryer
+}
+
+// NewCheckoutRepository creates a new checkout repository
+func NewCheckoutRepository(db *sqlx.DB) domain.CheckoutRepository {
+	return &checkoutRepository{
+		db: db,
+	}
+}
+
+// checkoutColumns are the columns selected for a checkout
+const checkoutColumns = `
+		id
+		, client_id
+		, package_id
+		, amount
+		, original_price
+		, discount
+		, price
+		, refunded
+		, currency
+		, COALESCE(promotion_id, '') AS promotion_id
+		, status
+		, provider
+		, provider_checkout_id
+		, COALESCE(provider_payment_id, '') AS provider_payment_id
+		, url
+		, COALESCE(billing_id, '') AS billing_id
+		, expires_at
+		, created_at
+		, updated_at`
+
+// checkoutRow is a stored checkout, with its amounts in minor units
+type checkoutRow struct {
+	domain.Checkout
+	OriginalPrice int64  `db:"original_price"`
+	Discount      int64  `db:"discount"`
+	Price         int64  `db:"price"`
+	Refunded      int64  `db:"refunded"`
+	Currency      string `db:"currency"`
+}
+
+// toCheckout converts a stored row to a domain.Checkout
+func (row *checkoutRow) toCheckout() *domain.Checkout {
+	checkout := row.Checkout
+	checkout.OriginalPrice = money.New(row.OriginalPrice, row.Currency)
+	checkout.Discount = money.New(row.Discount, row.Currency)
+	checkout.Price = money.New(row.Price, row.Currency)
+	checkout.Refunded = money.New(row.Refunded, row.Currency)
+	return &checkout
+}
+
+// Create creates a new checkout.
+func (r *checkoutRepository) Create(checkout *domain.Checkout) error {
+	if checkout.ID == "" {
+		checkout.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		checkouts (
+			id
+			, client_id
+			, package_id
+			, amount
+			, original_price
+			, discount
+			, price
+			, refunded
+			, currency
+			, promotion_id
+			, status
+			, provider
+			, provider_checkout_id
+			, provider_payment_id
+			, url
+			, billing_id
+			, expires_at
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), ?)
+	`
+
+	_, err := r.db.Exec(query, checkout.ID, checkout.ClientID, checkout.PackageID, checkout.Amount, checkout.OriginalPrice.Amount, checkout.Discount.Amount, checkout.Price.Amount,
+		checkout.Refunded.Amount, checkout.Price.Currency, checkout.PromotionID, checkout.Status, checkout.Provider, checkout.ProviderCheckoutID, checkout.ProviderPaymentID,
+		checkout.URL, checkout.BillingID, checkout.ExpiresAt)
+	if err != nil {
+		log.Error().Err(err).Interface("checkout", checkout).Msg("failed to create checkout")
+		return fmt.Errorf("failed to create checkout: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetAll returns all checkouts, most recent first.
+func (r *checkoutRepository) GetAll() ([]domain.Checkout, error) {
+	var rows []checkoutRow
+
+	query := `
+	SELECT
+	` + checkoutColumns + `
+	FROM
+		checkouts
+	ORDER BY
+		created_at DESC
+	`
+
+	if err := r.db.Select(&rows, query); err != nil {
+		log.Error().Err(err).Msg("failed to get all checkouts")
+		return nil, fmt.Errorf("failed to get all checkouts: %w", err)
+	}
+
+	checkouts := make([]domain.Checkout, 0, len(rows))
+	for i := range rows {
+		checkouts = append(checkouts, *rows[i].toCheckout())
+	}
+
+	return checkouts, nil
+}
+
+// GetByID returns a checkout by ID.
+func (r *checkoutRepository) GetByID(id string) (*domain.Checkout, error) {
+	return r.getOne(`
+	SELECT
+	`+checkoutColumns+`
+	FROM
+		checkouts
+	WHERE
+		id = ?
+	`, id)
+}
+
+// GetByIDForUpdate returns a checkout by ID and locks its row until the end
+// of the transaction.
+func (r *checkoutRepository) GetByIDForUpdate(id string) (*domain.Checkout, error) {
+	return r.getOne(`
+	SELECT
+	`+checkoutColumns+`
+	FROM
+		checkouts
+	WHERE
+		id = ?
+	FOR UPDATE
+	`, id)
+}
+
+// GetByProviderIDForUpdate returns a checkout by the ID its provider gave
+// it and locks its row until the end of the transaction.
+func (r *checkoutRepository) GetByProviderIDForUpdate(provider string, providerCheckoutID string) (*domain.Checkout, error) {
+	return r.getOne(`
+	SELECT
+	`+checkoutColumns+`
+	FROM
+		checkouts
+	WHERE
+		provider = ?
+		AND provider_checkout_id = ?
+	FOR UPDATE
+	`, provider, providerCheckoutID)
+}
+
+// Update updates the status, payment, refunds and billing of a checkout.
+func (r *checkoutRepository) Update(checkout *domain.Checkout) error {
+	query := `
+	UPDATE
+		checkouts
+	SET
+		status = ?
+		, provider_payment_id = NULLIF(?, '')
+		, refunded = ?
+		, billing_id = NULLIF(?, '')
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, checkout.Status, checkout.ProviderPaymentID, checkout.Refunded.Amount, checkout.BillingID, checkout.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("checkout", checkout).Msg("failed to update checkout")
+		return fmt.Errorf("failed to update checkout: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// RecordEvent records a payment event as handled. It returns false when
+// the event was already recorded.
+func (r *checkoutRepository) RecordEvent(provider string, event *domain.PaymentEvent) (bool, error) {
+	query := `
+	INSERT IGNORE INTO
+		payment_events (
+			provider
+			, event_id
+			, type
+			, checkout_id
+		)
+	VALUES (?, ?, ?, NULLIF(?, ''))
+	`
+
+	result, err := r.db.Exec(query, provider, event.ID, event.Type, event.CheckoutID)
+	if err != nil {
+		log.Error().Err(err).Str("provider", provider).Interface("event", event).Msg("failed to record payment event")
+		return false, fmt.Errorf("failed to record payment event: %w", constraintError(err))
+	}
+
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record payment event: %w", constraintError(err))
+	}
+
+	return recorded > 0, nil
+}
+
+// getOne returns the checkout selected by a query, or nil
+func (r *checkoutRepository) getOne(query string, args ...interface{}) (*domain.Checkout, error) {
+	var row checkoutRow
+
+	err := r.db.Get(&row, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Interface("args", args).Msg("failed to get checkout")
+		return nil, fmt.Errorf("failed to get checkout: %w", err)
+	}
+
+	return row.toCheckout(), nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
@@ -9,7 +10,7 @@ import (
 )
 
 type classRepository struct {
-	db *sqlx.DB
+	db queryer
 }
 
 // NewClassRepository creates a new class repository
@@ -32,7 +33,7 @@ func (r *classRepository) Create(class *domain.Class) error {
 	_, err := r.db.Exec(query, class.Name, class.Location, class.Type, class.Equipment)
 	if err != nil {
 		log.Error().Err(err).Interface("class", class).Msg("failed to create class")
-		return fmt.Errorf("failed to create class: %w", err)
+		return fmt.Errorf("failed to create class: %w", constraintError(err))
 	}
 
 	return nil
@@ -51,7 +52,7 @@ func (r *classRepository) Delete(id string) error {
 	_, err := r.db.Exec(query, id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to delete class")
-		return fmt.Errorf("failed to delete class: %w", err)
+		return fmt.Errorf("failed to delete class: %w", constraintError(err))
 	}
 
 	return nil
@@ -100,6 +101,9 @@ func (r *classRepository) GetByID(id string) (*domain.Class, error) {
 
 	err := r.db.Get(&class, query, id)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		log.Error().Err(err).Str("id", id).Msg("failed to get class")
 		return nil, fmt.Errorf("failed to get class: %w", err)
 	}
@@ -176,7 +180,7 @@ func (r *classRepository) Update(class *domain.Class) error {
 	_, err := r.db.Exec(query, class.Name, class.Location, class.Type, class.Equipment, class.ID)
 	if err != nil {
 		log.Error().Err(err).Interface("class", class).Msg("failed to update class")
-		return fmt.Errorf("failed to update class: %w", err)
+		return fmt.Errorf("failed to update class: %w", constraintError(err))
 	}
 
 	return nil
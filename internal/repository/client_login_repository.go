@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type clientLoginRepository struct {
+	db queryer
+}
+
+// NewClientLoginRepository creates a new client login repository
+func NewClientLoginRepository(db *sqlx.DB) domain.ClientLoginRepository {
+	return &clientLoginRepository{
+		db: db,
+	}
+}
+
+// clientLoginColumns are the columns selected for a client login
+const clientLoginColumns = `
+		id
+		, client_id
+		, code_hash
+		, token_hash
+		, attempts
+		, expires_at
+		, used_at
+		, created_at`
+
+// CreateLogin creates a new client login.
+func (r *clientLoginRepository) CreateLogin(login *domain.ClientLogin) error {
+	if login.ID == "" {
+		login.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		client_logins (
+			id
+			, client_id
+			, code_hash
+			, token_hash
+			, expires_at
+		)
+	VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, login.ID, login.ClientID, login.CodeHash, login.TokenHash, login.ExpiresAt)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", login.ClientID).Msg("failed to create client login")
+		return fmt.Errorf("failed to create client login: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetPendingLoginForUpdate returns the latest login of a client neither used
+// nor expired at a time, or nil, and locks its row until the end of the
+// transaction.
+func (r *clientLoginRepository) GetPendingLoginForUpdate(clientID string, at time.Time) (*domain.ClientLogin, error) {
+	return r.getOne(`
+	SELECT
+	`+clientLoginColumns+`
+	FROM
+		client_logins
+	WHERE
+		client_id = ?
+		AND used_at IS NULL
+		AND expires_at > ?
+	ORDER BY
+		created_at DESC
+	LIMIT 1
+	FOR UPDATE
+	`, clientID, at)
+}
+
+// GetLoginByTokenForUpdate returns a client login by the hash of its link
+// token, or nil, and locks its row until the end of the transaction.
+func (r *clientLoginRepository) GetLoginByTokenForUpdate(tokenHash string) (*domain.ClientLogin, error) {
+	return r.getOne(`
+	SELECT
+	`+clientLoginColumns+`
+	FROM
+		client_logins
+	WHERE
+		token_hash = ?
+	FOR UPDATE
+	`, tokenHash)
+}
+
+// CountLoginsSince returns how many logins a client requested since a time.
+func (r *clientLoginRepository) CountLoginsSince(clientID string, since time.Time) (int, error) {
+	var count int
+
+	query := `
+	SELECT
+		COUNT(*)
+	FROM
+		client_logins
+	WHERE
+		client_id = ?
+		AND created_at >= ?
+	`
+
+	if err := r.db.Get(&count, query, clientID, since); err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to count client logins")
+		return 0, fmt.Errorf("failed to count client logins: %w", err)
+	}
+
+	return count, nil
+}
+
+// UpdateLogin updates the attempts and use of a client login.
+func (r *clientLoginRepository) UpdateLogin(login *domain.ClientLogin) error {
+	query := `
+	UPDATE
+		client_logins
+	SET
+		attempts = ?
+		, used_at = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, login.Attempts, login.UsedAt, login.ID)
+	if err != nil {
+		log.Error().Err(err).Str("id", login.ID).Msg("failed to update client login")
+		return fmt.Errorf("failed to update client login: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// CreateSession creates a new client session.
+func (r *clientLoginRepository) CreateSession(session *domain.ClientSession) error {
+	query := `
+	INSERT INTO
+		client_sessions (
+			token_hash
+			, client_id
+			, expires_at
+		)
+	VALUES (?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, session.TokenHash, session.ClientID, session.ExpiresAt)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", session.ClientID).Msg("failed to create client session")
+		return fmt.Errorf("failed to create client session: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetSession returns a client session by token hash, or nil.
+func (r *clientLoginRepository) GetSession(tokenHash string) (*domain.ClientSession, error) {
+	var session domain.ClientSession
+
+	query := `
+	SELECT
+		token_hash
+		, client_id
+		, expires_at
+		, created_at
+	FROM
+		client_sessions
+	WHERE
+		token_hash = ?
+	`
+
+	err := r.db.Get(&session, query, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get client session")
+		return nil, fmt.Errorf("failed to get client session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// DeleteSession deletes a client session by token hash.
+func (r *clientLoginRepository) DeleteSession(tokenHash string) error {
+	query := `
+	DELETE FROM
+		client_sessions
+	WHERE
+		token_hash = ?
+	`
+
+	if _, err := r.db.Exec(query, tokenHash); err != nil {
+		log.Error().Err(err).Msg("failed to delete client session")
+		return fmt.Errorf("failed to delete client session: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes the logins and sessions expired before a time and
+// returns how many were deleted.
+func (r *clientLoginRepository) DeleteExpired(before time.Time) (int64, error) {
+	var deleted int64
+
+	for _, table := range []string{"client_logins", "client_sessions"} {
+		result, err := r.db.Exec(`DELETE FROM `+table+` WHERE expires_at < ?`, before)
+		if err != nil {
+			log.Error().Err(err).Str("table", table).Time("before", before).Msg("failed to delete expired client logins")
+			return deleted, fmt.Errorf("failed to delete expired %s: %w", table, err)
+		}
+
+		count, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += count
+	}
+
+	return deleted, nil
+}
+
+// getOne returns the client login selected by a query, or nil
+func (r *clientLoginRepository) getOne(query string, args ...interface{}) (*domain.ClientLogin, error) {
+	var login domain.ClientLogin
+
+	err := r.db.Get(&login, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Interface("args", args).Msg("failed to get client login")
+		return nil, fmt.Errorf("failed to get client login: %w", err)
+	}
+
+	return &login, nil
+}
@@ -6,11 +6,12 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
 type clientRepository struct {
-	db *sqlx.DB
+	db queryer
 }
 
 // NewClientRepository creates a new client repository
@@ -35,6 +36,20 @@ func (r *clientRepository) GetAll() ([]domain.Client, error) {
 	return clients, nil
 }
 
+// Each calls fn with every client in the same order as GetAll, one row at a
+// time.
+func (r *clientRepository) Each(fn func(*domain.Client) error) error {
+	query := `SELECT * FROM clients ORDER BY lastname, firstname`
+
+	err := each(r.db, fn, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to iterate clients")
+		return fmt.Errorf("failed to iterate clients: %w", err)
+	}
+
+	return nil
+}
+
 // GetByID returns a client by ID
 func (r *clientRepository) GetByID(id string) (*domain.Client, error) {
 	var client domain.Client
@@ -53,8 +68,32 @@ func (r *clientRepository) GetByID(id string) (*domain.Client, error) {
 	return &client, nil
 }
 
-// Create creates a new client
+// GetByIDForUpdate returns a client by ID and locks its row until the
+// surrounding transaction ends
+func (r *clientRepository) GetByIDForUpdate(id string) (*domain.Client, error) {
+	var client domain.Client
+
+	query := `SELECT * FROM clients WHERE id = ? FOR UPDATE`
+
+	err := r.db.Get(&client, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to lock client by ID")
+		return nil, fmt.Errorf("failed to lock client by ID: %w", err)
+	}
+
+	return &client, nil
+}
+
+// Create creates a new client. Credits start at zero and are granted through
+// the credit ledger.
 func (r *clientRepository) Create(client *domain.Client) error {
+	if client.ID == "" {
+		client.ID = utils.NewUUID()
+	}
+
 	query := `
 	INSERT INTO
 		clients (
@@ -71,16 +110,17 @@ func (r *clientRepository) Create(client *domain.Client) error {
 		)
 	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := r.db.Exec(query, client.FirstName, client.LastName, client.Phone, client.Email, client.StreetNumber, client.StreetName, client.City, client.ZipCode, client.Country)
+	_, err := r.db.Exec(query, client.ID, client.FirstName, client.LastName, client.Phone, client.Email, client.StreetNumber, client.StreetName, client.City, client.ZipCode, client.Country)
 	if err != nil {
 		log.Error().Err(err).Interface("client", client).Msg("failed to create client")
-		return fmt.Errorf("failed to create client: %w", err)
+		return fmt.Errorf("failed to create client: %w", constraintError(err))
 	}
 
 	return nil
 }
 
-// Update updates a client's information
+// Update updates a client's information. Credits are left untouched, see
+// UpdateCredits.
 func (r *clientRepository) Update(client *domain.Client) error {
 	query := `
 	UPDATE
@@ -95,15 +135,34 @@ func (r *clientRepository) Update(client *domain.Client) error {
 		, city = ?
 		, zip_code = ?
 		, country = ?
-		, group_credits = ?
-		, private_credits = ?
 	WHERE
 		id = ?`
 
-	_, err := r.db.Exec(query, client.FirstName, client.LastName, client.Phone, client.Email, client.StreetNumber, client.StreetName, client.City, client.ZipCode, client.Country, client.GroupCredits, client.PrivateCredits, client.ID)
+	_, err := r.db.Exec(query, client.FirstName, client.LastName, client.Phone, client.Email, client.StreetNumber, client.StreetName, client.City, client.ZipCode, client.Country, client.ID)
 	if err != nil {
 		log.Error().Err(err).Interface("client", client).Msg("failed to update client")
-		return fmt.Errorf("failed to update client: %w", err)
+		return fmt.Errorf("failed to update client: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// UpdateCredits updates a client's credit counters. It must only be called
+// along with a credit ledger entry.
+func (r *clientRepository) UpdateCredits(client *domain.Client) error {
+	query := `
+	UPDATE
+		clients
+	SET
+		group_credits = ?
+		, private_credits = ?
+	WHERE
+		id = ?`
+
+	_, err := r.db.Exec(query, client.GroupCredits, client.PrivateCredits, client.ID)
+	if err != nil {
+		log.Error().Err(err).Str("id", client.ID).Msg("failed to update client credits")
+		return fmt.Errorf("failed to update client credits: %w", constraintError(err))
 	}
 
 	return nil
@@ -121,7 +180,7 @@ func (r *clientRepository) Delete(id string) error {
 	_, err := r.db.Exec(query, id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to delete client")
-		return fmt.Errorf("failed to delete client: %w", err)
+		return fmt.Errorf("failed to delete client: %w", constraintError(err))
 	}
 
 	return nil
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type creditLedgerRepository struct {
+	db queryer
+}
+
+// NewCreditLedgerRepository creates a new credit ledger repository
+func NewCreditLedgerRepository(db *sqlx.DB) domain.CreditLedgerRepository {
+	return &creditLedgerRepository{
+		db: db,
+	}
+}
+
+// Create appends an entry to the ledger.
+func (r *creditLedgerRepository) Create(entry *domain.CreditEntry) error {
+	if entry.ID == "" {
+		entry.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		credit_ledger (
+			id
+			, client_id
+			, type
+			, kind
+			, delta
+			, balance_after
+			, reason
+			, actor
+			, reference_id
+			, lot_id
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))
+	`
+
+	_, err := r.db.Exec(query, entry.ID, entry.ClientID, entry.Type, entry.Kind, entry.Delta, entry.BalanceAfter, entry.Reason, entry.Actor, entry.ReferenceID, entry.LotID)
+	if err != nil {
+		log.Error().Err(err).Interface("entry", entry).Msg("failed to create credit ledger entry")
+		return fmt.Errorf("failed to create credit ledger entry: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetByClient returns the ledger entries of a client, most recent first.
+func (r *creditLedgerRepository) GetByClient(clientID string) ([]domain.CreditEntry, error) {
+	var entries []domain.CreditEntry
+
+	query := `
+	SELECT
+		id
+		, client_id
+		, type
+		, kind
+		, delta
+		, balance_after
+		, reason
+		, actor
+		, COALESCE(reference_id, '') AS reference_id
+		, COALESCE(lot_id, '') AS lot_id
+		, created_at
+	FROM
+		credit_ledger
+	WHERE
+		client_id = ?
+	ORDER BY
+		created_at DESC
+	`
+
+	err := r.db.Select(&entries, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get credit ledger by client ID")
+		return nil, fmt.Errorf("failed to get credit ledger by client ID: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetByReference returns the ledger entries referencing a billing or an
+// appointment, oldest first.
+func (r *creditLedgerRepository) GetByReference(referenceID string) ([]domain.CreditEntry, error) {
+	var entries []domain.CreditEntry
+
+	query := `
+	SELECT
+		id
+		, client_id
+		, type
+		, kind
+		, delta
+		, balance_after
+		, reason
+		, actor
+		, COALESCE(reference_id, '') AS reference_id
+		, COALESCE(lot_id, '') AS lot_id
+		, created_at
+	FROM
+		credit_ledger
+	WHERE
+		reference_id = ?
+	ORDER BY
+		created_at
+	`
+
+	err := r.db.Select(&entries, query, referenceID)
+	if err != nil {
+		log.Error().Err(err).Str("referenceID", referenceID).Msg("failed to get credit ledger by reference")
+		return nil, fmt.Errorf("failed to get credit ledger by reference: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetBalance returns the balances of a client summed from the ledger.
+func (r *creditLedgerRepository) GetBalance(clientID string) (*domain.CreditBalance, error) {
+	balance := domain.CreditBalance{ClientID: clientID}
+
+	query := `
+	SELECT
+		COALESCE(SUM(CASE WHEN type = 'GROUP' THEN delta ELSE 0 END), 0) AS group_credits
+		, COALESCE(SUM(CASE WHEN type = 'PRIVATE' THEN delta ELSE 0 END), 0) AS private_credits
+	FROM
+		credit_ledger
+	WHERE
+		client_id = ?
+	`
+
+	err := r.db.Get(&balance, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get credit balance")
+		return nil, fmt.Errorf("failed to get credit balance: %w", err)
+	}
+
+	return &balance, nil
+}
+
+// GetAllBalances returns the ledger balances of every client that has entries.
+func (r *creditLedgerRepository) GetAllBalances() ([]domain.CreditBalance, error) {
+	var balances []domain.CreditBalance
+
+	query := `
+	SELECT
+		client_id
+		, COALESCE(SUM(CASE WHEN type = 'GROUP' THEN delta ELSE 0 END), 0) AS group_credits
+		, COALESCE(SUM(CASE WHEN type = 'PRIVATE' THEN delta ELSE 0 END), 0) AS private_credits
+	FROM
+		credit_ledger
+	GROUP BY
+		client_id
+	`
+
+	err := r.db.Select(&balances, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all credit balances")
+		return nil, fmt.Errorf("failed to get all credit balances: %w", err)
+	}
+
+	return balances, nil
+}
+
+// CountByClient returns the number of ledger entries of a client.
+func (r *creditLedgerRepository) CountByClient(clientID string) (int, error) {
+	var count int
+
+	query := `
+	SELECT COUNT(1) FROM credit_ledger WHERE client_id = ?
+	`
+
+	err := r.db.Get(&count, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to count credit ledger entries")
+		return 0, fmt.Errorf("failed to count credit ledger entries: %w", err)
+	}
+
+	return count, nil
+}
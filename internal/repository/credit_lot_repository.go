@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type creditLotRepository struct {
+	db queryer
+}
+
+// NewCreditLotRepository creates a new credit lot repository
+func NewCreditLotRepository(db *sqlx.DB) domain.CreditLotRepository {
+	return &creditLotRepository{
+		db: db,
+	}
+}
+
+// creditLotColumns are the columns selected for a credit lot
+const creditLotColumns = `
+		id
+		, client_id
+		, COALESCE(billing_id, '') AS billing_id
+		, type
+		, credits
+		, remaining
+		, expires_at
+		, created_at`
+
+// Create creates a new credit lot.
+func (r *creditLotRepository) Create(lot *domain.CreditLot) error {
+	if lot.ID == "" {
+		lot.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		credit_lots (
+			id
+			, client_id
+			, billing_id
+			, type
+			, credits
+			, remaining
+			, expires_at
+		)
+	VALUES (?, ?, NULLIF(?, ''), ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, lot.ID, lot.ClientID, lot.BillingID, lot.Type, lot.Credits, lot.Remaining, lot.ExpiresAt)
+	if err != nil {
+		log.Error().Err(err).Interface("lot", lot).Msg("failed to create credit lot")
+		return fmt.Errorf("failed to create credit lot: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// Update updates the owner, credits and expiry of a credit lot.
+func (r *creditLotRepository) Update(lot *domain.CreditLot) error {
+	query := `
+	UPDATE
+		credit_lots
+	SET
+		client_id = ?
+		, type = ?
+		, credits = ?
+		, remaining = ?
+		, expires_at = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, lot.ClientID, lot.Type, lot.Credits, lot.Remaining, lot.ExpiresAt, lot.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("lot", lot).Msg("failed to update credit lot")
+		return fmt.Errorf("failed to update credit lot: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetByClient returns the credit lots of a client, soonest expiry first.
+func (r *creditLotRepository) GetByClient(clientID string) ([]domain.CreditLot, error) {
+	var lots []domain.CreditLot
+
+	query := `
+	SELECT` + creditLotColumns + `
+	FROM
+		credit_lots
+	WHERE
+		client_id = ?
+	ORDER BY
+		expires_at IS NULL, expires_at, created_at
+	`
+
+	err := r.db.Select(&lots, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get credit lots by client ID")
+		return nil, fmt.Errorf("failed to get credit lots by client ID: %w", err)
+	}
+
+	return lots, nil
+}
+
+// GetByIDForUpdate returns a credit lot by ID and locks its row until the end
+// of the transaction.
+func (r *creditLotRepository) GetByIDForUpdate(id string) (*domain.CreditLot, error) {
+	var lot domain.CreditLot
+
+	query := `
+	SELECT` + creditLotColumns + `
+	FROM
+		credit_lots
+	WHERE
+		id = ?
+	FOR UPDATE
+	`
+
+	err := r.db.Get(&lot, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to lock credit lot by ID")
+		return nil, fmt.Errorf("failed to lock credit lot by ID: %w", err)
+	}
+
+	return &lot, nil
+}
+
+// GetByBillingForUpdate returns the credit lot granted by a billing and locks
+// its row until the end of the transaction.
+func (r *creditLotRepository) GetByBillingForUpdate(billingID string) (*domain.CreditLot, error) {
+	var lot domain.CreditLot
+
+	query := `
+	SELECT` + creditLotColumns + `
+	FROM
+		credit_lots
+	WHERE
+		billing_id = ?
+	FOR UPDATE
+	`
+
+	err := r.db.Get(&lot, query, billingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("billingID", billingID).Msg("failed to lock credit lot by billing ID")
+		return nil, fmt.Errorf("failed to lock credit lot by billing ID: %w", err)
+	}
+
+	return &lot, nil
+}
+
+// GetAvailableForUpdate returns the lots of a client with credits left that
+// are still valid at a given time, in consumption order, and locks them.
+func (r *creditLotRepository) GetAvailableForUpdate(clientID string, creditType domain.CreditType, at time.Time) ([]domain.CreditLot, error) {
+	var lots []domain.CreditLot
+
+	query := `
+	SELECT` + creditLotColumns + `
+	FROM
+		credit_lots
+	WHERE
+		client_id = ?
+		AND type = ?
+		AND remaining > 0
+		AND (expires_at IS NULL OR expires_at > ?)
+	ORDER BY
+		expires_at IS NULL, expires_at, created_at
+	FOR UPDATE
+	`
+
+	err := r.db.Select(&lots, query, clientID, creditType, at)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Str("type", string(creditType)).Msg("failed to lock available credit lots")
+		return nil, fmt.Errorf("failed to lock available credit lots: %w", err)
+	}
+
+	return lots, nil
+}
+
+// GetExpiredForUpdate returns the lots of a client that expired at a given
+// time with credits left, and locks them.
+func (r *creditLotRepository) GetExpiredForUpdate(clientID string, at time.Time) ([]domain.CreditLot, error) {
+	var lots []domain.CreditLot
+
+	query := `
+	SELECT` + creditLotColumns + `
+	FROM
+		credit_lots
+	WHERE
+		client_id = ?
+		AND remaining > 0
+		AND expires_at <= ?
+	ORDER BY
+		expires_at
+	FOR UPDATE
+	`
+
+	err := r.db.Select(&lots, query, clientID, at)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to lock expired credit lots")
+		return nil, fmt.Errorf("failed to lock expired credit lots: %w", err)
+	}
+
+	return lots, nil
+}
+
+// GetClientsWithExpired returns the IDs of the clients holding expired lots
+// with credits left at a given time.
+func (r *creditLotRepository) GetClientsWithExpired(at time.Time) ([]string, error) {
+	var clientIDs []string
+
+	query := `
+	SELECT DISTINCT
+		client_id
+	FROM
+		credit_lots
+	WHERE
+		remaining > 0
+		AND expires_at <= ?
+	`
+
+	err := r.db.Select(&clientIDs, query, at)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get clients with expired credit lots")
+		return nil, fmt.Errorf("failed to get clients with expired credit lots: %w", err)
+	}
+
+	return clientIDs, nil
+}
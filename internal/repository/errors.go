@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/matthieukhl/align-back/internal/domain"
+)
+
+// MySQL error numbers of constraint violations
+const (
+	errDuplicateEntry  = 1062
+	errRowIsReferenced = 1451
+	errNoReferencedRow = 1452
+)
+
+var (
+	// errDuplicate is returned when a write breaks a unique constraint
+	errDuplicate = domain.NewError(domain.ErrConflict, "duplicate_entry", "already exists")
+	// errReferenced is returned when deleting or changing a row other rows
+	// still reference
+	errReferenced = domain.NewError(domain.ErrConflict, "still_referenced", "is still referenced by other records")
+	// errUnknownReference is returned when a write references a missing row
+	errUnknownReference = domain.NewError(domain.ErrValidation, "unknown_reference", "references a missing record")
+)
+
+// constraintError returns the domain error of a MySQL constraint violation,
+// or err itself. The original error is logged by the caller.
+func constraintError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case errDuplicateEntry:
+		return errDuplicate
+	case errRowIsReferenced:
+		return errReferenced
+	case errNoReferencedRow:
+		return errUnknownReference
+	default:
+		return err
+	}
+}
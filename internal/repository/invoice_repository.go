@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type invoiceRepository struct {
+	db queryer
+}
+
+// NewInvoiceRepository creates a new invoice repository
+func NewInvoiceRepository(db *sqlx.DB) domain.InvoiceRepository {
+	return &invoiceRepository{
+		db: db,
+	}
+}
+
+// invoiceColumns are the columns selected for an invoice
+const invoiceColumns = `
+		id
+		, kind
+		, number
+		, year
+		, sequence
+		, billing_id
+		, client_id
+		, issued_at
+		, paid_at
+		, seller_name
+		, seller_address
+		, seller_siret
+		, seller_vat_number
+		, seller_contact
+		, seller_legal_mentions
+		, buyer_name
+		, buyer_address
+		, COALESCE(corrected_invoice_id, '') AS corrected_invoice_id
+		, COALESCE(corrected_invoice_number, '') AS corrected_invoice_number
+		, COALESCE(reason, '') AS reason
+		, currency
+		, net_total
+		, vat_total
+		, total
+		, created_at`
+
+// invoiceRow is a stored invoice, with its totals in minor units
+type invoiceRow struct {
+	domain.Invoice
+	Currency string `db:"currency"`
+	NetTotal int64  `db:"net_total"`
+	VATTotal int64  `db:"vat_total"`
+	Total    int64  `db:"total"`
+}
+
+// invoiceLineRow is a stored invoice line, with its prices in minor units
+type invoiceLineRow struct {
+	domain.InvoiceLine
+	UnitPrice int64 `db:"unit_price"`
+	Total     int64 `db:"total"`
+}
+
+// NextSequence reserves the next number of a series for a year. The sequence
+// row stays locked until the end of the transaction, so numbers are only used
+// once the invoice is committed and rolled back otherwise, leaving no gap.
+func (r *invoiceRepository) NextSequence(series string, year int) (int, error) {
+	var sequence int
+
+	query := `
+	INSERT INTO
+		invoice_sequences (
+			series
+			, year
+			, last_number
+		)
+	VALUES (?, ?, 1)
+	ON DUPLICATE KEY UPDATE
+		last_number = last_number + 1
+	`
+
+	_, err := r.db.Exec(query, series, year)
+	if err != nil {
+		log.Error().Err(err).Str("series", series).Int("year", year).Msg("failed to increment invoice sequence")
+		return 0, fmt.Errorf("failed to increment invoice sequence: %w", err)
+	}
+
+	err = r.db.Get(&sequence, `SELECT last_number FROM invoice_sequences WHERE series = ? AND year = ?`, series, year)
+	if err != nil {
+		log.Error().Err(err).Str("series", series).Int("year", year).Msg("failed to get invoice sequence")
+		return 0, fmt.Errorf("failed to get invoice sequence: %w", err)
+	}
+
+	return sequence, nil
+}
+
+// Create creates an invoice or credit note with its lines.
+func (r *invoiceRepository) Create(invoice *domain.Invoice) error {
+	if invoice.ID == "" {
+		invoice.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		invoices (
+			id
+			, kind
+			, number
+			, year
+			, sequence
+			, billing_id
+			, client_id
+			, issued_at
+			, paid_at
+			, seller_name
+			, seller_address
+			, seller_siret
+			, seller_vat_number
+			, seller_contact
+			, seller_legal_mentions
+			, buyer_name
+			, buyer_address
+			, corrected_invoice_id
+			, corrected_invoice_number
+			, reason
+			, currency
+			, net_total
+			, vat_total
+			, total
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, invoice.ID, invoice.Kind, invoice.Number, invoice.Year, invoice.Sequence, invoice.BillingID, invoice.ClientID, invoice.IssuedAt, invoice.PaidAt,
+		invoice.SellerName, invoice.SellerAddress, invoice.SellerSIRET, invoice.SellerVATNumber, invoice.SellerContact, invoice.SellerLegalMentions,
+		invoice.BuyerName, invoice.BuyerAddress, invoice.CorrectedInvoiceID, invoice.CorrectedInvoiceNumber, invoice.Reason,
+		invoice.Total.Currency, invoice.NetTotal.Amount, invoice.VATTotal.Amount, invoice.Total.Amount)
+	if err != nil {
+		log.Error().Err(err).Interface("invoice", invoice).Msg("failed to create invoice")
+		return fmt.Errorf("failed to create invoice: %w", constraintError(err))
+	}
+
+	lineQuery := `
+	INSERT INTO
+		invoice_lines (
+			id
+			, invoice_id
+			, position
+			, description
+			, quantity
+			, unit_price
+			, vat_rate
+			, total
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		if line.ID == "" {
+			line.ID = utils.NewUUID()
+		}
+		line.InvoiceID = invoice.ID
+		line.Position = i + 1
+
+		_, err := r.db.Exec(lineQuery, line.ID, line.InvoiceID, line.Position, line.Description, line.Quantity, line.UnitPrice.Amount, line.VATRate, line.Total.Amount)
+		if err != nil {
+			log.Error().Err(err).Interface("line", line).Msg("failed to create invoice line")
+			return fmt.Errorf("failed to create invoice line: %w", constraintError(err))
+		}
+	}
+
+	return nil
+}
+
+// GetByID returns an invoice by ID with its lines.
+func (r *invoiceRepository) GetByID(id string) (*domain.Invoice, error) {
+	return r.getOne(`SELECT`+invoiceColumns+` FROM invoices WHERE id = ?`, id)
+}
+
+// GetByBilling returns the invoice of a billing, or the credit note of a
+// refund, with its lines.
+func (r *invoiceRepository) GetByBilling(billingID string) (*domain.Invoice, error) {
+	return r.getOne(`SELECT`+invoiceColumns+` FROM invoices WHERE billing_id = ?`, billingID)
+}
+
+// getOne returns the invoice selected by a query with its lines
+func (r *invoiceRepository) getOne(query string, arg string) (*domain.Invoice, error) {
+	var row invoiceRow
+
+	err := r.db.Get(&row, query, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("arg", arg).Msg("failed to get invoice")
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	linesQuery := `
+	SELECT
+		id
+		, invoice_id
+		, position
+		, description
+		, quantity
+		, unit_price
+		, vat_rate
+		, total
+	FROM
+		invoice_lines
+	WHERE
+		invoice_id = ?
+	ORDER BY
+		position
+	`
+
+	var lines []invoiceLineRow
+
+	err = r.db.Select(&lines, linesQuery, row.ID)
+	if err != nil {
+		log.Error().Err(err).Str("invoiceID", row.ID).Msg("failed to get invoice lines")
+		return nil, fmt.Errorf("failed to get invoice lines: %w", err)
+	}
+
+	invoice := row.Invoice
+	invoice.NetTotal = money.New(row.NetTotal, row.Currency)
+	invoice.VATTotal = money.New(row.VATTotal, row.Currency)
+	invoice.Total = money.New(row.Total, row.Currency)
+	invoice.Lines = make([]domain.InvoiceLine, 0, len(lines))
+	for _, line := range lines {
+		invoiceLine := line.InvoiceLine
+		invoiceLine.UnitPrice = money.New(line.UnitPrice, row.Currency)
+		invoiceLine.Total = money.New(line.Total, row.Currency)
+		invoice.Lines = append(invoice.Lines, invoiceLine)
+	}
+
+	return &invoice, nil
+}
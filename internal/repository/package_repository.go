@@ -6,11 +6,12 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
 	"github.com/rs/zerolog/log"
 )
 
 type packageRepository struct {
-	db *sqlx.DB
+	db queryer
 }
 
 // NewPackageRepository creates a new package repository
@@ -20,6 +21,29 @@ func NewPackageRepository(db *sqlx.DB) domain.PackageRepository {
 	}
 }
 
+// packageRow is a stored package, with its price in minor units
+type packageRow struct {
+	domain.Package
+	Price    int64  `db:"price"`
+	Currency string `db:"currency"`
+}
+
+// toPackage converts a stored row to a domain.Package
+func (row *packageRow) toPackage() *domain.Package {
+	pkg := row.Package
+	pkg.Price = money.New(row.Price, row.Currency)
+	return &pkg
+}
+
+// toPackages converts stored rows to domain.Packages
+func toPackages(rows []packageRow) []domain.Package {
+	packages := make([]domain.Package, 0, len(rows))
+	for i := range rows {
+		packages = append(packages, *rows[i].toPackage())
+	}
+	return packages
+}
+
 // Create creates a new Package.
 func (r *packageRepository) Create(pkg *domain.Package) error {
 	query := `
@@ -29,14 +53,17 @@ func (r *packageRepository) Create(pkg *domain.Package) error {
 			, number_of_sessions
 			, type
 			, price
+			, currency
+			, vat_rate
+			, validity_days
 		)
-	VALUES (?, ?, ?, ?)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.Exec(query, pkg.Name, pkg.NumberOfSessions, pkg.Type, pkg.Price)
+	_, err := r.db.Exec(query, pkg.Name, pkg.NumberOfSessions, pkg.Type, pkg.Price.Amount, pkg.Price.Currency, pkg.VATRate, pkg.ValidityDays)
 	if err != nil {
 		log.Error().Err(err).Interface("package", pkg).Msg("failed to create package")
-		return fmt.Errorf("failed to create package: %w", err)
+		return fmt.Errorf("failed to create package: %w", constraintError(err))
 	}
 
 	return nil
@@ -54,7 +81,7 @@ func (r *packageRepository) Delete(id string) error {
 	_, err := r.db.Exec(query, id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("failed to delete package")
-		return fmt.Errorf("failed to delete package: %w", err)
+		return fmt.Errorf("failed to delete package: %w", constraintError(err))
 	}
 
 	return nil
@@ -62,24 +89,24 @@ func (r *packageRepository) Delete(id string) error {
 
 // GetAll implements domain.PackageRepository.
 func (r *packageRepository) GetAll() ([]domain.Package, error) {
-	var packages []domain.Package
+	var rows []packageRow
 
 	query := `
 	SELECT * FROM packages
 	`
 
-	err := r.db.Select(&packages, query)
+	err := r.db.Select(&rows, query)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get all packages")
 		return nil, fmt.Errorf("failed to get all packages: %w", err)
 	}
 
-	return packages, nil
+	return toPackages(rows), nil
 }
 
 // GetByID implements domain.PackageRepository.
 func (r *packageRepository) GetByID(id string) (*domain.Package, error) {
-	var pkg domain.Package
+	var row packageRow
 
 	query := `
 	SELECT 
@@ -90,18 +117,21 @@ func (r *packageRepository) GetByID(id string) (*domain.Package, error) {
 		id = ?
 	`
 
-	err := r.db.Get(&pkg, query, id)
+	err := r.db.Get(&row, query, id)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		log.Error().Err(err).Str("id", id).Msg("failed to get package by ID")
 		return nil, fmt.Errorf("failed to get package by ID: %w", err)
 	}
 
-	return &pkg, nil
+	return row.toPackage(), nil
 }
 
 // GetByName returns a package by name
 func (r *packageRepository) GetByName(name string) (*domain.Package, error) {
-	var pkg domain.Package
+	var row packageRow
 
 	query := `
 	SELECT
@@ -110,21 +140,29 @@ func (r *packageRepository) GetByName(name string) (*domain.Package, error) {
 		, number_of_sessions
 		, type
 		, price
+		, currency
+		, vat_rate
+		, validity_days
 	FROM
 		packages
+	WHERE
+		name = ?
 	`
 
-	err := r.db.Get(&pkg, query, name)
+	err := r.db.Get(&row, query, name)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		log.Error().Err(err).Str("name", name).Msg("failed to get package by name")
 		return nil, fmt.Errorf("failed to get package by name")
 	}
-	return &pkg, nil
+	return row.toPackage(), nil
 }
 
 // GetByType implements domain.PackageRepository.
 func (r *packageRepository) GetByType(pkgType domain.PackageType) ([]domain.Package, error) {
-	var packages []domain.Package
+	var rows []packageRow
 
 	query := `
 	SELECT
@@ -133,13 +171,16 @@ func (r *packageRepository) GetByType(pkgType domain.PackageType) ([]domain.Pack
 		, number_of_sessions
 		, type
 		, price
+		, currency
+		, vat_rate
+		, validity_days
 	FROM 
 		packages
 	WHERE 
 		type = ?
 	`
 
-	err := r.db.Select(&packages, query, pkgType)
+	err := r.db.Select(&rows, query, pkgType)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -148,7 +189,7 @@ func (r *packageRepository) GetByType(pkgType domain.PackageType) ([]domain.Pack
 		return nil, fmt.Errorf("failed to get package by package type")
 	}
 
-	return packages, nil
+	return toPackages(rows), nil
 }
 
 // Update implements domain.PackageRepository.
@@ -161,14 +202,17 @@ func (r *packageRepository) Update(pkg *domain.Package) error {
 		, number_of_sessions = ?
 		, type = ?
 		, price = ?
+		, currency = ?
+		, vat_rate = ?
+		, validity_days = ?
 	WHERE
 		id = ?
 	`
 
-	_, err := r.db.Exec(query, pkg.Name, pkg.NumberOfSessions, pkg.Type, pkg.Price, pkg.ID)
+	_, err := r.db.Exec(query, pkg.Name, pkg.NumberOfSessions, pkg.Type, pkg.Price.Amount, pkg.Price.Currency, pkg.VATRate, pkg.ValidityDays, pkg.ID)
 	if err != nil {
 		log.Error().Err(err).Interface("package", pkg).Msg("failed to update package")
-		return fmt.Errorf("failed to update client: %w", err)
+		return fmt.Errorf("failed to update client: %w", constraintError(err))
 	}
 
 	return nil
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type paymentRepository struct {
+	db queryer
+}
+
+// NewPaymentRepository creates a new payment repository
+func NewPaymentRepository(db *sqlx.DB) domain.PaymentRepository {
+	return &paymentRepository{
+		db: db,
+	}
+}
+
+// paymentColumns are the columns selected for a payment
+const paymentColumns = `
+		id
+		, billing_id
+		, client_id
+		, method
+		, amount
+		, currency
+		, paid_at
+		, COALESCE(reference, '') AS reference
+		, created_at`
+
+// balanceQuery selects the balance of payment billings. What is due is the
+// price less the refunds, what is paid includes the money paid back for them.
+const balanceQuery = `
+	SELECT
+		b.id AS billing_id
+		, b.client_id
+		, b.payment_date
+		, b.currency
+		, b.price + COALESCE(r.refunded, 0) AS due
+		, COALESCE(p.paid, 0) AS paid
+	FROM
+		billings b
+		LEFT JOIN (
+			SELECT
+				refunded_billing_id
+				, SUM(price) AS refunded
+			FROM
+				billings
+			WHERE
+				refunded_billing_id IS NOT NULL
+			GROUP BY
+				refunded_billing_id
+		) r ON r.refunded_billing_id = b.id
+		LEFT JOIN (
+			SELECT
+				COALESCE(pb.refunded_billing_id, pb.id) AS billing_id
+				, SUM(py.amount) AS paid
+			FROM
+				payments py
+				JOIN billings pb ON pb.id = py.billing_id
+			GROUP BY
+				COALESCE(pb.refunded_billing_id, pb.id)
+		) p ON p.billing_id = b.id
+	WHERE
+		b.kind = 'PAYMENT'`
+
+// paymentRow is a stored payment, with its amount in minor units
+type paymentRow struct {
+	domain.Payment
+	Amount   int64  `db:"amount"`
+	Currency string `db:"currency"`
+}
+
+// balanceRow is a computed billing balance, with its amounts in minor units
+type balanceRow struct {
+	domain.BillingBalance
+	Currency string `db:"currency"`
+	Due      int64  `db:"due"`
+	Paid     int64  `db:"paid"`
+}
+
+// toBalance converts a computed row to a domain.BillingBalance
+func (row *balanceRow) toBalance() *domain.BillingBalance {
+	balance := row.BillingBalance
+	balance.Due = money.New(row.Due, row.Currency)
+	balance.Paid = money.New(row.Paid, row.Currency)
+	balance.Outstanding = balance.Due.Sub(balance.Paid)
+	balance.Status = domain.PaymentStatusOf(balance.Due, balance.Paid)
+	return &balance
+}
+
+// toBalances converts computed rows to domain.BillingBalances
+func toBalances(rows []balanceRow) []domain.BillingBalance {
+	balances := make([]domain.BillingBalance, 0, len(rows))
+	for i := range rows {
+		balances = append(balances, *rows[i].toBalance())
+	}
+	return balances
+}
+
+// Create records a payment.
+func (r *paymentRepository) Create(payment *domain.Payment) error {
+	if payment.ID == "" {
+		payment.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		payments (
+			id
+			, billing_id
+			, client_id
+			, method
+			, amount
+			, currency
+			, paid_at
+			, reference
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))
+	`
+
+	_, err := r.db.Exec(query, payment.ID, payment.BillingID, payment.ClientID, payment.Method, payment.Amount.Amount, payment.Amount.Currency, payment.PaidAt, payment.Reference)
+	if err != nil {
+		log.Error().Err(err).Interface("payment", payment).Msg("failed to create payment")
+		return fmt.Errorf("failed to create payment: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetByBilling returns the payments of a billing and of its refunds, oldest
+// first.
+func (r *paymentRepository) GetByBilling(billingID string) ([]domain.Payment, error) {
+	query := `
+	SELECT` + paymentColumns + `
+	FROM
+		payments
+	WHERE
+		billing_id = ?
+		OR billing_id IN (SELECT id FROM billings WHERE refunded_billing_id = ?)
+	ORDER BY
+		paid_at
+	`
+
+	return r.getMany(query, billingID, billingID)
+}
+
+// GetByClient returns the payments of a client, oldest first.
+func (r *paymentRepository) GetByClient(clientID string) ([]domain.Payment, error) {
+	query := `
+	SELECT` + paymentColumns + `
+	FROM
+		payments
+	WHERE
+		client_id = ?
+	ORDER BY
+		paid_at
+	`
+
+	return r.getMany(query, clientID)
+}
+
+// GetRecent returns the latest payments.
+func (r *paymentRepository) GetRecent(limit int) ([]domain.Payment, error) {
+	query := `
+	SELECT` + paymentColumns + `
+	FROM
+		payments
+	ORDER BY
+		paid_at DESC
+	LIMIT
+		?
+	`
+
+	return r.getMany(query, limit)
+}
+
+// GetBalance returns the balance of a payment billing, or nil if there is no
+// such billing.
+func (r *paymentRepository) GetBalance(billingID string) (*domain.BillingBalance, error) {
+	var row balanceRow
+
+	err := r.db.Get(&row, balanceQuery+` AND b.id = ?`, billingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("billingID", billingID).Msg("failed to get billing balance")
+		return nil, fmt.Errorf("failed to get billing balance: %w", err)
+	}
+
+	return row.toBalance(), nil
+}
+
+// GetBalancesByClient returns the balances of the billings of a client,
+// oldest first.
+func (r *paymentRepository) GetBalancesByClient(clientID string) ([]domain.BillingBalance, error) {
+	var rows []balanceRow
+
+	err := r.db.Select(&rows, balanceQuery+` AND b.client_id = ? ORDER BY b.payment_date`, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get client billing balances")
+		return nil, fmt.Errorf("failed to get client billing balances: %w", err)
+	}
+
+	return toBalances(rows), nil
+}
+
+// GetOutstanding returns the balances of the billings that are not fully
+// paid, oldest first.
+func (r *paymentRepository) GetOutstanding() ([]domain.BillingBalance, error) {
+	var rows []balanceRow
+
+	err := r.db.Select(&rows, balanceQuery+` AND b.price + COALESCE(r.refunded, 0) > COALESCE(p.paid, 0) ORDER BY b.payment_date`)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get outstanding billings")
+		return nil, fmt.Errorf("failed to get outstanding billings: %w", err)
+	}
+
+	return toBalances(rows), nil
+}
+
+// getMany returns the payments selected by a query
+func (r *paymentRepository) getMany(query string, args ...interface{}) ([]domain.Payment, error) {
+	var rows []paymentRow
+
+	err := r.db.Select(&rows, query, args...)
+	if err != nil {
+		log.Error().Err(err).Interface("args", args).Msg("failed to get payments")
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+
+	payments := make([]domain.Payment, 0, len(rows))
+	for _, row := range rows {
+		payment := row.Payment
+		payment.Amount = money.New(row.Amount, row.Currency)
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type promotionRepository struct {
+	db queryer
+}
+
+// NewPromotionRepository creates a new promotion repository
+func NewPromotionRepository(db *sqlx.DB) domain.PromotionRepository {
+	return &promotionRepository{
+		db: db,
+	}
+}
+
+// promotionColumns are the columns selected for a promotion, with the number
+// of billings that used it
+const promotionColumns = `
+		id
+		, code
+		, COALESCE(description, '') AS description
+		, discount_type
+		, rate
+		, value
+		, currency
+		, valid_from
+		, valid_until
+		, max_uses
+		, max_uses_per_client
+		, active
+		, (
+			SELECT
+				COUNT(*)
+			FROM
+				billings b
+			WHERE
+				b.promotion_id = promotions.id
+				AND b.kind = 'PAYMENT'
+		) AS uses
+		, created_at
+		, updated_at`
+
+// promotionRow is a stored promotion, with its value in minor units
+type promotionRow struct {
+	domain.Promotion
+	Value    int64  `db:"value"`
+	Currency string `db:"currency"`
+}
+
+// toPromotion converts a stored row to a domain.Promotion
+func (row *promotionRow) toPromotion() *domain.Promotion {
+	promotion := row.Promotion
+	promotion.Value = money.New(row.Value, row.Currency)
+	promotion.PackageIDs = []string{}
+	return &promotion
+}
+
+// Create creates a new promotion and the packages it is limited to.
+func (r *promotionRepository) Create(promotion *domain.Promotion) error {
+	if promotion.ID == "" {
+		promotion.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		promotions (
+			id
+			, code
+			, description
+			, discount_type
+			, rate
+			, value
+			, currency
+			, valid_from
+			, valid_until
+			, max_uses
+			, max_uses_per_client
+			, active
+		)
+	VALUES (?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, promotion.ID, promotion.Code, promotion.Description, promotion.DiscountType, promotion.Rate, promotion.Value.Amount, promotion.Value.Currency,
+		promotion.ValidFrom, promotion.ValidUntil, promotion.MaxUses, promotion.MaxUsesPerClient, promotion.Active)
+	if err != nil {
+		log.Error().Err(err).Interface("promotion", promotion).Msg("failed to create promotion")
+		return fmt.Errorf("failed to create promotion: %w", constraintError(err))
+	}
+
+	return r.setPackages(promotion)
+}
+
+// GetAll returns all promotions, most recent first.
+func (r *promotionRepository) GetAll() ([]domain.Promotion, error) {
+	var rows []promotionRow
+
+	query := `
+	SELECT
+	` + promotionColumns + `
+	FROM
+		promotions
+	ORDER BY
+		created_at DESC
+	`
+
+	if err := r.db.Select(&rows, query); err != nil {
+		log.Error().Err(err).Msg("failed to get all promotions")
+		return nil, fmt.Errorf("failed to get all promotions: %w", err)
+	}
+
+	var links []struct {
+		PromotionID string `db:"promotion_id"`
+		PackageID   string `db:"package_id"`
+	}
+
+	query = `
+	SELECT
+		promotion_id
+		, package_id
+	FROM
+		promotion_packages
+	`
+
+	if err := r.db.Select(&links, query); err != nil {
+		log.Error().Err(err).Msg("failed to get promotion packages")
+		return nil, fmt.Errorf("failed to get promotion packages: %w", err)
+	}
+
+	packageIDs := make(map[string][]string)
+	for _, link := range links {
+		packageIDs[link.PromotionID] = append(packageIDs[link.PromotionID], link.PackageID)
+	}
+
+	promotions := make([]domain.Promotion, 0, len(rows))
+	for i := range rows {
+		promotion := rows[i].toPromotion()
+		if ids, ok := packageIDs[promotion.ID]; ok {
+			promotion.PackageIDs = ids
+		}
+		promotions = append(promotions, *promotion)
+	}
+
+	return promotions, nil
+}
+
+// GetByID returns a promotion by ID.
+func (r *promotionRepository) GetByID(id string) (*domain.Promotion, error) {
+	return r.getOne(`
+	SELECT
+	`+promotionColumns+`
+	FROM
+		promotions
+	WHERE
+		id = ?
+	`, id)
+}
+
+// GetByCode returns a promotion by code.
+func (r *promotionRepository) GetByCode(code string) (*domain.Promotion, error) {
+	return r.getOne(`
+	SELECT
+	`+promotionColumns+`
+	FROM
+		promotions
+	WHERE
+		code = ?
+	`, code)
+}
+
+// GetByCodeForUpdate returns a promotion by code and locks its row until the
+// end of the transaction.
+func (r *promotionRepository) GetByCodeForUpdate(code string) (*domain.Promotion, error) {
+	return r.getOne(`
+	SELECT
+	`+promotionColumns+`
+	FROM
+		promotions
+	WHERE
+		code = ?
+	FOR UPDATE
+	`, code)
+}
+
+// CountUses returns the number of billings that used a promotion, in total
+// and by a client, leaving out a billing being updated. Pending checkouts
+// count too until they expire, as they become billings once paid.
+func (r *promotionRepository) CountUses(promotionID, clientID, excludedBillingID string) (int, int, error) {
+	var uses struct {
+		Total    int `db:"total"`
+		ByClient int `db:"by_client"`
+	}
+
+	query := `
+	SELECT
+		COUNT(*) AS total
+		, COALESCE(SUM(client_id = ?), 0) AS by_client
+	FROM (
+		SELECT
+			client_id
+		FROM
+			billings
+		WHERE
+			promotion_id = ?
+			AND kind = 'PAYMENT'
+			AND id <> ?
+		UNION ALL
+		SELECT
+			client_id
+		FROM
+			checkouts
+		WHERE
+			promotion_id = ?
+			AND status = 'PENDING'
+			AND expires_at > NOW()
+	) AS uses
+	`
+
+	if err := r.db.Get(&uses, query, clientID, promotionID, excludedBillingID, promotionID); err != nil {
+		log.Error().Err(err).Str("promotionID", promotionID).Str("clientID", clientID).Msg("failed to count promotion uses")
+		return 0, 0, fmt.Errorf("failed to count promotion uses: %w", err)
+	}
+
+	return uses.Total, uses.ByClient, nil
+}
+
+// Update updates an existing promotion and replaces the packages it is
+// limited to.
+func (r *promotionRepository) Update(promotion *domain.Promotion) error {
+	query := `
+	UPDATE
+		promotions
+	SET
+		code = ?
+		, description = NULLIF(?, '')
+		, discount_type = ?
+		, rate = ?
+		, value = ?
+		, currency = ?
+		, valid_from = ?
+		, valid_until = ?
+		, max_uses = ?
+		, max_uses_per_client = ?
+		, active = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, promotion.Code, promotion.Description, promotion.DiscountType, promotion.Rate, promotion.Value.Amount, promotion.Value.Currency,
+		promotion.ValidFrom, promotion.ValidUntil, promotion.MaxUses, promotion.MaxUsesPerClient, promotion.Active, promotion.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("promotion", promotion).Msg("failed to update promotion")
+		return fmt.Errorf("failed to update promotion: %w", constraintError(err))
+	}
+
+	query = `
+	DELETE FROM
+		promotion_packages
+	WHERE
+		promotion_id = ?
+	`
+
+	if _, err := r.db.Exec(query, promotion.ID); err != nil {
+		log.Error().Err(err).Str("promotionID", promotion.ID).Msg("failed to clear promotion packages")
+		return fmt.Errorf("failed to clear promotion packages: %w", err)
+	}
+
+	return r.setPackages(promotion)
+}
+
+// setPackages links a promotion to the packages it is limited to
+func (r *promotionRepository) setPackages(promotion *domain.Promotion) error {
+	query := `
+	INSERT INTO
+		promotion_packages (
+			promotion_id
+			, package_id
+		)
+	VALUES (?, ?)
+	`
+
+	for _, packageID := range promotion.PackageIDs {
+		if _, err := r.db.Exec(query, promotion.ID, packageID); err != nil {
+			log.Error().Err(err).Str("promotionID", promotion.ID).Str("packageID", packageID).Msg("failed to link promotion package")
+			return fmt.Errorf("failed to link promotion package: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getOne returns the promotion selected by a query with its packages, or nil
+func (r *promotionRepository) getOne(query string, args ...interface{}) (*domain.Promotion, error) {
+	var row promotionRow
+
+	err := r.db.Get(&row, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Interface("args", args).Msg("failed to get promotion")
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+
+	promotion := row.toPromotion()
+
+	query = `
+	SELECT
+		package_id
+	FROM
+		promotion_packages
+	WHERE
+		promotion_id = ?
+	`
+
+	if err := r.db.Select(&promotion.PackageIDs, query, promotion.ID); err != nil {
+		log.Error().Err(err).Str("promotionID", promotion.ID).Msg("failed to get promotion packages")
+		return nil, fmt.Errorf("failed to get promotion packages: %w", err)
+	}
+
+	return promotion, nil
+}
@@ -0,0 +1,401 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/rs/zerolog/log"
+)
+
+type reportRepository struct {
+	db queryer
+}
+
+// NewReportRepository creates a new report repository
+func NewReportRepository(db *sqlx.DB) domain.ReportRepository {
+	return &reportRepository{
+		db: db,
+	}
+}
+
+// localTimeYears is how many years before and after now local times are
+// computed exactly for reports without a range
+const localTimeYears = 10
+
+// bookedCounts selects the number of appointments per schedule
+const bookedCounts = `
+		LEFT JOIN (
+			SELECT
+				schedule_id
+				, COUNT(1) AS booked_count
+			FROM
+				appointments
+			GROUP BY
+				schedule_id
+		) a ON a.schedule_id = s.id`
+
+// amountsRow holds summed amounts in minor units
+type amountsRow struct {
+	Currency    string `db:"currency"`
+	NetAmount   int64  `db:"net"`
+	VATAmount   int64  `db:"vat"`
+	GrossAmount int64  `db:"gross"`
+}
+
+// GetRevenueByMonth sums the billings and refunds per month of payment.
+func (r *reportRepository) GetRevenueByMonth(filter domain.ReportFilter) ([]domain.MonthlyRevenue, error) {
+	var rows []struct {
+		domain.MonthlyRevenue
+		amountsRow
+		DiscountAmount int64 `db:"discounts"`
+	}
+
+	paidAt, args := localTime("payment_date", filter)
+	condition, conditionArgs := inRange("payment_date", filter)
+	args = append(args, conditionArgs...)
+	query := `
+	SELECT
+		DATE_FORMAT(` + paidAt + `, '%Y-%m') AS month
+		, currency
+		, SUM(kind = 'PAYMENT') AS billings
+		, SUM(kind = 'REFUND') AS refunds
+		, SUM(discount) AS discounts
+		, SUM(net_price) AS net
+		, SUM(vat) AS vat
+		, SUM(price) AS gross
+	FROM
+		billings
+	WHERE
+		` + condition + `
+	GROUP BY
+		month
+		, currency
+	ORDER BY
+		month
+	`
+
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get revenue by month")
+		return nil, fmt.Errorf("failed to get revenue by month: %w", err)
+	}
+
+	revenues := make([]domain.MonthlyRevenue, 0, len(rows))
+	for _, row := range rows {
+		revenue := row.MonthlyRevenue
+		revenue.Discounts = money.New(row.DiscountAmount, row.Currency)
+		revenue.Net = money.New(row.NetAmount, row.Currency)
+		revenue.VAT = money.New(row.VATAmount, row.Currency)
+		revenue.Gross = money.New(row.GrossAmount, row.Currency)
+		revenues = append(revenues, revenue)
+	}
+
+	return revenues, nil
+}
+
+// GetRevenueByLocation sums the value of the sessions booked per location of
+// their class. A session is worth the price of the package it was debited
+// from divided by its credits; sessions paid with credits not granted by a
+// billing are left out.
+func (r *reportRepository) GetRevenueByLocation(filter domain.ReportFilter) ([]domain.LocationRevenue, error) {
+	var rows []struct {
+		domain.LocationRevenue
+		amountsRow
+	}
+
+	condition, args := inRange("s.class_datetime", filter)
+	query := `
+	SELECT
+		c.location
+		, b.currency
+		, COUNT(DISTINCT ap.id) AS sessions
+		, ROUND(SUM(-l.delta * b.net_price / b.credits)) AS net
+		, ROUND(SUM(-l.delta * b.price / b.credits)) AS gross
+	FROM
+		appointments ap
+		INNER JOIN schedule s ON s.id = ap.schedule_id
+		INNER JOIN classes c ON c.id = s.class_id
+		INNER JOIN credit_ledger l ON l.reference_id = ap.id AND l.kind = 'DEBIT'
+		INNER JOIN credit_lots lot ON lot.id = l.lot_id
+		INNER JOIN billings b ON b.id = lot.billing_id
+	WHERE
+		b.credits > 0
+		AND ` + condition + `
+	GROUP BY
+		c.location
+		, b.currency
+	ORDER BY
+		c.location
+	`
+
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get revenue by location")
+		return nil, fmt.Errorf("failed to get revenue by location: %w", err)
+	}
+
+	revenues := make([]domain.LocationRevenue, 0, len(rows))
+	for _, row := range rows {
+		revenue := row.LocationRevenue
+		revenue.Net = money.New(row.NetAmount, row.Currency)
+		revenue.Gross = money.New(row.GrossAmount, row.Currency)
+		revenues = append(revenues, revenue)
+	}
+
+	return revenues, nil
+}
+
+// GetRevenueByPackageType sums the billings and refunds per package type.
+func (r *reportRepository) GetRevenueByPackageType(filter domain.ReportFilter) ([]domain.PackageTypeRevenue, error) {
+	var rows []struct {
+		domain.PackageTypeRevenue
+		amountsRow
+	}
+
+	condition, args := inRange("b.payment_date", filter)
+	query := `
+	SELECT
+		p.type
+		, b.currency
+		, SUM(b.kind = 'PAYMENT') AS billings
+		, SUM(b.net_price) AS net
+		, SUM(b.vat) AS vat
+		, SUM(b.price) AS gross
+	FROM
+		billings b
+		INNER JOIN packages p ON p.id = b.package_id
+	WHERE
+		` + condition + `
+	GROUP BY
+		p.type
+		, b.currency
+	ORDER BY
+		p.type
+	`
+
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get revenue by package type")
+		return nil, fmt.Errorf("failed to get revenue by package type: %w", err)
+	}
+
+	revenues := make([]domain.PackageTypeRevenue, 0, len(rows))
+	for _, row := range rows {
+		revenue := row.PackageTypeRevenue
+		revenue.Net = money.New(row.NetAmount, row.Currency)
+		revenue.VAT = money.New(row.VATAmount, row.Currency)
+		revenue.Gross = money.New(row.GrossAmount, row.Currency)
+		revenues = append(revenues, revenue)
+	}
+
+	return revenues, nil
+}
+
+// GetRevenueByPaymentMethod sums the payments per method.
+func (r *reportRepository) GetRevenueByPaymentMethod(filter domain.ReportFilter) ([]domain.PaymentMethodRevenue, error) {
+	var rows []struct {
+		domain.PaymentMethodRevenue
+		Currency string `db:"currency"`
+		Amount   int64  `db:"amount"`
+	}
+
+	condition, args := inRange("paid_at", filter)
+	query := `
+	SELECT
+		method
+		, currency
+		, COUNT(1) AS payments
+		, SUM(amount) AS amount
+	FROM
+		payments
+	WHERE
+		` + condition + `
+	GROUP BY
+		method
+		, currency
+	ORDER BY
+		method
+	`
+
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get revenue by payment method")
+		return nil, fmt.Errorf("failed to get revenue by payment method: %w", err)
+	}
+
+	revenues := make([]domain.PaymentMethodRevenue, 0, len(rows))
+	for _, row := range rows {
+		revenue := row.PaymentMethodRevenue
+		revenue.Amount = money.New(row.Amount, row.Currency)
+		revenues = append(revenues, revenue)
+	}
+
+	return revenues, nil
+}
+
+// GetSessionCounts counts the sessions scheduled per month and their
+// bookings.
+func (r *reportRepository) GetSessionCounts(filter domain.ReportFilter) ([]domain.SessionCount, error) {
+	counts := []domain.SessionCount{}
+
+	startsAt, args := localTime("s.class_datetime", filter)
+	condition, conditionArgs := inRange("s.class_datetime", filter)
+	args = append(args, conditionArgs...)
+	query := `
+	SELECT
+		DATE_FORMAT(` + startsAt + `, '%Y-%m') AS month
+		, COUNT(1) AS sessions
+		, SUM(s.capacity) AS capacity
+		, SUM(COALESCE(a.booked_count, 0)) AS booked
+	FROM
+		schedule s` + bookedCounts + `
+	WHERE
+		` + condition + `
+	GROUP BY
+		month
+	ORDER BY
+		month
+	`
+
+	if err := r.db.Select(&counts, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get session counts")
+		return nil, fmt.Errorf("failed to get session counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetFillRateByClass computes the booked share of the capacity of each class,
+// best filled first.
+func (r *reportRepository) GetFillRateByClass(filter domain.ReportFilter) ([]domain.ClassFillRate, error) {
+	rates := []domain.ClassFillRate{}
+
+	condition, args := inRange("s.class_datetime", filter)
+	query := `
+	SELECT
+		c.id AS class_id
+		, c.name AS class_name
+		, c.location
+		, COUNT(1) AS sessions
+		, SUM(s.capacity) AS capacity
+		, SUM(COALESCE(a.booked_count, 0)) AS booked
+		, COALESCE(ROUND(SUM(COALESCE(a.booked_count, 0)) / NULLIF(SUM(s.capacity), 0), 4), 0) AS fill_rate
+	FROM
+		schedule s
+		INNER JOIN classes c ON c.id = s.class_id` + bookedCounts + `
+	WHERE
+		` + condition + `
+	GROUP BY
+		c.id
+		, c.name
+		, c.location
+	ORDER BY
+		fill_rate DESC
+		, c.name
+	`
+
+	if err := r.db.Select(&rates, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get fill rate by class")
+		return nil, fmt.Errorf("failed to get fill rate by class: %w", err)
+	}
+
+	return rates, nil
+}
+
+// GetFillRateBySlot computes the booked share of the capacity of the sessions
+// starting on each weekday and time of day.
+func (r *reportRepository) GetFillRateBySlot(filter domain.ReportFilter) ([]domain.SlotFillRate, error) {
+	rates := []domain.SlotFillRate{}
+
+	startsAt, args := localTime("s.class_datetime", filter)
+	condition, conditionArgs := inRange("s.class_datetime", filter)
+	args = append(append(args, args...), conditionArgs...)
+	query := `
+	SELECT
+		WEEKDAY(` + startsAt + `) + 1 AS weekday
+		, DATE_FORMAT(` + startsAt + `, '%H:%i') AS time
+		, COUNT(1) AS sessions
+		, SUM(s.capacity) AS capacity
+		, SUM(COALESCE(a.booked_count, 0)) AS booked
+		, COALESCE(ROUND(SUM(COALESCE(a.booked_count, 0)) / NULLIF(SUM(s.capacity), 0), 4), 0) AS fill_rate
+	FROM
+		schedule s` + bookedCounts + `
+	WHERE
+		` + condition + `
+	GROUP BY
+		weekday
+		, time
+	ORDER BY
+		weekday
+		, time
+	`
+
+	if err := r.db.Select(&rates, query, args...); err != nil {
+		log.Error().Err(err).Interface("filter", filter).Msg("failed to get fill rate by slot")
+		return nil, fmt.Errorf("failed to get fill rate by slot: %w", err)
+	}
+
+	return rates, nil
+}
+
+// localTime returns the SQL expression of the wall clock time of a UTC
+// column in the location of a filter, with its arguments. MySQL converts to
+// named time zones only once its time zone tables are loaded, so the offset
+// of the location is computed here for each period between its transitions
+// over the range of the filter, or over localTimeYears around now for open
+// ranges.
+func localTime(column string, filter domain.ReportFilter) (string, []interface{}) {
+	if filter.Location == nil {
+		return column, nil
+	}
+
+	now := time.Now()
+	from, to := filter.From, filter.To
+	if from.IsZero() {
+		from = now.AddDate(-localTimeYears, 0, 0)
+	}
+	if to.IsZero() {
+		to = now.AddDate(localTimeYears, 0, 0)
+	}
+
+	var branches strings.Builder
+	args := []interface{}{}
+
+	t := from.In(filter.Location)
+	_, offset := t.Zone()
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break
+		}
+
+		fmt.Fprintf(&branches, " WHEN %s < ? THEN %s + INTERVAL %d SECOND", column, column, offset)
+		args = append(args, end)
+
+		t = end.In(filter.Location)
+		_, offset = t.Zone()
+	}
+
+	if branches.Len() == 0 {
+		return fmt.Sprintf("(%s + INTERVAL %d SECOND)", column, offset), args
+	}
+	return fmt.Sprintf("(CASE%s ELSE %s + INTERVAL %d SECOND END)", branches.String(), column, offset), args
+}
+
+// inRange returns the condition restricting a date column to the range of a
+// filter, with its arguments
+func inRange(column string, filter domain.ReportFilter) (string, []interface{}) {
+	condition, args := column+" IS NOT NULL", []interface{}{}
+
+	if !filter.From.IsZero() {
+		condition += " AND " + column + " >= ?"
+		args = append(args, filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		condition += " AND " + column + " < ?"
+		args = append(args, filter.To)
+	}
+
+	return condition, args
+}
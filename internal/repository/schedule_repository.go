@@ -0,0 +1,530 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type scheduleRepository struct {
+	db queryer
+}
+
+// NewScheduleRepository creates a new schedule repository
+func NewScheduleRepository(db *sqlx.DB) domain.ScheduleRepository {
+	return &scheduleRepository{
+		db: db,
+	}
+}
+
+// scheduleDetailsRow is a flattened schedule/class/booking count row
+type scheduleDetailsRow struct {
+	ID            string           `db:"id"`
+	ClassID       string           `db:"class_id"`
+	Capacity      int              `db:"capacity"`
+	ClassDatetime time.Time        `db:"class_datetime"`
+	CreatedAt     time.Time        `db:"created_at"`
+	UpdatedAt     time.Time        `db:"updated_at"`
+	SeriesID      string           `db:"series_id"`
+	Occurrence    string           `db:"occurrence_date"`
+	Detached      bool             `db:"detached"`
+	ClassName     string           `db:"class_name"`
+	ClassLocation domain.Location  `db:"class_location"`
+	ClassType     domain.ClassType `db:"class_type"`
+	Equipment     sql.NullString   `db:"class_equipment"`
+	BookedCount   int              `db:"booked_count"`
+}
+
+// toDetails converts a flattened row to a domain.ScheduleWithDetails
+func (row *scheduleDetailsRow) toDetails() domain.ScheduleWithDetails {
+	class := &domain.Class{
+		ID:        row.ClassID,
+		Name:      row.ClassName,
+		Location:  row.ClassLocation,
+		Type:      row.ClassType,
+		Equipment: row.Equipment.String,
+	}
+
+	schedule := &domain.Schedule{
+		ID:             row.ID,
+		ClassID:        row.ClassID,
+		Capacity:       row.Capacity,
+		ClassDatetime:  row.ClassDatetime,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+		SeriesID:       row.SeriesID,
+		OccurrenceDate: row.Occurrence,
+		Detached:       row.Detached,
+		Class:          class,
+		BookedCount:    row.BookedCount,
+	}
+
+	available := row.Capacity - row.BookedCount
+	if available < 0 {
+		available = 0
+	}
+
+	return domain.ScheduleWithDetails{
+		Schedule:       schedule,
+		Class:          class,
+		BookedCount:    row.BookedCount,
+		AvailableSlots: available,
+	}
+}
+
+// scheduleDetailsQuery selects schedules with their class and booking count.
+// Callers append their own WHERE/ORDER BY clauses.
+const scheduleDetailsQuery = `
+	SELECT
+		s.id
+		, s.class_id
+		, s.capacity
+		, s.class_datetime
+		, s.created_at
+		, s.updated_at
+		, COALESCE(s.series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(s.occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, s.detached
+		, c.name AS class_name
+		, c.location AS class_location
+		, c.type AS class_type
+		, c.equipment AS class_equipment
+		, COALESCE(a.booked_count, 0) AS booked_count
+	FROM
+		schedule s
+		INNER JOIN classes c ON c.id = s.class_id
+		LEFT JOIN (
+			SELECT
+				schedule_id
+				, COUNT(1) AS booked_count
+			FROM
+				appointments
+			GROUP BY
+				schedule_id
+		) a ON a.schedule_id = s.id
+	`
+
+// Create creates a new schedule.
+func (r *scheduleRepository) Create(schedule *domain.Schedule) error {
+	if schedule.ID == "" {
+		schedule.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		schedule (
+			id
+			, class_id
+			, capacity
+			, class_datetime
+			, series_id
+			, occurrence_date
+		)
+	VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))
+	`
+
+	_, err := r.db.Exec(query, schedule.ID, schedule.ClassID, schedule.Capacity, schedule.ClassDatetime, schedule.SeriesID, schedule.OccurrenceDate)
+	if err != nil {
+		log.Error().Err(err).Interface("schedule", schedule).Msg("failed to create schedule")
+		return fmt.Errorf("failed to create schedule: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// Delete deletes a schedule.
+func (r *scheduleRepository) Delete(id string) error {
+	query := `
+	DELETE FROM
+		schedule
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to delete schedule")
+		return fmt.Errorf("failed to delete schedule: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetAll returns all schedules.
+func (r *scheduleRepository) GetAll() ([]domain.Schedule, error) {
+	var schedules []domain.Schedule
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, class_datetime
+		, created_at
+		, updated_at
+		, COALESCE(series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, detached
+	FROM
+		schedule
+	ORDER BY
+		class_datetime
+	`
+
+	err := r.db.Select(&schedules, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all schedules")
+		return nil, fmt.Errorf("failed to get all schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// GetByID returns a schedule by ID.
+func (r *scheduleRepository) GetByID(id string) (*domain.Schedule, error) {
+	var schedule domain.Schedule
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, class_datetime
+		, created_at
+		, updated_at
+		, COALESCE(series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, detached
+	FROM
+		schedule
+	WHERE
+		id = ?
+	`
+
+	err := r.db.Get(&schedule, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to get schedule by ID")
+		return nil, fmt.Errorf("failed to get schedule by ID: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// GetByIDForUpdate returns a schedule by ID and locks its row until the
+// surrounding transaction ends.
+func (r *scheduleRepository) GetByIDForUpdate(id string) (*domain.Schedule, error) {
+	var schedule domain.Schedule
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, class_datetime
+		, created_at
+		, updated_at
+		, COALESCE(series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, detached
+	FROM
+		schedule
+	WHERE
+		id = ?
+	FOR UPDATE
+	`
+
+	err := r.db.Get(&schedule, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to lock schedule by ID")
+		return nil, fmt.Errorf("failed to lock schedule by ID: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// GetByDate returns the schedules of a given day.
+func (r *scheduleRepository) GetByDate(date time.Time) ([]domain.Schedule, error) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return r.GetByDateRange(start, start.AddDate(0, 0, 1))
+}
+
+// GetByDateRange returns the schedules between startDate (inclusive) and endDate (exclusive).
+func (r *scheduleRepository) GetByDateRange(startDate, endDate time.Time) ([]domain.Schedule, error) {
+	var schedules []domain.Schedule
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, class_datetime
+		, created_at
+		, updated_at
+		, COALESCE(series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, detached
+	FROM
+		schedule
+	WHERE
+		class_datetime >= ?
+		AND class_datetime < ?
+	ORDER BY
+		class_datetime
+	`
+
+	err := r.db.Select(&schedules, query, startDate, endDate)
+	if err != nil {
+		log.Error().Err(err).Time("startDate", startDate).Time("endDate", endDate).Msg("failed to get schedules by date range")
+		return nil, fmt.Errorf("failed to get schedules by date range: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// GetByClass returns the schedules of a given class.
+func (r *scheduleRepository) GetByClass(classID string) ([]domain.Schedule, error) {
+	var schedules []domain.Schedule
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, class_datetime
+		, created_at
+		, updated_at
+		, COALESCE(series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, detached
+	FROM
+		schedule
+	WHERE
+		class_id = ?
+	ORDER BY
+		class_datetime
+	`
+
+	err := r.db.Select(&schedules, query, classID)
+	if err != nil {
+		log.Error().Err(err).Str("classID", classID).Msg("failed to get schedules by class")
+		return nil, fmt.Errorf("failed to get schedules by class: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// GetBySeries returns the occurrences of a series.
+func (r *scheduleRepository) GetBySeries(seriesID string) ([]domain.Schedule, error) {
+	var schedules []domain.Schedule
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, class_datetime
+		, created_at
+		, updated_at
+		, COALESCE(series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, detached
+	FROM
+		schedule
+	WHERE
+		series_id = ?
+	ORDER BY
+		class_datetime
+	`
+
+	err := r.db.Select(&schedules, query, seriesID)
+	if err != nil {
+		log.Error().Err(err).Str("seriesID", seriesID).Msg("failed to get schedules by series")
+		return nil, fmt.Errorf("failed to get schedules by series: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// GetUpcoming returns the next schedules based on a limit.
+func (r *scheduleRepository) GetUpcoming(limit int) ([]domain.Schedule, error) {
+	var schedules []domain.Schedule
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, class_datetime
+		, created_at
+		, updated_at
+		, COALESCE(series_id, '') AS series_id
+		, COALESCE(DATE_FORMAT(occurrence_date, '%Y-%m-%d'), '') AS occurrence_date
+		, detached
+	FROM
+		schedule
+	WHERE
+		class_datetime >= NOW()
+	ORDER BY
+		class_datetime
+	LIMIT
+		?
+	`
+
+	err := r.db.Select(&schedules, query, limit)
+	if err != nil {
+		log.Error().Err(err).Int("limit", limit).Msg("failed to get upcoming schedules")
+		return nil, fmt.Errorf("failed to get upcoming schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// GetWithDetails returns a schedule by ID with its class and booking count.
+func (r *scheduleRepository) GetWithDetails(id string) (*domain.ScheduleWithDetails, error) {
+	var row scheduleDetailsRow
+
+	query := scheduleDetailsQuery + `
+	WHERE
+		s.id = ?
+	`
+
+	err := r.db.Get(&row, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to get schedule with details")
+		return nil, fmt.Errorf("failed to get schedule with details: %w", err)
+	}
+
+	details := row.toDetails()
+	return &details, nil
+}
+
+// GetAllWithDetails returns all schedules with their class and booking count.
+func (r *scheduleRepository) GetAllWithDetails() ([]domain.ScheduleWithDetails, error) {
+	var rows []scheduleDetailsRow
+
+	query := scheduleDetailsQuery + `
+	ORDER BY
+		s.class_datetime
+	`
+
+	err := r.db.Select(&rows, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all schedules with details")
+		return nil, fmt.Errorf("failed to get all schedules with details: %w", err)
+	}
+
+	schedules := make([]domain.ScheduleWithDetails, 0, len(rows))
+	for i := range rows {
+		schedules = append(schedules, rows[i].toDetails())
+	}
+
+	return schedules, nil
+}
+
+// EachWithDetails calls fn with every schedule and its class and booking
+// count in the same order as GetAllWithDetails, one row at a time.
+func (r *scheduleRepository) EachWithDetails(fn func(*domain.ScheduleWithDetails) error) error {
+	query := scheduleDetailsQuery + `
+	ORDER BY
+		s.class_datetime
+	`
+
+	err := each(r.db, func(row *scheduleDetailsRow) error {
+		details := row.toDetails()
+		return fn(&details)
+	}, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to iterate schedules with details")
+		return fmt.Errorf("failed to iterate schedules with details: %w", err)
+	}
+
+	return nil
+}
+
+// GetDetailsByDateRange returns the schedules between startDate (inclusive)
+// and endDate (exclusive) with their class and booking count.
+func (r *scheduleRepository) GetDetailsByDateRange(startDate, endDate time.Time) ([]domain.ScheduleWithDetails, error) {
+	var rows []scheduleDetailsRow
+
+	query := scheduleDetailsQuery + `
+	WHERE
+		s.class_datetime >= ?
+		AND s.class_datetime < ?
+	ORDER BY
+		s.class_datetime
+	`
+
+	err := r.db.Select(&rows, query, startDate, endDate)
+	if err != nil {
+		log.Error().Err(err).Time("startDate", startDate).Time("endDate", endDate).Msg("failed to get schedules with details by date range")
+		return nil, fmt.Errorf("failed to get schedules with details by date range: %w", err)
+	}
+
+	schedules := make([]domain.ScheduleWithDetails, 0, len(rows))
+	for i := range rows {
+		schedules = append(schedules, rows[i].toDetails())
+	}
+
+	return schedules, nil
+}
+
+// DeleteUnbookedBySeries deletes the occurrences of a series starting from a
+// date that have no appointment and were not edited individually.
+func (r *scheduleRepository) DeleteUnbookedBySeries(seriesID string, from time.Time) error {
+	query := `
+	DELETE FROM
+		schedule
+	WHERE
+		series_id = ?
+		AND class_datetime >= ?
+		AND detached = FALSE
+		AND NOT EXISTS (
+			SELECT 1 FROM appointments a WHERE a.schedule_id = schedule.id
+		)
+	`
+
+	_, err := r.db.Exec(query, seriesID, from)
+	if err != nil {
+		log.Error().Err(err).Str("seriesID", seriesID).Time("from", from).Msg("failed to delete unbooked series occurrences")
+		return fmt.Errorf("failed to delete unbooked series occurrences: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// Update updates an existing schedule.
+func (r *scheduleRepository) Update(schedule *domain.Schedule) error {
+	query := `
+	UPDATE
+		schedule
+	SET
+		class_id = ?
+		, capacity = ?
+		, class_datetime = ?
+		, detached = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, schedule.ClassID, schedule.Capacity, schedule.ClassDatetime, schedule.Detached, schedule.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("schedule", schedule).Msg("failed to update schedule")
+		return fmt.Errorf("failed to update schedule: %w", constraintError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type seriesRepository struct {
+	db queryer
+}
+
+// NewScheduleSeriesRepository creates a new schedule series repository
+func NewScheduleSeriesRepository(db *sqlx.DB) domain.ScheduleSeriesRepository {
+	return &seriesRepository{
+		db: db,
+	}
+}
+
+// seriesRow is a series as stored, with its exdates comma separated
+type seriesRow struct {
+	ID        string     `db:"id"`
+	ClassID   string     `db:"class_id"`
+	Capacity  int        `db:"capacity"`
+	DTStart   time.Time  `db:"dtstart"`
+	RRule     string     `db:"rrule"`
+	ExDates   string     `db:"exdates"`
+	EndsAt    *time.Time `db:"ends_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+}
+
+// toSeries converts a stored row to a domain.ScheduleSeries
+func (row *seriesRow) toSeries() domain.ScheduleSeries {
+	series := domain.ScheduleSeries{
+		ID:        row.ID,
+		ClassID:   row.ClassID,
+		Capacity:  row.Capacity,
+		DTStart:   row.DTStart,
+		RRule:     row.RRule,
+		ExDates:   []string{},
+		EndsAt:    row.EndsAt,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+
+	if row.ExDates != "" {
+		series.ExDates = strings.Split(row.ExDates, ",")
+	}
+
+	return series
+}
+
+// Create creates a new series.
+func (r *seriesRepository) Create(series *domain.ScheduleSeries) error {
+	if series.ID == "" {
+		series.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		schedule_series (
+			id
+			, class_id
+			, capacity
+			, dtstart
+			, rrule
+			, exdates
+			, ends_at
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, series.ID, series.ClassID, series.Capacity, series.DTStart, series.RRule, strings.Join(series.ExDates, ","), series.EndsAt)
+	if err != nil {
+		log.Error().Err(err).Interface("series", series).Msg("failed to create schedule series")
+		return fmt.Errorf("failed to create schedule series: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// Delete deletes a series. Its remaining occurrences are unlinked.
+func (r *seriesRepository) Delete(id string) error {
+	query := `
+	DELETE FROM
+		schedule_series
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to delete schedule series")
+		return fmt.Errorf("failed to delete schedule series: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetAll returns all series.
+func (r *seriesRepository) GetAll() ([]domain.ScheduleSeries, error) {
+	var rows []seriesRow
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, dtstart
+		, rrule
+		, COALESCE(exdates, '') AS exdates
+		, ends_at
+		, created_at
+		, updated_at
+	FROM
+		schedule_series
+	ORDER BY
+		dtstart
+	`
+
+	err := r.db.Select(&rows, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all schedule series")
+		return nil, fmt.Errorf("failed to get all schedule series: %w", err)
+	}
+
+	series := make([]domain.ScheduleSeries, 0, len(rows))
+	for i := range rows {
+		series = append(series, rows[i].toSeries())
+	}
+
+	return series, nil
+}
+
+// GetByID returns a series by ID.
+func (r *seriesRepository) GetByID(id string) (*domain.ScheduleSeries, error) {
+	var row seriesRow
+
+	query := `
+	SELECT
+		id
+		, class_id
+		, capacity
+		, dtstart
+		, rrule
+		, COALESCE(exdates, '') AS exdates
+		, ends_at
+		, created_at
+		, updated_at
+	FROM
+		schedule_series
+	WHERE
+		id = ?
+	`
+
+	err := r.db.Get(&row, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("id", id).Msg("failed to get schedule series by ID")
+		return nil, fmt.Errorf("failed to get schedule series by ID: %w", err)
+	}
+
+	series := row.toSeries()
+	return &series, nil
+}
+
+// Update updates an existing series.
+func (r *seriesRepository) Update(series *domain.ScheduleSeries) error {
+	query := `
+	UPDATE
+		schedule_series
+	SET
+		class_id = ?
+		, capacity = ?
+		, dtstart = ?
+		, rrule = ?
+		, exdates = ?
+		, ends_at = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, series.ClassID, series.Capacity, series.DTStart, series.RRule, strings.Join(series.ExDates, ","), series.EndsAt, series.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("series", series).Msg("failed to update schedule series")
+		return fmt.Errorf("failed to update schedule series: %w", constraintError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type staffRepository struct {
+	db queryer
+}
+
+// NewStaffRepository creates a new staff repository
+func NewStaffRepository(db *sqlx.DB) domain.StaffRepository {
+	return &staffRepository{
+		db: db,
+	}
+}
+
+// staffColumns are the columns selected for a staff user
+const staffColumns = `
+		id
+		, email
+		, name
+		, role
+		, password_hash
+		, active
+		, last_login_at
+		, created_at
+		, updated_at`
+
+// Create creates a new staff user.
+func (r *staffRepository) Create(user *domain.StaffUser) error {
+	if user.ID == "" {
+		user.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		staff_users (
+			id
+			, email
+			, name
+			, role
+			, password_hash
+			, active
+		)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, user.ID, user.Email, user.Name, user.Role, user.PasswordHash, user.Active)
+	if err != nil {
+		log.Error().Err(err).Str("email", user.Email).Msg("failed to create staff user")
+		return fmt.Errorf("failed to create staff user: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetAll returns all staff users, sorted by name.
+func (r *staffRepository) GetAll() ([]domain.StaffUser, error) {
+	var users []domain.StaffUser
+
+	query := `
+	SELECT
+	` + staffColumns + `
+	FROM
+		staff_users
+	ORDER BY
+		name
+	`
+
+	if err := r.db.Select(&users, query); err != nil {
+		log.Error().Err(err).Msg("failed to get all staff users")
+		return nil, fmt.Errorf("failed to get all staff users: %w", err)
+	}
+
+	return users, nil
+}
+
+// GetByID returns a staff user by ID.
+func (r *staffRepository) GetByID(id string) (*domain.StaffUser, error) {
+	return r.getOne(`
+	SELECT
+	`+staffColumns+`
+	FROM
+		staff_users
+	WHERE
+		id = ?
+	`, id)
+}
+
+// GetByEmail returns a staff user by email.
+func (r *staffRepository) GetByEmail(email string) (*domain.StaffUser, error) {
+	return r.getOne(`
+	SELECT
+	`+staffColumns+`
+	FROM
+		staff_users
+	WHERE
+		email = ?
+	`, email)
+}
+
+// Update updates an existing staff user.
+func (r *staffRepository) Update(user *domain.StaffUser) error {
+	query := `
+	UPDATE
+		staff_users
+	SET
+		email = ?
+		, name = ?
+		, role = ?
+		, password_hash = ?
+		, active = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, user.Email, user.Name, user.Role, user.PasswordHash, user.Active, user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("id", user.ID).Msg("failed to update staff user")
+		return fmt.Errorf("failed to update staff user: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// UpdateLastLogin records when a staff user last logged in.
+func (r *staffRepository) UpdateLastLogin(id string, at time.Time) error {
+	query := `
+	UPDATE
+		staff_users
+	SET
+		last_login_at = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, at, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to update staff last login")
+		return fmt.Errorf("failed to update staff last login: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// CreateSession creates a new staff session.
+func (r *staffRepository) CreateSession(session *domain.StaffSession) error {
+	query := `
+	INSERT INTO
+		staff_sessions (
+			token_hash
+			, staff_id
+			, expires_at
+		)
+	VALUES (?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, session.TokenHash, session.StaffID, session.ExpiresAt)
+	if err != nil {
+		log.Error().Err(err).Str("staffID", session.StaffID).Msg("failed to create staff session")
+		return fmt.Errorf("failed to create staff session: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetSession returns a staff session by token hash, or nil.
+func (r *staffRepository) GetSession(tokenHash string) (*domain.StaffSession, error) {
+	var session domain.StaffSession
+
+	query := `
+	SELECT
+		token_hash
+		, staff_id
+		, expires_at
+		, created_at
+	FROM
+		staff_sessions
+	WHERE
+		token_hash = ?
+	`
+
+	err := r.db.Get(&session, query, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get staff session")
+		return nil, fmt.Errorf("failed to get staff session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// DeleteSession deletes a staff session by token hash.
+func (r *staffRepository) DeleteSession(tokenHash string) error {
+	query := `
+	DELETE FROM
+		staff_sessions
+	WHERE
+		token_hash = ?
+	`
+
+	if _, err := r.db.Exec(query, tokenHash); err != nil {
+		log.Error().Err(err).Msg("failed to delete staff session")
+		return fmt.Errorf("failed to delete staff session: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// DeleteSessions deletes all sessions of a staff user.
+func (r *staffRepository) DeleteSessions(staffID string) error {
+	query := `
+	DELETE FROM
+		staff_sessions
+	WHERE
+		staff_id = ?
+	`
+
+	if _, err := r.db.Exec(query, staffID); err != nil {
+		log.Error().Err(err).Str("staffID", staffID).Msg("failed to delete staff sessions")
+		return fmt.Errorf("failed to delete staff sessions: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions expired before a time and
+// returns how many were deleted.
+func (r *staffRepository) DeleteExpiredSessions(before time.Time) (int64, error) {
+	query := `
+	DELETE FROM
+		staff_sessions
+	WHERE
+		expires_at < ?
+	`
+
+	result, err := r.db.Exec(query, before)
+	if err != nil {
+		log.Error().Err(err).Time("before", before).Msg("failed to delete expired staff sessions")
+		return 0, fmt.Errorf("failed to delete expired staff sessions: %w", constraintError(err))
+	}
+
+	return result.RowsAffected()
+}
+
+// getOne returns the staff user selected by a query, or nil
+func (r *staffRepository) getOne(query string, args ...interface{}) (*domain.StaffUser, error) {
+	var user domain.StaffUser
+
+	err := r.db.Get(&user, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Interface("args", args).Msg("failed to get staff user")
+		return nil, fmt.Errorf("failed to get staff user: %w", err)
+	}
+
+	return &user, nil
+}
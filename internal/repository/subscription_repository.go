@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type subscriptionRepository struct {
+	db queryer
+}
+
+// NewSubscriptionRepository creates a new subscription repository
+func NewSubscriptionRepository(db *sqlx.DB) domain.SubscriptionRepository {
+	return &subscriptionRepository{
+		db: db,
+	}
+}
+
+// subscriptionColumns are the columns selected for a subscription
+const subscriptionColumns = `
+		id
+		, client_id
+		, package_id
+		, status
+		, period
+		, start_date
+		, current_period_start
+		, current_period_end
+		, cancel_at_period_end
+		, paused_at
+		, cancelled_at
+		, created_at
+		, updated_at`
+
+// Create creates a new subscription.
+func (r *subscriptionRepository) Create(subscription *domain.Subscription) error {
+	if subscription.ID == "" {
+		subscription.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		subscriptions (
+			id
+			, client_id
+			, package_id
+			, status
+			, period
+			, start_date
+			, current_period_start
+			, current_period_end
+			, cancel_at_period_end
+			, paused_at
+			, cancelled_at
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, subscription.ID, subscription.ClientID, subscription.PackageID, subscription.Status, subscription.Period,
+		subscription.StartDate, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd, subscription.CancelAtPeriodEnd, subscription.PausedAt, subscription.CancelledAt)
+	if err != nil {
+		log.Error().Err(err).Interface("subscription", subscription).Msg("failed to create subscription")
+		return fmt.Errorf("failed to create subscription: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetAll returns all subscriptions, most recent first.
+func (r *subscriptionRepository) GetAll() ([]domain.Subscription, error) {
+	subscriptions := []domain.Subscription{}
+
+	query := `
+	SELECT
+	` + subscriptionColumns + `
+	FROM
+		subscriptions
+	ORDER BY
+		start_date DESC
+	`
+
+	err := r.db.Select(&subscriptions, query)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get all subscriptions")
+		return nil, fmt.Errorf("failed to get all subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// GetByID returns a subscription by ID.
+func (r *subscriptionRepository) GetByID(id string) (*domain.Subscription, error) {
+	return r.getOne(`
+	SELECT
+	`+subscriptionColumns+`
+	FROM
+		subscriptions
+	WHERE
+		id = ?
+	`, id)
+}
+
+// GetByIDForUpdate returns a subscription by ID and locks its row until the
+// end of the transaction.
+func (r *subscriptionRepository) GetByIDForUpdate(id string) (*domain.Subscription, error) {
+	return r.getOne(`
+	SELECT
+	`+subscriptionColumns+`
+	FROM
+		subscriptions
+	WHERE
+		id = ?
+	FOR UPDATE
+	`, id)
+}
+
+// GetCurrentByClient returns the subscription of a client that is not
+// cancelled, if any.
+func (r *subscriptionRepository) GetCurrentByClient(clientID string) (*domain.Subscription, error) {
+	return r.getOne(`
+	SELECT
+	`+subscriptionColumns+`
+	FROM
+		subscriptions
+	WHERE
+		client_id = ?
+		AND status <> 'CANCELLED'
+	ORDER BY
+		start_date DESC
+	LIMIT 1
+	`, clientID)
+}
+
+// GetByClient returns the subscriptions of a client, most recent first.
+func (r *subscriptionRepository) GetByClient(clientID string) ([]domain.Subscription, error) {
+	subscriptions := []domain.Subscription{}
+
+	query := `
+	SELECT
+	` + subscriptionColumns + `
+	FROM
+		subscriptions
+	WHERE
+		client_id = ?
+	ORDER BY
+		start_date DESC
+	`
+
+	err := r.db.Select(&subscriptions, query, clientID)
+	if err != nil {
+		log.Error().Err(err).Str("clientID", clientID).Msg("failed to get subscriptions by client")
+		return nil, fmt.Errorf("failed to get subscriptions by client: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// GetDue returns the active subscriptions whose current period has ended at
+// a time.
+func (r *subscriptionRepository) GetDue(at time.Time) ([]domain.Subscription, error) {
+	subscriptions := []domain.Subscription{}
+
+	query := `
+	SELECT
+	` + subscriptionColumns + `
+	FROM
+		subscriptions
+	WHERE
+		status = 'ACTIVE'
+		AND current_period_end <= ?
+	ORDER BY
+		current_period_end
+	`
+
+	err := r.db.Select(&subscriptions, query, at)
+	if err != nil {
+		log.Error().Err(err).Time("at", at).Msg("failed to get due subscriptions")
+		return nil, fmt.Errorf("failed to get due subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// GetRenewals returns the active subscriptions renewing before a time, with
+// their client and plan, soonest first. Subscriptions cancelled at the end
+// of their period are left out.
+func (r *subscriptionRepository) GetRenewals(until time.Time) ([]domain.SubscriptionRenewal, error) {
+	var rows []struct {
+		domain.Subscription
+		ClientFirstName string `db:"client_firstname"`
+		ClientLastName  string `db:"client_lastname"`
+		ClientEmail     string `db:"client_email"`
+		PackageName     string `db:"package_name"`
+		PackagePrice    int64  `db:"package_price"`
+		PackageCurrency string `db:"package_currency"`
+		PackageVATRate  int    `db:"package_vat_rate"`
+	}
+
+	query := `
+	SELECT
+		s.id
+		, s.client_id
+		, s.package_id
+		, s.status
+		, s.period
+		, s.start_date
+		, s.current_period_start
+		, s.current_period_end
+		, s.cancel_at_period_end
+		, s.paused_at
+		, s.cancelled_at
+		, s.created_at
+		, s.updated_at
+		, c.firstname AS client_firstname
+		, c.lastname AS client_lastname
+		, c.email AS client_email
+		, p.name AS package_name
+		, p.price AS package_price
+		, p.currency AS package_currency
+		, p.vat_rate AS package_vat_rate
+	FROM
+		subscriptions s
+		INNER JOIN clients c ON c.id = s.client_id
+		INNER JOIN packages p ON p.id = s.package_id
+	WHERE
+		s.status = 'ACTIVE'
+		AND s.cancel_at_period_end = FALSE
+		AND s.current_period_end < ?
+	ORDER BY
+		s.current_period_end
+	`
+
+	if err := r.db.Select(&rows, query, until); err != nil {
+		log.Error().Err(err).Time("until", until).Msg("failed to get subscription renewals")
+		return nil, fmt.Errorf("failed to get subscription renewals: %w", err)
+	}
+
+	renewals := make([]domain.SubscriptionRenewal, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		subscription := row.Subscription
+
+		renewals = append(renewals, domain.SubscriptionRenewal{
+			Subscription: &subscription,
+			Client: &domain.Client{
+				ID:        subscription.ClientID,
+				FirstName: row.ClientFirstName,
+				LastName:  row.ClientLastName,
+				Email:     row.ClientEmail,
+			},
+			Package: &domain.Package{
+				ID:      subscription.PackageID,
+				Name:    row.PackageName,
+				Type:    domain.MembershipPackage,
+				Price:   money.New(row.PackagePrice, row.PackageCurrency),
+				VATRate: row.PackageVATRate,
+			},
+			RenewsAt: subscription.CurrentPeriodEnd,
+		})
+	}
+
+	return renewals, nil
+}
+
+// Update updates an existing subscription.
+func (r *subscriptionRepository) Update(subscription *domain.Subscription) error {
+	query := `
+	UPDATE
+		subscriptions
+	SET
+		status = ?
+		, current_period_start = ?
+		, current_period_end = ?
+		, cancel_at_period_end = ?
+		, paused_at = ?
+		, cancelled_at = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, subscription.Status, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd,
+		subscription.CancelAtPeriodEnd, subscription.PausedAt, subscription.CancelledAt, subscription.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("subscription", subscription).Msg("failed to update subscription")
+		return fmt.Errorf("failed to update subscription: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// getOne returns the subscription selected by a query, or nil
+func (r *subscriptionRepository) getOne(query string, args ...interface{}) (*domain.Subscription, error) {
+	var subscription domain.Subscription
+
+	err := r.db.Get(&subscription, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Interface("args", args).Msg("failed to get subscription")
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	return &subscription, nil
+}
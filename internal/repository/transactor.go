@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx so repositories
+// can run either directly on the pool or inside a transaction
+type queryer interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Queryx(query string, args ...interface{}) (*sqlx.Rows, error)
+	sqlx.Execer
+}
+
+// each runs a query and calls fn with each row scanned into a T, without
+// loading the whole result in memory. It stops at the first error.
+func each[T any](db queryer, fn func(*T) error, query string, args ...interface{}) error {
+	rows, err := db.Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row T
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+type transactor struct {
+	db *sqlx.DB
+}
+
+// NewTransactor creates a new transactor
+func NewTransactor(db *sqlx.DB) domain.Transactor {
+	return &transactor{
+		db: db,
+	}
+}
+
+// WithinTransaction runs fn with repositories bound to a new transaction.
+// ctx is only passed on to fn, for the audit log.
+func (t *transactor) WithinTransaction(ctx context.Context, fn func(uow domain.UnitOfWork) error) (err error) {
+	tx, err := t.db.Beginx()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&unitOfWork{ctx: ctx, tx: tx}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Error().Err(rbErr).Msg("failed to rollback transaction")
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+type unitOfWork struct {
+	ctx context.Context
+	tx  *sqlx.Tx
+}
+
+// Context returns the context the transaction was started with
+func (u *unitOfWork) Context() context.Context {
+	return u.ctx
+}
+
+// Clients returns a client repository bound to the transaction
+func (u *unitOfWork) Clients() domain.ClientRepository {
+	return &clientRepository{db: u.tx}
+}
+
+// Credits returns a credit ledger repository bound to the transaction
+func (u *unitOfWork) Credits() domain.CreditLedgerRepository {
+	return &creditLedgerRepository{db: u.tx}
+}
+
+// CreditLots returns a credit lot repository bound to the transaction
+func (u *unitOfWork) CreditLots() domain.CreditLotRepository {
+	return &creditLotRepository{db: u.tx}
+}
+
+// Packages returns a package repository bound to the transaction
+func (u *unitOfWork) Packages() domain.PackageRepository {
+	return &packageRepository{db: u.tx}
+}
+
+// Billings returns a billing repository bound to the transaction
+func (u *unitOfWork) Billings() domain.BillingRepository {
+	return &billingRepository{db: u.tx}
+}
+
+// Invoices returns an invoice repository bound to the transaction
+func (u *unitOfWork) Invoices() domain.InvoiceRepository {
+	return &invoiceRepository{db: u.tx}
+}
+
+// Payments returns a payment repository bound to the transaction
+func (u *unitOfWork) Payments() domain.PaymentRepository {
+	return &paymentRepository{db: u.tx}
+}
+
+// Classes returns a class repository bound to the transaction
+func (u *unitOfWork) Classes() domain.ClassRepository {
+	return &classRepository{db: u.tx}
+}
+
+// Schedules returns a schedule repository bound to the transaction
+func (u *unitOfWork) Schedules() domain.ScheduleRepository {
+	return &scheduleRepository{db: u.tx}
+}
+
+// Series returns a schedule series repository bound to the transaction
+func (u *unitOfWork) Series() domain.ScheduleSeriesRepository {
+	return &seriesRepository{db: u.tx}
+}
+
+// Appointments returns an appointment repository bound to the transaction
+func (u *unitOfWork) Appointments() domain.AppointmentRepository {
+	return &appointmentRepository{db: u.tx}
+}
+
+// Cancellations returns a cancellation repository bound to the transaction
+func (u *unitOfWork) Cancellations() domain.CancellationRepository {
+	return &cancellationRepository{db: u.tx}
+}
+
+// Waitlist returns a waitlist repository bound to the transaction
+func (u *unitOfWork) Waitlist() domain.WaitlistRepository {
+	return &waitlistRepository{db: u.tx}
+}
+
+// Subscriptions returns a subscription repository bound to the transaction
+func (u *unitOfWork) Subscriptions() domain.SubscriptionRepository {
+	return &subscriptionRepository{db: u.tx}
+}
+
+// Promotions returns a promotion repository bound to the transaction
+func (u *unitOfWork) Promotions() domain.PromotionRepository {
+	return &promotionRepository{db: u.tx}
+}
+
+// Vouchers returns a voucher repository bound to the transaction
+func (u *unitOfWork) Vouchers() domain.VoucherRepository {
+	return &voucherRepository{db: u.tx}
+}
+
+// Checkouts returns a checkout repository bound to the transaction
+func (u *unitOfWork) Checkouts() domain.CheckoutRepository {
+	return &checkoutRepository{db: u.tx}
+}
+
+// ClientLogins returns a client login repository bound to the transaction
+func (u *unitOfWork) ClientLogins() domain.ClientLoginRepository {
+	return &clientLoginRepository{db: u.tx}
+}
+
+// Staff returns a staff repository bound to the transaction
+func (u *unitOfWork) Staff() domain.StaffRepository {
+	return &staffRepository{db: u.tx}
+}
+
+// Audit returns an audit repository bound to the transaction
+func (u *unitOfWork) Audit() domain.AuditRepository {
+	return &auditRepository{db: u.tx}
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type voucherRepository struct {
+	db queryer
+}
+
+// NewVoucherRepository creates a new voucher repository
+func NewVoucherRepository(db *sqlx.DB) domain.VoucherRepository {
+	return &voucherRepository{
+		db: db,
+	}
+}
+
+// voucherColumns are the columns selected for a voucher
+const voucherColumns = `
+		id
+		, code
+		, kind
+		, value
+		, balance
+		, currency
+		, sessions
+		, COALESCE(session_type, '') AS session_type
+		, purchaser_name
+		, COALESCE(purchaser_email, '') AS purchaser_email
+		, COALESCE(purchaser_client_id, '') AS purchaser_client_id
+		, status
+		, purchased_at
+		, expires_at
+		, created_at
+		, updated_at`
+
+// voucherRow is a stored voucher, with its amounts in minor units
+type voucherRow struct {
+	domain.Voucher
+	Value    int64  `db:"value"`
+	Balance  int64  `db:"balance"`
+	Currency string `db:"currency"`
+}
+
+// toVoucher converts a stored row to a domain.Voucher
+func (row *voucherRow) toVoucher() *domain.Voucher {
+	voucher := row.Voucher
+	voucher.Value = money.New(row.Value, row.Currency)
+	voucher.Balance = money.New(row.Balance, row.Currency)
+	return &voucher
+}
+
+// redemptionRow is a stored voucher redemption, with its amount in minor
+// units
+type redemptionRow struct {
+	domain.VoucherRedemption
+	Amount   int64  `db:"amount"`
+	Currency string `db:"currency"`
+}
+
+// Create creates a new voucher.
+func (r *voucherRepository) Create(voucher *domain.Voucher) error {
+	if voucher.ID == "" {
+		voucher.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		vouchers (
+			id
+			, code
+			, kind
+			, value
+			, balance
+			, currency
+			, sessions
+			, session_type
+			, purchaser_name
+			, purchaser_email
+			, purchaser_client_id
+			, status
+			, purchased_at
+			, expires_at
+		)
+	VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, voucher.ID, voucher.Code, voucher.Kind, voucher.Value.Amount, voucher.Balance.Amount, voucher.Value.Currency, voucher.Sessions, voucher.SessionType,
+		voucher.PurchaserName, voucher.PurchaserEmail, voucher.PurchaserClientID, voucher.Status, voucher.PurchasedAt, voucher.ExpiresAt)
+	if err != nil {
+		log.Error().Err(err).Interface("voucher", voucher).Msg("failed to create voucher")
+		return fmt.Errorf("failed to create voucher: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetAll returns all vouchers, most recently purchased first.
+func (r *voucherRepository) GetAll() ([]domain.Voucher, error) {
+	var rows []voucherRow
+
+	query := `
+	SELECT
+	` + voucherColumns + `
+	FROM
+		vouchers
+	ORDER BY
+		purchased_at DESC
+	`
+
+	if err := r.db.Select(&rows, query); err != nil {
+		log.Error().Err(err).Msg("failed to get all vouchers")
+		return nil, fmt.Errorf("failed to get all vouchers: %w", err)
+	}
+
+	vouchers := make([]domain.Voucher, 0, len(rows))
+	for i := range rows {
+		vouchers = append(vouchers, *rows[i].toVoucher())
+	}
+
+	return vouchers, nil
+}
+
+// GetByID returns a voucher by ID.
+func (r *voucherRepository) GetByID(id string) (*domain.Voucher, error) {
+	return r.getOne(`
+	SELECT
+	`+voucherColumns+`
+	FROM
+		vouchers
+	WHERE
+		id = ?
+	`, id)
+}
+
+// GetByIDForUpdate returns a voucher by ID and locks its row until the end
+// of the transaction.
+func (r *voucherRepository) GetByIDForUpdate(id string) (*domain.Voucher, error) {
+	return r.getOne(`
+	SELECT
+	`+voucherColumns+`
+	FROM
+		vouchers
+	WHERE
+		id = ?
+	FOR UPDATE
+	`, id)
+}
+
+// GetByCode returns a voucher by code.
+func (r *voucherRepository) GetByCode(code string) (*domain.Voucher, error) {
+	return r.getOne(`
+	SELECT
+	`+voucherColumns+`
+	FROM
+		vouchers
+	WHERE
+		code = ?
+	`, code)
+}
+
+// GetByCodeForUpdate returns a voucher by code and locks its row until the
+// end of the transaction.
+func (r *voucherRepository) GetByCodeForUpdate(code string) (*domain.Voucher, error) {
+	return r.getOne(`
+	SELECT
+	`+voucherColumns+`
+	FROM
+		vouchers
+	WHERE
+		code = ?
+	FOR UPDATE
+	`, code)
+}
+
+// GetRedemptions returns the redemptions of a voucher, oldest first.
+func (r *voucherRepository) GetRedemptions(voucherID string) ([]domain.VoucherRedemption, error) {
+	var rows []redemptionRow
+
+	query := `
+	SELECT
+		id
+		, voucher_id
+		, client_id
+		, amount
+		, currency
+		, sessions
+		, COALESCE(billing_id, '') AS billing_id
+		, created_at
+	FROM
+		voucher_redemptions
+	WHERE
+		voucher_id = ?
+	ORDER BY
+		created_at
+	`
+
+	if err := r.db.Select(&rows, query, voucherID); err != nil {
+		log.Error().Err(err).Str("voucherID", voucherID).Msg("failed to get voucher redemptions")
+		return nil, fmt.Errorf("failed to get voucher redemptions: %w", err)
+	}
+
+	redemptions := make([]domain.VoucherRedemption, 0, len(rows))
+	for _, row := range rows {
+		redemption := row.VoucherRedemption
+		redemption.Amount = money.New(row.Amount, row.Currency)
+		redemptions = append(redemptions, redemption)
+	}
+
+	return redemptions, nil
+}
+
+// Update updates the balance and status of a voucher.
+func (r *voucherRepository) Update(voucher *domain.Voucher) error {
+	query := `
+	UPDATE
+		vouchers
+	SET
+		balance = ?
+		, status = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, voucher.Balance.Amount, voucher.Status, voucher.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("voucher", voucher).Msg("failed to update voucher")
+		return fmt.Errorf("failed to update voucher: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// CreateRedemption records a redemption of a voucher.
+func (r *voucherRepository) CreateRedemption(redemption *domain.VoucherRedemption) error {
+	if redemption.ID == "" {
+		redemption.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		voucher_redemptions (
+			id
+			, voucher_id
+			, client_id
+			, amount
+			, currency
+			, sessions
+			, billing_id
+		)
+	VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''))
+	`
+
+	_, err := r.db.Exec(query, redemption.ID, redemption.VoucherID, redemption.ClientID, redemption.Amount.Amount, redemption.Amount.Currency, redemption.Sessions, redemption.BillingID)
+	if err != nil {
+		log.Error().Err(err).Interface("redemption", redemption).Msg("failed to create voucher redemption")
+		return fmt.Errorf("failed to create voucher redemption: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// getOne returns the voucher selected by a query, or nil
+func (r *voucherRepository) getOne(query string, args ...interface{}) (*domain.Voucher, error) {
+	var row voucherRow
+
+	err := r.db.Get(&row, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Interface("args", args).Msg("failed to get voucher")
+		return nil, fmt.Errorf("failed to get voucher: %w", err)
+	}
+
+	return row.toVoucher(), nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type waitlistRepository struct {
+	db queryer
+}
+
+// NewWaitlistRepository creates a new waitlist repository
+func NewWaitlistRepository(db *sqlx.DB) domain.WaitlistRepository {
+	return &waitlistRepository{
+		db: db,
+	}
+}
+
+// Create adds a client to a schedule waitlist.
+func (r *waitlistRepository) Create(entry *domain.WaitlistEntry) error {
+	if entry.ID == "" {
+		entry.ID = utils.NewUUID()
+	}
+
+	query := `
+	INSERT INTO
+		waitlist (
+			id
+			, schedule_id
+			, client_id
+			, status
+		)
+	VALUES (?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, entry.ID, entry.ScheduleID, entry.ClientID, entry.Status)
+	if err != nil {
+		log.Error().Err(err).Interface("entry", entry).Msg("failed to create waitlist entry")
+		return fmt.Errorf("failed to create waitlist entry: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// Delete removes a waitlist entry.
+func (r *waitlistRepository) Delete(id string) error {
+	query := `
+	DELETE FROM
+		waitlist
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to delete waitlist entry")
+		return fmt.Errorf("failed to delete waitlist entry: %w", constraintError(err))
+	}
+
+	return nil
+}
+
+// GetBySchedule returns the waiting entries of a schedule in join order.
+func (r *waitlistRepository) GetBySchedule(scheduleID string) ([]domain.WaitlistEntry, error) {
+	var entries []domain.WaitlistEntry
+
+	query := `
+	SELECT
+		id
+		, schedule_id
+		, client_id
+		, status
+		, COALESCE(appointment_id, '') AS appointment_id
+		, promoted_at
+		, created_at
+	FROM
+		waitlist
+	WHERE
+		schedule_id = ?
+		AND status = ?
+	ORDER BY
+		created_at
+		, id
+	`
+
+	err := r.db.Select(&entries, query, scheduleID, domain.Waiting)
+	if err != nil {
+		log.Error().Err(err).Str("scheduleID", scheduleID).Msg("failed to get waitlist by schedule")
+		return nil, fmt.Errorf("failed to get waitlist by schedule: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetWaitingByClientAndSchedule returns the waiting entry of a client on a schedule.
+func (r *waitlistRepository) GetWaitingByClientAndSchedule(clientID, scheduleID string) (*domain.WaitlistEntry, error) {
+	var entry domain.WaitlistEntry
+
+	query := `
+	SELECT
+		id
+		, schedule_id
+		, client_id
+		, status
+		, COALESCE(appointment_id, '') AS appointment_id
+		, promoted_at
+		, created_at
+	FROM
+		waitlist
+	WHERE
+		schedule_id = ?
+		AND client_id = ?
+		AND status = ?
+	`
+
+	err := r.db.Get(&entry, query, scheduleID, clientID, domain.Waiting)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Str("clientID", clientID).Str("scheduleID", scheduleID).Msg("failed to get waitlist entry")
+		return nil, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Update updates the status of a waitlist entry.
+func (r *waitlistRepository) Update(entry *domain.WaitlistEntry) error {
+	query := `
+	UPDATE
+		waitlist
+	SET
+		status = ?
+		, appointment_id = NULLIF(?, '')
+		, promoted_at = ?
+	WHERE
+		id = ?
+	`
+
+	_, err := r.db.Exec(query, entry.Status, entry.AppointmentID, entry.PromotedAt, entry.ID)
+	if err != nil {
+		log.Error().Err(err).Interface("entry", entry).Msg("failed to update waitlist entry")
+		return fmt.Errorf("failed to update waitlist entry: %w", constraintError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+type accountingService struct {
+	billings domain.BillingRepository
+	settings domain.AccountingSettings
+	location *time.Location
+}
+
+// NewAccountingService creates a new accounting service. Fiscal years and
+// entry dates follow the calendar in location.
+func NewAccountingService(billings domain.BillingRepository, settings domain.AccountingSettings, location *time.Location) domain.AccountingService {
+	return &accountingService{
+		billings: billings,
+		settings: settings,
+		location: location,
+	}
+}
+
+// GetSalesJournal posts the billings paid during a fiscal year to the sales
+// journal. Each billing is an entry debiting the client receivable account
+// with the gross price and crediting the sales and VAT accounts with the net
+// price and VAT; refunds reverse the sides.
+func (s *accountingService) GetSalesJournal(year int) (*domain.Journal, error) {
+	if year < 1 {
+		return nil, domain.NewValidationError("year", "is invalid: %d", year)
+	}
+
+	startMonth := s.settings.FiscalYearStartMonth
+	if startMonth < time.January || startMonth > time.December {
+		return nil, fmt.Errorf("invalid fiscal year start month: %d", startMonth)
+	}
+
+	// A fiscal year is named after the calendar year it closes in
+	startYear := year
+	if startMonth != time.January {
+		startYear--
+	}
+	start := time.Date(startYear, startMonth, 1, 0, 0, 0, 0, s.location)
+	end := start.AddDate(1, 0, 0)
+
+	billings, err := s.billings.GetDetailsByPeriod(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &domain.Journal{
+		SIREN: s.settings.SIREN,
+		Year:  year,
+		Start: start,
+		End:   end,
+		Lines: []domain.JournalLine{},
+	}
+
+	number := 0
+	for _, details := range billings {
+		if details.Billing.Price.IsZero() {
+			continue
+		}
+		number++
+		journal.Lines = append(journal.Lines, s.entry(strconv.Itoa(number), details)...)
+	}
+
+	return journal, nil
+}
+
+// entry returns the lines of the entry posting a billing
+func (s *accountingService) entry(number string, details domain.BillingWithDetails) []domain.JournalLine {
+	billing := details.Billing
+	clientName := strings.TrimSpace(details.Client.FirstName + " " + details.Client.LastName)
+
+	label := clientName + " - " + details.Package.Name
+	if billing.Kind == domain.RefundBilling {
+		label = "Remboursement " + label
+	}
+
+	// Billings are referenced by their invoice or credit note once issued
+	pieceRef, pieceDate := billing.ID, billing.PaymentDate
+	if details.Invoice != nil {
+		pieceRef, pieceDate = details.Invoice.Number, details.Invoice.IssuedAt
+	}
+
+	entryDate := billing.PaymentDate.In(s.location)
+	base := domain.JournalLine{
+		JournalCode:  s.settings.JournalCode,
+		JournalLabel: s.settings.JournalLabel,
+		EntryNumber:  number,
+		EntryDate:    entryDate,
+		PieceRef:     pieceRef,
+		PieceDate:    pieceDate.In(s.location),
+		Label:        label,
+		ValidatedAt:  entryDate,
+	}
+
+	receivable := base
+	receivable.AccountNumber = s.settings.ReceivableAccount
+	receivable.AccountLabel = s.settings.ReceivableAccountLabel
+	receivable.AuxiliaryNumber = s.settings.ClientAccountPrefix + billing.ClientID
+	receivable.AuxiliaryLabel = clientName
+	receivable.Debit, receivable.Credit = sides(billing.Price)
+
+	sales := base
+	sales.AccountNumber = s.settings.SalesAccount
+	sales.AccountLabel = s.settings.SalesAccountLabel
+	sales.Credit, sales.Debit = sides(billing.NetPrice)
+
+	lines := []domain.JournalLine{receivable, sales}
+
+	if !billing.VAT.IsZero() {
+		vat := base
+		vat.AccountNumber = s.settings.VATAccount
+		vat.AccountLabel = s.settings.VATAccountLabel
+		vat.Credit, vat.Debit = sides(billing.VAT)
+		lines = append(lines, vat)
+	}
+
+	return lines
+}
+
+// sides splits an amount into a debit and a credit, one of them zero. A
+// negative amount is posted to the opposite side.
+func sides(amount money.Money) (debit, credit money.Money) {
+	zero := money.New(0, amount.Currency)
+	if amount.IsNegative() {
+		return zero, amount.Neg()
+	}
+	return amount, zero
+}
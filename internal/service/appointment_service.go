@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+)
+
+type appointmentService struct {
+	repo             domain.AppointmentRepository
+	scheduleRepo     domain.ScheduleRepository
+	clientRepo       domain.ClientRepository
+	cancellationRepo domain.CancellationRepository
+	policy           domain.CancellationPolicy
+	tx               domain.Transactor
+}
+
+// NewAppointmentService creates a new appointment service
+func NewAppointmentService(repo domain.AppointmentRepository, scheduleRepo domain.ScheduleRepository, clientRepo domain.ClientRepository, cancellationRepo domain.CancellationRepository, policy domain.CancellationPolicy, tx domain.Transactor) domain.AppointmentService {
+	return &appointmentService{
+		repo:             repo,
+		scheduleRepo:     scheduleRepo,
+		clientRepo:       clientRepo,
+		cancellationRepo: cancellationRepo,
+		policy:           policy,
+		tx:               tx,
+	}
+}
+
+// GetAll returns all appointments
+func (s *appointmentService) GetAll() ([]domain.Appointment, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns an appointment by ID
+func (s *appointmentService) GetByID(id string) (*domain.Appointment, error) {
+	return s.repo.GetByID(id)
+}
+
+// GetByClientID returns the appointments of a client
+func (s *appointmentService) GetByClientID(clientID string) ([]domain.Appointment, error) {
+	return s.repo.GetByClient(clientID)
+}
+
+// GetByScheduleID returns the appointments of a schedule
+func (s *appointmentService) GetByScheduleID(scheduleID string) ([]domain.Appointment, error) {
+	return s.repo.GetBySchedule(scheduleID)
+}
+
+// GetUpcomingByClient returns the upcoming appointments of a client
+func (s *appointmentService) GetUpcomingByClient(clientID string) ([]domain.Appointment, error) {
+	return s.repo.GetUpcomingByClient(clientID)
+}
+
+// GetWithDetails returns an appointment with its client and schedule details
+func (s *appointmentService) GetWithDetails(id string) (*domain.AppointmentWithDetails, error) {
+	return s.repo.GetWithDetails(id)
+}
+
+// EachWithDetails calls fn with every appointment and its client and
+// schedule details, one at a time
+func (s *appointmentService) EachWithDetails(fn func(*domain.AppointmentWithDetails) error) error {
+	return s.repo.EachWithDetails(fn)
+}
+
+// Create books a client onto a schedule and debits one credit of the
+// class type, all in one transaction
+func (s *appointmentService) Create(ctx context.Context, appointment *domain.Appointment) error {
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := reserve(uow, appointment); err != nil {
+			return err
+		}
+
+		if err := uow.Appointments().Create(appointment); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditAppointment, appointment.ID, nil, appointment)
+	})
+}
+
+// Update moves an appointment to another schedule and/or client, refunding
+// the credit of the original booking and debiting the new one
+func (s *appointmentService) Update(ctx context.Context, appointment *domain.Appointment) error {
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if appointment exists
+		existingAppointment, err := uow.Appointments().GetByID(appointment.ID)
+		if err != nil {
+			return err
+		}
+
+		if existingAppointment == nil {
+			return fmt.Errorf("appointment with ID %s: %w", appointment.ID, domain.ErrNotFound)
+		}
+
+		if existingAppointment.ScheduleID == appointment.ScheduleID && existingAppointment.ClientID == appointment.ClientID {
+			return nil
+		}
+
+		// Lock both schedules, then both clients, in ID order so that
+		// concurrent moves in opposite directions cannot deadlock
+		for _, scheduleID := range sortedIDs(existingAppointment.ScheduleID, appointment.ScheduleID) {
+			if _, _, err := lockSchedule(uow, scheduleID); err != nil {
+				return err
+			}
+		}
+
+		for _, clientID := range sortedIDs(existingAppointment.ClientID, appointment.ClientID) {
+			if _, err := uow.Clients().GetByIDForUpdate(clientID); err != nil {
+				return err
+			}
+		}
+
+		existingAppointment, err = lockAppointment(uow, existingAppointment)
+		if err != nil {
+			return err
+		}
+
+		// Release the original booking, then book again
+		if err := release(uow, existingAppointment); err != nil {
+			return err
+		}
+
+		if err := uow.Appointments().Delete(existingAppointment.ID); err != nil {
+			return err
+		}
+
+		if err := reserve(uow, appointment); err != nil {
+			return err
+		}
+
+		if err := uow.Appointments().Create(appointment); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditAppointment, appointment.ID, existingAppointment, appointment); err != nil {
+			return err
+		}
+
+		// A slot may have been freed on the original schedule
+		if existingAppointment.ScheduleID != appointment.ScheduleID {
+			return promoteWaitlist(uow, existingAppointment.ScheduleID)
+		}
+
+		return nil
+	})
+}
+
+// Delete cancels an appointment applying the cancellation policy
+func (s *appointmentService) Delete(ctx context.Context, id string) error {
+	_, err := s.Cancel(ctx, id, domain.CancellationInput{})
+	return err
+}
+
+// Cancel cancels an appointment. The credit is refunded when the client
+// cancels before the late cancellation window of the class and forfeited
+// inside it, unless staff override the decision with a reason.
+func (s *appointmentService) Cancel(ctx context.Context, id string, input domain.CancellationInput) (*domain.Cancellation, error) {
+	if input.Refund != nil && input.Reason == "" {
+		return nil, domain.ErrOverrideReasonRequired
+	}
+
+	var cancellation *domain.Cancellation
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if appointment exists
+		appointment, err := uow.Appointments().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if appointment == nil {
+			return fmt.Errorf("appointment with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		schedule, class, err := lockSchedule(uow, appointment.ScheduleID)
+		if err != nil {
+			return err
+		}
+
+		appointment, err = lockAppointment(uow, appointment)
+		if err != nil {
+			return err
+		}
+
+		window := s.policy.Window(class.Location, class.Type)
+		late := time.Now().After(schedule.ClassDatetime.Add(-window))
+
+		cancellation = &domain.Cancellation{
+			AppointmentID: appointment.ID,
+			ScheduleID:    appointment.ScheduleID,
+			ClientID:      appointment.ClientID,
+			ClassType:     class.Type,
+			Late:          late,
+			Refunded:      !late,
+		}
+
+		// Staff override
+		if input.Refund != nil {
+			cancellation.Refunded = *input.Refund
+			cancellation.OverrideReason = input.Reason
+		}
+
+		if cancellation.Refunded {
+			if err := refund(uow, appointment, class.Type, "appointment cancelled"); err != nil {
+				return err
+			}
+		}
+
+		if err := uow.Appointments().Delete(appointment.ID); err != nil {
+			return err
+		}
+
+		if err := uow.Cancellations().Create(cancellation); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditAppointment, appointment.ID, appointment, nil); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditCancellation, cancellation.ID, nil, cancellation); err != nil {
+			return err
+		}
+
+		return promoteWaitlist(uow, appointment.ScheduleID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cancellation, nil
+}
+
+// GetCancellationsByClient returns the cancellation history of a client
+func (s *appointmentService) GetCancellationsByClient(clientID string) ([]domain.Cancellation, error) {
+	return s.cancellationRepo.GetByClient(clientID)
+}
+
+// lockAppointment locks an appointment read before its schedule was locked
+// and returns it, once sure that a concurrent cancellation or move did not
+// get there first
+func lockAppointment(uow domain.UnitOfWork, appointment *domain.Appointment) (*domain.Appointment, error) {
+	locked, err := uow.Appointments().GetByIDForUpdate(appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if locked == nil {
+		return nil, fmt.Errorf("appointment with ID %s: %w", appointment.ID, domain.ErrNotFound)
+	}
+
+	if locked.ScheduleID != appointment.ScheduleID || locked.ClientID != appointment.ClientID {
+		return nil, fmt.Errorf("appointment %s was moved meanwhile: %w", appointment.ID, domain.ErrConflict)
+	}
+
+	return locked, nil
+}
+
+// reserve checks that a client can be booked onto a schedule and debits one
+// credit of the class type, unless the client's subscription covers the
+// class. The schedule row is locked first, then the client row, so concurrent
+// bookings on the same schedule are serialized. The appointment ID is
+// assigned here so the ledger entry can reference it.
+func reserve(uow domain.UnitOfWork, appointment *domain.Appointment) error {
+	clientID, scheduleID := appointment.ClientID, appointment.ScheduleID
+
+	schedule, class, err := lockSchedule(uow, scheduleID)
+	if err != nil {
+		return err
+	}
+
+	if !schedule.ClassDatetime.After(time.Now()) {
+		return fmt.Errorf("schedule %s: %w", scheduleID, domain.ErrScheduleStarted)
+	}
+
+	// Check if client is already booked
+	existingAppointment, err := uow.Appointments().GetByClientAndSchedule(clientID, scheduleID)
+	if err != nil {
+		return err
+	}
+
+	if existingAppointment != nil {
+		return fmt.Errorf("client %s on schedule %s: %w", clientID, scheduleID, domain.ErrAlreadyBooked)
+	}
+
+	// Check capacity
+	count, err := uow.Appointments().CountBySchedule(scheduleID)
+	if err != nil {
+		return err
+	}
+
+	if count >= schedule.Capacity {
+		return fmt.Errorf("schedule %s (%d/%d): %w", scheduleID, count, schedule.Capacity, domain.ErrScheduleFull)
+	}
+
+	if appointment.ID == "" {
+		appointment.ID = utils.NewUUID()
+	}
+
+	// Members book group classes without credits
+	if class.Type == domain.GroupClass {
+		subscription, err := uow.Subscriptions().GetCurrentByClient(clientID)
+		if err != nil {
+			return err
+		}
+
+		if subscription != nil && subscription.Covers(schedule.ClassDatetime) {
+			appointment.SubscriptionID = subscription.ID
+			return nil
+		}
+	}
+
+	// Debit one credit
+	return applyCredit(uow, &domain.CreditEntry{
+		ClientID:    clientID,
+		Type:        domain.CreditType(class.Type),
+		Kind:        domain.CreditDebit,
+		Delta:       -1,
+		Reason:      "appointment booked",
+		ReferenceID: appointment.ID,
+	})
+}
+
+// release refunds the credit consumed by an appointment
+func release(uow domain.UnitOfWork, appointment *domain.Appointment) error {
+	_, class, err := lockSchedule(uow, appointment.ScheduleID)
+	if err != nil {
+		return err
+	}
+
+	return refund(uow, appointment, class.Type, "appointment moved")
+}
+
+// sortedIDs returns the distinct IDs in order, the order rows are locked in
+// when a transaction locks several rows of a table
+func sortedIDs(ids ...string) []string {
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// lockSchedule locks a schedule row and returns it with its class
+func lockSchedule(uow domain.UnitOfWork, scheduleID string) (*domain.Schedule, *domain.Class, error) {
+	schedule, err := uow.Schedules().GetByIDForUpdate(scheduleID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if schedule == nil {
+		return nil, nil, fmt.Errorf("schedule with ID %s: %w", scheduleID, domain.ErrNotFound)
+	}
+
+	class, err := uow.Classes().GetByID(schedule.ClassID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if class == nil {
+		return nil, nil, fmt.Errorf("class with ID %s not found", schedule.ClassID)
+	}
+
+	return schedule, class, nil
+}
+
+// refund gives the credit consumed by an appointment back to its client, of
+// the type and in the lot it was taken from, or of the type of the class for
+// bookings made before the ledger. Bookings covered by a subscription used
+// none.
+func refund(uow domain.UnitOfWork, appointment *domain.Appointment, classType domain.ClassType, reason string) error {
+	if appointment.SubscriptionID != "" {
+		return nil
+	}
+
+	entries, err := uow.Credits().GetByReference(appointment.ID)
+	if err != nil {
+		return err
+	}
+
+	// The class of the schedule may have changed type since the booking
+	creditType, lotID := domain.CreditType(classType), ""
+	for _, entry := range entries {
+		if entry.Kind == domain.CreditDebit {
+			creditType, lotID = entry.Type, entry.LotID
+		}
+	}
+
+	return applyCredit(uow, &domain.CreditEntry{
+		ClientID:    appointment.ClientID,
+		Type:        creditType,
+		Kind:        domain.CreditRefund,
+		Delta:       1,
+		Reason:      reason,
+		ReferenceID: appointment.ID,
+		LotID:       lotID,
+	})
+}
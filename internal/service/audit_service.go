@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/matthieukhl/align-back/internal/domain"
+)
+
+const (
+	// defaultAuditLimit is how many audit entries are returned by default
+	defaultAuditLimit = 100
+	// maxAuditLimit is the most audit entries returned at once
+	maxAuditLimit = 1000
+)
+
+type auditService struct {
+	repo domain.AuditRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(repo domain.AuditRepository) domain.AuditService {
+	return &auditService{
+		repo: repo,
+	}
+}
+
+// Find returns the latest audit entries of an entity type, or of an entity
+func (s *auditService) Find(filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	if filter.EntityType == "" {
+		return nil, domain.NewValidationError("entity", "is required")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	filter.Limit = min(filter.Limit, maxAuditLimit)
+
+	return s.repo.Find(filter)
+}
+
+// record appends the change of an entity to the audit log, within the
+// transaction and on behalf of the actor of the unit of work. before is nil
+// for a creation and after is nil for a deletion. Updates changing nothing
+// are not recorded.
+func record(uow domain.UnitOfWork, entityType string, entityID string, before interface{}, after interface{}) error {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return err
+	}
+
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return err
+	}
+
+	action := domain.AuditUpdate
+	switch {
+	case beforeFields == nil:
+		action = domain.AuditCreate
+	case afterFields == nil:
+		action = domain.AuditDelete
+	}
+
+	changes := make(map[string]domain.AuditChange)
+	for field, value := range beforeFields {
+		if afterValue, ok := afterFields[field]; !ok || !bytes.Equal(value, afterValue) {
+			changes[field] = domain.AuditChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = domain.AuditChange{After: value}
+		}
+	}
+
+	if action == domain.AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+
+	ctx := uow.Context()
+	actor := domain.ActorFrom(ctx)
+
+	return uow.Audit().Create(&domain.AuditEntry{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		ActorType:  actor.Type,
+		ActorID:    actor.ID,
+		RequestID:  middleware.GetReqID(ctx),
+		Changes:    encoded,
+	})
+}
+
+// auditFields returns the JSON fields of an entity, or nil for a nil entity
+func auditFields(entity interface{}) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audited entity: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode audited entity: %w", err)
+	}
+
+	return fields, nil
+}
@@ -1,60 +1,243 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
 )
 
 type billingService struct {
-	repo domain.BillingRepository
+	repo        domain.BillingRepository
+	clientRepo  domain.ClientRepository
+	packageRepo domain.PackageRepository
+	tx          domain.Transactor
+	issuer      domain.InvoiceIssuer
+	location    *time.Location
 }
 
-// NewBillingService creates a new billing service
-func NewBillingService(repo domain.BillingRepository) domain.BillingService {
+// NewBillingService creates a new billing service. Invoices are issued by
+// issuer, with years following the calendar in location.
+func NewBillingService(repo domain.BillingRepository, clientRepo domain.ClientRepository, packageRepo domain.PackageRepository, tx domain.Transactor, issuer domain.InvoiceIssuer, location *time.Location) domain.BillingService {
 	return &billingService{
-		repo: repo,
+		repo:        repo,
+		clientRepo:  clientRepo,
+		packageRepo: packageRepo,
+		tx:          tx,
+		issuer:      issuer,
+		location:    location,
 	}
 }
 
-// Create creates a new billing.
-func (s *billingService) Create(input domain.BillingInput) error {
-	// Check if amount is negative or 0
-	if input.Amount < 1 {
-		return fmt.Errorf("Amount cannot be less than 1: %d", input.Amount)
+// Create creates a new billing, discounted by its promotion code if any,
+// grants the credits of the package to the client, records the payments
+// already received and issues the invoice.
+func (s *billingService) Create(ctx context.Context, input domain.BillingInput) error {
+	billing, pkg, err := s.newBilling(input)
+	if err != nil {
+		return err
 	}
 
-	// Check if price is negative
-	if input.Price < 0.0 {
-		return fmt.Errorf("Price cannot negative: %.2f", input.Price)
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if input.PromotionCode != "" {
+			if err := applyPromotion(uow, billing, input.PromotionCode); err != nil {
+				return err
+			}
+		}
+
+		if err := createBilling(uow, billing, pkg); err != nil {
+			return err
+		}
+
+		for _, payment := range input.Payments {
+			if _, err := recordPayment(uow, billing, payment); err != nil {
+				return err
+			}
+		}
+
+		_, err := issueInvoice(uow, s.issuer, s.location, billing)
+		return err
+	})
+}
+
+// Refund refunds part or all of a billing. The refund is recorded as a
+// negative billing, takes back the matching credits from the client, issues
+// a credit note correcting the invoice of the billing unless only credits
+// are refunded, and records the money paid back when a method is given.
+func (s *billingService) Refund(ctx context.Context, id string, input domain.RefundInput) (*domain.Billing, error) {
+	if input.Reason == "" {
+		return nil, domain.NewValidationError("reason", "is required")
 	}
 
-	// Create new billing
-	billing := &domain.Billing{
-		ClientID:    input.ClientID,
-		PackageID:   input.PackageID,
-		Amount:      input.Amount,
-		Price:       input.Price,
-		Credits:     input.Credits,
-		PaymentDate: input.PaymentDate,
+	if input.Credits != nil && *input.Credits < 0 {
+		return nil, domain.NewValidationError("credits", "cannot be negative: %d", *input.Credits)
 	}
 
-	return s.repo.Create(billing)
-}
+	var refund *domain.Billing
 
-// Delete deletes an existing service.
-func (s *billingService) Delete(id string) error {
-	// Check if billing ID exists
-	existingBilling, err := s.repo.GetByID(id)
-	if err != nil {
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if billing exists
+		billing, err := uow.Billings().GetByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if billing == nil {
+			return fmt.Errorf("billing with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		if billing.Kind == domain.RefundBilling {
+			return fmt.Errorf("billing %s is a refund and cannot be refunded: %w", id, domain.ErrConflict)
+		}
+
+		// What is left after previous refunds
+		refunds, err := uow.Billings().GetRefunds(id)
+		if err != nil {
+			return err
+		}
+
+		remainingPrice, remainingNet, remainingCredits := billing.Price, billing.NetPrice, billing.Credits
+		for _, previous := range refunds {
+			remainingPrice = remainingPrice.Add(previous.Price)
+			remainingNet = remainingNet.Add(previous.NetPrice)
+			remainingCredits += previous.Credits
+		}
+
+		price := remainingPrice
+		if input.Price != nil {
+			if input.Price.Currency != "" && input.Price.Currency != billing.Price.Currency {
+				return domain.NewValidationError("price", "is in %s for a billing in %s", input.Price.Currency, billing.Price.Currency)
+			}
+
+			if input.Price.IsNegative() {
+				return domain.NewValidationError("price", "cannot be negative")
+			}
+
+			price = money.New(input.Price.Amount, billing.Price.Currency)
+		}
+
+		if price.Amount > remainingPrice.Amount {
+			return fmt.Errorf("refund of %s with %s left: %w", price, remainingPrice, domain.ErrRefundExceedsBilling)
+		}
+
+		credits := remainingCredits
+		switch {
+		case input.Credits != nil:
+			credits = *input.Credits
+		case price.Amount < remainingPrice.Amount && billing.Price.Amount > 0:
+			credits = int(int64(billing.Credits) * price.Amount / billing.Price.Amount)
+		}
+
+		if credits > remainingCredits {
+			return fmt.Errorf("refund of %d credits with %d left: %w", credits, remainingCredits, domain.ErrRefundExceedsBilling)
+		}
+
+		if price.IsZero() && credits == 0 {
+			return fmt.Errorf("billing %s: %w", id, domain.ErrRefundExceedsBilling)
+		}
+
+		// Refunding what is left zeroes the net and VAT of the billing too
+		net, vat := price.SplitVAT(billing.VATRate)
+		if price == remainingPrice {
+			net, vat = remainingNet, remainingPrice.Sub(remainingNet)
+		}
+
+		// Billings recorded before invoicing get their invoice first
+		invoice, err := uow.Invoices().GetByBilling(id)
+		if err != nil {
+			return err
+		}
+
+		if invoice == nil {
+			invoice, err = issueInvoice(uow, s.issuer, s.location, billing)
+			if err != nil {
+				return err
+			}
+		}
+
+		refund = &domain.Billing{
+			Kind:              domain.RefundBilling,
+			ClientID:          billing.ClientID,
+			PackageID:         billing.PackageID,
+			OriginalPrice:     price.Neg(),
+			Discount:          money.New(0, price.Currency),
+			Price:             price.Neg(),
+			NetPrice:          net.Neg(),
+			VAT:               vat.Neg(),
+			VATRate:           billing.VATRate,
+			Credits:           -credits,
+			PaymentDate:       time.Now(),
+			RefundedBillingID: billing.ID,
+			Reason:            input.Reason,
+		}
+
+		if err := uow.Billings().Create(refund); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditBilling, refund.ID, nil, refund); err != nil {
+			return err
+		}
+
+		if credits > 0 {
+			creditType, err := s.creditTypeOf(billing)
+			if err != nil {
+				return err
+			}
+
+			lotID, err := lotOf(uow, billing)
+			if err != nil {
+				return err
+			}
+
+			err = applyCredit(uow, &domain.CreditEntry{
+				ClientID:    billing.ClientID,
+				Type:        creditType,
+				Kind:        domain.CreditAdjustment,
+				Delta:       -credits,
+				Reason:      fmt.Sprintf("refund: %s", input.Reason),
+				ReferenceID: refund.ID,
+				LotID:       lotID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// A refund of credits alone corrects no amount and takes no number
+		// in the credit note sequence
+		if price.IsZero() {
+			return nil
+		}
+
+		if _, err := issueCreditNote(uow, s.location, refund, invoice); err != nil {
+			return err
+		}
+
+		if input.Method == "" {
+			return nil
+		}
+
+		_, err = recordPayment(uow, refund, domain.PaymentInput{
+			Method:    input.Method,
+			Amount:    price,
+			PaidAt:    refund.PaymentDate,
+			Reference: input.Reference,
+		})
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if existingBilling == nil {
-		return fmt.Errorf("billing with id %s not found", id)
-	}
+	return s.repo.GetByID(refund.ID)
+}
 
-	return s.repo.Delete(id)
+// GetRefunds returns the refunds of a billing
+func (s *billingService) GetRefunds(id string) ([]domain.Billing, error) {
+	return s.repo.GetRefunds(id)
 }
 
 // GetAll returns all billings.
@@ -67,6 +250,11 @@ func (s *billingService) GetAllWithDetails() ([]domain.BillingWithDetails, error
 	panic("unimplemented")
 }
 
+// EachWithDetails calls fn with every billing and its details, one at a time
+func (s *billingService) EachWithDetails(fn func(*domain.BillingWithDetails) error) error {
+	return s.repo.EachWithDetails(fn)
+}
+
 // GetByClientID retuns billings by client ID.
 func (s *billingService) GetByClientID(clientID string) ([]domain.Billing, error) {
 	return s.repo.GetByClient(clientID)
@@ -87,32 +275,277 @@ func (s *billingService) GetWithDetails(id string) ([]domain.BillingWithDetails,
 	panic("unimplemented")
 }
 
-// Update updates an existing billing
-func (s *billingService) Update(id string, input domain.BillingInput) (*domain.Billing, error) {
-	// Check if billing ID exists
-	existingBilling, err := s.repo.GetByID(id)
+// Update updates an existing billing that has not been invoiced. The credits
+// granted by the billing are adjusted by the difference, or moved when the
+// client or credit type changes.
+func (s *billingService) Update(ctx context.Context, id string, input domain.BillingInput) (*domain.Billing, error) {
+	billing, pkg, err := s.newBilling(input)
+	if err != nil {
+		return nil, err
+	}
+	billing.ID = id
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if billing ID exists
+		existingBilling, err := uow.Billings().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if existingBilling == nil {
+			return fmt.Errorf("billing with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		if err := checkNotInvoiced(uow, id); err != nil {
+			return err
+		}
+
+		if input.PromotionCode != "" {
+			if err := applyPromotion(uow, billing, input.PromotionCode); err != nil {
+				return err
+			}
+		}
+
+		oldType, err := s.creditTypeOf(existingBilling)
+		if err != nil {
+			return err
+		}
+		newType := domain.CreditType(pkg.Type)
+
+		lot, err := uow.CreditLots().GetByBillingForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		lotID := ""
+		if lot != nil {
+			lotID = lot.ID
+		}
+
+		if err := uow.Billings().Update(billing); err != nil {
+			return err
+		}
+
+		updated, err := uow.Billings().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditBilling, id, existingBilling, updated); err != nil {
+			return err
+		}
+
+		// Same counter, only the difference is recorded
+		if existingBilling.ClientID == billing.ClientID && oldType == newType {
+			if lot != nil {
+				lot.ExpiresAt = expiryOf(billing, pkg)
+				if err := uow.CreditLots().Update(lot); err != nil {
+					return err
+				}
+			}
+
+			delta := billing.Credits - existingBilling.Credits
+			if delta == 0 {
+				return nil
+			}
+
+			return applyCredit(uow, &domain.CreditEntry{
+				ClientID:    billing.ClientID,
+				Type:        newType,
+				Kind:        domain.CreditAdjustment,
+				Delta:       delta,
+				Reason:      "billing updated",
+				ReferenceID: billing.ID,
+				LotID:       lotID,
+			})
+		}
+
+		err = applyCredit(uow, &domain.CreditEntry{
+			ClientID:    existingBilling.ClientID,
+			Type:        oldType,
+			Kind:        domain.CreditAdjustment,
+			Delta:       -existingBilling.Credits,
+			Reason:      "billing updated",
+			ReferenceID: billing.ID,
+			LotID:       lotID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// The emptied lot moves to the new client and credit type
+		if lot != nil {
+			lot.ClientID = billing.ClientID
+			lot.Type = newType
+			lot.Credits = 0
+			lot.Remaining = 0
+			lot.ExpiresAt = expiryOf(billing, pkg)
+			if err := uow.CreditLots().Update(lot); err != nil {
+				return err
+			}
+		}
+
+		return grant(uow, billing, pkg, lotID)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if existingBilling == nil {
-		return nil, fmt.Errorf("billing with ID %s not found", id)
+	return s.repo.GetByID(id)
+}
+
+// newBilling validates an input and builds the billing it describes along
+// with its package
+func (s *billingService) newBilling(input domain.BillingInput) (*domain.Billing, *domain.Package, error) {
+	// Check if amount is negative or 0
+	if input.Amount < 1 {
+		return nil, nil, domain.NewValidationError("amount", "cannot be less than 1: %d", input.Amount)
+	}
+
+	// Check if price is negative
+	if input.Price.IsNegative() {
+		return nil, nil, domain.NewValidationError("price", "cannot be negative: %s", input.Price)
+	}
+
+	// Check if client exists
+	client, err := s.clientRepo.GetByID(input.ClientID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Create new billing
+	if client == nil {
+		return nil, nil, fmt.Errorf("client with ID %s: %w", input.ClientID, domain.ErrNotFound)
+	}
+
+	// Check if package exists
+	pkg, err := s.packageRepo.GetByID(input.PackageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pkg == nil {
+		return nil, nil, fmt.Errorf("package with ID %s: %w", input.PackageID, domain.ErrNotFound)
+	}
+
+	if pkg.Type == domain.MembershipPackage {
+		return nil, nil, domain.NewValidationError("package_id", "is the membership plan %s, billed by its subscriptions", pkg.Name)
+	}
+
+	// Prices are in the currency of the package
+	if input.Price.Currency != "" && input.Price.Currency != pkg.Price.Currency {
+		return nil, nil, domain.NewValidationError("price", "is in %s for a package in %s", input.Price.Currency, pkg.Price.Currency)
+	}
+
+	price := money.New(input.Price.Amount, pkg.Price.Currency)
+	net, vat := price.SplitVAT(pkg.VATRate)
+
 	billing := &domain.Billing{
-		ClientID:    input.ClientID,
-		PackageID:   input.PackageID,
-		Amount:      input.Amount,
-		Price:       input.Price,
-		Credits:     input.Credits,
-		PaymentDate: input.PaymentDate,
+		Kind:          domain.PaymentBilling,
+		ClientID:      input.ClientID,
+		PackageID:     input.PackageID,
+		Amount:        input.Amount,
+		OriginalPrice: price,
+		Discount:      money.New(0, price.Currency),
+		Price:         price,
+		NetPrice:      net,
+		VAT:           vat,
+		VATRate:       pkg.VATRate,
+		Credits:       pkg.NumberOfSessions * input.Amount,
+		PaymentDate:   input.PaymentDate,
 	}
 
-	err = s.repo.Update(billing)
+	return billing, pkg, nil
+}
+
+// creditTypeOf returns the type of the credits granted by a billing
+func (s *billingService) creditTypeOf(billing *domain.Billing) (domain.CreditType, error) {
+	pkg, err := s.packageRepo.GetByID(billing.PackageID)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return s.repo.GetByID(id)
+	if pkg == nil {
+		return "", fmt.Errorf("package with ID %s not found", billing.PackageID)
+	}
+
+	return domain.CreditType(pkg.Type), nil
+}
+
+// createBilling creates a billing and grants the credits of its package to
+// the client, in a lot of their own
+func createBilling(uow domain.UnitOfWork, billing *domain.Billing, pkg *domain.Package) error {
+	if err := uow.Billings().Create(billing); err != nil {
+		return err
+	}
+
+	if err := record(uow, domain.AuditBilling, billing.ID, nil, billing); err != nil {
+		return err
+	}
+
+	lot := &domain.CreditLot{
+		ClientID:  billing.ClientID,
+		BillingID: billing.ID,
+		Type:      domain.CreditType(pkg.Type),
+		ExpiresAt: expiryOf(billing, pkg),
+	}
+
+	if err := uow.CreditLots().Create(lot); err != nil {
+		return err
+	}
+
+	return grant(uow, billing, pkg, lot.ID)
+}
+
+// grant credits the client of a billing with the credits of its package
+func grant(uow domain.UnitOfWork, billing *domain.Billing, pkg *domain.Package, lotID string) error {
+	return applyCredit(uow, &domain.CreditEntry{
+		ClientID:    billing.ClientID,
+		Type:        domain.CreditType(pkg.Type),
+		Kind:        domain.CreditGrant,
+		Delta:       billing.Credits,
+		Reason:      fmt.Sprintf("package purchased: %s", pkg.Name),
+		ReferenceID: billing.ID,
+		LotID:       lotID,
+	})
+}
+
+// lotOf returns the ID of the credit lot granted by a billing, or an empty
+// string for billings predating lots
+func lotOf(uow domain.UnitOfWork, billing *domain.Billing) (string, error) {
+	lot, err := uow.CreditLots().GetByBillingForUpdate(billing.ID)
+	if err != nil || lot == nil {
+		return "", err
+	}
+	return lot.ID, nil
+}
+
+// expiryOf returns when the credits of a billing expire, counted from the
+// payment date, or nil when the package never expires
+func expiryOf(billing *domain.Billing, pkg *domain.Package) *time.Time {
+	if pkg.ValidityDays == 0 {
+		return nil
+	}
+
+	paidAt := billing.PaymentDate
+	if paidAt.IsZero() {
+		paidAt = time.Now()
+	}
+
+	expiresAt := paidAt.AddDate(0, 0, pkg.ValidityDays)
+	return &expiresAt
+}
+
+// checkNotInvoiced fails with domain.ErrInvoiceIssued if a billing has an
+// invoice, which can no longer change
+func checkNotInvoiced(uow domain.UnitOfWork, billingID string) error {
+	invoice, err := uow.Invoices().GetByBilling(billingID)
+	if err != nil {
+		return err
+	}
+
+	if invoice != nil {
+		return fmt.Errorf("billing %s, invoice %s: %w", billingID, invoice.Number, domain.ErrInvoiceIssued)
+	}
+
+	return nil
 }
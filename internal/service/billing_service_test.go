@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+// fakeTx runs units of work against in-memory repositories. Repositories
+// embed their interface, so a test only implements what it calls.
+type fakeTx struct {
+	billings   *fakeBillings
+	invoices   *fakeInvoices
+	audit      *fakeAudit
+	clients    *fakeClients
+	credits    *fakeCredits
+	lots       *fakeCreditLots
+	packages   *fakePackages
+	promotions *fakePromotions
+}
+
+func (tx *fakeTx) WithinTransaction(ctx context.Context, fn func(uow domain.UnitOfWork) error) error {
+	return fn(&fakeUnitOfWork{tx: tx, ctx: ctx})
+}
+
+type fakeUnitOfWork struct {
+	domain.UnitOfWork
+	tx  *fakeTx
+	ctx context.Context
+}
+
+func (u *fakeUnitOfWork) Context() context.Context               { return u.ctx }
+func (u *fakeUnitOfWork) Billings() domain.BillingRepository     { return u.tx.billings }
+func (u *fakeUnitOfWork) Invoices() domain.InvoiceRepository     { return u.tx.invoices }
+func (u *fakeUnitOfWork) Audit() domain.AuditRepository          { return u.tx.audit }
+func (u *fakeUnitOfWork) Clients() domain.ClientRepository       { return u.tx.clients }
+func (u *fakeUnitOfWork) Credits() domain.CreditLedgerRepository { return u.tx.credits }
+func (u *fakeUnitOfWork) CreditLots() domain.CreditLotRepository { return u.tx.lots }
+func (u *fakeUnitOfWork) Packages() domain.PackageRepository     { return u.tx.packages }
+func (u *fakeUnitOfWork) Promotions() domain.PromotionRepository { return u.tx.promotions }
+
+type fakeBillings struct {
+	domain.BillingRepository
+	billings map[string]*domain.Billing
+}
+
+func (r *fakeBillings) GetByID(id string) (*domain.Billing, error) {
+	return r.billings[id], nil
+}
+
+func (r *fakeBillings) GetByIDForUpdate(id string) (*domain.Billing, error) {
+	return r.billings[id], nil
+}
+
+func (r *fakeBillings) GetRefunds(billingID string) ([]domain.Billing, error) {
+	var refunds []domain.Billing
+	for _, billing := range r.billings {
+		if billing.RefundedBillingID == billingID {
+			refunds = append(refunds, *billing)
+		}
+	}
+	return refunds, nil
+}
+
+func (r *fakeBillings) Create(billing *domain.Billing) error {
+	billing.ID = "refund"
+	r.billings[billing.ID] = billing
+	return nil
+}
+
+type fakeInvoices struct {
+	domain.InvoiceRepository
+	invoices []*domain.Invoice
+}
+
+func (r *fakeInvoices) GetByBilling(billingID string) (*domain.Invoice, error) {
+	for _, invoice := range r.invoices {
+		if invoice.BillingID == billingID {
+			return invoice, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeInvoices) NextSequence(series string, year int) (int, error) {
+	return len(r.invoices) + 1, nil
+}
+
+func (r *fakeInvoices) Create(invoice *domain.Invoice) error {
+	r.invoices = append(r.invoices, invoice)
+	return nil
+}
+
+type fakeAudit struct {
+	domain.AuditRepository
+}
+
+func (r *fakeAudit) Create(entry *domain.AuditEntry) error {
+	return nil
+}
+
+func TestRefundWithoutPrice(t *testing.T) {
+	billings := &fakeBillings{billings: map[string]*domain.Billing{
+		"billing": {
+			ID:            "billing",
+			Kind:          domain.PaymentBilling,
+			ClientID:      "client",
+			OriginalPrice: money.New(15000, money.EUR),
+			Discount:      money.New(0, money.EUR),
+			Price:         money.New(15000, money.EUR),
+			NetPrice:      money.New(12500, money.EUR),
+			VAT:           money.New(2500, money.EUR),
+			VATRate:       2000,
+		},
+	}}
+	invoices := &fakeInvoices{invoices: []*domain.Invoice{
+		{ID: "invoice", BillingID: "billing", Number: "F-2025-000001"},
+	}}
+	tx := &fakeTx{billings: billings, invoices: invoices, audit: &fakeAudit{}}
+	service := NewBillingService(billings, nil, nil, tx, domain.InvoiceIssuer{}, time.UTC)
+
+	refund, err := service.Refund(context.Background(), "billing", domain.RefundInput{Reason: "cancelled"})
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+
+	if refund.Price != money.New(-15000, money.EUR) || refund.NetPrice != money.New(-12500, money.EUR) || refund.VAT != money.New(-2500, money.EUR) {
+		t.Errorf("Refund() = %v (net %v, VAT %v), want the whole billing back", refund.Price, refund.NetPrice, refund.VAT)
+	}
+
+	if len(invoices.invoices) != 2 || invoices.invoices[1].CorrectedInvoiceID != "invoice" {
+		t.Errorf("Refund() did not issue a credit note correcting the invoice")
+	}
+}
@@ -0,0 +1,439 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type checkoutService struct {
+	repo     domain.CheckoutRepository
+	billings domain.BillingService
+	provider domain.PaymentProvider
+	tx       domain.Transactor
+	issuer   domain.InvoiceIssuer
+	location *time.Location
+	ttl      time.Duration
+}
+
+// NewCheckoutService creates a new checkout service taking payments with
+// provider, each checkout being payable for ttl. Refunds are recorded
+// through billings. Invoices of the packages paid online are issued by
+// issuer, with years following the calendar in location.
+func NewCheckoutService(repo domain.CheckoutRepository, billings domain.BillingService, provider domain.PaymentProvider, tx domain.Transactor, issuer domain.InvoiceIssuer, location *time.Location,
+	ttl time.Duration) domain.CheckoutService {
+	return &checkoutService{
+		repo:     repo,
+		billings: billings,
+		provider: provider,
+		tx:       tx,
+		issuer:   issuer,
+		location: location,
+		ttl:      ttl,
+	}
+}
+
+// GetAll returns all checkouts
+func (s *checkoutService) GetAll() ([]domain.Checkout, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns a checkout by ID
+func (s *checkoutService) GetByID(id string) (*domain.Checkout, error) {
+	return s.repo.GetByID(id)
+}
+
+// Create opens a checkout with the provider for a client to buy packages
+// online, at the price of the package discounted by the promotion code if
+// any. The price is kept when the payment is confirmed, even if the
+// promotion has run out in the meantime. The checkout holds a use of the
+// promotion until it expires.
+func (s *checkoutService) Create(ctx context.Context, input domain.CheckoutInput) (*domain.Checkout, error) {
+	amount := input.Amount
+	if amount == 0 {
+		amount = 1
+	}
+
+	if amount < 0 {
+		return nil, domain.NewValidationError("amount", "cannot be less than 1: %d", amount)
+	}
+
+	checkout := &domain.Checkout{
+		ID:        utils.NewUUID(),
+		ClientID:  input.ClientID,
+		PackageID: input.PackageID,
+		Amount:    amount,
+		Status:    domain.PendingCheckout,
+		Provider:  s.provider.Name(),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	var request domain.CheckoutRequest
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		client, err := uow.Clients().GetByID(input.ClientID)
+		if err != nil {
+			return err
+		}
+
+		if client == nil {
+			return fmt.Errorf("client with ID %s: %w", input.ClientID, domain.ErrNotFound)
+		}
+
+		pkg, err := uow.Packages().GetByID(input.PackageID)
+		if err != nil {
+			return err
+		}
+
+		if pkg == nil {
+			return fmt.Errorf("package with ID %s: %w", input.PackageID, domain.ErrNotFound)
+		}
+
+		if pkg.Type == domain.MembershipPackage {
+			return domain.NewValidationError("package_id", "is the membership plan %s, billed by its subscriptions", pkg.Name)
+		}
+
+		price := pkg.Price.Mul(int64(amount))
+
+		// The billing the checkout will create, to price the promotion
+		billing := &domain.Billing{
+			Kind:          domain.PaymentBilling,
+			ClientID:      input.ClientID,
+			PackageID:     pkg.ID,
+			Amount:        amount,
+			OriginalPrice: price,
+			Discount:      money.New(0, price.Currency),
+			Price:         price,
+			VATRate:       pkg.VATRate,
+		}
+
+		if input.PromotionCode != "" {
+			if err := applyPromotion(uow, billing, input.PromotionCode); err != nil {
+				return err
+			}
+		}
+
+		if billing.Price.IsZero() {
+			return fmt.Errorf("nothing to pay online for package %s: %w", pkg.Name, domain.ErrConflict)
+		}
+
+		checkout.OriginalPrice = billing.OriginalPrice
+		checkout.Discount = billing.Discount
+		checkout.Price = billing.Price
+		checkout.Refunded = money.New(0, billing.Price.Currency)
+		checkout.PromotionID = billing.PromotionID
+
+		request = domain.CheckoutRequest{
+			Reference:   checkout.ID,
+			Description: fmt.Sprintf("%d x %s", amount, pkg.Name),
+			Price:       checkout.Price,
+			Email:       client.Email,
+			SuccessURL:  input.SuccessURL,
+			CancelURL:   input.CancelURL,
+			ExpiresAt:   checkout.ExpiresAt,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	opened, err := s.provider.CreateCheckout(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkout with %s: %w", s.provider.Name(), err)
+	}
+
+	checkout.ProviderCheckoutID = opened.ID
+	checkout.URL = opened.URL
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Another checkout may have taken the last use of the promotion
+		// while the provider opened this one
+		if checkout.PromotionID != "" {
+			promotion, err := uow.Promotions().GetByCodeForUpdate(normalizeCode(input.PromotionCode))
+			if err != nil {
+				return err
+			}
+
+			if promotion == nil || promotion.ID != checkout.PromotionID {
+				return fmt.Errorf("code %s: %w", input.PromotionCode, domain.ErrUnknownPromotion)
+			}
+
+			if err := checkPromotionUses(uow, promotion, checkout.ClientID, ""); err != nil {
+				return err
+			}
+		}
+
+		if err := uow.Checkouts().Create(checkout); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditCheckout, checkout.ID, nil, checkout)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(checkout.ID)
+}
+
+// HandleEvent handles a webhook event of the provider once its signature is
+// checked. Each event is handled once: deliveries of an event already
+// handled are ignored, as are events of no interest.
+func (s *checkoutService) HandleEvent(ctx context.Context, payload []byte, signature string) error {
+	event, err := s.provider.ParseEvent(payload, signature)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		recorded, err := uow.Checkouts().RecordEvent(s.provider.Name(), event)
+		if err != nil {
+			return err
+		}
+
+		if !recorded {
+			log.Info().Str("eventID", event.ID).Msg("payment event already handled")
+			return nil
+		}
+
+		switch event.Type {
+		case domain.PaymentSucceededEvent:
+			return s.settle(uow, event.CheckoutID, domain.PaidCheckout, event.PaymentID)
+		case domain.PaymentFailedEvent:
+			return s.settle(uow, event.CheckoutID, domain.FailedCheckout, "")
+		}
+
+		return nil
+	})
+}
+
+// Sync fetches the status of a pending checkout from the provider and
+// settles it, for events that never reached the webhook
+func (s *checkoutService) Sync(ctx context.Context, id string) (*domain.Checkout, error) {
+	checkout, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if checkout == nil {
+		return nil, fmt.Errorf("checkout with ID %s: %w", id, domain.ErrNotFound)
+	}
+
+	if checkout.Status != domain.PendingCheckout {
+		return checkout, nil
+	}
+
+	status, err := s.provider.GetCheckout(checkout.ProviderCheckoutID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkout from %s: %w", s.provider.Name(), err)
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		return s.settle(uow, checkout.ProviderCheckoutID, status.Status, status.PaymentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(id)
+}
+
+// Refund pays back part or all of a paid checkout with the provider, then
+// refunds its billing. Without a price, what is left is refunded. The
+// refund is reserved on the locked checkout before calling the provider, so
+// concurrent refunds cannot pay back more than was paid.
+func (s *checkoutService) Refund(ctx context.Context, id string, input domain.RefundInput) (*domain.Billing, error) {
+	if input.Reason == "" {
+		return nil, domain.NewValidationError("reason", "is required")
+	}
+
+	var checkout *domain.Checkout
+	var price money.Money
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		var err error
+		checkout, err = uow.Checkouts().GetByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if checkout == nil {
+			return fmt.Errorf("checkout with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		if checkout.Status != domain.PaidCheckout {
+			return fmt.Errorf("checkout %s is %s: %w", id, checkout.Status, domain.ErrCheckoutStatus)
+		}
+
+		left := checkout.Price.Sub(checkout.Refunded)
+		price = left
+		if input.Price != nil {
+			if input.Price.Currency != "" && input.Price.Currency != checkout.Price.Currency {
+				return domain.NewValidationError("price", "is in %s for a checkout in %s", input.Price.Currency, checkout.Price.Currency)
+			}
+
+			// Credits alone are refunded on the billing, there is nothing to pay back
+			if input.Price.IsZero() || input.Price.IsNegative() {
+				return domain.NewValidationError("price", "must be positive")
+			}
+
+			price = money.New(input.Price.Amount, checkout.Price.Currency)
+		}
+
+		if price.IsNegative() || price.Amount > left.Amount {
+			return fmt.Errorf("refund of %s with %s left: %w", price, left, domain.ErrRefundExceedsBilling)
+		}
+
+		return s.addRefunded(uow, checkout, price)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.provider.Refund(checkout.ProviderPaymentID, price); err != nil {
+		// Release the reservation
+		err = fmt.Errorf("failed to refund payment with %s: %w", s.provider.Name(), err)
+		releaseErr := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+			checkout, err := uow.Checkouts().GetByIDForUpdate(id)
+			if err != nil {
+				return err
+			}
+			return s.addRefunded(uow, checkout, price.Neg())
+		})
+		if releaseErr != nil {
+			log.Error().Err(releaseErr).Str("checkoutID", id).Str("refund", price.String()).Msg("refund failed with the provider but still reserved on the checkout")
+		}
+		return nil, err
+	}
+
+	// The money is paid back: from here on failures need a manual fix
+	refund, err := s.billings.Refund(ctx, checkout.BillingID, domain.RefundInput{
+		Price:     &price,
+		Credits:   input.Credits,
+		Reason:    input.Reason,
+		Method:    domain.CardPayment,
+		Reference: checkout.ProviderPaymentID,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("checkoutID", id).Str("refund", price.String()).Msg("payment refunded by the provider but not recorded")
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// addRefunded adds an amount to what was refunded of a locked checkout,
+// which is refunded once nothing is left
+func (s *checkoutService) addRefunded(uow domain.UnitOfWork, checkout *domain.Checkout, amount money.Money) error {
+	before := *checkout
+
+	checkout.Refunded = checkout.Refunded.Add(amount)
+	checkout.Status = domain.PaidCheckout
+	if checkout.Refunded == checkout.Price {
+		checkout.Status = domain.RefundedCheckout
+	}
+
+	if err := uow.Checkouts().Update(checkout); err != nil {
+		return err
+	}
+
+	return record(uow, domain.AuditCheckout, checkout.ID, &before, checkout)
+}
+
+// settle moves a pending checkout to the status reported by the provider.
+// A paid checkout gets its billing, credits, card payment and invoice;
+// checkouts already settled are left as they are.
+func (s *checkoutService) settle(uow domain.UnitOfWork, providerCheckoutID string, status domain.CheckoutStatus, paymentID string) error {
+	checkout, err := uow.Checkouts().GetByProviderIDForUpdate(s.provider.Name(), providerCheckoutID)
+	if err != nil {
+		return err
+	}
+
+	// Possibly notified before the checkout was stored, the provider
+	// delivers the event again
+	if checkout == nil {
+		return fmt.Errorf("checkout %s of %s not found", providerCheckoutID, s.provider.Name())
+	}
+
+	if checkout.Status != domain.PendingCheckout {
+		return nil
+	}
+	before := *checkout
+
+	switch status {
+	case domain.PaidCheckout:
+		if err := s.confirm(uow, checkout, paymentID); err != nil {
+			return err
+		}
+	case domain.FailedCheckout:
+		checkout.Status = domain.FailedCheckout
+		if err := uow.Checkouts().Update(checkout); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	return record(uow, domain.AuditCheckout, checkout.ID, &before, checkout)
+}
+
+// confirm bills a checkout paid with the provider
+func (s *checkoutService) confirm(uow domain.UnitOfWork, checkout *domain.Checkout, paymentID string) error {
+	pkg, err := uow.Packages().GetByID(checkout.PackageID)
+	if err != nil {
+		return err
+	}
+
+	if pkg == nil {
+		return fmt.Errorf("package with ID %s not found", checkout.PackageID)
+	}
+
+	now := time.Now()
+	net, vat := checkout.Price.SplitVAT(pkg.VATRate)
+
+	billing := &domain.Billing{
+		Kind:          domain.PaymentBilling,
+		ClientID:      checkout.ClientID,
+		PackageID:     checkout.PackageID,
+		Amount:        checkout.Amount,
+		OriginalPrice: checkout.OriginalPrice,
+		Discount:      checkout.Discount,
+		Price:         checkout.Price,
+		NetPrice:      net,
+		VAT:           vat,
+		VATRate:       pkg.VATRate,
+		Credits:       pkg.NumberOfSessions * checkout.Amount,
+		PaymentDate:   now,
+		PromotionID:   checkout.PromotionID,
+	}
+
+	if err := createBilling(uow, billing, pkg); err != nil {
+		return err
+	}
+
+	_, err = recordPayment(uow, billing, domain.PaymentInput{
+		Method:    domain.CardPayment,
+		Amount:    checkout.Price,
+		PaidAt:    now,
+		Reference: paymentID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := issueInvoice(uow, s.issuer, s.location, billing); err != nil {
+		return err
+	}
+
+	checkout.Status = domain.PaidCheckout
+	checkout.ProviderPaymentID = paymentID
+	checkout.BillingID = billing.ID
+	return uow.Checkouts().Update(checkout)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/matthieukhl/align-back/internal/domain"
@@ -8,12 +9,14 @@ import (
 
 type classService struct {
 	repo domain.ClassRepository
+	tx   domain.Transactor
 }
 
 // NewClassService creates a new class service
-func NewClassService(repo domain.ClassRepository) domain.ClassService {
+func NewClassService(repo domain.ClassRepository, tx domain.Transactor) domain.ClassService {
 	return &classService{
 		repo: repo,
+		tx:   tx,
 	}
 }
 
@@ -38,7 +41,7 @@ func (s *classService) GetByType(classType domain.ClassType) ([]domain.Class, er
 }
 
 // Create creates a class
-func (s *classService) Create(input domain.ClassInput) error {
+func (s *classService) Create(ctx context.Context, input domain.ClassInput) error {
 	// Check if class name is already used
 	existingClass, err := s.repo.GetByName(input.Name)
 	if err != nil {
@@ -46,7 +49,7 @@ func (s *classService) Create(input domain.ClassInput) error {
 	}
 
 	if existingClass != nil {
-		return fmt.Errorf("class name %s is already in use", input.Name)
+		return fmt.Errorf("class name %s: %w", input.Name, domain.ErrClassNameExists)
 	}
 
 	// Create a new class
@@ -57,16 +60,17 @@ func (s *classService) Create(input domain.ClassInput) error {
 		Equipment: input.Equipment,
 	}
 
-	err = s.repo.Create(class)
-	if err != nil {
-		return err
-	}
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Classes().Create(class); err != nil {
+			return err
+		}
 
-	return nil
+		return record(uow, domain.AuditClass, class.ID, nil, class)
+	})
 }
 
 // Updates update an existing class
-func (s *classService) Update(id string, input domain.ClassInput) (*domain.Class, error) {
+func (s *classService) Update(ctx context.Context, id string, input domain.ClassInput) (*domain.Class, error) {
 	// Check if class ID exists
 	existingClass, err := s.repo.GetByID(id)
 	if err != nil {
@@ -74,7 +78,7 @@ func (s *classService) Update(id string, input domain.ClassInput) (*domain.Class
 	}
 
 	if existingClass == nil {
-		return nil, err
+		return nil, fmt.Errorf("class with ID %s: %w", id, domain.ErrNotFound)
 	}
 
 	// Check if name is already in use by another client
@@ -85,19 +89,31 @@ func (s *classService) Update(id string, input domain.ClassInput) (*domain.Class
 		}
 
 		if classWithName != nil && classWithName.ID != id {
-			return nil, fmt.Errorf("class name %s is already in use", input.Name)
+			return nil, fmt.Errorf("class name %s: %w", input.Name, domain.ErrClassNameExists)
 		}
 	}
 
 	// Update client
 	class := &domain.Class{
+		ID:        id,
 		Name:      input.Name,
 		Location:  input.Location,
 		Type:      input.Type,
 		Equipment: input.Equipment,
 	}
 
-	err = s.repo.Update(class)
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Classes().Update(class); err != nil {
+			return err
+		}
+
+		updated, err := uow.Classes().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditClass, id, existingClass, updated)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +124,7 @@ func (s *classService) Update(id string, input domain.ClassInput) (*domain.Class
 }
 
 // Delete deletes a class
-func (s *classService) Delete(id string) error {
+func (s *classService) Delete(ctx context.Context, id string) error {
 	// Check if class exists
 	existingClass, err := s.repo.GetByID(id)
 	if err != nil {
@@ -116,8 +132,14 @@ func (s *classService) Delete(id string) error {
 	}
 
 	if existingClass == nil {
-		return fmt.Errorf("class with ID %s not found", id)
+		return fmt.Errorf("class with ID %s: %w", id, domain.ErrNotFound)
 	}
 
-	return s.repo.Delete(id)
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Classes().Delete(id); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditClass, id, existingClass, nil)
+	})
 }
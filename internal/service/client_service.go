@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/matthieukhl/align-back/internal/domain"
@@ -8,12 +9,14 @@ import (
 
 type clientService struct {
 	repo domain.ClientRepository
+	tx   domain.Transactor
 }
 
 // NewClientService creates a new client service
-func NewClientService(repo domain.ClientRepository) domain.ClientService {
+func NewClientService(repo domain.ClientRepository, tx domain.Transactor) domain.ClientService {
 	return &clientService{
 		repo: repo,
+		tx:   tx,
 	}
 }
 
@@ -22,13 +25,19 @@ func (s *clientService) GetAll() ([]domain.Client, error) {
 	return s.repo.GetAll()
 }
 
+// Each calls fn with every client, one at a time
+func (s *clientService) Each(fn func(*domain.Client) error) error {
+	return s.repo.Each(fn)
+}
+
 // GetByID returns a client by ID
 func (s *clientService) GetByID(id string) (*domain.Client, error) {
 	return s.repo.GetByID(id)
 }
 
-// Create creates a new client
-func (s *clientService) Create(input domain.ClientInput) error {
+// Create creates a new client. Initial credits are recorded in the ledger as
+// opening adjustments.
+func (s *clientService) Create(ctx context.Context, input domain.ClientInput) error {
 	// Check if email is already used
 	existingClient, err := s.repo.GetByEmail(input.Email)
 	if err != nil {
@@ -36,34 +45,42 @@ func (s *clientService) Create(input domain.ClientInput) error {
 	}
 
 	if existingClient != nil {
-		return fmt.Errorf("email %s is already in use", input.Email)
+		return fmt.Errorf("email %s: %w", input.Email, domain.ErrClientEmailExists)
 	}
 
 	// Create a new client
 	client := &domain.Client{
-		FirstName:      input.FirstName,
-		LastName:       input.LastName,
-		Phone:          input.Phone,
-		Email:          input.Email,
-		StreetNumber:   input.StreetNumber,
-		StreetName:     input.StreetName,
-		City:           input.City,
-		ZipCode:        input.ZipCode,
-		Country:        input.Country,
-		GroupCredits:   input.GroupCredits,
-		PrivateCredits: input.PrivateCredits,
-	}
+		FirstName:    input.FirstName,
+		LastName:     input.LastName,
+		Phone:        input.Phone,
+		Email:        input.Email,
+		StreetNumber: input.StreetNumber,
+		StreetName:   input.StreetName,
+		City:         input.City,
+		ZipCode:      input.ZipCode,
+		Country:      input.Country,
+	}
+
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Clients().Create(client); err != nil {
+			return err
+		}
 
-	err = s.repo.Create(client)
-	if err != nil {
-		return err
-	}
+		if err := record(uow, domain.AuditClient, client.ID, nil, client); err != nil {
+			return err
+		}
+
+		if err := setCredits(uow, client.ID, domain.GroupCredit, input.GroupCredits, "opening balance"); err != nil {
+			return err
+		}
 
-	return nil
+		return setCredits(uow, client.ID, domain.PrivateCredit, input.PrivateCredits, "opening balance")
+	})
 }
 
-// Update updates a client
-func (s *clientService) Update(id string, input domain.ClientInput) (*domain.Client, error) {
+// Update updates a client. Credits only change through the ledger and are
+// left untouched.
+func (s *clientService) Update(ctx context.Context, id string, input domain.ClientInput) (*domain.Client, error) {
 	// Check if client exists
 	existingClient, err := s.repo.GetByID(id)
 	if err != nil {
@@ -71,7 +88,7 @@ func (s *clientService) Update(id string, input domain.ClientInput) (*domain.Cli
 	}
 
 	if existingClient == nil {
-		return nil, nil
+		return nil, fmt.Errorf("client with ID %s: %w", id, domain.ErrNotFound)
 	}
 
 	// Check if email is already in use by another client
@@ -82,26 +99,36 @@ func (s *clientService) Update(id string, input domain.ClientInput) (*domain.Cli
 		}
 
 		if clientWithEmail != nil && clientWithEmail.ID != id {
-			return nil, fmt.Errorf("email %s is already in use", input.Email)
+			return nil, fmt.Errorf("email %s: %w", input.Email, domain.ErrClientEmailExists)
 		}
 	}
 
 	// Update client
 	client := &domain.Client{
-		FirstName:      input.FirstName,
-		LastName:       input.LastName,
-		Phone:          input.Phone,
-		Email:          input.Email,
-		StreetNumber:   input.StreetNumber,
-		StreetName:     input.StreetName,
-		City:           input.City,
-		ZipCode:        input.ZipCode,
-		Country:        input.Country,
-		GroupCredits:   input.GroupCredits,
-		PrivateCredits: input.PrivateCredits,
-	}
+		ID:           id,
+		FirstName:    input.FirstName,
+		LastName:     input.LastName,
+		Phone:        input.Phone,
+		Email:        input.Email,
+		StreetNumber: input.StreetNumber,
+		StreetName:   input.StreetName,
+		City:         input.City,
+		ZipCode:      input.ZipCode,
+		Country:      input.Country,
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Clients().Update(client); err != nil {
+			return err
+		}
+
+		updated, err := uow.Clients().GetByID(id)
+		if err != nil {
+			return err
+		}
 
-	err = s.repo.Update(client)
+		return record(uow, domain.AuditClient, id, existingClient, updated)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -110,8 +137,9 @@ func (s *clientService) Update(id string, input domain.ClientInput) (*domain.Cli
 	return s.repo.GetByID(id)
 }
 
-// Delete deletes a client
-func (s *clientService) Delete(id string) error {
+// Delete deletes a client who was never billed, as billings are never
+// deleted
+func (s *clientService) Delete(ctx context.Context, id string) error {
 	// Check if client exists
 	existingClient, err := s.repo.GetByID(id)
 	if err != nil {
@@ -119,10 +147,25 @@ func (s *clientService) Delete(id string) error {
 	}
 
 	if existingClient == nil {
-		return fmt.Errorf("client with ID %s not found", id)
+		return fmt.Errorf("client with ID %s: %w", id, domain.ErrNotFound)
 	}
 
-	return s.repo.Delete(id)
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		count, err := uow.Billings().CountByClient(id)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return fmt.Errorf("client %s (%d billings): %w", id, count, domain.ErrClientBilled)
+		}
+
+		if err := uow.Clients().Delete(id); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditClient, id, existingClient, nil)
+	})
 }
 
 // GetByEmail returns a client by email
@@ -140,38 +183,49 @@ func (s *clientService) GetLowPrivateCredits(threshold int) ([]domain.Client, er
 	return s.repo.GetLowPrivateCredits(threshold)
 }
 
-// UpdateCredits updates a client's group credits
-func (s *clientService) UpdateGroupCredits(id string, credits int) error {
-	// Check if client exists
-	existingClient, err := s.repo.GetByID(id)
-	if err != nil {
-		return err
-	}
-
-	if existingClient == nil {
-		return fmt.Errorf("client with id %s not found", id)
-	}
-
-	// Update group credits
-	existingClient.GroupCredits = credits
+// UpdateGroupCredits sets a client's group credits, recording the difference
+// in the ledger as an adjustment
+func (s *clientService) UpdateGroupCredits(ctx context.Context, id string, credits int) error {
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		return setCredits(uow, id, domain.GroupCredit, credits, fmt.Sprintf("balance set to %d", credits))
+	})
+}
 
-	return s.repo.Update(existingClient)
+// UpdatePrivateCredits sets a client's private credits, recording the
+// difference in the ledger as an adjustment
+func (s *clientService) UpdatePrivateCredits(ctx context.Context, id string, credits int) error {
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		return setCredits(uow, id, domain.PrivateCredit, credits, fmt.Sprintf("balance set to %d", credits))
+	})
 }
 
-// UpdateCredits updates a client's private credits
-func (s *clientService) UpdatePrivateCredits(id string, credits int) error {
-	// Check if client exists
-	existingClient, err := s.repo.GetByID(id)
+// setCredits records the adjustment bringing a client's credits of a type to
+// the given value, made by the actor of the unit of work
+func setCredits(uow domain.UnitOfWork, clientID string, creditType domain.CreditType, credits int, reason string) error {
+	if credits < 0 {
+		return domain.NewValidationError("credits", "cannot be negative: %d", credits)
+	}
+
+	client, err := uow.Clients().GetByIDForUpdate(clientID)
 	if err != nil {
 		return err
 	}
 
-	if existingClient == nil {
-		return fmt.Errorf("client with id %s not found", id)
+	if client == nil {
+		return fmt.Errorf("client with ID %s: %w", clientID, domain.ErrNotFound)
 	}
 
-	// Update group credits
-	existingClient.PrivateCredits = credits
+	delta := credits - *creditsFor(client, creditType)
+	if delta == 0 {
+		return nil
+	}
 
-	return s.repo.Update(existingClient)
+	return applyCredit(uow, &domain.CreditEntry{
+		ClientID: clientID,
+		Type:     creditType,
+		Kind:     domain.CreditAdjustment,
+		Delta:    delta,
+		Reason:   reason,
+		Actor:    ledgerActor(uow.Context()),
+	})
 }
@@ -0,0 +1,493 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+type creditService struct {
+	repo       domain.CreditLedgerRepository
+	lotRepo    domain.CreditLotRepository
+	clientRepo domain.ClientRepository
+	tx         domain.Transactor
+}
+
+// NewCreditService creates a new credit service
+func NewCreditService(repo domain.CreditLedgerRepository, lotRepo domain.CreditLotRepository, clientRepo domain.ClientRepository, tx domain.Transactor) domain.CreditService {
+	return &creditService{
+		repo:       repo,
+		lotRepo:    lotRepo,
+		clientRepo: clientRepo,
+		tx:         tx,
+	}
+}
+
+// GetHistory returns the credit ledger of a client, most recent first
+func (s *creditService) GetHistory(clientID string) ([]domain.CreditEntry, error) {
+	return s.repo.GetByClient(clientID)
+}
+
+// GetBalance returns the balances of a client as derived from the ledger
+func (s *creditService) GetBalance(clientID string) (*domain.CreditBalance, error) {
+	return s.repo.GetBalance(clientID)
+}
+
+// GetLots returns the credit lots of a client, soonest expiry first
+func (s *creditService) GetLots(clientID string) ([]domain.CreditLot, error) {
+	return s.lotRepo.GetByClient(clientID)
+}
+
+// Adjust records a manual credit adjustment
+func (s *creditService) Adjust(ctx context.Context, clientID string, input domain.CreditAdjustmentInput) (*domain.CreditEntry, error) {
+	if input.Delta == 0 {
+		return nil, domain.NewValidationError("delta", "cannot be 0")
+	}
+
+	if input.Reason == "" {
+		return nil, domain.NewValidationError("reason", "is required")
+	}
+
+	if input.Type != domain.GroupCredit && input.Type != domain.PrivateCredit {
+		return nil, domain.NewValidationError("type", "is invalid: %s", input.Type)
+	}
+
+	entry := &domain.CreditEntry{
+		ClientID: clientID,
+		Type:     input.Type,
+		Kind:     domain.CreditAdjustment,
+		Delta:    input.Delta,
+		Reason:   input.Reason,
+		Actor:    ledgerActor(ctx),
+	}
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		return applyCredit(uow, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Reconcile resets the credit counters of every client to the balances
+// derived from the ledger and returns the clients that were out of sync.
+// Clients without any ledger entry get an opening balance entry instead,
+// and counters are never made negative.
+func (s *creditService) Reconcile(ctx context.Context) ([]domain.CreditBalance, error) {
+	clients, err := s.clientRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := s.repo.GetAllBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := make(map[string]domain.CreditBalance, len(balances))
+	for _, balance := range balances {
+		ledger[balance.ClientID] = balance
+	}
+
+	fixed := []domain.CreditBalance{}
+
+	for _, client := range clients {
+		balance, ok := ledger[client.ID]
+		if ok && balance.GroupCredits == client.GroupCredits && balance.PrivateCredits == client.PrivateCredits {
+			continue
+		}
+
+		skipped := false
+		err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+			locked, err := uow.Clients().GetByIDForUpdate(client.ID)
+			if err != nil || locked == nil {
+				return err
+			}
+
+			opened, err := openBalance(uow, locked)
+			if err != nil || opened {
+				balance = domain.CreditBalance{GroupCredits: locked.GroupCredits, PrivateCredits: locked.PrivateCredits}
+				return err
+			}
+
+			current, err := uow.Credits().GetBalance(client.ID)
+			if err != nil {
+				return err
+			}
+
+			// A negative ledger balance means entries are missing, not that
+			// the client owes credits
+			if current.GroupCredits < 0 || current.PrivateCredits < 0 {
+				skipped = true
+				log.Error().Str("clientID", client.ID).Int("groupCredits", current.GroupCredits).Int("privateCredits", current.PrivateCredits).Msg("refused to reconcile client credits with a negative ledger balance")
+				return nil
+			}
+
+			before := *locked
+			locked.GroupCredits = current.GroupCredits
+			locked.PrivateCredits = current.PrivateCredits
+			balance = *current
+
+			if err := uow.Clients().UpdateCredits(locked); err != nil {
+				return err
+			}
+
+			return record(uow, domain.AuditClient, client.ID, &before, locked)
+		})
+		if err != nil {
+			return fixed, err
+		}
+
+		if skipped {
+			continue
+		}
+
+		balance.ClientID = client.ID
+		log.Warn().Str("clientID", client.ID).Int("groupCredits", balance.GroupCredits).Int("privateCredits", balance.PrivateCredits).Msg("reconciled client credits with the ledger")
+		fixed = append(fixed, balance)
+	}
+
+	return fixed, nil
+}
+
+// ExpireAll retires the credits left in expired lots and returns the expiry
+// entries recorded
+func (s *creditService) ExpireAll(ctx context.Context) ([]domain.CreditEntry, error) {
+	now := time.Now()
+
+	clientIDs, err := s.lotRepo.GetClientsWithExpired(now)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []domain.CreditEntry{}
+
+	for _, clientID := range clientIDs {
+		err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+			client, err := uow.Clients().GetByIDForUpdate(clientID)
+			if err != nil || client == nil {
+				return err
+			}
+
+			before := *client
+			entries, err := expireLots(uow, client, now)
+			if err != nil {
+				return err
+			}
+
+			if len(entries) > 0 {
+				if err := uow.Clients().UpdateCredits(client); err != nil {
+					return err
+				}
+
+				if err := record(uow, domain.AuditClient, clientID, &before, client); err != nil {
+					return err
+				}
+			}
+
+			expired = append(expired, entries...)
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	for _, entry := range expired {
+		log.Info().Str("clientID", entry.ClientID).Str("lotID", entry.LotID).Str("type", string(entry.Type)).Int("credits", -entry.Delta).Msg("expired unused credits")
+	}
+
+	return expired, nil
+}
+
+// applyCredit changes one of a client's credit counters by entry.Delta and
+// appends the change to the ledger, keeping the credit lots in step. With
+// entry.LotID set the change applies to that lot: refunds give credits back
+// to it while grants and adjustments also change what it holds. Otherwise a
+// credit opens a lot that never expires and a debit consumes the valid lots
+// expiring soonest first, splitting the entry per lot; any part not covered
+// by a lot comes from balances predating lots. The first change of a client
+// predating the ledger opens it with the counters as they were, and expired
+// lots are retired first. It locks the client row, refuses to make a balance
+// negative and records the new balances in the audit log.
+func applyCredit(uow domain.UnitOfWork, entry *domain.CreditEntry) error {
+	client, err := uow.Clients().GetByIDForUpdate(entry.ClientID)
+	if err != nil {
+		return err
+	}
+
+	if client == nil {
+		return fmt.Errorf("client with ID %s: %w", entry.ClientID, domain.ErrNotFound)
+	}
+	before := *client
+
+	if _, err := openBalance(uow, client); err != nil {
+		return err
+	}
+
+	// Expiry is persisted even when the change itself is refused, so that
+	// callers going on with the transaction keep the counters in step
+	now := time.Now()
+	expired, err := expireLots(uow, client, now)
+	if err != nil {
+		return err
+	}
+
+	if len(expired) > 0 {
+		if err := uow.Clients().UpdateCredits(client); err != nil {
+			return err
+		}
+	}
+
+	credits := creditsFor(client, entry.Type)
+	if *credits+entry.Delta < 0 {
+		return fmt.Errorf("client %s has %d %s credits: %w", entry.ClientID, *credits, entry.Type, domain.ErrInsufficientCredits)
+	}
+
+	entries := []*domain.CreditEntry{entry}
+
+	switch {
+	case entry.LotID != "":
+		lot, err := uow.CreditLots().GetByIDForUpdate(entry.LotID)
+		if err != nil {
+			return err
+		}
+
+		if lot == nil {
+			return fmt.Errorf("credit lot with ID %s not found", entry.LotID)
+		}
+
+		lot.Remaining += entry.Delta
+		if lot.Remaining < 0 {
+			return fmt.Errorf("credit lot %s has %d credits left: %w", lot.ID, lot.Remaining-entry.Delta, domain.ErrInsufficientCredits)
+		}
+
+		if entry.Kind != domain.CreditRefund && entry.Kind != domain.CreditDebit {
+			lot.Credits += entry.Delta
+		}
+
+		if err := uow.CreditLots().Update(lot); err != nil {
+			return err
+		}
+	case entry.Delta > 0:
+		lot := &domain.CreditLot{
+			ClientID:  entry.ClientID,
+			Type:      entry.Type,
+			Credits:   entry.Delta,
+			Remaining: entry.Delta,
+		}
+
+		if err := uow.CreditLots().Create(lot); err != nil {
+			return err
+		}
+		entry.LotID = lot.ID
+	default:
+		entries, err = consumeLots(uow, entry, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		*credits += e.Delta
+		e.BalanceAfter = *credits
+		if e.Actor == "" {
+			e.Actor = domain.SystemActor
+		}
+
+		if err := uow.Credits().Create(e); err != nil {
+			return err
+		}
+	}
+
+	if err := uow.Clients().UpdateCredits(client); err != nil {
+		return err
+	}
+
+	// Credits given back to an expired lot expire right away
+	if entry.LotID != "" && entry.Delta > 0 {
+		expired, err := expireLots(uow, client, now)
+		if err != nil {
+			return err
+		}
+
+		if len(expired) > 0 {
+			if err := uow.Clients().UpdateCredits(client); err != nil {
+				return err
+			}
+		}
+	}
+
+	return record(uow, domain.AuditClient, client.ID, &before, client)
+}
+
+// openBalance records the credit counters of a locked client without any
+// ledger entry, i.e. predating the ledger, as opening balance entries, each
+// with a lot that never expires. It reports whether the ledger was opened.
+func openBalance(uow domain.UnitOfWork, client *domain.Client) (bool, error) {
+	count, err := uow.Credits().CountByClient(client.ID)
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	for _, creditType := range []domain.CreditType{domain.GroupCredit, domain.PrivateCredit} {
+		credits := *creditsFor(client, creditType)
+		if credits == 0 {
+			continue
+		}
+
+		lot := &domain.CreditLot{
+			ClientID:  client.ID,
+			Type:      creditType,
+			Credits:   credits,
+			Remaining: credits,
+		}
+
+		if err := uow.CreditLots().Create(lot); err != nil {
+			return false, err
+		}
+
+		err := uow.Credits().Create(&domain.CreditEntry{
+			ClientID:     client.ID,
+			Type:         creditType,
+			Kind:         domain.CreditAdjustment,
+			Delta:        credits,
+			BalanceAfter: credits,
+			Reason:       "opening balance",
+			Actor:        domain.SystemActor,
+			LotID:        lot.ID,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// consumeLots takes the credits debited by entry from the valid lots of the
+// client, soonest expiry first, and returns the entry split per lot. The
+// first part is entry itself.
+func consumeLots(uow domain.UnitOfWork, entry *domain.CreditEntry, now time.Time) ([]*domain.CreditEntry, error) {
+	lots, err := uow.CreditLots().GetAvailableForUpdate(entry.ClientID, entry.Type, now)
+	if err != nil {
+		return nil, err
+	}
+
+	needed := -entry.Delta
+	parts := []*domain.CreditEntry{}
+
+	for i := range lots {
+		if needed == 0 {
+			break
+		}
+
+		lot := &lots[i]
+		taken := min(needed, lot.Remaining)
+		lot.Remaining -= taken
+
+		if err := uow.CreditLots().Update(lot); err != nil {
+			return nil, err
+		}
+
+		part := entry
+		if len(parts) > 0 {
+			copied := *entry
+			copied.ID = ""
+			part = &copied
+		}
+		part.Delta = -taken
+		part.LotID = lot.ID
+
+		parts = append(parts, part)
+		needed -= taken
+	}
+
+	// Balance predating lots
+	if needed > 0 {
+		part := entry
+		if len(parts) > 0 {
+			copied := *entry
+			copied.ID = ""
+			part = &copied
+		}
+		part.Delta = -needed
+		part.LotID = ""
+
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// expireLots retires the credits left in the expired lots of a locked client
+// and returns the expiry entries. The caller saves the client counters.
+func expireLots(uow domain.UnitOfWork, client *domain.Client, now time.Time) ([]domain.CreditEntry, error) {
+	lots, err := uow.CreditLots().GetExpiredForUpdate(client.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]domain.CreditEntry, 0, len(lots))
+
+	for i := range lots {
+		lot := &lots[i]
+
+		credits := creditsFor(client, lot.Type)
+		expired := min(lot.Remaining, *credits)
+		*credits -= expired
+		lot.Remaining = 0
+
+		if err := uow.CreditLots().Update(lot); err != nil {
+			return nil, err
+		}
+
+		if expired == 0 {
+			continue
+		}
+
+		entry := domain.CreditEntry{
+			ClientID:     client.ID,
+			Type:         lot.Type,
+			Kind:         domain.CreditExpiry,
+			Delta:        -expired,
+			BalanceAfter: *credits,
+			Reason:       fmt.Sprintf("credits expired on %s", lot.ExpiresAt.Format(dateLayout)),
+			Actor:        domain.SystemActor,
+			ReferenceID:  lot.BillingID,
+			LotID:        lot.ID,
+		}
+
+		if err := uow.Credits().Create(&entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ledgerActor returns the ledger actor of the changes made with ctx, e.g.
+// "staff:<id>", or SystemActor when ctx carries no user
+func ledgerActor(ctx context.Context) string {
+	actor := domain.ActorFrom(ctx)
+	if actor.ID == "" {
+		return domain.SystemActor
+	}
+	return strings.ToLower(string(actor.Type)) + ":" + actor.ID
+}
+
+// creditsFor returns the client's credit counter matching a credit type
+func creditsFor(client *domain.Client, creditType domain.CreditType) *int {
+	if creditType == domain.PrivateCredit {
+		return &client.PrivateCredits
+	}
+	return &client.GroupCredits
+}
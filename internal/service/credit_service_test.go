@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+)
+
+type fakeClients struct {
+	domain.ClientRepository
+	clients map[string]*domain.Client
+}
+
+func (r *fakeClients) GetByID(id string) (*domain.Client, error) {
+	return r.GetByIDForUpdate(id)
+}
+
+func (r *fakeClients) GetByIDForUpdate(id string) (*domain.Client, error) {
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *client
+	return &copied, nil
+}
+
+func (r *fakeClients) UpdateCredits(client *domain.Client) error {
+	copied := *client
+	r.clients[client.ID] = &copied
+	return nil
+}
+
+type fakeCredits struct {
+	domain.CreditLedgerRepository
+	entries []*domain.CreditEntry
+}
+
+func (r *fakeCredits) CountByClient(clientID string) (int, error) {
+	count := 0
+	for _, entry := range r.entries {
+		if entry.ClientID == clientID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeCredits) Create(entry *domain.CreditEntry) error {
+	entry.ID = fmt.Sprintf("entry-%d", len(r.entries)+1)
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// fakeCreditLots keeps lots in creation order
+type fakeCreditLots struct {
+	domain.CreditLotRepository
+	lots []domain.CreditLot
+}
+
+func (r *fakeCreditLots) get(id string) *domain.CreditLot {
+	for i := range r.lots {
+		if r.lots[i].ID == id {
+			return &r.lots[i]
+		}
+	}
+	return nil
+}
+
+func (r *fakeCreditLots) GetByIDForUpdate(id string) (*domain.CreditLot, error) {
+	lot := r.get(id)
+	if lot == nil {
+		return nil, nil
+	}
+	copied := *lot
+	return &copied, nil
+}
+
+func (r *fakeCreditLots) GetAvailableForUpdate(clientID string, creditType domain.CreditType, at time.Time) ([]domain.CreditLot, error) {
+	var lots []domain.CreditLot
+	for _, lot := range r.lots {
+		if lot.ClientID == clientID && lot.Type == creditType && lot.Remaining > 0 && (lot.ExpiresAt == nil || lot.ExpiresAt.After(at)) {
+			lots = append(lots, lot)
+		}
+	}
+
+	// Soonest expiry first, lots that never expire last
+	slices.SortStableFunc(lots, func(a, b domain.CreditLot) int {
+		switch {
+		case a.ExpiresAt == nil && b.ExpiresAt == nil:
+			return 0
+		case a.ExpiresAt == nil:
+			return 1
+		case b.ExpiresAt == nil:
+			return -1
+		}
+		return a.ExpiresAt.Compare(*b.ExpiresAt)
+	})
+	return lots, nil
+}
+
+func (r *fakeCreditLots) GetExpiredForUpdate(clientID string, at time.Time) ([]domain.CreditLot, error) {
+	var lots []domain.CreditLot
+	for _, lot := range r.lots {
+		if lot.ClientID == clientID && lot.Remaining > 0 && lot.ExpiresAt != nil && !lot.ExpiresAt.After(at) {
+			lots = append(lots, lot)
+		}
+	}
+	return lots, nil
+}
+
+func (r *fakeCreditLots) Create(lot *domain.CreditLot) error {
+	lot.ID = fmt.Sprintf("lot-%d", len(r.lots)+1)
+	r.lots = append(r.lots, *lot)
+	return nil
+}
+
+func (r *fakeCreditLots) Update(lot *domain.CreditLot) error {
+	*r.get(lot.ID) = *lot
+	return nil
+}
+
+// creditPart is the lot and delta of a ledger entry
+type creditPart struct {
+	lotID string
+	delta int
+}
+
+func TestApplyCredit(t *testing.T) {
+	now := time.Now()
+	in := func(days int) *time.Time {
+		at := now.AddDate(0, 0, days)
+		return &at
+	}
+
+	tests := []struct {
+		name          string
+		lots          []domain.CreditLot
+		entry         domain.CreditEntry
+		wantErr       error
+		wantParts     []creditPart
+		wantRemaining map[string]int
+		wantCredits   map[string]int
+		wantBalance   int
+	}{
+		{
+			name: "debit takes the lot expiring soonest",
+			lots: []domain.CreditLot{
+				{ID: "later", Credits: 2, Remaining: 2, ExpiresAt: in(30)},
+				{ID: "never", Credits: 2, Remaining: 2},
+				{ID: "sooner", Credits: 2, Remaining: 2, ExpiresAt: in(10)},
+			},
+			entry:         domain.CreditEntry{Kind: domain.CreditDebit, Delta: -1},
+			wantParts:     []creditPart{{"sooner", -1}},
+			wantRemaining: map[string]int{"later": 2, "never": 2, "sooner": 1},
+			wantBalance:   5,
+		},
+		{
+			name: "debit spanning two lots",
+			lots: []domain.CreditLot{
+				{ID: "later", Credits: 2, Remaining: 2, ExpiresAt: in(30)},
+				{ID: "sooner", Credits: 2, Remaining: 1, ExpiresAt: in(10)},
+			},
+			entry:         domain.CreditEntry{Kind: domain.CreditDebit, Delta: -2},
+			wantParts:     []creditPart{{"sooner", -1}, {"later", -1}},
+			wantRemaining: map[string]int{"later": 1, "sooner": 0},
+			wantBalance:   1,
+		},
+		{
+			name: "refund goes back to its lot",
+			lots: []domain.CreditLot{
+				{ID: "sooner", Credits: 2, Remaining: 2, ExpiresAt: in(10)},
+				{ID: "later", Credits: 2, Remaining: 0, ExpiresAt: in(30)},
+			},
+			entry:         domain.CreditEntry{Kind: domain.CreditRefund, Delta: 1, LotID: "later"},
+			wantParts:     []creditPart{{"later", 1}},
+			wantRemaining: map[string]int{"sooner": 2, "later": 1},
+			wantCredits:   map[string]int{"sooner": 2, "later": 2},
+			wantBalance:   3,
+		},
+		{
+			name: "adjustment of a lot changes its credits",
+			lots: []domain.CreditLot{
+				{ID: "lot", Credits: 5, Remaining: 3},
+			},
+			entry:         domain.CreditEntry{Kind: domain.CreditAdjustment, Delta: -2, LotID: "lot"},
+			wantParts:     []creditPart{{"lot", -2}},
+			wantRemaining: map[string]int{"lot": 1},
+			wantCredits:   map[string]int{"lot": 3},
+			wantBalance:   1,
+		},
+		{
+			name: "expired lot refused",
+			lots: []domain.CreditLot{
+				{ID: "expired", Credits: 2, Remaining: 2, ExpiresAt: in(-1)},
+			},
+			entry:         domain.CreditEntry{Kind: domain.CreditDebit, Delta: -1},
+			wantErr:       domain.ErrInsufficientCredits,
+			wantParts:     []creditPart{{"expired", -2}},
+			wantRemaining: map[string]int{"expired": 0},
+			wantBalance:   0,
+		},
+		{
+			name: "expired lot skipped for a valid one",
+			lots: []domain.CreditLot{
+				{ID: "expired", Credits: 2, Remaining: 2, ExpiresAt: in(-1)},
+				{ID: "valid", Credits: 2, Remaining: 2, ExpiresAt: in(10)},
+			},
+			entry:         domain.CreditEntry{Kind: domain.CreditDebit, Delta: -1},
+			wantParts:     []creditPart{{"expired", -2}, {"valid", -1}},
+			wantRemaining: map[string]int{"expired": 0, "valid": 1},
+			wantBalance:   1,
+		},
+		{
+			name: "refund to an expired lot expires right away",
+			lots: []domain.CreditLot{
+				{ID: "expired", Credits: 2, Remaining: 0, ExpiresAt: in(-1)},
+				{ID: "valid", Credits: 2, Remaining: 2},
+			},
+			entry:         domain.CreditEntry{Kind: domain.CreditRefund, Delta: 1, LotID: "expired"},
+			wantParts:     []creditPart{{"expired", 1}, {"expired", -1}},
+			wantRemaining: map[string]int{"expired": 0, "valid": 2},
+			wantBalance:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balance := 0
+			for i := range tt.lots {
+				tt.lots[i].ClientID = "client"
+				tt.lots[i].Type = domain.GroupCredit
+				balance += tt.lots[i].Remaining
+			}
+
+			clients := &fakeClients{clients: map[string]*domain.Client{
+				"client": {ID: "client", GroupCredits: balance},
+			}}
+			// An earlier entry, so the ledger is already open
+			credits := &fakeCredits{entries: []*domain.CreditEntry{
+				{ClientID: "client", Type: domain.GroupCredit, Kind: domain.CreditGrant, Delta: balance, BalanceAfter: balance},
+			}}
+			lots := &fakeCreditLots{lots: tt.lots}
+			tx := &fakeTx{audit: &fakeAudit{}, clients: clients, credits: credits, lots: lots}
+
+			entry := tt.entry
+			entry.ClientID = "client"
+			entry.Type = domain.GroupCredit
+
+			err := tx.WithinTransaction(context.Background(), func(uow domain.UnitOfWork) error {
+				return applyCredit(uow, &entry)
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("applyCredit() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var parts []creditPart
+			for _, e := range credits.entries[1:] {
+				parts = append(parts, creditPart{e.LotID, e.Delta})
+			}
+			if !slices.Equal(parts, tt.wantParts) {
+				t.Errorf("ledger entries = %v, want %v", parts, tt.wantParts)
+			}
+
+			for id, want := range tt.wantRemaining {
+				if got := lots.get(id).Remaining; got != want {
+					t.Errorf("lot %s remaining = %d, want %d", id, got, want)
+				}
+			}
+
+			for id, want := range tt.wantCredits {
+				if got := lots.get(id).Credits; got != want {
+					t.Errorf("lot %s credits = %d, want %d", id, got, want)
+				}
+			}
+
+			if got := clients.clients["client"].GroupCredits; got != tt.wantBalance {
+				t.Errorf("group credits = %d, want %d", got, tt.wantBalance)
+			}
+		})
+	}
+}
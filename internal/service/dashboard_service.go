@@ -0,0 +1,146 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+type dashboardService struct {
+	clientService domain.ClientService
+	scheduleRepo  domain.ScheduleRepository
+	paymentRepo   domain.PaymentRepository
+	reportRepo    domain.ReportRepository
+	settings      domain.DashboardSettings
+	location      *time.Location
+
+	mu        sync.Mutex
+	cached    *domain.Dashboard
+	expiresAt time.Time
+}
+
+// NewDashboardService creates a new dashboard service. Days and weeks follow
+// the calendar in location.
+func NewDashboardService(clientService domain.ClientService, scheduleRepo domain.ScheduleRepository, paymentRepo domain.PaymentRepository, reportRepo domain.ReportRepository, settings domain.DashboardSettings, location *time.Location) domain.DashboardService {
+	return &dashboardService{
+		clientService: clientService,
+		scheduleRepo:  scheduleRepo,
+		paymentRepo:   paymentRepo,
+		reportRepo:    reportRepo,
+		settings:      settings,
+		location:      location,
+	}
+}
+
+// Get returns the dashboard, built at most once per cache period
+func (s *dashboardService) Get() (*domain.Dashboard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.cached != nil && now.Before(s.expiresAt) {
+		return s.cached, nil
+	}
+
+	dashboard, err := s.build(now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cached, s.expiresAt = dashboard, now.Add(s.settings.CacheTTL)
+	return dashboard, nil
+}
+
+// build gathers the dashboard data as of now
+func (s *dashboardService) build(now time.Time) (*domain.Dashboard, error) {
+	local := now.In(s.location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+	monday := weekStart(today)
+
+	week, err := s.scheduleRepo.GetDetailsByDateRange(monday, monday.AddDate(0, 0, 7))
+	if err != nil {
+		return nil, err
+	}
+
+	todays := []domain.ScheduleWithDetails{}
+	for _, schedule := range week {
+		if !schedule.Schedule.ClassDatetime.Before(today) && schedule.Schedule.ClassDatetime.Before(today.AddDate(0, 0, 1)) {
+			todays = append(todays, schedule)
+		}
+	}
+
+	upcoming, err := s.scheduleRepo.GetDetailsByDateRange(now, today.AddDate(0, 0, s.settings.EmptyClassDays+1))
+	if err != nil {
+		return nil, err
+	}
+
+	empty := []domain.ScheduleWithDetails{}
+	for _, schedule := range upcoming {
+		if schedule.BookedCount == 0 {
+			empty = append(empty, schedule)
+		}
+	}
+
+	lowGroup, err := s.clientService.GetLowGroupCredits(s.settings.LowCreditThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	lowPrivate, err := s.clientService.GetLowPrivateCredits(s.settings.LowCreditThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := s.paymentRepo.GetRecent(s.settings.RecentPayments)
+	if err != nil {
+		return nil, err
+	}
+
+	months, err := s.reportRepo.GetRevenueByMonth(domain.ReportFilter{
+		From:     time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, s.location),
+		To:       now,
+		Location: s.location,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	currency := s.settings.Currency
+	if currency == "" {
+		currency = money.EUR
+	}
+
+	monthToDate := domain.MonthlyRevenue{
+		Month:     local.Format("2006-01"),
+		Discounts: money.New(0, currency),
+		Net:       money.New(0, currency),
+		VAT:       money.New(0, currency),
+		Gross:     money.New(0, currency),
+	}
+	for _, month := range months {
+		// Amounts in other currencies cannot be added up
+		if month.Gross.Currency != currency {
+			continue
+		}
+
+		monthToDate.Billings += month.Billings
+		monthToDate.Refunds += month.Refunds
+		monthToDate.Discounts = monthToDate.Discounts.Add(month.Discounts)
+		monthToDate.Net = monthToDate.Net.Add(month.Net)
+		monthToDate.VAT = monthToDate.VAT.Add(month.VAT)
+		monthToDate.Gross = monthToDate.Gross.Add(month.Gross)
+	}
+
+	return &domain.Dashboard{
+		GeneratedAt:       now,
+		Today:             todays,
+		ThisWeek:          week,
+		LowGroupCredits:   lowGroup,
+		LowPrivateCredits: lowPrivate,
+		RecentPayments:    payments,
+		MonthToDate:       monthToDate,
+		EmptyClasses:      empty,
+	}, nil
+}
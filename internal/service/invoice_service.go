@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+type invoiceService struct {
+	repo     domain.InvoiceRepository
+	tx       domain.Transactor
+	issuer   domain.InvoiceIssuer
+	location *time.Location
+}
+
+// NewInvoiceService creates a new invoice service. Invoice years follow the
+// calendar in location.
+func NewInvoiceService(repo domain.InvoiceRepository, tx domain.Transactor, issuer domain.InvoiceIssuer, location *time.Location) domain.InvoiceService {
+	return &invoiceService{
+		repo:     repo,
+		tx:       tx,
+		issuer:   issuer,
+		location: location,
+	}
+}
+
+// GetByBilling returns the invoice of a billing, or nil if not issued yet
+func (s *invoiceService) GetByBilling(billingID string) (*domain.Invoice, error) {
+	return s.repo.GetByBilling(billingID)
+}
+
+// Issue returns the invoice of a billing or the credit note of a refund,
+// issuing the invoice first for billings recorded before invoicing
+func (s *invoiceService) Issue(ctx context.Context, billingID string) (*domain.Invoice, error) {
+	var invoice *domain.Invoice
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		billing, err := uow.Billings().GetByID(billingID)
+		if err != nil {
+			return err
+		}
+
+		if billing == nil {
+			return fmt.Errorf("billing with ID %s: %w", billingID, domain.ErrNotFound)
+		}
+
+		invoice, err = uow.Invoices().GetByBilling(billingID)
+		if err != nil || invoice != nil {
+			return err
+		}
+
+		// Credit notes are issued along with their refund
+		if billing.Kind == domain.RefundBilling {
+			return fmt.Errorf("refund %s has no credit note: %w", billingID, domain.ErrConflict)
+		}
+
+		invoice, err = issueInvoice(uow, s.issuer, s.location, billing)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// issueInvoice numbers and creates the invoice of a billing. It must run in
+// the transaction recording the billing so that a failure leaves no gap in
+// the numbering.
+func issueInvoice(uow domain.UnitOfWork, issuer domain.InvoiceIssuer, location *time.Location, billing *domain.Billing) (*domain.Invoice, error) {
+	client, err := uow.Clients().GetByID(billing.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, fmt.Errorf("client with ID %s not found", billing.ClientID)
+	}
+
+	pkg, err := uow.Packages().GetByID(billing.PackageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkg == nil {
+		return nil, fmt.Errorf("package with ID %s not found", billing.PackageID)
+	}
+
+	issuedAt := time.Now()
+	year := issuedAt.In(location).Year()
+
+	sequence, err := uow.Invoices().NextSequence(domain.InvoiceSeries, year)
+	if err != nil {
+		return nil, err
+	}
+
+	paidAt := billing.PaymentDate
+	if paidAt.IsZero() {
+		paidAt = issuedAt
+	}
+
+	description := fmt.Sprintf("%s (%d séances)", pkg.Name, pkg.NumberOfSessions)
+	if pkg.Type == domain.MembershipPackage {
+		description = fmt.Sprintf("%s (mois d'abonnement)", pkg.Name)
+	}
+
+	invoice := &domain.Invoice{
+		Kind:                domain.InvoiceDocument,
+		Number:              domain.InvoiceNumber(domain.InvoiceSeries, year, sequence),
+		Year:                year,
+		Sequence:            sequence,
+		BillingID:           billing.ID,
+		ClientID:            client.ID,
+		IssuedAt:            issuedAt,
+		PaidAt:              paidAt,
+		SellerName:          issuer.Name,
+		SellerAddress:       issuer.Address,
+		SellerSIRET:         issuer.SIRET,
+		SellerVATNumber:     issuer.VATNumber,
+		SellerContact:       issuer.Contact,
+		SellerLegalMentions: issuer.LegalMentions,
+		BuyerName:           strings.TrimSpace(client.FirstName + " " + client.LastName),
+		BuyerAddress:        clientAddress(client),
+		NetTotal:            billing.NetPrice,
+		VATTotal:            billing.VAT,
+		Total:               billing.Price,
+		Lines: []domain.InvoiceLine{
+			{
+				Description: description,
+				Quantity:    billing.Amount,
+				UnitPrice:   billing.NetPrice.Div(int64(billing.Amount)),
+				VATRate:     billing.VATRate,
+				Total:       billing.NetPrice,
+			},
+		},
+	}
+
+	// Discounts are shown on a line of their own, off the price before them
+	if !billing.Discount.IsZero() {
+		promotion, err := uow.Promotions().GetByID(billing.PromotionID)
+		if err != nil {
+			return nil, err
+		}
+
+		discountDescription := "Remise"
+		if promotion != nil {
+			discountDescription = fmt.Sprintf("Remise (code %s)", promotion.Code)
+		}
+
+		// The line before the discount is its unit price times the
+		// quantity, the discount taking any rounding so the lines add up
+		// to the net total
+		unitNet, _ := billing.OriginalPrice.Div(int64(billing.Amount)).SplitVAT(billing.VATRate)
+		originalNet := unitNet.Mul(int64(billing.Amount))
+		discountNet := billing.NetPrice.Sub(originalNet)
+
+		invoice.Lines[0].UnitPrice = unitNet
+		invoice.Lines[0].Total = originalNet
+		invoice.Lines = append(invoice.Lines, domain.InvoiceLine{
+			Description: discountDescription,
+			Quantity:    1,
+			UnitPrice:   discountNet,
+			VATRate:     billing.VATRate,
+			Total:       discountNet,
+		})
+	}
+
+	if err := uow.Invoices().Create(invoice); err != nil {
+		return nil, err
+	}
+
+	if err := record(uow, domain.AuditInvoice, invoice.ID, nil, invoice); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("billingID", billing.ID).Str("number", invoice.Number).Msg("issued invoice")
+
+	return invoice, nil
+}
+
+// issueCreditNote numbers and creates the credit note of a refund,
+// correcting the invoice of the refunded billing. Buyer and seller details
+// are those of the corrected invoice.
+func issueCreditNote(uow domain.UnitOfWork, location *time.Location, refund *domain.Billing, invoice *domain.Invoice) (*domain.Invoice, error) {
+	issuedAt := time.Now()
+	year := issuedAt.In(location).Year()
+
+	sequence, err := uow.Invoices().NextSequence(domain.CreditNoteSeries, year)
+	if err != nil {
+		return nil, err
+	}
+
+	creditNote := &domain.Invoice{
+		Kind:                   domain.CreditNoteDocument,
+		Number:                 domain.InvoiceNumber(domain.CreditNoteSeries, year, sequence),
+		Year:                   year,
+		Sequence:               sequence,
+		BillingID:              refund.ID,
+		ClientID:               refund.ClientID,
+		IssuedAt:               issuedAt,
+		PaidAt:                 refund.PaymentDate,
+		SellerName:             invoice.SellerName,
+		SellerAddress:          invoice.SellerAddress,
+		SellerSIRET:            invoice.SellerSIRET,
+		SellerVATNumber:        invoice.SellerVATNumber,
+		SellerContact:          invoice.SellerContact,
+		SellerLegalMentions:    invoice.SellerLegalMentions,
+		BuyerName:              invoice.BuyerName,
+		BuyerAddress:           invoice.BuyerAddress,
+		CorrectedInvoiceID:     invoice.ID,
+		CorrectedInvoiceNumber: invoice.Number,
+		Reason:                 refund.Reason,
+		NetTotal:               refund.NetPrice,
+		VATTotal:               refund.VAT,
+		Total:                  refund.Price,
+		Lines: []domain.InvoiceLine{
+			{
+				Description: fmt.Sprintf("Remboursement sur la facture %s : %s", invoice.Number, refund.Reason),
+				Quantity:    1,
+				UnitPrice:   refund.NetPrice,
+				VATRate:     refund.VATRate,
+				Total:       refund.NetPrice,
+			},
+		},
+	}
+
+	if err := uow.Invoices().Create(creditNote); err != nil {
+		return nil, err
+	}
+
+	if err := record(uow, domain.AuditInvoice, creditNote.ID, nil, creditNote); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("billingID", refund.RefundedBillingID).Str("number", creditNote.Number).Str("invoice", invoice.Number).Msg("issued credit note")
+
+	return creditNote, nil
+}
+
+// clientAddress formats the postal address of a client, one part per line
+func clientAddress(client *domain.Client) string {
+	lines := []string{}
+	for _, line := range []string{
+		client.StreetNumber + " " + client.StreetName,
+		client.ZipCode + " " + client.City,
+		client.Country,
+	} {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+type fakePackages struct {
+	domain.PackageRepository
+	packages map[string]*domain.Package
+}
+
+func (r *fakePackages) GetByID(id string) (*domain.Package, error) {
+	return r.packages[id], nil
+}
+
+type fakePromotions struct {
+	domain.PromotionRepository
+	promotions map[string]*domain.Promotion
+}
+
+func (r *fakePromotions) GetByID(id string) (*domain.Promotion, error) {
+	return r.promotions[id], nil
+}
+
+func TestIssueInvoiceLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		unitPrice int64
+		amount    int
+		vatRate   int
+		discount  int64
+	}{
+		{"without discount", 5000, 1, 2000, 0},
+		{"discounted single package", 15000, 1, 2000, 1500},
+		{"discounted packages", 5000, 3, 2000, 1500},
+		{"discounted packages, reduced rate", 3333, 7, 550, 999},
+		{"discounted packages, unit net rounded down", 1001, 4, 2000, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := money.New(tt.unitPrice*int64(tt.amount), money.EUR)
+			price := original.Sub(money.New(tt.discount, money.EUR))
+			net, vat := price.SplitVAT(tt.vatRate)
+
+			billing := &domain.Billing{
+				ID:            "billing",
+				Kind:          domain.PaymentBilling,
+				ClientID:      "client",
+				PackageID:     "package",
+				Amount:        tt.amount,
+				OriginalPrice: original,
+				Discount:      money.New(tt.discount, money.EUR),
+				Price:         price,
+				NetPrice:      net,
+				VAT:           vat,
+				VATRate:       tt.vatRate,
+			}
+			if tt.discount != 0 {
+				billing.PromotionID = "promotion"
+			}
+
+			invoices := &fakeInvoices{}
+			tx := &fakeTx{
+				invoices:   invoices,
+				audit:      &fakeAudit{},
+				clients:    &fakeClients{clients: map[string]*domain.Client{"client": {ID: "client", FirstName: "Jeanne", LastName: "Martin"}}},
+				packages:   &fakePackages{packages: map[string]*domain.Package{"package": {ID: "package", Name: "Carnet", NumberOfSessions: 10}}},
+				promotions: &fakePromotions{promotions: map[string]*domain.Promotion{"promotion": {ID: "promotion", Code: "BIENVENUE"}}},
+			}
+
+			var invoice *domain.Invoice
+			err := tx.WithinTransaction(context.Background(), func(uow domain.UnitOfWork) error {
+				var err error
+				invoice, err = issueInvoice(uow, domain.InvoiceIssuer{}, time.UTC, billing)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("issueInvoice() error = %v", err)
+			}
+
+			wantLines := 1
+			if tt.discount != 0 {
+				wantLines = 2
+			}
+			if len(invoice.Lines) != wantLines {
+				t.Fatalf("issueInvoice() has %d lines, want %d", len(invoice.Lines), wantLines)
+			}
+
+			sum := money.New(0, money.EUR)
+			for _, line := range invoice.Lines {
+				sum = sum.Add(line.Total)
+			}
+			if sum != invoice.NetTotal {
+				t.Errorf("lines add up to %s, want the net total %s", sum, invoice.NetTotal)
+			}
+
+			if tt.discount != 0 {
+				first := invoice.Lines[0]
+				if got := first.UnitPrice.Mul(int64(first.Quantity)); got != first.Total {
+					t.Errorf("line %d x %s = %s, want its total %s", first.Quantity, first.UnitPrice, got, first.Total)
+				}
+			}
+
+			if invoice.NetTotal != net || invoice.NetTotal.Add(invoice.VATTotal) != invoice.Total || invoice.Total != price {
+				t.Errorf("totals net %s + VAT %s = %s, want %s + %s = %s", invoice.NetTotal, invoice.VATTotal, invoice.Total, net, vat, price)
+			}
+		})
+	}
+}
@@ -1,24 +1,28 @@
 package service
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
 )
 
 type packageService struct {
 	repo domain.PackageRepository
+	tx   domain.Transactor
 }
 
 // NewPackageService creates a new package service
-func NewPackageService(repo domain.PackageRepository) domain.PackageService {
+func NewPackageService(repo domain.PackageRepository, tx domain.Transactor) domain.PackageService {
 	return &packageService{
 		repo: repo,
+		tx:   tx,
 	}
 }
 
 // Create creates a new package.
-func (s *packageService) Create(input domain.PackageInput) error {
+func (s *packageService) Create(ctx context.Context, input domain.PackageInput) error {
 	// Check if package already exists
 	existingPackage, err := s.repo.GetByName(input.Name)
 	if err != nil {
@@ -26,7 +30,15 @@ func (s *packageService) Create(input domain.PackageInput) error {
 	}
 
 	if existingPackage != nil {
-		return fmt.Errorf("package %s already exists", input.Name)
+		return fmt.Errorf("package name %s: %w", input.Name, domain.ErrPackageNameExists)
+	}
+
+	if input.ValidityDays < 0 {
+		return domain.NewValidationError("validity_days", "cannot be negative: %d", input.ValidityDays)
+	}
+
+	if err := validatePrice(input); err != nil {
+		return err
 	}
 
 	// Create a new package
@@ -34,19 +46,23 @@ func (s *packageService) Create(input domain.PackageInput) error {
 		Name:             input.Name,
 		NumberOfSessions: input.NumberOfSessions,
 		Type:             input.Type,
-		Price:            input.Price,
+		Price:            priceOf(input),
+		VATRate:          input.VATRate,
+		ValidityDays:     input.ValidityDays,
 	}
 
-	err = s.repo.Create(pkg)
-	if err != nil {
-		return err
-	}
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Packages().Create(pkg); err != nil {
+			return err
+		}
 
-	return nil
+		return record(uow, domain.AuditPackage, pkg.ID, nil, pkg)
+	})
 }
 
-// Delete deletes an existing package.
-func (s *packageService) Delete(id string) error {
+// Delete deletes an existing package that was never billed, as billings
+// are never deleted.
+func (s *packageService) Delete(ctx context.Context, id string) error {
 	// Check if package exists
 	existingPackage, err := s.repo.GetByID(id)
 	if err != nil {
@@ -54,10 +70,25 @@ func (s *packageService) Delete(id string) error {
 	}
 
 	if existingPackage == nil {
-		return fmt.Errorf("package with ID %s not found", id)
+		return fmt.Errorf("package with ID %s: %w", id, domain.ErrNotFound)
 	}
 
-	return s.repo.Delete(id)
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		count, err := uow.Billings().CountByPackage(id)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return fmt.Errorf("package %s (%d billings): %w", id, count, domain.ErrPackageBilled)
+		}
+
+		if err := uow.Packages().Delete(id); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditPackage, id, existingPackage, nil)
+	})
 }
 
 // GetAll returns all packages.
@@ -81,7 +112,7 @@ func (s *packageService) GetByName(name string) (*domain.Package, error) {
 }
 
 // Update implements domain.PackageService.
-func (s *packageService) Update(id string, input domain.PackageInput) (*domain.Package, error) {
+func (s *packageService) Update(ctx context.Context, id string, input domain.PackageInput) (*domain.Package, error) {
 	// Check if package exists
 	existingPackage, err := s.repo.GetByID(id)
 	if err != nil {
@@ -89,7 +120,7 @@ func (s *packageService) Update(id string, input domain.PackageInput) (*domain.P
 	}
 
 	if existingPackage == nil {
-		return nil, fmt.Errorf("package with id %s not found", id)
+		return nil, fmt.Errorf("package with ID %s: %w", id, domain.ErrNotFound)
 	}
 
 	// Check if name is already in use by another package
@@ -100,19 +131,47 @@ func (s *packageService) Update(id string, input domain.PackageInput) (*domain.P
 		}
 
 		if packageWithName != nil && packageWithName.ID != id {
-			return nil, fmt.Errorf("package name %s already exists")
+			return nil, fmt.Errorf("package name %s: %w", input.Name, domain.ErrPackageNameExists)
 		}
 	}
 
+	if input.ValidityDays < 0 {
+		return nil, domain.NewValidationError("validity_days", "cannot be negative: %d", input.ValidityDays)
+	}
+
+	// Credits and subscriptions depend on whether the package is a plan
+	isMembership := input.Type == domain.MembershipPackage
+	if isMembership != (existingPackage.Type == domain.MembershipPackage) {
+		return nil, domain.NewValidationError("type", "cannot change from %s to %s", existingPackage.Type, input.Type)
+	}
+
+	if err := validatePrice(input); err != nil {
+		return nil, err
+	}
+
 	// Update package
 	pkg := &domain.Package{
+		ID:               id,
 		Name:             input.Name,
 		NumberOfSessions: input.NumberOfSessions,
 		Type:             input.Type,
-		Price:            input.Price,
+		Price:            priceOf(input),
+		VATRate:          input.VATRate,
+		ValidityDays:     input.ValidityDays,
 	}
 
-	err = s.repo.Update(pkg)
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Packages().Update(pkg); err != nil {
+			return err
+		}
+
+		updated, err := uow.Packages().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditPackage, id, existingPackage, updated)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -120,3 +179,25 @@ func (s *packageService) Update(id string, input domain.PackageInput) (*domain.P
 	// Get the updated package to return with all fields
 	return s.repo.GetByID(id)
 }
+
+// validatePrice checks the price and VAT rate of a package
+func validatePrice(input domain.PackageInput) error {
+	if input.Price.IsNegative() {
+		return domain.NewValidationError("price", "cannot be negative: %s", input.Price)
+	}
+
+	if input.VATRate < 0 || input.VATRate > 10000 {
+		return domain.NewValidationError("vat_rate", "must be between 0 and 10000 basis points: %d", input.VATRate)
+	}
+
+	return nil
+}
+
+// priceOf returns the price of a package input, in euros when no currency
+// is given
+func priceOf(input domain.PackageInput) money.Money {
+	if input.Price.Currency == "" {
+		return money.New(input.Price.Amount, money.EUR)
+	}
+	return input.Price
+}
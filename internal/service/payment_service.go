@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/rs/zerolog/log"
+)
+
+type paymentService struct {
+	repo domain.PaymentRepository
+	tx   domain.Transactor
+}
+
+// NewPaymentService creates a new payment service
+func NewPaymentService(repo domain.PaymentRepository, tx domain.Transactor) domain.PaymentService {
+	return &paymentService{
+		repo: repo,
+		tx:   tx,
+	}
+}
+
+// GetByBilling returns the payments of a billing and of its refunds
+func (s *paymentService) GetByBilling(billingID string) ([]domain.Payment, error) {
+	return s.repo.GetByBilling(billingID)
+}
+
+// GetByClient returns the payments of a client
+func (s *paymentService) GetByClient(clientID string) ([]domain.Payment, error) {
+	return s.repo.GetByClient(clientID)
+}
+
+// Record records a payment of a billing, or the money paid back for a
+// refund.
+func (s *paymentService) Record(ctx context.Context, billingID string, input domain.PaymentInput) (*domain.Payment, error) {
+	if err := validatePayment(input); err != nil {
+		return nil, err
+	}
+
+	var payment *domain.Payment
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		billing, err := uow.Billings().GetByID(billingID)
+		if err != nil {
+			return err
+		}
+
+		if billing == nil {
+			return fmt.Errorf("billing with ID %s: %w", billingID, domain.ErrNotFound)
+		}
+
+		payment, err = recordPayment(uow, billing, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// GetBalance returns what is left to pay on a billing
+func (s *paymentService) GetBalance(billingID string) (*domain.BillingBalance, error) {
+	return s.repo.GetBalance(billingID)
+}
+
+// GetClientBalance returns what is left to pay on each billing of a client
+// and in total
+func (s *paymentService) GetClientBalance(clientID string) (*domain.ClientBalance, error) {
+	balances, err := s.repo.GetBalancesByClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := money.EUR
+	if len(balances) > 0 {
+		currency = balances[0].Due.Currency
+	}
+
+	balance := &domain.ClientBalance{
+		ClientID:    clientID,
+		Due:         money.New(0, currency),
+		Paid:        money.New(0, currency),
+		Outstanding: money.New(0, currency),
+		Billings:    balances,
+	}
+
+	for _, billing := range balances {
+		balance.Due = balance.Due.Add(billing.Due)
+		balance.Paid = balance.Paid.Add(billing.Paid)
+		balance.Outstanding = balance.Outstanding.Add(billing.Outstanding)
+	}
+
+	return balance, nil
+}
+
+// GetOutstanding returns the billings that are unpaid or partially paid, or
+// only those with the given status
+func (s *paymentService) GetOutstanding(status domain.PaymentStatus) ([]domain.BillingBalance, error) {
+	if status != "" && status != domain.UnpaidStatus && status != domain.PartialStatus {
+		return nil, domain.NewValidationError("status", "is invalid: %s", status)
+	}
+
+	balances, err := s.repo.GetOutstanding()
+	if err != nil || status == "" {
+		return balances, err
+	}
+
+	filtered := []domain.BillingBalance{}
+	for _, balance := range balances {
+		if balance.Status == status {
+			filtered = append(filtered, balance)
+		}
+	}
+
+	return filtered, nil
+}
+
+// validatePayment checks the method and amount of a payment
+func validatePayment(input domain.PaymentInput) error {
+	switch input.Method {
+	case domain.CashPayment, domain.ChequePayment, domain.CardPayment, domain.TransferPayment:
+	default:
+		return domain.NewValidationError("method", "is invalid: %s", input.Method)
+	}
+
+	if input.Amount.Amount <= 0 {
+		return domain.NewValidationError("amount", "must be positive: %s", input.Amount)
+	}
+
+	return nil
+}
+
+// recordPayment records a payment of a billing, or money paid back for a
+// refund, which cannot exceed what is left to pay or to pay back. The
+// refunded billing is locked until the end of the transaction so that
+// concurrent payments see each other.
+func recordPayment(uow domain.UnitOfWork, billing *domain.Billing, input domain.PaymentInput) (*domain.Payment, error) {
+	if err := validatePayment(input); err != nil {
+		return nil, err
+	}
+
+	return addPayment(uow, billing, input)
+}
+
+// addPayment records a payment like recordPayment, with any method. It is
+// used directly for the payments made by redeeming a voucher.
+func addPayment(uow domain.UnitOfWork, billing *domain.Billing, input domain.PaymentInput) (*domain.Payment, error) {
+	if input.Amount.Currency != "" && input.Amount.Currency != billing.Price.Currency {
+		return nil, domain.NewValidationError("amount", "is in %s for a billing in %s", input.Amount.Currency, billing.Price.Currency)
+	}
+
+	paidID := billing.ID
+	if billing.Kind == domain.RefundBilling {
+		paidID = billing.RefundedBillingID
+	}
+
+	if _, err := uow.Billings().GetByIDForUpdate(paidID); err != nil {
+		return nil, err
+	}
+
+	balance, err := uow.Payments().GetBalance(paidID)
+	if err != nil {
+		return nil, err
+	}
+
+	if balance == nil {
+		return nil, fmt.Errorf("billing with ID %s: %w", paidID, domain.ErrNotFound)
+	}
+
+	// Money paid back is what the studio owes, recorded as negative
+	amount, left := money.New(input.Amount.Amount, billing.Price.Currency), balance.Outstanding
+	if billing.Kind == domain.RefundBilling {
+		left = left.Neg()
+	}
+
+	if amount.Amount > left.Amount {
+		return nil, fmt.Errorf("payment of %s with %s left: %w", amount, left, domain.ErrPaymentExceedsBalance)
+	}
+
+	if billing.Kind == domain.RefundBilling {
+		amount = amount.Neg()
+	}
+
+	paidAt := input.PaidAt
+	if paidAt.IsZero() {
+		paidAt = time.Now()
+	}
+
+	payment := &domain.Payment{
+		BillingID: billing.ID,
+		ClientID:  billing.ClientID,
+		Method:    input.Method,
+		Amount:    amount,
+		PaidAt:    paidAt,
+		Reference: input.Reference,
+	}
+
+	if err := uow.Payments().Create(payment); err != nil {
+		return nil, err
+	}
+
+	if err := record(uow, domain.AuditPayment, payment.ID, nil, payment); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("billingID", billing.ID).Str("method", string(payment.Method)).Str("amount", payment.Amount.String()).Msg("recorded payment")
+
+	return payment, nil
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// loginValidity is how long the code and link of a login email work
+	loginValidity = 15 * time.Minute
+	// maxLoginAttempts is how many wrong codes a login takes before it stops
+	// working
+	maxLoginAttempts = 5
+	// maxLoginRequests is how many login emails a client gets within
+	// loginValidity, so that codes cannot be guessed by asking for new ones
+	maxLoginRequests = 3
+)
+
+type portalService struct {
+	logins       domain.ClientLoginRepository
+	clients      domain.ClientService
+	credits      domain.CreditService
+	appointments domain.AppointmentService
+	billings     domain.BillingService
+	schedules    domain.ScheduleService
+	mailer       domain.Mailer
+	tx           domain.Transactor
+	portalURL    string
+	sessionTTL   time.Duration
+}
+
+// NewPortalService creates a new portal service. Login emails link to the
+// client portal at portalURL and sessions last sessionTTL from login.
+func NewPortalService(logins domain.ClientLoginRepository, clients domain.ClientService, credits domain.CreditService, appointments domain.AppointmentService, billings domain.BillingService,
+	schedules domain.ScheduleService, mailer domain.Mailer, tx domain.Transactor, portalURL string, sessionTTL time.Duration) domain.PortalService {
+	return &portalService{
+		logins:       logins,
+		clients:      clients,
+		credits:      credits,
+		appointments: appointments,
+		billings:     billings,
+		schedules:    schedules,
+		mailer:       mailer,
+		tx:           tx,
+		portalURL:    strings.TrimSuffix(portalURL, "/"),
+		sessionTTL:   sessionTTL,
+	}
+}
+
+// RequestLogin emails a login code and link to the client with an email.
+// Nothing tells whether the email belongs to a client.
+func (s *portalService) RequestLogin(input domain.PortalLoginInput) error {
+	client, err := s.clients.GetByEmail(strings.TrimSpace(input.Email))
+	if err != nil {
+		return err
+	}
+
+	if client == nil {
+		log.Info().Str("email", input.Email).Msg("portal login requested for an unknown email")
+		return nil
+	}
+
+	now := time.Now()
+	requests, err := s.logins.CountLoginsSince(client.ID, now.Add(-loginValidity))
+	if err != nil {
+		return err
+	}
+
+	if requests >= maxLoginRequests {
+		log.Warn().Str("clientID", client.ID).Int("requests", requests).Msg("too many portal login requests")
+		return nil
+	}
+
+	code, err := newLoginCode()
+	if err != nil {
+		return err
+	}
+
+	token := utils.NewToken(sessionTokenBytes)
+	login := &domain.ClientLogin{
+		ID:        utils.NewUUID(),
+		ClientID:  client.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(loginValidity),
+	}
+	login.CodeHash = hashCode(login.ID, code)
+
+	if err := s.logins.CreateLogin(login); err != nil {
+		return err
+	}
+
+	link := s.portalURL + "/login?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Bonjour %s,\n\n"+
+		"Votre code de connexion est %s. Il est valable %d minutes.\n\n"+
+		"Vous pouvez aussi vous connecter en suivant ce lien :\n%s\n\n"+
+		"Si vous n'êtes pas à l'origine de cette demande, ignorez ce message.\n",
+		client.FirstName, code, int(loginValidity.Minutes()), link)
+
+	return s.mailer.Send(client.Email, "Votre code de connexion", body)
+}
+
+// Verify opens a session for the client of a login, with the code and
+// email or with the token of the link. Wrong codes count as attempts even
+// though they fail.
+func (s *portalService) Verify(ctx context.Context, input domain.PortalVerifyInput) (*domain.ClientSession, error) {
+	now := time.Now()
+	token := utils.NewToken(sessionTokenBytes)
+	session := &domain.ClientSession{
+		Token:     token,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(s.sessionTTL),
+		CreatedAt: now,
+	}
+
+	valid := false
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		var login *domain.ClientLogin
+
+		switch {
+		case input.Token != "":
+			var err error
+			login, err = uow.ClientLogins().GetLoginByTokenForUpdate(hashToken(input.Token))
+			if err != nil || login == nil {
+				return err
+			}
+
+			if login.UsedAt != nil || !now.Before(login.ExpiresAt) || login.Attempts >= maxLoginAttempts {
+				return nil
+			}
+		case input.Email != "" && input.Code != "":
+			client, err := uow.Clients().GetByEmail(strings.TrimSpace(input.Email))
+			if err != nil || client == nil {
+				return err
+			}
+
+			login, err = uow.ClientLogins().GetPendingLoginForUpdate(client.ID, now)
+			if err != nil || login == nil {
+				return err
+			}
+
+			if login.Attempts >= maxLoginAttempts {
+				return nil
+			}
+
+			login.Attempts++
+			if subtle.ConstantTimeCompare([]byte(hashCode(login.ID, strings.TrimSpace(input.Code))), []byte(login.CodeHash)) != 1 {
+				return uow.ClientLogins().UpdateLogin(login)
+			}
+		default:
+			return nil
+		}
+
+		valid = true
+		login.UsedAt = &now
+		if err := uow.ClientLogins().UpdateLogin(login); err != nil {
+			return err
+		}
+
+		session.ClientID = login.ClientID
+		return uow.ClientLogins().CreateSession(session)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !valid {
+		return nil, domain.ErrInvalidLoginCode
+	}
+
+	session.Client, err = s.clients.GetByID(session.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Logout closes the session of a token
+func (s *portalService) Logout(token string) error {
+	return s.logins.DeleteSession(hashToken(token))
+}
+
+// Authenticate returns the client logged in with a session token
+func (s *portalService) Authenticate(token string) (*domain.Client, error) {
+	if token == "" {
+		return nil, domain.ErrInvalidSession
+	}
+
+	session, err := s.logins.GetSession(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil || !time.Now().Before(session.ExpiresAt) {
+		return nil, domain.ErrInvalidSession
+	}
+
+	client, err := s.clients.GetByID(session.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, domain.ErrInvalidSession
+	}
+
+	return client, nil
+}
+
+// GetCredits returns the credit balance of a client
+func (s *portalService) GetCredits(clientID string) (*domain.CreditBalance, error) {
+	return s.credits.GetBalance(clientID)
+}
+
+// GetUpcomingAppointments returns the upcoming appointments of a client
+func (s *portalService) GetUpcomingAppointments(clientID string) ([]domain.Appointment, error) {
+	return s.appointments.GetUpcomingByClient(clientID)
+}
+
+// GetBillings returns the billings of a client
+func (s *portalService) GetBillings(clientID string) ([]domain.Billing, error) {
+	return s.billings.GetByClientID(clientID)
+}
+
+// GetSchedule returns the classes of the week of a date
+func (s *portalService) GetSchedule(date time.Time) ([]domain.Schedule, error) {
+	return s.schedules.GetByWeek(date)
+}
+
+// Book books a class for a client, debiting a credit as staff bookings do
+func (s *portalService) Book(ctx context.Context, clientID string, input domain.PortalBookingInput) (*domain.Appointment, error) {
+	appointment := &domain.Appointment{
+		ScheduleID: input.ScheduleID,
+		ClientID:   clientID,
+	}
+
+	if err := s.appointments.Create(ctx, appointment); err != nil {
+		return nil, err
+	}
+
+	return appointment, nil
+}
+
+// Cancel cancels an appointment of a client under the cancellation policy.
+// It returns nil when the appointment does not exist or belongs to another
+// client.
+func (s *portalService) Cancel(ctx context.Context, clientID string, appointmentID string) (*domain.Cancellation, error) {
+	appointment, err := s.appointments.GetByID(appointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if appointment == nil || appointment.ClientID != clientID {
+		return nil, nil
+	}
+
+	return s.appointments.Cancel(ctx, appointmentID, domain.CancellationInput{})
+}
+
+// PurgeExpired deletes the expired logins and sessions and returns how many
+// were
+func (s *portalService) PurgeExpired() (int64, error) {
+	return s.logins.DeleteExpired(time.Now())
+}
+
+// newLoginCode returns a random six digit login code
+func newLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate login code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashCode returns the hash of the code of a login as stored, salted with
+// the login ID
+func hashCode(loginID string, code string) string {
+	return hashToken(loginID + ":" + code)
+}
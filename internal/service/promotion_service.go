@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+)
+
+type promotionService struct {
+	repo domain.PromotionRepository
+	tx   domain.Transactor
+}
+
+// NewPromotionService creates a new promotion service
+func NewPromotionService(repo domain.PromotionRepository, tx domain.Transactor) domain.PromotionService {
+	return &promotionService{
+		repo: repo,
+		tx:   tx,
+	}
+}
+
+// GetAll returns all promotions
+func (s *promotionService) GetAll() ([]domain.Promotion, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns a promotion by ID
+func (s *promotionService) GetByID(id string) (*domain.Promotion, error) {
+	return s.repo.GetByID(id)
+}
+
+// Create creates a new promotion
+func (s *promotionService) Create(ctx context.Context, input domain.PromotionInput) (*domain.Promotion, error) {
+	promotion, err := newPromotion(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := checkPromotion(uow, promotion); err != nil {
+			return err
+		}
+
+		if err := uow.Promotions().Create(promotion); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditPromotion, promotion.ID, nil, promotion)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(promotion.ID)
+}
+
+// Update updates an existing promotion. Billings that already used it keep
+// their discount.
+func (s *promotionService) Update(ctx context.Context, id string, input domain.PromotionInput) (*domain.Promotion, error) {
+	promotion, err := newPromotion(input)
+	if err != nil {
+		return nil, err
+	}
+	promotion.ID = id
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if promotion exists
+		existingPromotion, err := uow.Promotions().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if existingPromotion == nil {
+			return fmt.Errorf("promotion with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		if err := checkPromotion(uow, promotion); err != nil {
+			return err
+		}
+
+		if err := uow.Promotions().Update(promotion); err != nil {
+			return err
+		}
+
+		updated, err := uow.Promotions().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditPromotion, id, existingPromotion, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(id)
+}
+
+// newPromotion validates an input and builds the promotion it describes
+func newPromotion(input domain.PromotionInput) (*domain.Promotion, error) {
+	code := normalizeCode(input.Code)
+	if code == "" {
+		return nil, domain.NewValidationError("code", "is required")
+	}
+
+	value := input.Value
+	if value.Currency == "" {
+		value = money.New(value.Amount, money.EUR)
+	}
+
+	switch input.DiscountType {
+	case domain.PercentageDiscount:
+		if input.Rate <= 0 || input.Rate > 10000 {
+			return nil, domain.NewValidationError("rate", "must be between 1 and 10000 basis points: %d", input.Rate)
+		}
+		value = money.New(0, value.Currency)
+	case domain.FixedDiscount:
+		if value.Amount <= 0 {
+			return nil, domain.NewValidationError("value", "must be positive: %s", value)
+		}
+		input.Rate = 0
+	default:
+		return nil, domain.NewValidationError("discount_type", "is unknown: %s", input.DiscountType)
+	}
+
+	if input.ValidFrom != nil && input.ValidUntil != nil && !input.ValidUntil.After(*input.ValidFrom) {
+		return nil, domain.NewValidationError("valid_until", "is before valid_from: %s", input.ValidUntil.Format(time.RFC3339))
+	}
+
+	if input.MaxUses < 0 || input.MaxUsesPerClient < 0 {
+		return nil, domain.NewValidationError("max_uses", "cannot be negative")
+	}
+
+	active := true
+	if input.Active != nil {
+		active = *input.Active
+	}
+
+	packageIDs := input.PackageIDs
+	if packageIDs == nil {
+		packageIDs = []string{}
+	}
+
+	return &domain.Promotion{
+		Code:             code,
+		Description:      input.Description,
+		DiscountType:     input.DiscountType,
+		Rate:             input.Rate,
+		Value:            value,
+		ValidFrom:        input.ValidFrom,
+		ValidUntil:       input.ValidUntil,
+		MaxUses:          input.MaxUses,
+		MaxUsesPerClient: input.MaxUsesPerClient,
+		PackageIDs:       packageIDs,
+		Active:           active,
+	}, nil
+}
+
+// checkPromotion checks that the code of a promotion is not used by another
+// one and that its packages exist
+func checkPromotion(uow domain.UnitOfWork, promotion *domain.Promotion) error {
+	existingPromotion, err := uow.Promotions().GetByCode(promotion.Code)
+	if err != nil {
+		return err
+	}
+
+	if existingPromotion != nil && existingPromotion.ID != promotion.ID {
+		return fmt.Errorf("code %s: %w", promotion.Code, domain.ErrPromotionCodeExists)
+	}
+
+	for _, packageID := range promotion.PackageIDs {
+		pkg, err := uow.Packages().GetByID(packageID)
+		if err != nil {
+			return err
+		}
+
+		if pkg == nil {
+			return fmt.Errorf("package with ID %s: %w", packageID, domain.ErrNotFound)
+		}
+	}
+
+	return nil
+}
+
+// applyPromotion discounts a billing with the promotion of a code, checking
+// that it is active and valid at the payment date, that it applies to the
+// package and that its usage limits are not reached. The promotion row is
+// locked so concurrent billings cannot go over its limits.
+func applyPromotion(uow domain.UnitOfWork, billing *domain.Billing, code string) error {
+	promotion, err := uow.Promotions().GetByCodeForUpdate(normalizeCode(code))
+	if err != nil {
+		return err
+	}
+
+	if promotion == nil {
+		return fmt.Errorf("code %s: %w", code, domain.ErrUnknownPromotion)
+	}
+
+	paidAt := billing.PaymentDate
+	if paidAt.IsZero() {
+		paidAt = time.Now()
+	}
+
+	if !promotion.Active {
+		return fmt.Errorf("promotion %s is inactive: %w", promotion.Code, domain.ErrPromotionNotApplicable)
+	}
+
+	if !promotion.ValidAt(paidAt) {
+		return fmt.Errorf("promotion %s is not valid on %s: %w", promotion.Code, paidAt.Format(time.DateOnly), domain.ErrPromotionNotApplicable)
+	}
+
+	if !promotion.AppliesTo(billing.PackageID) {
+		return fmt.Errorf("promotion %s does not apply to package %s: %w", promotion.Code, billing.PackageID, domain.ErrPromotionNotApplicable)
+	}
+
+	if promotion.DiscountType == domain.FixedDiscount && promotion.Value.Currency != billing.OriginalPrice.Currency {
+		return fmt.Errorf("promotion %s in %s for a price in %s: %w", promotion.Code, promotion.Value.Currency, billing.OriginalPrice.Currency, domain.ErrPromotionNotApplicable)
+	}
+
+	if err := checkPromotionUses(uow, promotion, billing.ClientID, billing.ID); err != nil {
+		return err
+	}
+
+	billing.PromotionID = promotion.ID
+	billing.Discount = promotion.DiscountOn(billing.OriginalPrice)
+	billing.Price = billing.OriginalPrice.Sub(billing.Discount)
+	billing.NetPrice, billing.VAT = billing.Price.SplitVAT(billing.VATRate)
+
+	return nil
+}
+
+// checkPromotionUses checks that a locked promotion has not reached its
+// usage limits, in total or for a client, leaving out a billing being
+// updated
+func checkPromotionUses(uow domain.UnitOfWork, promotion *domain.Promotion, clientID string, billingID string) error {
+	total, byClient, err := uow.Promotions().CountUses(promotion.ID, clientID, billingID)
+	if err != nil {
+		return err
+	}
+
+	if promotion.MaxUses > 0 && total >= promotion.MaxUses {
+		return fmt.Errorf("promotion %s used %d times out of %d: %w", promotion.Code, total, promotion.MaxUses, domain.ErrPromotionNotApplicable)
+	}
+
+	if promotion.MaxUsesPerClient > 0 && byClient >= promotion.MaxUsesPerClient {
+		return fmt.Errorf("promotion %s used %d times by client %s out of %d: %w", promotion.Code, byClient, clientID, promotion.MaxUsesPerClient, domain.ErrPromotionNotApplicable)
+	}
+
+	return nil
+}
+
+// normalizeCode returns a promotion code as stored, trimmed and upper case
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
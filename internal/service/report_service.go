@@ -0,0 +1,78 @@
+package service
+
+import "github.com/matthieukhl/align-back/internal/domain"
+
+type reportService struct {
+	repo domain.ReportRepository
+}
+
+// NewReportService creates a new report service
+func NewReportService(repo domain.ReportRepository) domain.ReportService {
+	return &reportService{
+		repo: repo,
+	}
+}
+
+// GetRevenueByMonth returns the revenue billed per month
+func (s *reportService) GetRevenueByMonth(filter domain.ReportFilter) ([]domain.MonthlyRevenue, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevenueByMonth(filter)
+}
+
+// GetRevenueByLocation returns the value of the sessions booked per location
+func (s *reportService) GetRevenueByLocation(filter domain.ReportFilter) ([]domain.LocationRevenue, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevenueByLocation(filter)
+}
+
+// GetRevenueByPackageType returns the revenue billed per package type
+func (s *reportService) GetRevenueByPackageType(filter domain.ReportFilter) ([]domain.PackageTypeRevenue, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevenueByPackageType(filter)
+}
+
+// GetRevenueByPaymentMethod returns the money collected per payment method
+func (s *reportService) GetRevenueByPaymentMethod(filter domain.ReportFilter) ([]domain.PaymentMethodRevenue, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevenueByPaymentMethod(filter)
+}
+
+// GetSessionCounts returns the sessions and bookings per month
+func (s *reportService) GetSessionCounts(filter domain.ReportFilter) ([]domain.SessionCount, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetSessionCounts(filter)
+}
+
+// GetFillRateByClass returns the fill rate of each class
+func (s *reportService) GetFillRateByClass(filter domain.ReportFilter) ([]domain.ClassFillRate, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetFillRateByClass(filter)
+}
+
+// GetFillRateBySlot returns the fill rate of each weekday and time of day
+func (s *reportService) GetFillRateBySlot(filter domain.ReportFilter) ([]domain.SlotFillRate, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetFillRateBySlot(filter)
+}
+
+// validateFilter checks that a report range is not reversed
+func validateFilter(filter domain.ReportFilter) error {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return domain.NewValidationError("to", "must be after from: %s to %s", filter.From.Format("2006-01-02"), filter.To.Format("2006-01-02"))
+	}
+	return nil
+}
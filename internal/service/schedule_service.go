@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+)
+
+type scheduleService struct {
+	repo      domain.ScheduleRepository
+	classRepo domain.ClassRepository
+	tx        domain.Transactor
+}
+
+// NewScheduleService creates a new schedule service
+func NewScheduleService(repo domain.ScheduleRepository, classRepo domain.ClassRepository, tx domain.Transactor) domain.ScheduleService {
+	return &scheduleService{
+		repo:      repo,
+		classRepo: classRepo,
+		tx:        tx,
+	}
+}
+
+// GetAll returns all schedules
+func (s *scheduleService) GetAll() ([]domain.Schedule, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns a schedule by ID
+func (s *scheduleService) GetByID(id string) (*domain.Schedule, error) {
+	return s.repo.GetByID(id)
+}
+
+// GetByDate returns the schedules of a given day
+func (s *scheduleService) GetByDate(date time.Time) ([]domain.Schedule, error) {
+	return s.repo.GetByDate(date)
+}
+
+// GetByWeek returns the schedules of the ISO week (Monday to Sunday) containing date
+func (s *scheduleService) GetByWeek(date time.Time) ([]domain.Schedule, error) {
+	start := weekStart(date)
+	return s.repo.GetByDateRange(start, start.AddDate(0, 0, 7))
+}
+
+// GetByClass returns the schedules of a given class
+func (s *scheduleService) GetByClass(classID string) ([]domain.Schedule, error) {
+	return s.repo.GetByClass(classID)
+}
+
+// GetUpcoming returns the next schedules
+func (s *scheduleService) GetUpcoming(limit int) ([]domain.Schedule, error) {
+	return s.repo.GetUpcoming(limit)
+}
+
+// GetWithDetails returns a schedule with its class and booking count
+func (s *scheduleService) GetWithDetails(id string) (*domain.ScheduleWithDetails, error) {
+	return s.repo.GetWithDetails(id)
+}
+
+// GetAllWithDetails returns all schedules with their class and booking count
+func (s *scheduleService) GetAllWithDetails() ([]domain.ScheduleWithDetails, error) {
+	return s.repo.GetAllWithDetails()
+}
+
+// EachWithDetails calls fn with every schedule and its class and booking
+// count, one at a time
+func (s *scheduleService) EachWithDetails(fn func(*domain.ScheduleWithDetails) error) error {
+	return s.repo.EachWithDetails(fn)
+}
+
+// Create creates a new schedule
+func (s *scheduleService) Create(ctx context.Context, input domain.ScheduleInput) (*domain.Schedule, error) {
+	if input.Capacity < 1 {
+		return nil, domain.NewValidationError("capacity", "cannot be less than 1: %d", input.Capacity)
+	}
+
+	// Check if class exists
+	class, err := s.classRepo.GetByID(input.ClassID)
+	if err != nil {
+		return nil, err
+	}
+
+	if class == nil {
+		return nil, fmt.Errorf("class with ID %s: %w", input.ClassID, domain.ErrNotFound)
+	}
+
+	// Create a new schedule
+	schedule := &domain.Schedule{
+		ClassID:       input.ClassID,
+		Capacity:      input.Capacity,
+		ClassDatetime: input.ClassDatetime,
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Schedules().Create(schedule); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditSchedule, schedule.ID, nil, schedule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the created schedule to return with all fields
+	return s.repo.GetByID(schedule.ID)
+}
+
+// Update updates an existing schedule. When the capacity is increased,
+// waitlisted clients are promoted into the new slots.
+func (s *scheduleService) Update(ctx context.Context, id string, input domain.ScheduleInput) (*domain.Schedule, error) {
+	if input.Capacity < 1 {
+		return nil, domain.NewValidationError("capacity", "cannot be less than 1: %d", input.Capacity)
+	}
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if schedule exists
+		existingSchedule, err := uow.Schedules().GetByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if existingSchedule == nil {
+			return fmt.Errorf("schedule with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		// Check if the new class exists
+		if existingSchedule.ClassID != input.ClassID {
+			class, err := uow.Classes().GetByID(input.ClassID)
+			if err != nil {
+				return err
+			}
+
+			if class == nil {
+				return fmt.Errorf("class with ID %s: %w", input.ClassID, domain.ErrNotFound)
+			}
+		}
+
+		// Capacity cannot drop below the number of booked clients
+		count, err := uow.Appointments().CountBySchedule(id)
+		if err != nil {
+			return err
+		}
+
+		if input.Capacity < count {
+			return fmt.Errorf("capacity %d is below the %d booked appointments: %w", input.Capacity, count, domain.ErrConflict)
+		}
+
+		// Update schedule. An occurrence of a series edited on its own is
+		// detached so that series updates leave it untouched.
+		schedule := &domain.Schedule{
+			ID:            id,
+			ClassID:       input.ClassID,
+			Capacity:      input.Capacity,
+			ClassDatetime: input.ClassDatetime,
+			Detached:      existingSchedule.Detached || existingSchedule.SeriesID != "",
+		}
+
+		if err := uow.Schedules().Update(schedule); err != nil {
+			return err
+		}
+
+		updated, err := uow.Schedules().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditSchedule, id, existingSchedule, updated); err != nil {
+			return err
+		}
+
+		if input.Capacity > existingSchedule.Capacity {
+			return promoteWaitlist(uow, id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the updated schedule to return with all fields
+	return s.repo.GetByID(id)
+}
+
+// Delete deletes a schedule without appointments, which must be cancelled
+// first so their credits are refunded. Deleting an occurrence of a series
+// excludes its date from the series so it is not generated again.
+func (s *scheduleService) Delete(ctx context.Context, id string) error {
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if schedule exists, locking it against new bookings
+		existingSchedule, err := uow.Schedules().GetByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if existingSchedule == nil {
+			return fmt.Errorf("schedule with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		count, err := uow.Appointments().CountBySchedule(id)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return fmt.Errorf("schedule %s (%d appointments): %w", id, count, domain.ErrScheduleBooked)
+		}
+
+		if existingSchedule.SeriesID != "" && existingSchedule.OccurrenceDate != "" {
+			series, err := uow.Series().GetByID(existingSchedule.SeriesID)
+			if err != nil {
+				return err
+			}
+
+			if series != nil {
+				before := *series
+				series.ExDates = append(slices.Clone(series.ExDates), existingSchedule.OccurrenceDate)
+				if err := uow.Series().Update(series); err != nil {
+					return err
+				}
+
+				if err := record(uow, domain.AuditSeries, series.ID, &before, series); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := uow.Schedules().Delete(id); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditSchedule, id, existingSchedule, nil)
+	})
+}
+
+// weekStart returns midnight on the Monday of the ISO week containing date
+func weekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7 // Monday = 0, Sunday = 6
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/rrule"
+	"github.com/rs/zerolog/log"
+)
+
+// dateLayout is the format of occurrence and exclusion dates
+const dateLayout = "2006-01-02"
+
+type seriesService struct {
+	repo         domain.ScheduleSeriesRepository
+	scheduleRepo domain.ScheduleRepository
+	classRepo    domain.ClassRepository
+	tx           domain.Transactor
+	location     *time.Location
+	horizon      time.Duration
+}
+
+// NewScheduleSeriesService creates a new schedule series service. Occurrences
+// are generated in location, horizonDays ahead of now.
+func NewScheduleSeriesService(repo domain.ScheduleSeriesRepository, scheduleRepo domain.ScheduleRepository, classRepo domain.ClassRepository, tx domain.Transactor, location *time.Location, horizonDays int) domain.ScheduleSeriesService {
+	return &seriesService{
+		repo:         repo,
+		scheduleRepo: scheduleRepo,
+		classRepo:    classRepo,
+		tx:           tx,
+		location:     location,
+		horizon:      time.Duration(horizonDays) * 24 * time.Hour,
+	}
+}
+
+// GetAll returns all series
+func (s *seriesService) GetAll() ([]domain.ScheduleSeries, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns a series by ID
+func (s *seriesService) GetByID(id string) (*domain.ScheduleSeries, error) {
+	return s.repo.GetByID(id)
+}
+
+// GetOccurrences returns the schedules generated from a series
+func (s *seriesService) GetOccurrences(id string) ([]domain.Schedule, error) {
+	return s.scheduleRepo.GetBySeries(id)
+}
+
+// Create creates a new series and generates its occurrences up to the horizon
+func (s *seriesService) Create(ctx context.Context, input domain.ScheduleSeriesInput) (*domain.ScheduleSeries, error) {
+	series, err := s.newSeries(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if class exists
+	class, err := s.classRepo.GetByID(input.ClassID)
+	if err != nil {
+		return nil, err
+	}
+
+	if class == nil {
+		return nil, fmt.Errorf("class with ID %s: %w", input.ClassID, domain.ErrNotFound)
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Series().Create(series); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditSeries, series.ID, nil, series); err != nil {
+			return err
+		}
+
+		_, err := s.generate(uow, series, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(series.ID)
+}
+
+// Update updates a series. Upcoming occurrences that are neither booked nor
+// edited individually are regenerated with the new settings; the others are
+// left untouched. With input.From set, the series is split: the original one
+// ends at From and a new series carries the changes from then on.
+func (s *seriesService) Update(ctx context.Context, id string, input domain.ScheduleSeriesUpdateInput) (*domain.ScheduleSeries, error) {
+	updated, err := s.newSeries(input.ScheduleSeriesInput)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if series exists
+		existingSeries, err := uow.Series().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if existingSeries == nil {
+			return fmt.Errorf("schedule series with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		// Check if the new class exists
+		if existingSeries.ClassID != input.ClassID {
+			class, err := uow.Classes().GetByID(input.ClassID)
+			if err != nil {
+				return err
+			}
+
+			if class == nil {
+				return fmt.Errorf("class with ID %s: %w", input.ClassID, domain.ErrNotFound)
+			}
+		}
+
+		now := time.Now()
+
+		// Whole series
+		if input.From == nil || !input.From.After(existingSeries.DTStart) {
+			if err := uow.Schedules().DeleteUnbookedBySeries(id, now); err != nil {
+				return err
+			}
+
+			updated.ID = id
+			updated.EndsAt = existingSeries.EndsAt
+			if input.ExDates == nil {
+				updated.ExDates = existingSeries.ExDates
+			}
+
+			if err := uow.Series().Update(updated); err != nil {
+				return err
+			}
+
+			if err := record(uow, domain.AuditSeries, id, existingSeries, updated); err != nil {
+				return err
+			}
+
+			_, err := s.generate(uow, updated, now)
+			return err
+		}
+
+		// This and following
+		from := time.Date(input.From.Year(), input.From.Month(), input.From.Day(), 0, 0, 0, 0, s.location)
+		if updated.DTStart.Before(from) {
+			return domain.NewValidationError("from", "is after the start %s: %s", updated.DTStart.Format(time.RFC3339), from.Format(dateLayout))
+		}
+
+		pivot := from
+		if pivot.Before(now) {
+			pivot = now
+		}
+
+		if err := uow.Schedules().DeleteUnbookedBySeries(id, pivot); err != nil {
+			return err
+		}
+
+		ended := *existingSeries
+		ended.EndsAt = &from
+		if err := uow.Series().Update(&ended); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditSeries, id, existingSeries, &ended); err != nil {
+			return err
+		}
+
+		// Days keeping a booked occurrence of the original series are skipped
+		occurrences, err := uow.Schedules().GetBySeries(id)
+		if err != nil {
+			return err
+		}
+
+		for _, occurrence := range occurrences {
+			if !occurrence.ClassDatetime.Before(from) && occurrence.OccurrenceDate != "" {
+				updated.ExDates = append(updated.ExDates, occurrence.OccurrenceDate)
+			}
+		}
+
+		if err := uow.Series().Create(updated); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditSeries, updated.ID, nil, updated); err != nil {
+			return err
+		}
+
+		_, err = s.generate(uow, updated, pivot)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(updated.ID)
+}
+
+// Delete deletes a series and its upcoming unbooked occurrences. Booked and
+// past occurrences are kept as standalone schedules.
+func (s *seriesService) Delete(ctx context.Context, id string) error {
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Check if series exists
+		existingSeries, err := uow.Series().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if existingSeries == nil {
+			return fmt.Errorf("schedule series with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		if err := uow.Schedules().DeleteUnbookedBySeries(id, time.Now()); err != nil {
+			return err
+		}
+
+		if err := uow.Series().Delete(id); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditSeries, id, existingSeries, nil)
+	})
+}
+
+// GenerateAll extends every series up to the rolling horizon and returns the
+// number of schedules created
+func (s *seriesService) GenerateAll(ctx context.Context) (int, error) {
+	allSeries, err := s.repo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	var lastErr error
+
+	for i := range allSeries {
+		series := &allSeries[i]
+
+		err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+			created, err := s.generate(uow, series, time.Now())
+			total += created
+			return err
+		})
+		if err != nil {
+			log.Error().Err(err).Str("seriesID", series.ID).Msg("failed to generate series occurrences")
+			lastErr = err
+		}
+	}
+
+	return total, lastErr
+}
+
+// generate creates the occurrences of a series from a date up to the horizon,
+// skipping excluded days and days that already have an occurrence.
+// Occurrences follow from their series and are only audited through it.
+func (s *seriesService) generate(uow domain.UnitOfWork, series *domain.ScheduleSeries, from time.Time) (int, error) {
+	rule, err := rrule.Parse(series.RRule)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rule for series %s: %w", series.ID, err)
+	}
+
+	before := time.Now().Add(s.horizon)
+	if series.EndsAt != nil && series.EndsAt.Before(before) {
+		before = *series.EndsAt
+	}
+
+	existing, err := uow.Schedules().GetBySeries(series.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	skip := make(map[string]bool, len(existing)+len(series.ExDates))
+	for _, schedule := range existing {
+		skip[schedule.OccurrenceDate] = true
+	}
+	for _, date := range series.ExDates {
+		skip[date] = true
+	}
+
+	created := 0
+	for _, occurrence := range rule.Between(series.DTStart.In(s.location), from, before) {
+		day := occurrence.Format(dateLayout)
+		if skip[day] {
+			continue
+		}
+
+		schedule := &domain.Schedule{
+			ClassID:        series.ClassID,
+			Capacity:       series.Capacity,
+			ClassDatetime:  occurrence,
+			SeriesID:       series.ID,
+			OccurrenceDate: day,
+		}
+
+		if err := uow.Schedules().Create(schedule); err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	return created, nil
+}
+
+// newSeries validates an input and builds the series it describes
+func (s *seriesService) newSeries(input domain.ScheduleSeriesInput) (*domain.ScheduleSeries, error) {
+	if input.Capacity < 1 {
+		return nil, domain.NewValidationError("capacity", "cannot be less than 1: %d", input.Capacity)
+	}
+
+	if input.Start.IsZero() {
+		return nil, domain.NewValidationError("start", "is required")
+	}
+
+	for _, date := range input.ExDates {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return nil, domain.NewValidationError("exdates", "has invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+
+	rule, err := s.buildRule(input)
+	if err != nil {
+		return nil, err
+	}
+
+	exDates := input.ExDates
+	if exDates == nil {
+		exDates = []string{}
+	}
+
+	return &domain.ScheduleSeries{
+		ClassID:  input.ClassID,
+		Capacity: input.Capacity,
+		DTStart:  input.Start,
+		RRule:    rule.String(),
+		ExDates:  exDates,
+	}, nil
+}
+
+// buildRule returns the recurrence rule of an input, either parsed from its
+// RRULE or built as a weekly rule from its weekdays, end date and count
+func (s *seriesService) buildRule(input domain.ScheduleSeriesInput) (*rrule.Rule, error) {
+	if input.RRule != "" {
+		rule, err := rrule.Parse(input.RRule)
+		if err != nil {
+			return nil, domain.NewValidationError("rrule", "%v", err)
+		}
+		return rule, nil
+	}
+
+	if len(input.Weekdays) == 0 {
+		return nil, domain.NewValidationError("rrule", "or weekdays is required")
+	}
+
+	parts := []string{"FREQ=WEEKLY", "BYDAY=" + strings.ToUpper(strings.Join(input.Weekdays, ","))}
+
+	if input.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", input.Count))
+	}
+
+	if input.End != nil {
+		// The end date is inclusive in the studio time zone
+		end := time.Date(input.End.Year(), input.End.Month(), input.End.Day(), 23, 59, 59, 0, s.location)
+		parts = append(parts, "UNTIL="+end.UTC().Format("20060102T150405Z"))
+	}
+
+	rule, err := rrule.Parse(strings.Join(parts, ";"))
+	if err != nil {
+		return nil, domain.NewValidationError("rrule", "%v", err)
+	}
+
+	return rule, nil
+}
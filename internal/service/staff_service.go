@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// minPasswordLength is the shortest password accepted for staff
+	minPasswordLength = 10
+	// maxPasswordLength is the longest password bcrypt hashes
+	maxPasswordLength = 72
+	// sessionTokenBytes is the size of the random session tokens
+	sessionTokenBytes = 32
+)
+
+// dummyHash is checked against when logging in with an unknown or inactive
+// account, so that it takes as long as with a valid one
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(utils.NewToken(sessionTokenBytes)), bcrypt.DefaultCost)
+	return hash
+})
+
+type staffService struct {
+	repo       domain.StaffRepository
+	tx         domain.Transactor
+	sessionTTL time.Duration
+}
+
+// NewStaffService creates a new staff service. Sessions last sessionTTL
+// from login.
+func NewStaffService(repo domain.StaffRepository, tx domain.Transactor, sessionTTL time.Duration) domain.StaffService {
+	return &staffService{
+		repo:       repo,
+		tx:         tx,
+		sessionTTL: sessionTTL,
+	}
+}
+
+// GetAll returns all staff users
+func (s *staffService) GetAll() ([]domain.StaffUser, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns a staff user by ID
+func (s *staffService) GetByID(id string) (*domain.StaffUser, error) {
+	return s.repo.GetByID(id)
+}
+
+// Create creates a new staff account
+func (s *staffService) Create(ctx context.Context, input domain.StaffInput) (*domain.StaffUser, error) {
+	if err := validateStaff(input, true); err != nil {
+		return nil, err
+	}
+
+	email := normalizeEmail(input.Email)
+
+	// Check if email is already used
+	existingUser, err := s.repo.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if existingUser != nil {
+		return nil, fmt.Errorf("email %s: %w", email, domain.ErrStaffEmailExists)
+	}
+
+	hash, err := hashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.StaffUser{
+		Email:        email,
+		Name:         input.Name,
+		Role:         input.Role,
+		PasswordHash: hash,
+		Active:       input.Active == nil || *input.Active,
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Staff().Create(user); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditStaff, user.ID, nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(user.ID)
+}
+
+// Update updates a staff account. Changing the role or password, or
+// deactivating the account, logs it out everywhere. The last active owner
+// cannot be demoted nor deactivated.
+func (s *staffService) Update(ctx context.Context, id string, input domain.StaffInput) (*domain.StaffUser, error) {
+	if err := validateStaff(input, false); err != nil {
+		return nil, err
+	}
+
+	// Check if user exists
+	existingUser, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if existingUser == nil {
+		return nil, nil
+	}
+
+	email := normalizeEmail(input.Email)
+
+	// Check if email is already in use by another user
+	if existingUser.Email != email {
+		userWithEmail, err := s.repo.GetByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+
+		if userWithEmail != nil && userWithEmail.ID != id {
+			return nil, fmt.Errorf("email %s: %w", email, domain.ErrStaffEmailExists)
+		}
+	}
+
+	user := *existingUser
+	user.Email = email
+	user.Name = input.Name
+	user.Role = input.Role
+	if input.Active != nil {
+		user.Active = *input.Active
+	}
+
+	if input.Password != "" {
+		user.PasswordHash, err = hashPassword(input.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if existingUser.Active && existingUser.Role == domain.OwnerRole && (!user.Active || user.Role != domain.OwnerRole) {
+		if err := s.checkOtherOwner(id); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Staff().Update(&user); err != nil {
+			return err
+		}
+
+		if !user.Active || user.Role != existingUser.Role || input.Password != "" {
+			if err := uow.Staff().DeleteSessions(id); err != nil {
+				return err
+			}
+		}
+
+		updated, err := uow.Staff().GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditStaff, id, existingUser, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(id)
+}
+
+// Login opens a session for a staff member with their email and password
+func (s *staffService) Login(input domain.LoginInput) (*domain.StaffSession, error) {
+	user, err := s.repo.GetByEmail(normalizeEmail(input.Email))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.Active {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(input.Password))
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	token := utils.NewToken(sessionTokenBytes)
+	session := &domain.StaffSession{
+		Token:     token,
+		TokenHash: hashToken(token),
+		StaffID:   user.ID,
+		ExpiresAt: now.Add(s.sessionTTL),
+		CreatedAt: now,
+		Staff:     user,
+	}
+
+	if err := s.repo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateLastLogin(user.ID, now); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Logout closes the session of a token
+func (s *staffService) Logout(token string) error {
+	return s.repo.DeleteSession(hashToken(token))
+}
+
+// Authenticate returns the staff member logged in with a session token
+func (s *staffService) Authenticate(token string) (*domain.StaffUser, error) {
+	if token == "" {
+		return nil, domain.ErrInvalidSession
+	}
+
+	session, err := s.repo.GetSession(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil || !time.Now().Before(session.ExpiresAt) {
+		return nil, domain.ErrInvalidSession
+	}
+
+	user, err := s.repo.GetByID(session.StaffID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.Active {
+		return nil, domain.ErrInvalidSession
+	}
+
+	return user, nil
+}
+
+// PurgeSessions deletes the expired sessions and returns how many were
+func (s *staffService) PurgeSessions() (int64, error) {
+	return s.repo.DeleteExpiredSessions(time.Now())
+}
+
+// checkOtherOwner fails unless an active owner other than id remains
+func (s *staffService) checkOtherOwner(id string) error {
+	users, err := s.repo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user.ID != id && user.Active && user.Role == domain.OwnerRole {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("staff %s is the last active owner: %w", id, domain.ErrLastOwner)
+}
+
+// validateStaff checks the fields of a staff input. An empty password is
+// only valid when not required.
+func validateStaff(input domain.StaffInput, passwordRequired bool) error {
+	if strings.TrimSpace(input.Email) == "" {
+		return domain.NewValidationError("email", "is required")
+	}
+
+	if strings.TrimSpace(input.Name) == "" {
+		return domain.NewValidationError("name", "is required")
+	}
+
+	switch input.Role {
+	case domain.OwnerRole, domain.FrontDeskRole, domain.InstructorRole:
+	default:
+		return domain.NewValidationError("role", "is invalid: %s", input.Role)
+	}
+
+	if (passwordRequired || input.Password != "") && len(input.Password) < minPasswordLength {
+		return domain.NewValidationError("password", "must be at least %d characters long", minPasswordLength)
+	}
+
+	if len(input.Password) > maxPasswordLength {
+		return domain.NewValidationError("password", "must be at most %d bytes long", maxPasswordLength)
+	}
+
+	return nil
+}
+
+// hashPassword returns the bcrypt hash of a password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// hashToken returns the SHA-256 of a token as stored, so that tokens leaked
+// from the database cannot be used
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeEmail returns an email as stored, trimmed and lower case
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/rs/zerolog/log"
+)
+
+type subscriptionService struct {
+	repo     domain.SubscriptionRepository
+	tx       domain.Transactor
+	issuer   domain.InvoiceIssuer
+	location *time.Location
+}
+
+// NewSubscriptionService creates a new subscription service. Periods follow
+// the calendar in location and their invoices are issued by issuer.
+func NewSubscriptionService(repo domain.SubscriptionRepository, tx domain.Transactor, issuer domain.InvoiceIssuer, location *time.Location) domain.SubscriptionService {
+	return &subscriptionService{
+		repo:     repo,
+		tx:       tx,
+		issuer:   issuer,
+		location: location,
+	}
+}
+
+// GetAll returns all subscriptions
+func (s *subscriptionService) GetAll() ([]domain.Subscription, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns a subscription by ID
+func (s *subscriptionService) GetByID(id string) (*domain.Subscription, error) {
+	return s.repo.GetByID(id)
+}
+
+// GetByClient returns the subscriptions of a client
+func (s *subscriptionService) GetByClient(clientID string) ([]domain.Subscription, error) {
+	return s.repo.GetByClient(clientID)
+}
+
+// GetRenewals returns the subscriptions renewing within a number of days,
+// with the price of their next period
+func (s *subscriptionService) GetRenewals(days int) ([]domain.SubscriptionRenewal, error) {
+	if days < 0 {
+		return nil, domain.NewValidationError("days", "cannot be negative: %d", days)
+	}
+
+	renewals, err := s.repo.GetRenewals(time.Now().AddDate(0, 0, days))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range renewals {
+		months := renewals[i].Subscription.Period.Months()
+		renewals[i].Price = renewals[i].Package.Price.Mul(int64(months))
+	}
+
+	return renewals, nil
+}
+
+// Create subscribes a client to a plan and bills its first period
+func (s *subscriptionService) Create(ctx context.Context, input domain.SubscriptionInput) (*domain.Subscription, error) {
+	if input.Period == "" {
+		input.Period = domain.MonthlyPeriod
+	}
+
+	if input.Period.Months() == 0 {
+		return nil, domain.NewValidationError("period", "is unknown: %s", input.Period)
+	}
+
+	start := input.StartDate
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	subscription := &domain.Subscription{
+		ClientID:           input.ClientID,
+		PackageID:          input.PackageID,
+		Status:             domain.ActiveSubscription,
+		Period:             input.Period,
+		StartDate:          start,
+		CurrentPeriodStart: start,
+	}
+	subscription.CurrentPeriodEnd = s.periodEnd(subscription, start)
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		// Locking the client serializes subscriptions of the same client
+		client, err := uow.Clients().GetByIDForUpdate(input.ClientID)
+		if err != nil {
+			return err
+		}
+
+		if client == nil {
+			return fmt.Errorf("client with ID %s: %w", input.ClientID, domain.ErrNotFound)
+		}
+
+		current, err := uow.Subscriptions().GetCurrentByClient(input.ClientID)
+		if err != nil {
+			return err
+		}
+
+		if current != nil {
+			return fmt.Errorf("client %s, subscription %s: %w", input.ClientID, current.ID, domain.ErrAlreadySubscribed)
+		}
+
+		pkg, err := planOf(uow, input.PackageID)
+		if err != nil {
+			return err
+		}
+
+		if err := uow.Subscriptions().Create(subscription); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditSubscription, subscription.ID, nil, subscription); err != nil {
+			return err
+		}
+
+		return billPeriod(uow, s.issuer, s.location, subscription, pkg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(subscription.ID)
+}
+
+// Pause pauses an active subscription. It can no longer be used to book and
+// is not renewed until resumed.
+func (s *subscriptionService) Pause(ctx context.Context, id string) (*domain.Subscription, error) {
+	return s.update(ctx, id, func(subscription *domain.Subscription, now time.Time) error {
+		if subscription.Status != domain.ActiveSubscription {
+			return fmt.Errorf("pausing %s subscription %s: %w", subscription.Status, id, domain.ErrSubscriptionStatus)
+		}
+
+		subscription.Status = domain.PausedSubscription
+		subscription.PausedAt = &now
+		return nil
+	})
+}
+
+// Resume resumes a paused subscription, extending its current period by the
+// time it was paused
+func (s *subscriptionService) Resume(ctx context.Context, id string) (*domain.Subscription, error) {
+	return s.update(ctx, id, func(subscription *domain.Subscription, now time.Time) error {
+		if subscription.Status != domain.PausedSubscription {
+			return fmt.Errorf("resuming %s subscription %s: %w", subscription.Status, id, domain.ErrSubscriptionStatus)
+		}
+
+		// Time paused before the period started does not extend it
+		pausedAt := *subscription.PausedAt
+		if pausedAt.Before(subscription.CurrentPeriodStart) {
+			pausedAt = subscription.CurrentPeriodStart
+		}
+		if now.After(pausedAt) {
+			subscription.CurrentPeriodEnd = subscription.CurrentPeriodEnd.Add(now.Sub(pausedAt))
+		}
+
+		subscription.Status = domain.ActiveSubscription
+		subscription.PausedAt = nil
+		return nil
+	})
+}
+
+// Cancel cancels a subscription at the end of its current period. A paused
+// subscription is cancelled at once.
+func (s *subscriptionService) Cancel(ctx context.Context, id string) (*domain.Subscription, error) {
+	return s.update(ctx, id, func(subscription *domain.Subscription, now time.Time) error {
+		switch subscription.Status {
+		case domain.ActiveSubscription:
+			subscription.CancelAtPeriodEnd = true
+		case domain.PausedSubscription:
+			subscription.Status = domain.CancelledSubscription
+			subscription.CancelledAt = &now
+		default:
+			return fmt.Errorf("cancelling %s subscription %s: %w", subscription.Status, id, domain.ErrSubscriptionStatus)
+		}
+		return nil
+	})
+}
+
+// RenewAll bills the next period of the active subscriptions whose current
+// period has ended, catching up on missed periods, and ends those cancelled
+// at the end of their period. A subscription failing to renew does not stop
+// the others. It returns the number of periods billed.
+func (s *subscriptionService) RenewAll(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	due, err := s.repo.GetDue(now)
+	if err != nil {
+		return 0, err
+	}
+
+	billed := 0
+	var lastErr error
+
+	for _, subscription := range due {
+		err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+			n, err := s.renew(uow, subscription.ID, now)
+			billed += n
+			return err
+		})
+		if err != nil {
+			log.Error().Err(err).Str("subscriptionID", subscription.ID).Msg("failed to renew subscription")
+			lastErr = err
+		}
+	}
+
+	return billed, lastErr
+}
+
+// renew brings a subscription up to date at a time and returns the number
+// of periods billed
+func (s *subscriptionService) renew(uow domain.UnitOfWork, id string, now time.Time) (int, error) {
+	subscription, err := uow.Subscriptions().GetByIDForUpdate(id)
+	if err != nil {
+		return 0, err
+	}
+
+	// Changed since it was listed
+	if subscription == nil || subscription.Status != domain.ActiveSubscription || subscription.CurrentPeriodEnd.After(now) {
+		return 0, nil
+	}
+	before := *subscription
+
+	if subscription.CancelAtPeriodEnd {
+		subscription.Status = domain.CancelledSubscription
+		subscription.CancelledAt = &subscription.CurrentPeriodEnd
+		if err := uow.Subscriptions().Update(subscription); err != nil {
+			return 0, err
+		}
+
+		return 0, record(uow, domain.AuditSubscription, id, &before, subscription)
+	}
+
+	pkg, err := planOf(uow, subscription.PackageID)
+	if err != nil {
+		return 0, err
+	}
+
+	billed := 0
+	for !subscription.CurrentPeriodEnd.After(now) {
+		subscription.CurrentPeriodStart = subscription.CurrentPeriodEnd
+		subscription.CurrentPeriodEnd = s.periodEnd(subscription, subscription.CurrentPeriodStart)
+
+		if err := billPeriod(uow, s.issuer, s.location, subscription, pkg); err != nil {
+			return 0, err
+		}
+		billed++
+	}
+
+	if err := uow.Subscriptions().Update(subscription); err != nil {
+		return 0, err
+	}
+
+	if err := record(uow, domain.AuditSubscription, id, &before, subscription); err != nil {
+		return 0, err
+	}
+
+	return billed, nil
+}
+
+// update applies change to a subscription within a transaction and returns
+// the updated subscription
+func (s *subscriptionService) update(ctx context.Context, id string, change func(subscription *domain.Subscription, now time.Time) error) (*domain.Subscription, error) {
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		subscription, err := uow.Subscriptions().GetByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if subscription == nil {
+			return fmt.Errorf("subscription with ID %s: %w", id, domain.ErrNotFound)
+		}
+		before := *subscription
+
+		if err := change(subscription, time.Now()); err != nil {
+			return err
+		}
+
+		if err := uow.Subscriptions().Update(subscription); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditSubscription, id, &before, subscription)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(id)
+}
+
+// periodEnd returns the end of the billing period of a subscription starting
+// at a time. Periods are counted from the start date and end on its day of
+// the month, or on the last day of shorter months, so a subscription started
+// on January 31 renews on February 28, then March 31. Periods shifted by a
+// pause stay shifted as much.
+func (s *subscriptionService) periodEnd(subscription *domain.Subscription, start time.Time) time.Time {
+	anchor := subscription.StartDate.In(s.location)
+	months := subscription.Period.Months()
+
+	n := 0
+	for !addMonths(anchor, (n+1)*months).After(start) {
+		n++
+	}
+	shift := start.Sub(addMonths(anchor, n*months))
+
+	return addMonths(anchor, (n+1)*months).Add(shift)
+}
+
+// addMonths adds months to a time, keeping its day of the month unless the
+// target month is shorter, in which case it ends on its last day
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// planOf returns a membership plan by ID
+func planOf(uow domain.UnitOfWork, packageID string) (*domain.Package, error) {
+	pkg, err := uow.Packages().GetByID(packageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkg == nil {
+		return nil, fmt.Errorf("package with ID %s: %w", packageID, domain.ErrNotFound)
+	}
+
+	if pkg.Type != domain.MembershipPackage {
+		return nil, domain.NewValidationError("package_id", "is not a membership plan: %s", pkg.Name)
+	}
+
+	return pkg, nil
+}
+
+// billPeriod records the billing of the current period of a subscription,
+// for the number of months of its period at the monthly price of the plan,
+// and issues its invoice. It grants no credits.
+func billPeriod(uow domain.UnitOfWork, issuer domain.InvoiceIssuer, location *time.Location, subscription *domain.Subscription, pkg *domain.Package) error {
+	months := subscription.Period.Months()
+	price := pkg.Price.Mul(int64(months))
+	net, vat := price.SplitVAT(pkg.VATRate)
+
+	billing := &domain.Billing{
+		Kind:           domain.PaymentBilling,
+		ClientID:       subscription.ClientID,
+		PackageID:      pkg.ID,
+		Amount:         months,
+		OriginalPrice:  price,
+		Discount:       money.New(0, price.Currency),
+		Price:          price,
+		NetPrice:       net,
+		VAT:            vat,
+		VATRate:        pkg.VATRate,
+		PaymentDate:    subscription.CurrentPeriodStart,
+		SubscriptionID: subscription.ID,
+	}
+
+	if err := uow.Billings().Create(billing); err != nil {
+		return err
+	}
+
+	if err := record(uow, domain.AuditBilling, billing.ID, nil, billing); err != nil {
+		return err
+	}
+
+	_, err := issueInvoice(uow, issuer, location, billing)
+	return err
+}
@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/matthieukhl/align-back/pkg/utils"
+)
+
+// voucherValidityMonths is how long vouchers are valid when sold without an
+// expiry date
+const voucherValidityMonths = 12
+
+type voucherService struct {
+	repo     domain.VoucherRepository
+	tx       domain.Transactor
+	issuer   domain.InvoiceIssuer
+	location *time.Location
+}
+
+// NewVoucherService creates a new voucher service. Invoices of the packages
+// paid with vouchers are issued by issuer, with years following the calendar
+// in location.
+func NewVoucherService(repo domain.VoucherRepository, tx domain.Transactor, issuer domain.InvoiceIssuer, location *time.Location) domain.VoucherService {
+	return &voucherService{
+		repo:     repo,
+		tx:       tx,
+		issuer:   issuer,
+		location: location,
+	}
+}
+
+// GetAll returns all vouchers
+func (s *voucherService) GetAll() ([]domain.Voucher, error) {
+	return s.repo.GetAll()
+}
+
+// GetByID returns a voucher by ID with its redemptions
+func (s *voucherService) GetByID(id string) (*domain.Voucher, error) {
+	voucher, err := s.repo.GetByID(id)
+	if err != nil || voucher == nil {
+		return nil, err
+	}
+
+	return s.withRedemptions(voucher)
+}
+
+// GetByCode returns a voucher by code with its redemptions
+func (s *voucherService) GetByCode(code string) (*domain.Voucher, error) {
+	voucher, err := s.repo.GetByCode(normalizeCode(code))
+	if err != nil || voucher == nil {
+		return nil, err
+	}
+
+	return s.withRedemptions(voucher)
+}
+
+// Create records the sale of a voucher
+func (s *voucherService) Create(ctx context.Context, input domain.VoucherInput) (*domain.Voucher, error) {
+	if input.PurchaserName == "" {
+		return nil, domain.NewValidationError("purchaser_name", "is required")
+	}
+
+	code := normalizeCode(input.Code)
+	if code == "" {
+		code = utils.NewCode(3)
+	}
+
+	purchasedAt := input.PurchasedAt
+	if purchasedAt.IsZero() {
+		purchasedAt = time.Now()
+	}
+
+	expiresAt := purchasedAt.In(s.location).AddDate(0, voucherValidityMonths, 0)
+	if input.ExpiresAt != nil {
+		expiresAt = *input.ExpiresAt
+	}
+
+	if !expiresAt.After(purchasedAt) {
+		return nil, domain.NewValidationError("expires_at", "is before the purchase: %s", expiresAt.Format(time.RFC3339))
+	}
+
+	voucher := &domain.Voucher{
+		Code:              code,
+		Kind:              input.Kind,
+		PurchaserName:     input.PurchaserName,
+		PurchaserEmail:    input.PurchaserEmail,
+		PurchaserClientID: input.PurchaserClientID,
+		Status:            domain.ActiveVoucher,
+		PurchasedAt:       purchasedAt,
+		ExpiresAt:         expiresAt,
+	}
+
+	if input.Value.Amount <= 0 {
+		return nil, domain.NewValidationError("value", "must be positive: %s", input.Value)
+	}
+
+	voucher.Value = input.Value
+	if voucher.Value.Currency == "" {
+		voucher.Value = money.New(input.Value.Amount, money.EUR)
+	}
+	voucher.Balance = voucher.Value
+
+	switch input.Kind {
+	case domain.MoneyVoucher:
+	case domain.SessionsVoucher:
+		if input.Sessions <= 0 {
+			return nil, domain.NewValidationError("sessions", "must be positive: %d", input.Sessions)
+		}
+
+		if input.SessionType != domain.GroupPackage && input.SessionType != domain.PrivatePackage {
+			return nil, domain.NewValidationError("session_type", "is invalid: %s", input.SessionType)
+		}
+
+		voucher.Sessions = input.Sessions
+		voucher.SessionType = input.SessionType
+	default:
+		return nil, domain.NewValidationError("kind", "is unknown: %s", input.Kind)
+	}
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		existingVoucher, err := uow.Vouchers().GetByCode(code)
+		if err != nil {
+			return err
+		}
+
+		if existingVoucher != nil {
+			return fmt.Errorf("code %s: %w", code, domain.ErrVoucherCodeExists)
+		}
+
+		if input.PurchaserClientID != "" {
+			client, err := uow.Clients().GetByID(input.PurchaserClientID)
+			if err != nil {
+				return err
+			}
+
+			if client == nil {
+				return fmt.Errorf("client with ID %s: %w", input.PurchaserClientID, domain.ErrNotFound)
+			}
+		}
+
+		if err := uow.Vouchers().Create(voucher); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditVoucher, voucher.ID, nil, voucher)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(voucher.ID)
+}
+
+// Redeem redeems a voucher for a client. A money voucher pays for packages:
+// the billing is created and invoiced as usual, and paid with what is left on
+// the voucher, up to its price. A sessions voucher is redeemed at once for a
+// package of its session type: its sessions are billed at the price of the
+// voucher and invoiced, so the sale reaches revenue and VAT when redeemed.
+func (s *voucherService) Redeem(ctx context.Context, code string, input domain.RedemptionInput) (*domain.VoucherRedemption, error) {
+	redemption := &domain.VoucherRedemption{
+		ClientID: input.ClientID,
+	}
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		voucher, err := uow.Vouchers().GetByCodeForUpdate(normalizeCode(code))
+		if err != nil {
+			return err
+		}
+
+		if voucher == nil {
+			return fmt.Errorf("code %s: %w", code, domain.ErrUnknownVoucher)
+		}
+		before := *voucher
+
+		client, err := uow.Clients().GetByID(input.ClientID)
+		if err != nil {
+			return err
+		}
+
+		if client == nil {
+			return fmt.Errorf("client with ID %s: %w", input.ClientID, domain.ErrNotFound)
+		}
+
+		now := time.Now()
+		if !voucher.Redeemable(now) {
+			return fmt.Errorf("voucher %s is %s, expiring %s: %w", voucher.Code, voucher.Status, voucher.ExpiresAt.Format(time.DateOnly), domain.ErrVoucherNotRedeemable)
+		}
+
+		redemption.VoucherID = voucher.ID
+		redemption.Amount = money.New(0, voucher.Balance.Currency)
+
+		var billing *domain.Billing
+		var pkg *domain.Package
+
+		switch voucher.Kind {
+		case domain.MoneyVoucher:
+			billing, pkg, err = packageBilling(uow, voucher, input, now)
+		case domain.SessionsVoucher:
+			billing, pkg, err = sessionsBilling(uow, voucher, input, now)
+			redemption.Sessions = voucher.Sessions
+		}
+		if err != nil {
+			return err
+		}
+
+		paid, err := s.payWith(uow, voucher, billing, pkg, now)
+		if err != nil {
+			return err
+		}
+
+		redemption.BillingID = billing.ID
+		redemption.Amount = paid
+
+		if err := uow.Vouchers().CreateRedemption(redemption); err != nil {
+			return err
+		}
+
+		if err := uow.Vouchers().Update(voucher); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditVoucher, voucher.ID, &before, voucher)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return redemption, nil
+}
+
+// Cancel cancels a voucher that has not been fully redeemed. Its redemptions
+// so far are kept.
+func (s *voucherService) Cancel(ctx context.Context, id string) (*domain.Voucher, error) {
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		voucher, err := uow.Vouchers().GetByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if voucher == nil {
+			return fmt.Errorf("voucher with ID %s: %w", id, domain.ErrNotFound)
+		}
+
+		if voucher.Status == domain.RedeemedVoucher || voucher.Status == domain.CancelledVoucher {
+			return fmt.Errorf("voucher %s is %s: %w", voucher.Code, voucher.Status, domain.ErrVoucherNotRedeemable)
+		}
+
+		before := *voucher
+		voucher.Status = domain.CancelledVoucher
+		if err := uow.Vouchers().Update(voucher); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditVoucher, id, &before, voucher)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(id)
+}
+
+// packageBilling returns the billing of the packages a money voucher pays
+// for on redemption
+func packageBilling(uow domain.UnitOfWork, voucher *domain.Voucher, input domain.RedemptionInput, now time.Time) (*domain.Billing, *domain.Package, error) {
+	amount := input.Amount
+	if amount == 0 {
+		amount = 1
+	}
+
+	if amount < 0 {
+		return nil, nil, domain.NewValidationError("amount", "cannot be less than 1: %d", amount)
+	}
+
+	pkg, err := voucherPackage(uow, voucher, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	price := pkg.Price.Mul(int64(amount))
+	net, vat := price.SplitVAT(pkg.VATRate)
+
+	billing := &domain.Billing{
+		Kind:          domain.PaymentBilling,
+		ClientID:      input.ClientID,
+		PackageID:     pkg.ID,
+		Amount:        amount,
+		OriginalPrice: price,
+		Discount:      money.New(0, price.Currency),
+		Price:         price,
+		NetPrice:      net,
+		VAT:           vat,
+		VATRate:       pkg.VATRate,
+		Credits:       pkg.NumberOfSessions * amount,
+		PaymentDate:   now,
+	}
+
+	return billing, pkg, nil
+}
+
+// sessionsBilling returns the billing of the sessions of a sessions voucher
+// on redemption, at the price the voucher was sold for. The package gives
+// the VAT rate and validity of the credits.
+func sessionsBilling(uow domain.UnitOfWork, voucher *domain.Voucher, input domain.RedemptionInput, now time.Time) (*domain.Billing, *domain.Package, error) {
+	pkg, err := voucherPackage(uow, voucher, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pkg.Type != voucher.SessionType {
+		return nil, nil, domain.NewValidationError("package_id", "is a %s package for voucher %s of %s sessions", pkg.Type, voucher.Code, voucher.SessionType)
+	}
+
+	price := voucher.Balance
+	net, vat := price.SplitVAT(pkg.VATRate)
+
+	billing := &domain.Billing{
+		Kind:          domain.PaymentBilling,
+		ClientID:      input.ClientID,
+		PackageID:     pkg.ID,
+		Amount:        1,
+		OriginalPrice: price,
+		Discount:      money.New(0, price.Currency),
+		Price:         price,
+		NetPrice:      net,
+		VAT:           vat,
+		VATRate:       pkg.VATRate,
+		Credits:       voucher.Sessions,
+		PaymentDate:   now,
+		Reason:        fmt.Sprintf("gift voucher %s", voucher.Code),
+	}
+
+	return billing, pkg, nil
+}
+
+// voucherPackage returns the package a voucher is redeemed for
+func voucherPackage(uow domain.UnitOfWork, voucher *domain.Voucher, input domain.RedemptionInput) (*domain.Package, error) {
+	if input.PackageID == "" {
+		return nil, domain.NewValidationError("package_id", "is required to redeem voucher %s", voucher.Code)
+	}
+
+	pkg, err := uow.Packages().GetByID(input.PackageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkg == nil {
+		return nil, fmt.Errorf("package with ID %s: %w", input.PackageID, domain.ErrNotFound)
+	}
+
+	if pkg.Type == domain.MembershipPackage {
+		return nil, domain.NewValidationError("package_id", "is the membership plan %s, billed by its subscriptions", pkg.Name)
+	}
+
+	if pkg.Price.Currency != voucher.Balance.Currency {
+		return nil, domain.NewValidationError("package_id", "is in %s for voucher %s in %s", pkg.Price.Currency, voucher.Code, voucher.Balance.Currency)
+	}
+
+	return pkg, nil
+}
+
+// payWith creates and invoices the billing of a redemption and pays it with
+// what is left on the voucher, updating its balance and status. It returns
+// the amount paid with the voucher.
+func (s *voucherService) payWith(uow domain.UnitOfWork, voucher *domain.Voucher, billing *domain.Billing, pkg *domain.Package, now time.Time) (money.Money, error) {
+	if err := createBilling(uow, billing, pkg); err != nil {
+		return money.Money{}, err
+	}
+
+	paid := voucher.Balance
+	if billing.Price.Amount < paid.Amount {
+		paid = billing.Price
+	}
+
+	if !paid.IsZero() {
+		_, err := addPayment(uow, billing, domain.PaymentInput{
+			Method:    domain.VoucherPayment,
+			Amount:    paid,
+			PaidAt:    now,
+			Reference: voucher.Code,
+		})
+		if err != nil {
+			return money.Money{}, err
+		}
+	}
+
+	if _, err := issueInvoice(uow, s.issuer, s.location, billing); err != nil {
+		return money.Money{}, err
+	}
+
+	voucher.Balance = voucher.Balance.Sub(paid)
+	voucher.Status = domain.PartiallyRedeemedVoucher
+	if voucher.Balance.IsZero() || voucher.Kind == domain.SessionsVoucher {
+		voucher.Status = domain.RedeemedVoucher
+	}
+
+	return paid, nil
+}
+
+// withRedemptions returns a voucher with its redemptions
+func (s *voucherService) withRedemptions(voucher *domain.Voucher) (*domain.Voucher, error) {
+	redemptions, err := s.repo.GetRedemptions(voucher.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	voucher.Redemptions = redemptions
+	return voucher, nil
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matthieukhl/align-back/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+type waitlistService struct {
+	repo domain.WaitlistRepository
+	tx   domain.Transactor
+}
+
+// NewWaitlistService creates a new waitlist service
+func NewWaitlistService(repo domain.WaitlistRepository, tx domain.Transactor) domain.WaitlistService {
+	return &waitlistService{
+		repo: repo,
+		tx:   tx,
+	}
+}
+
+// GetBySchedule returns the clients waiting on a schedule in join order
+func (s *waitlistService) GetBySchedule(scheduleID string) ([]domain.WaitlistEntry, error) {
+	return s.repo.GetBySchedule(scheduleID)
+}
+
+// Join adds a client to the waitlist of a full schedule
+func (s *waitlistService) Join(ctx context.Context, scheduleID string, input domain.WaitlistInput) (*domain.WaitlistEntry, error) {
+	var entry *domain.WaitlistEntry
+
+	err := s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		schedule, _, err := lockSchedule(uow, scheduleID)
+		if err != nil {
+			return err
+		}
+
+		if !schedule.ClassDatetime.After(time.Now()) {
+			return fmt.Errorf("schedule %s: %w", scheduleID, domain.ErrScheduleStarted)
+		}
+
+		// Check if client exists
+		client, err := uow.Clients().GetByID(input.ClientID)
+		if err != nil {
+			return err
+		}
+
+		if client == nil {
+			return fmt.Errorf("client with ID %s: %w", input.ClientID, domain.ErrNotFound)
+		}
+
+		// Check if client is already booked or waiting
+		existingAppointment, err := uow.Appointments().GetByClientAndSchedule(input.ClientID, scheduleID)
+		if err != nil {
+			return err
+		}
+
+		if existingAppointment != nil {
+			return fmt.Errorf("client %s on schedule %s: %w", input.ClientID, scheduleID, domain.ErrAlreadyBooked)
+		}
+
+		existingEntry, err := uow.Waitlist().GetWaitingByClientAndSchedule(input.ClientID, scheduleID)
+		if err != nil {
+			return err
+		}
+
+		if existingEntry != nil {
+			return fmt.Errorf("client %s on schedule %s: %w", input.ClientID, scheduleID, domain.ErrAlreadyWaitlisted)
+		}
+
+		// Only full schedules have a waitlist
+		count, err := uow.Appointments().CountBySchedule(scheduleID)
+		if err != nil {
+			return err
+		}
+
+		if count < schedule.Capacity {
+			return fmt.Errorf("schedule %s (%d/%d): %w", scheduleID, count, schedule.Capacity, domain.ErrScheduleNotFull)
+		}
+
+		entry = &domain.WaitlistEntry{
+			ScheduleID: scheduleID,
+			ClientID:   input.ClientID,
+			Status:     domain.Waiting,
+		}
+
+		if err := uow.Waitlist().Create(entry); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditWaitlist, entry.ID, nil, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Leave removes a client from the waitlist of a schedule
+func (s *waitlistService) Leave(ctx context.Context, scheduleID, clientID string) error {
+	entry, err := s.repo.GetWaitingByClientAndSchedule(clientID, scheduleID)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("client %s on the waitlist of schedule %s: %w", clientID, scheduleID, domain.ErrNotFound)
+	}
+
+	return s.tx.WithinTransaction(ctx, func(uow domain.UnitOfWork) error {
+		if err := uow.Waitlist().Delete(entry.ID); err != nil {
+			return err
+		}
+
+		return record(uow, domain.AuditWaitlist, entry.ID, entry, nil)
+	})
+}
+
+// promoteWaitlist books waiting clients onto a schedule, in join order,
+// while slots are available. Clients without enough credits are skipped and
+// stay on the waitlist. It must run in the transaction that freed the slots.
+func promoteWaitlist(uow domain.UnitOfWork, scheduleID string) error {
+	schedule, class, err := lockSchedule(uow, scheduleID)
+	if err != nil {
+		return err
+	}
+
+	entries, err := uow.Waitlist().GetBySchedule(scheduleID)
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+
+		// Checked before booking, which writes to the ledger and cannot
+		// be undone without rolling back the whole transaction
+		affordable, err := canAfford(uow, entry.ClientID, schedule, class)
+		if err != nil {
+			return err
+		}
+
+		if !affordable {
+			continue
+		}
+
+		appointment := &domain.Appointment{
+			ScheduleID: scheduleID,
+			ClientID:   entry.ClientID,
+		}
+
+		err = reserve(uow, appointment)
+		switch {
+		case errors.Is(err, domain.ErrScheduleFull), errors.Is(err, domain.ErrScheduleStarted):
+			return nil
+		case errors.Is(err, domain.ErrAlreadyBooked):
+			// Booked by other means, the entry is stale
+			if err := uow.Waitlist().Delete(entry.ID); err != nil {
+				return err
+			}
+
+			if err := record(uow, domain.AuditWaitlist, entry.ID, entry, nil); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		}
+
+		if err := uow.Appointments().Create(appointment); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditAppointment, appointment.ID, nil, appointment); err != nil {
+			return err
+		}
+
+		before := *entry
+		now := time.Now()
+		entry.Status = domain.Promoted
+		entry.AppointmentID = appointment.ID
+		entry.PromotedAt = &now
+
+		if err := uow.Waitlist().Update(entry); err != nil {
+			return err
+		}
+
+		if err := record(uow, domain.AuditWaitlist, entry.ID, &before, entry); err != nil {
+			return err
+		}
+
+		log.Info().Str("scheduleID", scheduleID).Str("clientID", entry.ClientID).Str("appointmentID", appointment.ID).Msg("promoted client from waitlist")
+	}
+
+	return nil
+}
+
+// canAfford reports whether a client can be booked onto a schedule without
+// changing anything: a member whose subscription covers the class, or a
+// client with a credit of the class type left once expired lots are retired.
+func canAfford(uow domain.UnitOfWork, clientID string, schedule *domain.Schedule, class *domain.Class) (bool, error) {
+	if class.Type == domain.GroupClass {
+		subscription, err := uow.Subscriptions().GetCurrentByClient(clientID)
+		if err != nil {
+			return false, err
+		}
+
+		if subscription != nil && subscription.Covers(schedule.ClassDatetime) {
+			return true, nil
+		}
+	}
+
+	client, err := uow.Clients().GetByIDForUpdate(clientID)
+	if err != nil || client == nil {
+		return false, err
+	}
+
+	creditType := domain.CreditType(class.Type)
+	credits := *creditsFor(client, creditType)
+
+	lots, err := uow.CreditLots().GetExpiredForUpdate(clientID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	for _, lot := range lots {
+		if lot.Type == creditType {
+			credits -= lot.Remaining
+		}
+	}
+
+	return credits > 0, nil
+}
@@ -0,0 +1,159 @@
+// Package spreadsheet writes tables row by row as CSV or XLSX
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matthieukhl/align-back/pkg/money"
+	"github.com/xuri/excelize/v2"
+)
+
+// Writer writes the rows of a table. Values may be strings, integers,
+// booleans, times or money amounts; zero times are left empty.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	// Close writes what is left of the table
+	Close() error
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter returns a writer of comma-separated values to w. Times are
+// formatted as RFC 3339 and amounts as decimals, e.g. 150.00. Text starting
+// like a formula is prefixed with a quote.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// WriteRow writes a row
+func (cw *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			record[i] = escapeFormula(v)
+		case int:
+			record[i] = strconv.Itoa(v)
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case time.Time:
+			if !v.IsZero() {
+				record[i] = v.Format(time.RFC3339)
+			}
+		case money.Money:
+			record[i] = v.Decimal()
+		default:
+			record[i] = escapeFormula(fmt.Sprint(v))
+		}
+	}
+	return cw.w.Write(record)
+}
+
+// escapeFormula prefixes text that spreadsheet applications would run as a
+// formula with a quote, so that e.g. a client named "=HYPERLINK(...)" is
+// shown as typed. Phone numbers such as "+33 6 12 34 56 78" are left as is.
+func escapeFormula(text string) string {
+	if text == "" || !strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return text
+	}
+
+	if text[0] == '+' && isPhoneNumber(text[1:]) {
+		return text
+	}
+
+	return "'" + text
+}
+
+// isPhoneNumber reports whether text only holds digits and spaces, with at
+// least one digit
+func isPhoneNumber(text string) bool {
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+
+	for _, c := range text {
+		if c != ' ' && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Close flushes the buffered rows
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type xlsxWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+}
+
+// NewXLSXWriter returns a writer of a workbook with a single sheet to w. The
+// workbook is only written to w on Close, as its parts are zipped together.
+func NewXLSXWriter(w io.Writer, sheet string) (Writer, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{
+		w:      w,
+		file:   file,
+		stream: stream,
+	}, nil
+}
+
+// WriteRow writes a row
+func (xw *xlsxWriter) WriteRow(values []interface{}) error {
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			// Written as an inline string cell, which is never run as a
+			// formula, so it needs no escaping
+			row[i] = v
+		case time.Time:
+			if !v.IsZero() {
+				row[i] = v
+			}
+		case money.Money:
+			// Amounts are in cents
+			row[i] = float64(v.Amount) / 100
+		default:
+			row[i] = v
+		}
+	}
+
+	xw.rows++
+	cell, err := excelize.CoordinatesToCellName(1, xw.rows)
+	if err != nil {
+		return err
+	}
+	return xw.stream.SetRow(cell, row)
+}
+
+// Close writes the workbook
+func (xw *xlsxWriter) Close() error {
+	defer xw.file.Close()
+
+	if err := xw.stream.Flush(); err != nil {
+		return err
+	}
+	return xw.file.Write(xw.w)
+}
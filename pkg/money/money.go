@@ -0,0 +1,97 @@
+// Package money represents amounts as integer minor units of a currency so
+// that sums, refunds and VAT never suffer from floating point rounding.
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EUR is the currency used by default
+const EUR = "EUR"
+
+// Money is an amount in minor units of a currency, e.g. 15000 EUR is 150.00 €.
+// Arithmetic keeps the currency of the receiver; callers make sure both
+// operands share the same currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New returns an amount of minor units of a currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns m * n
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Div returns m / n rounded half away from zero
+func (m Money) Div(n int64) Money {
+	return Money{Amount: divRound(m.Amount, n), Currency: m.Currency}
+}
+
+// IsZero reports whether m is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether m is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// SplitVAT splits a gross amount, VAT included, into its net amount and VAT
+// for a rate in basis points (2000 is 20%). Net and VAT always add up to m.
+func (m Money) SplitVAT(rate int) (net, vat Money) {
+	net = Money{Amount: divRound(m.Amount*10000, int64(10000+rate)), Currency: m.Currency}
+	return net, m.Sub(net)
+}
+
+// Decimal formats the amount in major units with two decimals, e.g. -150.00
+func (m Money) Decimal() string {
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// String formats the amount with its currency, e.g. 150.00 EUR
+func (m Money) String() string {
+	return strings.TrimSpace(m.Decimal() + " " + m.Currency)
+}
+
+// FormatRate formats a rate in basis points as a percentage, e.g. 5.5
+func FormatRate(rate int) string {
+	s := fmt.Sprintf("%d.%02d", rate/100, rate%100)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// divRound divides a by b rounding half away from zero
+func divRound(a, b int64) int64 {
+	if b < 0 {
+		a, b = -a, -b
+	}
+	if a < 0 {
+		return -((-a + b/2) / b)
+	}
+	return (a + b/2) / b
+}
@@ -0,0 +1,145 @@
+package money
+
+import "testing"
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		a, b int64
+		want int64
+	}{
+		{10, 2, 5},
+		{0, 7, 0},
+		{1, 3, 0},
+		{2, 3, 1},
+		{5, 2, 3},
+		{3, 2, 2},
+		{7, 4, 2},
+		{-1, 3, 0},
+		{-2, 3, -1},
+		{-5, 2, -3},
+		{-3, 2, -2},
+		{5, -2, -3},
+		{-5, -2, 3},
+		{149, 100, 1},
+		{150, 100, 2},
+		{-150, 100, -2},
+	}
+
+	for _, tt := range tests {
+		if got := divRound(tt.a, tt.b); got != tt.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		amount int64
+		n      int64
+		want   int64
+	}{
+		{15000, 10, 1500},
+		{10000, 3, 3333},
+		{20000, 3, 6667},
+		{5, 2, 3},
+		{-5, 2, -3},
+		{-10000, 3, -3333},
+	}
+
+	for _, tt := range tests {
+		got := New(tt.amount, EUR).Div(tt.n)
+		if got != New(tt.want, EUR) {
+			t.Errorf("%d.Div(%d) = %v, want %d EUR", tt.amount, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSplitVAT(t *testing.T) {
+	tests := []struct {
+		gross   int64
+		rate    int
+		net     int64
+		vat     int64
+		comment string
+	}{
+		{15000, 2000, 12500, 2500, "20%"},
+		{1000, 550, 948, 52, "5.5%, rounded up"},
+		{1000, 1000, 909, 91, "10%, rounded down"},
+		{1, 10000, 1, 0, "half-way rounds away from zero"},
+		{3, 10000, 2, 1, "half-way rounds away from zero"},
+		{-1, 10000, -1, 0, "negative half-way rounds away from zero"},
+		{-15000, 2000, -12500, -2500, "refund"},
+		{-1000, 550, -948, -52, "refund mirrors the payment"},
+		{9000, 0, 9000, 0, "no VAT"},
+		{0, 2000, 0, 0, "zero"},
+	}
+
+	for _, tt := range tests {
+		net, vat := New(tt.gross, EUR).SplitVAT(tt.rate)
+		if net != New(tt.net, EUR) || vat != New(tt.vat, EUR) {
+			t.Errorf("%d.SplitVAT(%d) = %v, %v, want %d, %d (%s)", tt.gross, tt.rate, net, vat, tt.net, tt.vat, tt.comment)
+		}
+		if net.Add(vat).Amount != tt.gross {
+			t.Errorf("%d.SplitVAT(%d) = %v + %v, which does not add up", tt.gross, tt.rate, net, vat)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{50, "0.50"},
+		{15000, "150.00"},
+		{123456, "1234.56"},
+		{-5, "-0.05"},
+		{-99, "-0.99"},
+		{-15000, "-150.00"},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.amount, EUR).Decimal(); got != tt.want {
+			t.Errorf("%d.Decimal() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{New(15000, EUR), "150.00 EUR"},
+		{New(-5, EUR), "-0.05 EUR"},
+		{New(100, ""), "1.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	tests := []struct {
+		rate int
+		want string
+	}{
+		{2000, "20"},
+		{1000, "10"},
+		{550, "5.5"},
+		{210, "2.1"},
+		{1005, "10.05"},
+		{0, "0"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatRate(tt.rate); got != tt.want {
+			t.Errorf("FormatRate(%d) = %q, want %q", tt.rate, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used for
+// class series: FREQ=DAILY|WEEKLY with INTERVAL, BYDAY, UNTIL and COUNT.
+// Weeks start on Monday.
+package rrule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the recurrence frequency of a rule
+type Frequency string
+
+const (
+	Daily  Frequency = "DAILY"
+	Weekly Frequency = "WEEKLY"
+)
+
+// maxOccurrences bounds the expansion of rules without UNTIL nor COUNT
+const maxOccurrences = 10000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []time.Weekday
+	Until    time.Time
+	Count    int
+}
+
+// Parse parses a recurrence rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// An optional "RRULE:" prefix is accepted.
+func Parse(s string) (*Rule, error) {
+	rule := &Rule{Interval: 1}
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			switch Frequency(strings.ToUpper(value)) {
+			case Daily:
+				rule.Freq = Daily
+			case Weekly:
+				rule.Freq = Weekly
+			default:
+				return nil, fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid interval %q", value)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid count %q", value)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported weekday %q", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return nil, fmt.Errorf("unsupported week start %q", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("missing FREQ in rule %q", s)
+	}
+
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot be combined")
+	}
+
+	return rule, nil
+}
+
+// parseUntil parses an UNTIL value in date or UTC date-time form
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// A date includes the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid until %q", value)
+}
+
+// String formats the rule back to its RFC 5545 form
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			days = append(days, strings.ToUpper(weekday.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Between returns the occurrences of the rule starting at dtstart that fall
+// in [after, before). Occurrences keep the wall clock time of dtstart in its
+// location, so they do not drift across daylight saving changes.
+func (r *Rule) Between(dtstart, after, before time.Time) []time.Time {
+	var occurrences []time.Time
+
+	r.each(dtstart, func(t time.Time) bool {
+		if !t.Before(before) {
+			return false
+		}
+		if !t.Before(after) {
+			occurrences = append(occurrences, t)
+		}
+		return true
+	})
+
+	return occurrences
+}
+
+// each calls fn for every occurrence in order until fn returns false or the
+// rule ends
+func (r *Rule) each(dtstart time.Time, fn func(time.Time) bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	byDay := r.ByDay
+	if len(byDay) == 0 {
+		byDay = []time.Weekday{dtstart.Weekday()}
+	}
+
+	emitted := 0
+	emit := func(t time.Time) bool {
+		if t.Before(dtstart) {
+			return true
+		}
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		if r.Count > 0 && emitted >= r.Count {
+			return false
+		}
+		emitted++
+		return fn(t) && emitted < maxOccurrences
+	}
+
+	hour, min, sec := dtstart.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, dtstart.Location())
+	}
+
+	switch r.Freq {
+	case Daily:
+		// BYDAY limits daily occurrences to some weekdays
+		var allowed [7]bool
+		for _, day := range r.ByDay {
+			allowed[day] = true
+		}
+
+		// Weekdays repeat every 7 steps, so 7 skipped days in a row mean no
+		// day ever matches, e.g. INTERVAL=7 on another weekday
+		skipped := 0
+		for i := 0; skipped < 7; i += interval {
+			t := at(dtstart.Year(), dtstart.Month(), dtstart.Day()+i)
+			if len(r.ByDay) > 0 && !allowed[t.Weekday()] {
+				skipped++
+				continue
+			}
+			skipped = 0
+
+			if !emit(t) {
+				return
+			}
+		}
+	case Weekly:
+		// Monday of the week of dtstart
+		monday := dtstart.Day() - (int(dtstart.Weekday())+6)%7
+		for week := 0; ; week += interval {
+			for _, offset := range sortedOffsets(byDay) {
+				if !emit(at(dtstart.Year(), dtstart.Month(), monday+7*week+offset)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// sortedOffsets returns the distinct day offsets from Monday of weekdays in
+// ascending order
+func sortedOffsets(days []time.Weekday) []int {
+	var seen [7]bool
+	for _, day := range days {
+		seen[(int(day)+6)%7] = true
+	}
+
+	offsets := make([]int, 0, 7)
+	for offset, ok := range seen {
+		if ok {
+			offsets = append(offsets, offset)
+		}
+	}
+	return offsets
+}
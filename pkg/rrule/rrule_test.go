@@ -0,0 +1,228 @@
+package rrule
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule string
+		want Rule
+	}{
+		{"FREQ=DAILY", Rule{Freq: Daily, Interval: 1}},
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE", Rule{Freq: Weekly, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Wednesday}}},
+		{"freq=weekly;byday=fr;interval=2", Rule{Freq: Weekly, Interval: 2, ByDay: []time.Weekday{time.Friday}}},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=10", Rule{Freq: Daily, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, Count: 10}},
+		{"FREQ=WEEKLY;UNTIL=20250301T100000Z;WKST=MO", Rule{Freq: Weekly, Interval: 1, Until: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)}},
+		{"FREQ=WEEKLY;UNTIL=20250301", Rule{Freq: Weekly, Interval: 1, Until: time.Date(2025, 3, 1, 23, 59, 59, 0, time.UTC)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+
+			if got.Freq != tt.want.Freq || got.Interval != tt.want.Interval || got.Count != tt.want.Count ||
+				!got.Until.Equal(tt.want.Until) || !slices.Equal(got.ByDay, tt.want.ByDay) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.rule, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"RRULE:",
+		"FREQ=MONTHLY",
+		"FREQ",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;UNTIL=2025-03-01",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;WKST=SU",
+		"FREQ=WEEKLY;BYMONTH=1",
+		"FREQ=WEEKLY;COUNT=2;UNTIL=20250301",
+	}
+
+	for _, rule := range tests {
+		t.Run(rule, func(t *testing.T) {
+			if got, err := Parse(rule); err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", rule, *got)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []string{
+		"FREQ=DAILY",
+		"FREQ=DAILY;INTERVAL=3;COUNT=5",
+		"FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20250301T100000Z",
+	}
+
+	for _, rule := range tests {
+		t.Run(rule, func(t *testing.T) {
+			parsed, err := Parse(rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", rule, err)
+			}
+			if got := parsed.String(); got != rule {
+				t.Errorf("String() = %q, want %q", got, rule)
+			}
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+
+	// Monday, January 27, 2025 at 10:00
+	dtstart := time.Date(2025, 1, 27, 10, 0, 0, 0, paris)
+	after := dtstart
+	before := time.Date(2025, 12, 31, 0, 0, 0, 0, paris)
+
+	tests := []struct {
+		name   string
+		rule   string
+		after  time.Time
+		before time.Time
+		want   []string
+	}{
+		{
+			name: "daily count over month end",
+			rule: "FREQ=DAILY;COUNT=7",
+			want: []string{"2025-01-27", "2025-01-28", "2025-01-29", "2025-01-30", "2025-01-31", "2025-02-01", "2025-02-02"},
+		},
+		{
+			name: "daily interval",
+			rule: "FREQ=DAILY;INTERVAL=3;COUNT=4",
+			want: []string{"2025-01-27", "2025-01-30", "2025-02-02", "2025-02-05"},
+		},
+		{
+			name: "daily on weekdays skips weekends",
+			rule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=7",
+			want: []string{"2025-01-27", "2025-01-28", "2025-01-29", "2025-01-30", "2025-01-31", "2025-02-03", "2025-02-04"},
+		},
+		{
+			name: "daily on a weekday never reached",
+			rule: "FREQ=DAILY;INTERVAL=7;BYDAY=TU",
+			want: nil,
+		},
+		{
+			name: "weekly on the day of dtstart",
+			rule: "FREQ=WEEKLY;COUNT=3",
+			want: []string{"2025-01-27", "2025-02-03", "2025-02-10"},
+		},
+		{
+			name: "weekly on several days",
+			rule: "FREQ=WEEKLY;BYDAY=FR,MO,WE;COUNT=5",
+			want: []string{"2025-01-27", "2025-01-29", "2025-01-31", "2025-02-03", "2025-02-05"},
+		},
+		{
+			name: "every other week",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=3",
+			want: []string{"2025-01-28", "2025-02-11", "2025-02-25"},
+		},
+		{
+			name: "until a date includes that day",
+			rule: "FREQ=WEEKLY;BYDAY=MO;UNTIL=20250210",
+			want: []string{"2025-01-27", "2025-02-03", "2025-02-10"},
+		},
+		{
+			name: "until a time excludes later occurrences",
+			rule: "FREQ=WEEKLY;BYDAY=MO;UNTIL=20250210T080000Z",
+			want: []string{"2025-01-27", "2025-02-03"},
+		},
+		{
+			name:   "window",
+			rule:   "FREQ=DAILY",
+			after:  time.Date(2025, 2, 27, 0, 0, 0, 0, paris),
+			before: time.Date(2025, 3, 3, 0, 0, 0, 0, paris),
+			want:   []string{"2025-02-27", "2025-02-28", "2025-03-01", "2025-03-02"},
+		},
+		{
+			name:   "count applies from dtstart, not from the window",
+			rule:   "FREQ=DAILY;COUNT=3",
+			after:  time.Date(2025, 1, 28, 12, 0, 0, 0, paris),
+			before: before,
+			want:   []string{"2025-01-29"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+
+			from, to := tt.after, tt.before
+			if from.IsZero() {
+				from, to = after, before
+			}
+
+			var got []string
+			for _, occurrence := range rule.Between(dtstart, from, to) {
+				if hour, min, _ := occurrence.Clock(); hour != 10 || min != 0 {
+					t.Errorf("occurrence %s is not at 10:00", occurrence)
+				}
+				got = append(got, occurrence.Format(time.DateOnly))
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Between() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetweenKeepsWallClockAcrossDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=SA;COUNT=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clocks change on Sunday, March 30, 2025
+	dtstart := time.Date(2025, 3, 29, 18, 30, 0, 0, paris)
+	got := rule.Between(dtstart, dtstart, dtstart.AddDate(0, 1, 0))
+
+	if len(got) != 2 {
+		t.Fatalf("Between() returned %d occurrences, want 2", len(got))
+	}
+	if got[1].Sub(got[0]) != 7*24*time.Hour-time.Hour {
+		t.Errorf("occurrences are %s apart, want a week less the hour skipped", got[1].Sub(got[0]))
+	}
+	if hour, min, _ := got[1].Clock(); hour != 18 || min != 30 {
+		t.Errorf("second occurrence at %s, want 18:30", got[1])
+	}
+}
+
+func TestBetweenUnbounded(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dtstart := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	got := rule.Between(dtstart, dtstart, dtstart.AddDate(100, 0, 0))
+
+	if len(got) != maxOccurrences {
+		t.Errorf("Between() returned %d occurrences, want at most %d", len(got), maxOccurrences)
+	}
+}
@@ -1 +1,53 @@
 package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// NewUUID returns a random (version 4) UUID string
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate UUID: %v", err))
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// codeAlphabet leaves out characters easily mistaken for one another
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewCode returns a random code of groups of four characters separated by
+// dashes, e.g. 7KQ2-MX4P-R9TB, easy to read out and type
+func NewCode(groups int) string {
+	b := make([]byte, groups*4)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate code: %v", err))
+	}
+
+	code := make([]byte, 0, groups*5)
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			code = append(code, '-')
+		}
+		code = append(code, codeAlphabet[int(c)%len(codeAlphabet)])
+	}
+
+	return string(code)
+}
+
+// NewToken returns a random URL safe token of size bytes, to be handed out
+// as a secret
+func NewToken(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate token: %v", err))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}